@@ -0,0 +1,715 @@
+// Package caldav serves todos as a single CalDAV collection of VTODO
+// resources (RFC 4791), with WebDAV sync (RFC 6578) driven by the todo change
+// log.
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"todo-app/app/constant"
+	"todo-app/app/ical"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+const (
+	collectionName   = "todos"
+	collectionTitle  = "Todos"
+	syncTokenPrefix  = "urn:todo-app:sync:"
+	calendarDataType = "text/calendar; charset=utf-8; component=VTODO"
+	maxPutSize       = 1 << 20
+)
+
+var (
+	allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+
+	propResourceType       = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: nsDAV, Local: "displayname"}
+	propGetETag            = xml.Name{Space: nsDAV, Local: "getetag"}
+	propGetContentType     = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCurrentPrincipal   = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propPrivilegeSet       = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propSupportedReportSet = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propSyncToken          = xml.Name{Space: nsDAV, Local: "sync-token"}
+	propCalendarHomeSet    = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propComponentSet       = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propCTag               = xml.Name{Space: nsCS, Local: "getctag"}
+
+	errValidSyncToken = xml.Name{Space: nsDAV, Local: "valid-sync-token"}
+	errSupportedData  = xml.Name{Space: nsCalDAV, Local: "supported-calendar-data"}
+	errValidData      = xml.Name{Space: nsCalDAV, Local: "valid-calendar-data"}
+	errValidObject    = xml.Name{Space: nsCalDAV, Local: "valid-calendar-object-resource"}
+	errSupportedComp  = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component"}
+	errSupportedRep   = xml.Name{Space: nsDAV, Local: "supported-report"}
+)
+
+// Handler answers every request below prefix. There is one principal, the
+// root, whose calendar home holds the todos collection.
+type Handler struct {
+	todoService *service.TodoService
+	prefix      string
+}
+
+func NewHandler(todoService *service.TodoService, prefix string) *Handler {
+	return &Handler{todoService: todoService, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+type target int
+
+const (
+	targetNone target = iota
+	targetRoot
+	targetCollection
+	targetResource
+	// targetOtherResource is a resource in the collection whose name is not
+	// a todo ID, so it can hold nothing.
+	targetOtherResource
+)
+
+func (h *Handler) rootHref() string {
+	return h.prefix + "/"
+}
+
+func (h *Handler) collectionHref() string {
+	return h.prefix + "/" + collectionName + "/"
+}
+
+func (h *Handler) resourceHref(id string) string {
+	return h.collectionHref() + id + ".ics"
+}
+
+// resolve maps a request path onto what it addresses. Resource names must be
+// a UUID followed by .ics to address a todo, because the name becomes the
+// todo ID.
+func (h *Handler) resolve(requestPath string) (target, string) {
+	rest := strings.TrimPrefix(requestPath, h.prefix)
+
+	switch strings.Trim(rest, "/") {
+	case "":
+		return targetRoot, ""
+	case collectionName:
+		return targetCollection, ""
+	}
+
+	dir, file := path.Split(rest)
+	if strings.Trim(dir, "/") != collectionName {
+		return targetNone, ""
+	}
+
+	id, err := uuid.Parse(strings.TrimSuffix(file, ".ics"))
+	if err != nil || !strings.HasSuffix(file, ".ics") {
+		return targetOtherResource, ""
+	}
+
+	return targetResource, id.String()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+
+	kind, id := h.resolve(r.URL.Path)
+
+	// Clients that name resources themselves learn why they cannot create
+	// one there (RFC 4791 section 5.3.2.1).
+	if kind == targetOtherResource && r.Method == http.MethodPut {
+		writeError(w, http.StatusForbidden, errValidObject)
+
+		return
+	}
+
+	if kind == targetNone || kind == targetOtherResource {
+		http.Error(w, "Not Found", http.StatusNotFound)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		h.propfind(w, r, kind, id)
+	case "REPORT":
+		h.report(w, r, kind)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, kind, id)
+	case http.MethodPut:
+		h.put(w, r, kind, id)
+	case http.MethodDelete:
+		h.delete(w, r, kind, id)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeMultistatus(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, precondition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(errorBody(precondition))
+}
+
+// writeServiceError maps a TodoService error onto an HTTP status.
+func writeServiceError(w http.ResponseWriter, err error) {
+	if todoErr, ok := err.(service.TodoError); ok {
+		switch todoErr.Reason {
+		case service.ReasonNotFound:
+			http.Error(w, todoErr.Message, http.StatusNotFound)
+		case service.ReasonForbidden:
+			http.Error(w, todoErr.Message, http.StatusForbidden)
+		case service.ReasonPreconditionFailed:
+			http.Error(w, todoErr.Message, http.StatusPreconditionFailed)
+		case service.ReasonConflict:
+			http.Error(w, todoErr.Message, http.StatusConflict)
+		default:
+			http.Error(w, todoErr.Message, http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func syncToken(seq int64) string {
+	return syncTokenPrefix + strconv.FormatInt(seq, 10)
+}
+
+func parseSyncToken(token string) (int64, bool) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, true
+	}
+
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseInt(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+
+	return seq, err == nil && seq >= 0
+}
+
+// requestedProps reads a PROPFIND or REPORT body. A nil result with
+// allProps set means every live property was asked for.
+func requestedProps(root *node) (names []xml.Name, allProps bool) {
+	if root == nil || root.child(nsDAV, "allprop") != nil {
+		return nil, true
+	}
+
+	prop := root.child(nsDAV, "prop")
+	if prop == nil {
+		return nil, true
+	}
+
+	for _, c := range prop.children {
+		names = append(names, c.name)
+	}
+
+	return names, false
+}
+
+// collect splits the requested names into found properties and missing
+// names. With allProps every available property except calendar-data, which
+// must be asked for explicitly, is returned.
+func collect(available map[xml.Name]string, names []xml.Name, allProps bool) ([]property, []xml.Name) {
+	var found []property
+	var missing []xml.Name
+
+	if allProps {
+		for name, inner := range available {
+			if name != propCalendarData {
+				found = append(found, property{name: name, inner: inner})
+			}
+		}
+
+		sort.Slice(found, func(i, j int) bool {
+			return found[i].name.Space+found[i].name.Local < found[j].name.Space+found[j].name.Local
+		})
+
+		return found, nil
+	}
+
+	for _, name := range names {
+		if inner, ok := available[name]; ok {
+			found = append(found, property{name: name, inner: inner})
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	return found, missing
+}
+
+func (h *Handler) rootProps() map[xml.Name]string {
+	return map[xml.Name]string{
+		propResourceType:     dav("collection", "") + dav("principal", ""),
+		propDisplayName:      collectionTitle,
+		propCurrentPrincipal: hrefElement(h.rootHref()),
+		propPrincipalURL:     hrefElement(h.rootHref()),
+		propCalendarHomeSet:  hrefElement(h.rootHref()),
+	}
+}
+
+func (h *Handler) collectionProps(seq int64) map[xml.Name]string {
+	privileges := ""
+	for _, privilege := range []string{"read", "write", "write-content", "bind", "unbind"} {
+		privileges += dav("privilege", dav(privilege, ""))
+	}
+
+	reports := ""
+	for _, report := range []xml.Name{
+		{Space: nsCalDAV, Local: "calendar-query"},
+		{Space: nsCalDAV, Local: "calendar-multiget"},
+		{Space: nsDAV, Local: "sync-collection"},
+	} {
+		reports += dav("supported-report", dav("report", element(report, "")))
+	}
+
+	return map[xml.Name]string{
+		propResourceType:       dav("collection", "") + element(xml.Name{Space: nsCalDAV, Local: "calendar"}, ""),
+		propDisplayName:        collectionTitle,
+		propCurrentPrincipal:   hrefElement(h.rootHref()),
+		propPrivilegeSet:       privileges,
+		propSupportedReportSet: reports,
+		propComponentSet:       `<c:comp name="VTODO"/>`,
+		propSyncToken:          escape(syncToken(seq)),
+		propCTag:               escape(syncToken(seq)),
+	}
+}
+
+func resourceProps(todo *types.Todo) map[xml.Name]string {
+	return map[xml.Name]string{
+		propResourceType:   "",
+		propGetETag:        escape(ical.ETag(todo)),
+		propGetContentType: calendarDataType,
+		propCalendarData:   escape(string(ical.Marshal(todo))),
+	}
+}
+
+func (h *Handler) todoResponse(todo *types.Todo, names []xml.Name, allProps bool) response {
+	found, missing := collect(resourceProps(todo), names, allProps)
+
+	return response{href: h.resourceHref(todo.ExternalID), found: found, missing: missing}
+}
+
+func readBody(r *http.Request) (*node, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPutSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(string(body)) == "" {
+		return nil, nil
+	}
+
+	return parseXML(strings.NewReader(string(body)))
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, kind target, id string) {
+	root, err := readBody(r)
+	if err != nil {
+		http.Error(w, "Invalid XML: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	names, allProps := requestedProps(root)
+	depthZero := r.Header.Get("Depth") == "0"
+
+	var responses []response
+
+	switch kind {
+	case targetRoot:
+		found, missing := collect(h.rootProps(), names, allProps)
+		responses = append(responses, response{href: h.rootHref(), found: found, missing: missing})
+
+		if depthZero {
+			break
+		}
+
+		seq, err := h.todoService.LatestChangeSeq()
+		if err != nil {
+			writeServiceError(w, err)
+
+			return
+		}
+
+		found, missing = collect(h.collectionProps(seq), names, allProps)
+		responses = append(responses, response{href: h.collectionHref(), found: found, missing: missing})
+	case targetCollection:
+		seq, err := h.todoService.LatestChangeSeq()
+		if err != nil {
+			writeServiceError(w, err)
+
+			return
+		}
+
+		found, missing := collect(h.collectionProps(seq), names, allProps)
+		responses = append(responses, response{href: h.collectionHref(), found: found, missing: missing})
+
+		if depthZero {
+			break
+		}
+
+		todos, _, err := h.todoService.FindTodos(types.TodoFilter{})
+		if err != nil {
+			writeServiceError(w, err)
+
+			return
+		}
+
+		for i := range todos {
+			responses = append(responses, h.todoResponse(&todos[i], names, allProps))
+		}
+	case targetResource:
+		todo, err := h.todoService.GetTodoByID(id)
+		if err != nil {
+			writeServiceError(w, err)
+
+			return
+		}
+
+		responses = append(responses, h.todoResponse(todo, names, allProps))
+	}
+
+	writeMultistatus(w, multistatus(responses, ""))
+}
+
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, kind target) {
+	if kind != targetCollection {
+		writeError(w, http.StatusForbidden, errSupportedRep)
+
+		return
+	}
+
+	root, err := readBody(r)
+	if err != nil || root == nil {
+		http.Error(w, "A report body is required", http.StatusBadRequest)
+
+		return
+	}
+
+	names, allProps := requestedProps(root)
+
+	switch root.name {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		h.calendarQuery(w, root, names, allProps)
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		h.calendarMultiget(w, root, names, allProps)
+	case xml.Name{Space: nsDAV, Local: "sync-collection"}:
+		h.syncCollection(w, root, names, allProps)
+	default:
+		writeError(w, http.StatusForbidden, errSupportedRep)
+	}
+}
+
+// calendarQuery returns every todo unless the filter asks for a component
+// other than VTODO. Time ranges and property filters are not evaluated, which
+// only widens the result.
+func (h *Handler) calendarQuery(w http.ResponseWriter, root *node, names []xml.Name, allProps bool) {
+	for _, filter := range root.descendants(nsCalDAV, "comp-filter") {
+		if name := strings.ToUpper(filter.attrs["name"]); name != "VCALENDAR" && name != "VTODO" {
+			writeMultistatus(w, multistatus(nil, ""))
+
+			return
+		}
+	}
+
+	todos, _, err := h.todoService.FindTodos(types.TodoFilter{})
+	if err != nil {
+		writeServiceError(w, err)
+
+		return
+	}
+
+	responses := make([]response, len(todos))
+
+	for i := range todos {
+		responses[i] = h.todoResponse(&todos[i], names, allProps)
+	}
+
+	writeMultistatus(w, multistatus(responses, ""))
+}
+
+func (h *Handler) calendarMultiget(w http.ResponseWriter, root *node, names []xml.Name, allProps bool) {
+	var ids []string
+	var responses []response
+
+	hrefs := make(map[string]string)
+
+	for _, href := range root.descendants(nsDAV, "href") {
+		raw := strings.TrimSpace(href.text)
+		hrefPath := raw
+
+		if parsed, err := url.Parse(raw); err == nil {
+			hrefPath = parsed.Path
+		}
+
+		kind, id := h.resolve(hrefPath)
+		if kind != targetResource {
+			responses = append(responses, response{href: raw, status: http.StatusNotFound})
+
+			continue
+		}
+
+		ids = append(ids, id)
+		hrefs[id] = raw
+	}
+
+	if len(ids) > 0 {
+		todos, err := h.todoService.GetTodosByIDs(ids)
+		if err != nil {
+			writeServiceError(w, err)
+
+			return
+		}
+
+		for i := range todos {
+			responses = append(responses, h.todoResponse(&todos[i], names, allProps))
+			delete(hrefs, todos[i].ExternalID)
+		}
+
+		for _, raw := range hrefs {
+			responses = append(responses, response{href: raw, status: http.StatusNotFound})
+		}
+	}
+
+	writeMultistatus(w, multistatus(responses, ""))
+}
+
+func (h *Handler) syncCollection(w http.ResponseWriter, root *node, names []xml.Name, allProps bool) {
+	var token string
+
+	if tokenNode := root.child(nsDAV, "sync-token"); tokenNode != nil {
+		token = tokenNode.text
+	}
+
+	seq, ok := parseSyncToken(token)
+	if !ok {
+		writeError(w, http.StatusForbidden, errValidSyncToken)
+
+		return
+	}
+
+	changes, err := h.todoService.ChangesSince(seq, constant.SyncMaxChanges)
+	if err != nil {
+		if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonPreconditionFailed {
+			writeError(w, http.StatusForbidden, errValidSyncToken)
+
+			return
+		}
+
+		writeServiceError(w, err)
+
+		return
+	}
+
+	var responses []response
+
+	for _, changed := range [][]types.Todo{changes.Created, changes.Updated} {
+		for i := range changed {
+			responses = append(responses, h.todoResponse(&changed[i], names, allProps))
+		}
+	}
+
+	for _, id := range changes.Deleted {
+		responses = append(responses, response{href: h.resourceHref(id), status: http.StatusNotFound})
+	}
+
+	// A truncated result marks the collection itself, and the client asks
+	// again with the token returned (RFC 6578 section 3.6).
+	if changes.More {
+		responses = append(responses, response{href: h.collectionHref(), status: http.StatusInsufficientStorage})
+	}
+
+	writeMultistatus(w, multistatus(responses, syncToken(changes.Seq)))
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, kind target, id string) {
+	if kind != targetResource {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	todo, err := h.todoService.GetTodoByID(id)
+	if err != nil {
+		writeServiceError(w, err)
+
+		return
+	}
+
+	body := ical.Marshal(todo)
+
+	w.Header().Set("Content-Type", calendarDataType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", ical.ETag(todo))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, todo) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// etagMatches evaluates an If-Match or If-None-Match header against a todo.
+func etagMatches(header string, todo *types.Todo) bool {
+	etag := ical.ETag(todo)
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preconditions turns the conditional request headers into a check the
+// service runs while the todo is locked.
+func preconditions(r *http.Request) func(current *types.Todo) error {
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+
+	failed := service.TodoError{Message: "Precondition failed", Reason: service.ReasonPreconditionFailed}
+
+	return func(current *types.Todo) error {
+		if ifMatch != "" && (current == nil || !etagMatches(ifMatch, current)) {
+			return failed
+		}
+
+		if ifNoneMatch != "" && current != nil && etagMatches(ifNoneMatch, current) {
+			return failed
+		}
+
+		return nil
+	}
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, kind target, id string) {
+	if kind != targetResource {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(strings.ToLower(contentType), "text/calendar") {
+		writeError(w, http.StatusUnsupportedMediaType, errSupportedData)
+
+		return
+	}
+
+	rows, err := ical.ParseTodos(io.LimitReader(r.Body, maxPutSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errValidData)
+
+		return
+	}
+
+	if len(rows) != 1 {
+		writeError(w, http.StatusForbidden, errSupportedComp)
+
+		return
+	}
+
+	row := rows[0]
+
+	// The todo is served with its ID as UID, so a different UID would not
+	// survive the round trip.
+	if row.ID != "" && !strings.EqualFold(row.ID, id) {
+		writeError(w, http.StatusForbidden, errValidObject)
+
+		return
+	}
+
+	service.ValidateImportRow(&row)
+
+	if len(row.Errors) > 0 {
+		http.Error(w, strings.Join(row.Errors, "; "), http.StatusBadRequest)
+
+		return
+	}
+
+	todo := types.Todo{
+		ExternalID: id,
+		Title:      row.Title,
+		DueAt:      row.DueAt,
+		Status:     row.Status,
+		Recurrence: row.Recurrence,
+	}
+
+	if row.CreatedAt != nil {
+		todo.CreatedAt = *row.CreatedAt
+	}
+
+	stored, created, err := h.todoService.PutTodo(todo, preconditions(r))
+	if err != nil {
+		writeServiceError(w, err)
+
+		return
+	}
+
+	// The ETag is that of the object GET serves, which is rebuilt from the
+	// todo fields and may differ from the body that was sent.
+	w.Header().Set("ETag", ical.ETag(stored))
+
+	if created {
+		w.Header().Set("Location", h.resourceHref(id))
+		w.WriteHeader(http.StatusCreated)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, kind target, id string) {
+	if kind != targetResource {
+		http.Error(w, fmt.Sprintf("The %s collection cannot be deleted", collectionName), http.StatusForbidden)
+
+		return
+	}
+
+	var precondition func(current *types.Todo) error
+
+	if r.Header.Get("If-Match") != "" {
+		precondition = preconditions(r)
+	}
+
+	if err := h.todoService.DeleteTodoIf(id, precondition); err != nil {
+		writeServiceError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+var prefixes = map[string]string{
+	nsDAV:    "d",
+	nsCalDAV: "c",
+	nsCS:     "cs",
+}
+
+// node is a parsed request element. Only the parts the reports look at are
+// kept: names, attributes, children and character data.
+type node struct {
+	name     xml.Name
+	attrs    map[string]string
+	children []*node
+	text     string
+}
+
+func (n *node) child(space, local string) *node {
+	for _, c := range n.children {
+		if c.name.Space == space && c.name.Local == local {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// descendants returns every element below n with the given name.
+func (n *node) descendants(space, local string) []*node {
+	var found []*node
+
+	for _, c := range n.children {
+		if c.name.Space == space && c.name.Local == local {
+			found = append(found, c)
+		}
+
+		found = append(found, c.descendants(space, local)...)
+	}
+
+	return found
+}
+
+func parseXML(r io.Reader) (*node, error) {
+	decoder := xml.NewDecoder(r)
+
+	var stack []*node
+	var root *node
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attrs: make(map[string]string)}
+
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("empty XML document")
+	}
+
+	return root, nil
+}
+
+// property is one entry of a propstat. Inner is trusted, already encoded XML.
+type property struct {
+	name  xml.Name
+	inner string
+}
+
+type response struct {
+	href    string
+	found   []property
+	missing []xml.Name
+	// status replaces the propstats, as for members removed since a sync token.
+	status int
+}
+
+func escape(value string) string {
+	buf := new(bytes.Buffer)
+	xml.EscapeText(buf, []byte(value))
+
+	return buf.String()
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, statusText(code))
+}
+
+func statusText(code int) string {
+	switch code {
+	case 200:
+		return "OK"
+	case 404:
+		return "Not Found"
+	case 507:
+		return "Insufficient Storage"
+	}
+
+	return ""
+}
+
+// element renders an empty or filled element, declaring namespaces that have
+// no prefix on the root.
+func element(name xml.Name, inner string) string {
+	tag := name.Local
+	declaration := ""
+
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "x:" + name.Local
+		declaration = ` xmlns:x="` + escape(name.Space) + `"`
+	}
+
+	if inner == "" {
+		return "<" + tag + declaration + "/>"
+	}
+
+	return "<" + tag + declaration + ">" + inner + "</" + tag + ">"
+}
+
+func dav(local string, inner string) string {
+	return element(xml.Name{Space: nsDAV, Local: local}, inner)
+}
+
+func hrefElement(href string) string {
+	return dav("href", escape(href))
+}
+
+// multistatus renders a 207 body. syncToken is only set for sync-collection
+// reports.
+func multistatus(responses []response, syncToken string) []byte {
+	var out strings.Builder
+
+	out.WriteString(xml.Header)
+	out.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+
+	for _, resp := range responses {
+		out.WriteString("<d:response>")
+		out.WriteString(hrefElement(resp.href))
+
+		if resp.status != 0 {
+			out.WriteString(dav("status", statusLine(resp.status)))
+		}
+
+		if len(resp.found) > 0 {
+			out.WriteString("<d:propstat><d:prop>")
+
+			for _, prop := range resp.found {
+				out.WriteString(element(prop.name, prop.inner))
+			}
+
+			out.WriteString("</d:prop>" + dav("status", statusLine(200)) + "</d:propstat>")
+		}
+
+		if len(resp.missing) > 0 {
+			out.WriteString("<d:propstat><d:prop>")
+
+			for _, name := range resp.missing {
+				out.WriteString(element(name, ""))
+			}
+
+			out.WriteString("</d:prop>" + dav("status", statusLine(404)) + "</d:propstat>")
+		}
+
+		out.WriteString("</d:response>")
+	}
+
+	if syncToken != "" {
+		out.WriteString(dav("sync-token", escape(syncToken)))
+	}
+
+	out.WriteString("</d:multistatus>")
+
+	return []byte(out.String())
+}
+
+// errorBody renders a DAV:error with one precondition element.
+func errorBody(name xml.Name) []byte {
+	return []byte(xml.Header + `<d:error xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">` + element(name, "") + `</d:error>`)
+}
@@ -1,22 +1,256 @@
 package constant
 
+import "time"
+
+const (
+	GetTodosLogEventErrorKey    string = "todo_get_all_fail"
+	GetTodosLogEventKey         string = "todo_get_all"
+	CreateTodoLogEventKey       string = "todo_create"
+	CreateTodoLogEventErrorKey  string = "todo_create_fail"
+	GetTodoLogEventErrorKey     string = "todo_get_fail"
+	GetTodoLogEventKey          string = "todo_get"
+	UpdateTodoLogEventKey       string = "todo_update"
+	UpdateTodoLogEventErrorKey  string = "todo_update_fail"
+	DeleteTodoLogEventKey       string = "todo_delete"
+	DeleteTodoLogEventErrorKey  string = "todo_delete_fail"
+	DbIdNotFoundMsg             string = "Id not found"
+	DbQueryFailMsg              string = "Failed to query database"
+	DbExecFailMsg               string = "Failed to execute database query"
+	DbRowsAffectedFailMsg       string = "Failed to get rows affected"
+	DbScanFailMsg               string = "Failed to scan database row"
+	DbTxBeginFailMsg            string = "Failed to begin transaction"
+	DbTxCommitFailMsg           string = "Failed to commit transaction"
+	ErrMsgInternalServer        string = "Internal server error"
+	ConfigLoadLogEventErrorKey  string = "config_load_fail"
+	DbInitErrorEventKey         string = "db_init_fail"
+	OpenAPILoadLogEventErrorKey string = "openapi_load_fail"
+)
+
+const (
+	GetWebhooksLogEventErrorKey     string = "webhook_get_all_fail"
+	GetWebhookLogEventErrorKey      string = "webhook_get_fail"
+	CreateWebhookLogEventKey        string = "webhook_create"
+	CreateWebhookLogEventErrorKey   string = "webhook_create_fail"
+	UpdateWebhookLogEventKey        string = "webhook_update"
+	UpdateWebhookLogEventErrorKey   string = "webhook_update_fail"
+	DeleteWebhookLogEventKey        string = "webhook_delete"
+	DeleteWebhookLogEventErrorKey   string = "webhook_delete_fail"
+	GetDeliveriesLogEventErrorKey   string = "webhook_delivery_get_all_fail"
+	RedeliverLogEventKey            string = "webhook_redeliver"
+	RedeliverLogEventErrorKey       string = "webhook_redeliver_fail"
+	EnqueueWebhookLogEventErrorKey  string = "webhook_enqueue_fail"
+	DispatchWebhookLogEventKey      string = "webhook_dispatch"
+	DispatchWebhookLogEventErrorKey string = "webhook_dispatch_fail"
+	TodoEventCreated                string = "todo.created"
+	TodoEventUpdated                string = "todo.updated"
+	TodoEventDeleted                string = "todo.deleted"
+	WebhookDeliveryStatusPending    string = "pending"
+	WebhookDeliveryStatusDelivered  string = "delivered"
+	WebhookDeliveryStatusDead       string = "dead"
+	WebhookSignatureHeader          string = "X-Webhook-Signature"
+	WebhookTimestampHeader          string = "X-Webhook-Timestamp"
+	WebhookEventHeader              string = "X-Webhook-Event"
+	WebhookDeliveryHeader           string = "X-Webhook-Delivery"
+)
+
+const (
+	WebSocketLogEventKey      string        = "websocket"
+	WebSocketLogEventErrorKey string        = "websocket_fail"
+	WebSocketPingInterval     time.Duration = 30 * time.Second
+	WebSocketPongWait         time.Duration = 60 * time.Second
+	WebSocketWriteWait        time.Duration = 10 * time.Second
+	WebSocketMaxMessageSize   int64         = 64 << 10
+	WebSocketSendQueueSize    int           = 64
+)
+
+const (
+	GRPCServerLogEventErrorKey string = "grpc_server_fail"
+	GRPCWatchBufferSize        int    = 64
+)
+
+const (
+	GraphQLSchemaLogEventErrorKey string = "graphql_schema_fail"
+	GraphQLMaxDepth               int    = 8
+	GraphQLMaxComplexity          int    = 2000
+	ErrMsgGraphQLMutationOverGet  string = "Mutations must be sent with POST"
+)
+
+const (
+	ExportTodosLogEventKey      string = "todo_export"
+	ExportTodosLogEventErrorKey string = "todo_export_fail"
+	ExportBatchSize             int    = 500
+)
+
+const (
+	ImportTodosLogEventKey      string = "todo_import"
+	ImportTodosLogEventErrorKey string = "todo_import_fail"
+	ImportBatchSize             int    = 500
+	ImportMaxUploadSize         int64  = 10 << 20
+	ImportStatusCreated         string = "created"
+	ImportStatusWouldCreate     string = "would_create"
+	ImportStatusDuplicate       string = "duplicate"
+	ImportStatusInvalid         string = "invalid"
+	ImportDedupeNone            string = "none"
+	ImportDedupeTitle           string = "title"
+	ImportDedupeID              string = "id"
+	TodoTitleMaxLength          int    = 255
+)
+
+const (
+	TodoStatusNeedsAction           string = "needs-action"
+	TodoStatusInProcess             string = "in-process"
+	TodoStatusCompleted             string = "completed"
+	TodoStatusCancelled             string = "cancelled"
+	CalendarFeedLogEventKey         string = "calendar_feed"
+	CalendarFeedLogEventErrorKey    string = "calendar_feed_fail"
+	CalendarFeedTokenQueryParam     string = "token"
+	CalendarFeedUnauthorizedMessage string = "Invalid or missing feed token"
+)
+
+const (
+	TodoChangesLogEventErrorKey string = "todo_changes_fail"
+	PutTodoLogEventKey          string = "todo_put"
+	PutTodoLogEventErrorKey     string = "todo_put_fail"
+	ErrMsgInvalidSyncToken      string = "Sync token is invalid or from the future"
+	ErrMsgTodoCreatedMeanwhile  string = "Another request created a todo with this ID meanwhile"
+	CalDAVPrefix                string = "/dav"
+)
+
+const (
+	SyncTodosLogEventKey      string = "todo_sync"
+	SyncTodosLogEventErrorKey string = "todo_sync_fail"
+	SyncTokenQueryParam       string = "since"
+	SyncMaxChanges            int    = 500
+	SyncStatusCreated         string = "created"
+	SyncStatusUpdated         string = "updated"
+	SyncStatusDeleted         string = "deleted"
+	SyncStatusUnchanged       string = "unchanged"
+	SyncStatusConflict        string = "conflict"
+	SyncStatusInvalid         string = "invalid"
+	SyncStatusForbidden       string = "forbidden"
+)
+
+const (
+	APIKeyLogEventKey         string = "api_key"
+	APIKeyLogEventErrorKey    string = "api_key_fail"
+	APIKeyTokenPrefix         string = "tda_"
+	APIKeyVisiblePrefixLength int    = 12
+	APIKeyContextKey          string = "api_key"
+	APIKeyHeader              string = "X-API-Key"
+	APIKeyUnauthorizedMessage string = "Invalid, revoked or missing API key"
+	APIKeyForbiddenMessage    string = "The API key lacks the required scope"
+	APIKeyScopesExceededMsg   string = "A key can only grant scopes the key creating it holds"
+	ScopeTodosRead            string = "todos:read"
+	ScopeTodosWrite           string = "todos:write"
+	ScopeWebhooksRead         string = "webhooks:read"
+	ScopeWebhooksWrite        string = "webhooks:write"
+	ScopeKeysManage           string = "keys:manage"
+)
+
+const (
+	AccessLogEventKey            string = "access"
+	AccessLogEventErrorKey       string = "access_fail"
+	ListLogEventKey              string = "todo_list"
+	ListLogEventErrorKey         string = "todo_list_fail"
+	InvitationLogEventKey        string = "invitation"
+	InvitationLogEventErrorKey   string = "invitation_fail"
+	InvitationTokenPrefix        string = "tdi_"
+	RoleOwner                    string = "owner"
+	RoleEditor                   string = "editor"
+	RoleViewer                   string = "viewer"
+	ErrMsgForbidden              string = "You do not have permission to do this"
+	ErrMsgInvitationNeedsUser    string = "Invitations can only be accepted with a personal API key"
+	ErrMsgInvitationWrongAddress string = "The invitation was sent to another address"
+)
+
+const (
+	WorkspaceLogEventKey        string = "workspace"
+	WorkspaceLogEventErrorKey   string = "workspace_fail"
+	WorkspaceContextKey         string = "workspace"
+	WorkspaceHeader             string = "X-Workspace"
+	WorkspaceTenantRole         string = "todo_tenant"
+	DefaultWorkspaceID          int    = 1
+	ScopeWorkspacesManage       string = "workspaces:manage"
+	ErrMsgWorkspaceNotFound     string = "Workspace not found"
+	ErrMsgWorkspaceMismatch     string = "The API key belongs to another workspace"
+	ErrMsgWorkspaceUnauthorized string = "Credentials valid in the workspace are required"
+	ErrMsgWorkspaceSlugTaken    string = "A workspace with this slug already exists"
+	ErrMsgWorkspaceAdminOnly    string = "Workspaces can only be managed from the default workspace"
+	ErrMsgWorkspaceSlugInvalid  string = "The slug must be a lowercase DNS label"
+)
+
+const (
+	SessionLogEventKey         string = "session"
+	SessionLogEventErrorKey    string = "session_fail"
+	SessionKeyName             string = "session"
+	SessionTokenIssuer         string = "todo-app"
+	LoginFlowCookie            string = "todo_login"
+	LoginFlowCookiePath        string = "/auth"
+	LoginFlowTTL               int    = 600
+	ErrMsgSessionInvalid       string = "Invalid or expired session"
+	ErrMsgLoginFlowInvalid     string = "The login attempt is invalid or has expired, please start again"
+	ErrMsgLoginProviderFailed  string = "The identity provider could not be reached or rejected the login"
+	ErrMsgLoginEmailUnverified string = "The identity provider has not verified the email address"
+	ErrMsgLoginEmailMissing    string = "The identity provider did not share an email address"
+)
+
+const (
+	RateLimitLogEventErrorKey string = "rate_limit_fail"
+	RateLimitStoreMemory      string = "memory"
+	RateLimitStorePostgres    string = "postgres"
+	ErrMsgRateLimited         string = "Too many requests, please retry later"
+)
+
+const (
+	TracingLogEventErrorKey string = "tracing_fail"
+)
+
+const (
+	RequestIDHeader string = "X-Request-ID"
+)
+
+const (
+	LogLevelLogEventKey     string = "log_level"
+	ScopeLogsManage         string = "logs:manage"
+	LogRedactFieldsDefault  string = "token,access_token,id_token,refresh_token,secret,password,authorization,key,code,email"
+	ErrMsgLogLevelAdminOnly string = "The log level can only be changed from the default workspace"
+)
+
+const (
+	ScopeMetricsRead          string = "metrics:read"
+	ErrMsgTodoCacheAdminOnly  string = "The todo cache can only be inspected from the default workspace"
+	TodoCacheLogEventKey      string = "todo_cache"
+	TodoCacheLogEventErrorKey string = "todo_cache_fail"
+)
+
+const (
+	DBTypePostgres string = "postgres"
+	DBTypeSQLite   string = "sqlite"
+	DBTypeFile     string = "file"
+)
+
+const (
+	FileStoreLogEventKey         string = "file_store"
+	FileStoreLogEventErrorKey    string = "file_store_fail"
+	FileStoreFailMsg             string = "File store operation failed"
+	ErrMsgFileStoreNoInvitations string = "Invitations need user accounts, which the file store does not keep"
+)
+
+const (
+	DBSSLModeDisable     string        = "disable"
+	DBSSLModeRequire     string        = "require"
+	DBSSLModeVerifyCA    string        = "verify-ca"
+	DBSSLModeVerifyFull  string        = "verify-full"
+	DbConnectLogEventKey string        = "db_connect"
+	DbConnectMaxBackoff  time.Duration = 30 * time.Second
+	DbConnectFailMsg     string        = "Failed to connect to database"
+	DbConnectRetryMsg    string        = "Database not reachable yet, retrying"
+)
+
 const (
-	GetTodosLogEventErrorKey   string = "todo_get_all_fail"
-	GetTodosLogEventKey        string = "todo_get_all"
-	CreateTodoLogEventKey      string = "todo_create"
-	CreateTodoLogEventErrorKey string = "todo_create_fail"
-	GetTodoLogEventErrorKey    string = "todo_get_fail"
-	GetTodoLogEventKey         string = "todo_get"
-	UpdateTodoLogEventKey      string = "todo_update"
-	UpdateTodoLogEventErrorKey string = "todo_update_fail"
-	DeleteTodoLogEventKey      string = "todo_delete"
-	DeleteTodoLogEventErrorKey string = "todo_delete_fail"
-	DbIdNotFoundMsg            string = "Id not found"
-	DbQueryFailMsg             string = "Failed to query database"
-	DbExecFailMsg              string = "Failed to execute database query"
-	DbRowsAffectedFailMsg      string = "Failed to get rows affected"
-	DbScanFailMsg              string = "Failed to scan database row"
-	ErrMsgInternalServer       string = "Internal server error"
-	ConfigLoadLogEventErrorKey string = "config_load_fail"
-	DbInitErrorEventKey        string = "db_init_fail"
+	MigrateLogEventKey      string = "migrate"
+	MigrateLogEventErrorKey string = "migrate_fail"
+	// MigrationLockID keys the advisory lock migrators take; it is "todo" in
+	// ASCII, to stay clear of the keys golang-migrate derives.
+	MigrationLockID int64 = 0x746f646f
 )
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+var apiKeyScopes = map[string]bool{
+	constant.ScopeTodosRead:        true,
+	constant.ScopeTodosWrite:       true,
+	constant.ScopeWebhooksRead:     true,
+	constant.ScopeWebhooksWrite:    true,
+	constant.ScopeKeysManage:       true,
+	constant.ScopeWorkspacesManage: true,
+	constant.ScopeLogsManage:       true,
+	constant.ScopeMetricsRead:      true,
+}
+
+// apiKeyServiceFor returns apiKeyService limited to the request's workspace.
+func apiKeyServiceFor(c *gin.Context, apiKeyService *service.APIKeyService) *service.APIKeyService {
+	return apiKeyService.InWorkspace(middlewares.WorkspaceIDFromContext(c))
+}
+
+// grantsScopes reports whether creator may hand out a key limited to scopes:
+// only some of its own, unless it is unrestricted itself. No scopes mean every
+// scope, so a limited key cannot create such a key.
+func grantsScopes(creator *types.APIKey, scopes []string) bool {
+	if creator == nil || len(creator.Scopes) == 0 {
+		return true
+	}
+
+	if len(scopes) == 0 {
+		return false
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(creator.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func GetAPIKeys(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := apiKeyServiceFor(c, apiKeyService).GetAllKeys(middlewares.ActorFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedKeys := make([]types.APIKeyResponse, len(keys))
+
+		for i, key := range keys {
+			mappedKeys[i] = *utils.MapAPIKeyResponse(&key)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedKeys)
+	}
+}
+
+func CreateAPIKey(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.APIKeyInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		for _, scope := range input.Scopes {
+			if !apiKeyScopes[scope] {
+				respondError(c, http.StatusBadRequest, "Unknown scope "+scope)
+
+				return
+			}
+		}
+
+		if !grantsScopes(middlewares.APIKeyFromContext(c), input.Scopes) {
+			respondError(c, http.StatusForbidden, constant.APIKeyScopesExceededMsg)
+
+			return
+		}
+
+		newKey, secret, err := apiKeyServiceFor(c, apiKeyService).CreateKey(input, middlewares.ActorFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, types.APIKeyCreatedResponse{
+			APIKeyResponse: *utils.MapAPIKeyResponse(newKey),
+			Key:            secret,
+		})
+	}
+}
+
+func RevokeAPIKey(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		key, err := apiKeyServiceFor(c, apiKeyService).RevokeKey(id, middlewares.ActorFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, utils.MapAPIKeyResponse(key))
+	}
+}
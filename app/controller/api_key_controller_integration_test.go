@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestAPIKey(t *testing.T, input types.APIKeyInput) types.APIKeyCreatedResponse {
+	jsonValue, _ := json.Marshal(input)
+
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var key types.APIKeyCreatedResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &key); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return key
+}
+
+func TestAPIKeys(t *testing.T) {
+	key := createTestAPIKey(t, types.APIKeyInput{Name: "CI", Scopes: []string{constant.ScopeTodosRead}})
+
+	// A router guarded the way router.Init guards it, with auth required.
+	apiKeyService := service.NewAPIKeyService(db)
+	todoService := service.NewTodoService(db)
+
+	guarded := gin.New()
+	guarded.Use(middlewares.AuthMiddleware(apiKeyService, nil))
+	guarded.GET("/todos", middlewares.RequireScope(constant.ScopeTodosRead, true), GetTodos(todoService))
+	guarded.POST("/todos", middlewares.RequireScope(constant.ScopeTodosWrite, true), CreateTodo(todoService))
+	guarded.GET("/graphql", middlewares.RequireScope(constant.ScopeTodosRead, true), GraphQL(todoService))
+	guarded.GET("/ws", middlewares.RequireScope(constant.ScopeTodosRead, true), TodoWebSocket(todoService))
+	guarded.POST("/api-keys", middlewares.RequireScope(constant.ScopeKeysManage, true), CreateAPIKey(apiKeyService))
+
+	guardedRequest := func(method string, header string, value string) int {
+		req, _ := http.NewRequest(method, "/todos", strings.NewReader(`{"title": "Not allowed"}`))
+
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		w := httptest.NewRecorder()
+		guarded.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	t.Run("It should show the key once with a visible prefix", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(key.Key, constant.APIKeyTokenPrefix))
+		assert.Equal(t, key.Key[:constant.APIKeyVisiblePrefixLength], key.Prefix)
+
+		req, _ := http.NewRequest("GET", "/api-keys", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), key.Prefix)
+		assert.NotContains(t, w.Body.String(), key.Key)
+	})
+
+	t.Run("It should reject unknown scopes", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(types.APIKeyInput{Name: "Bot", Scopes: []string{"todos:everything"}})
+
+		req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("It should require a key when auth is required", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, guardedRequest("GET", "", ""))
+		assert.Equal(t, http.StatusUnauthorized, guardedRequest("GET", constant.APIKeyHeader, "tda_wrong"))
+	})
+
+	t.Run("It should enforce the scopes of a key", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, guardedRequest("GET", "Authorization", "Bearer "+key.Key))
+		assert.Equal(t, http.StatusForbidden, guardedRequest("POST", constant.APIKeyHeader, key.Key))
+	})
+
+	t.Run("It should only let a key grant scopes it holds", func(t *testing.T) {
+		manager := createTestAPIKey(t, types.APIKeyInput{Name: "Manager", Scopes: []string{constant.ScopeKeysManage, constant.ScopeTodosRead}})
+
+		createAs := func(scopes []string) int {
+			jsonValue, _ := json.Marshal(types.APIKeyInput{Name: "Child", Scopes: scopes})
+
+			req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonValue))
+			req.Header.Set(constant.APIKeyHeader, manager.Key)
+			w := httptest.NewRecorder()
+			guarded.ServeHTTP(w, req)
+
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusForbidden, createAs([]string{constant.ScopeTodosWrite}))
+		assert.Equal(t, http.StatusForbidden, createAs([]string{constant.ScopeTodosRead, constant.ScopeWorkspacesManage}))
+		assert.Equal(t, http.StatusForbidden, createAs(nil))
+		assert.Equal(t, http.StatusCreated, createAs([]string{constant.ScopeTodosRead}))
+	})
+
+	t.Run("It should not let a read key mutate over GraphQL GET", func(t *testing.T) {
+		todo := *utils.MapTodoResponse(&testData[1])
+		query := url.Values{"query": {`mutation { deleteTodo(id: "` + todo.ID + `") }`}}
+
+		req, _ := http.NewRequest("GET", "/graphql?"+query.Encode(), nil)
+		req.Header.Set(constant.APIKeyHeader, key.Key)
+		w := httptest.NewRecorder()
+		guarded.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+
+		req, _ = http.NewRequest("GET", "/todos/"+todo.ID, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("It should not let a read key write over the WebSocket", func(t *testing.T) {
+		server := httptest.NewServer(guarded)
+		defer server.Close()
+
+		header := http.Header{constant.APIKeyHeader: {key.Key}}
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+		if err != nil {
+			t.Fatalf("Failed to dial websocket: %v", err)
+		}
+
+		defer conn.Close()
+
+		todo := *utils.MapTodoResponse(&testData[1])
+
+		sendTestWebSocketMessage(t, conn, "create", "create-1", types.WebSocketTodoPayload{Title: "Not allowed"})
+		sendTestWebSocketMessage(t, conn, "update", "update-1", types.WebSocketTodoPayload{ID: todo.ID, Title: "Not allowed"})
+		sendTestWebSocketMessage(t, conn, "delete", "delete-1", types.WebSocketTodoPayload{ID: todo.ID})
+
+		for _, id := range []string{"create-1", "update-1", "delete-1"} {
+			msg := readTestWebSocketMessage(t, conn, withCorrelationID(id))
+
+			assert.Equal(t, "error", msg["type"])
+			assert.Equal(t, float64(http.StatusForbidden), msg["error"].(map[string]interface{})["status"])
+		}
+
+		sendTestWebSocketMessage(t, conn, "subscribe", "sub-1", nil)
+
+		assert.Equal(t, "ack", readTestWebSocketMessage(t, conn, withCorrelationID("sub-1"))["type"])
+	})
+
+	t.Run("It should record when the key was used", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api-keys", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var keys []types.APIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &keys)
+
+		assert.Equal(t, key.ID, keys[0].ID)
+		assert.NotNil(t, keys[0].LastUsedAt)
+	})
+
+	t.Run("It should stop accepting a revoked key", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/api-keys/"+key.ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"revoked_at": "`)
+		assert.Equal(t, http.StatusUnauthorized, guardedRequest("GET", constant.APIKeyHeader, key.Key))
+	})
+}
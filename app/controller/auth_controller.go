@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/utils"
+)
+
+func respondLoginError(c *gin.Context, err error) {
+	if todoErr, ok := err.(service.TodoError); ok {
+		switch todoErr.Reason {
+		case service.ReasonUnauthorized:
+			respondError(c, http.StatusUnauthorized, todoErr.Message)
+		case service.ReasonForbidden:
+			respondError(c, http.StatusForbidden, todoErr.Message)
+		case service.ReasonBadGateway:
+			respondError(c, http.StatusBadGateway, todoErr.Message)
+		default:
+			respondError(c, http.StatusInternalServerError, todoErr.Message)
+		}
+
+		return
+	}
+
+	respondError(c, http.StatusInternalServerError, err.Error())
+}
+
+// setLoginFlowCookie keeps the login flow for the callback. SameSite=Lax
+// still sends it on the provider's top-level redirect back to us.
+func setLoginFlowCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constant.LoginFlowCookie, value, maxAge, constant.LoginFlowCookiePath, "", c.Request.TLS != nil, true)
+}
+
+// Login sends the user to the OpenID provider to log into the request's
+// workspace.
+func Login(sessionService *service.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspace := middlewares.WorkspaceFromContext(c)
+
+		authURL, flow, err := sessionService.StartLogin(c.Request.Context(), workspace)
+
+		if err != nil {
+			respondLoginError(c, err)
+
+			return
+		}
+
+		setLoginFlowCookie(c, flow, constant.LoginFlowTTL)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// LoginCallback is where the provider sends the user back to. It finishes the
+// login and responds with the session token.
+func LoginCallback(sessionService *service.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if providerErr := c.Query("error"); providerErr != "" {
+			respondError(c, http.StatusUnauthorized, constant.ErrMsgLoginProviderFailed+": "+providerErr)
+
+			return
+		}
+
+		flow, err := c.Cookie(constant.LoginFlowCookie)
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, constant.ErrMsgLoginFlowInvalid)
+
+			return
+		}
+
+		// The flow is single use, whatever the outcome.
+		setLoginFlowCookie(c, "", -1)
+
+		session, token, err := sessionService.FinishLogin(c.Request.Context(), flow, c.Query("state"), c.Query("code"))
+
+		if err != nil {
+			respondLoginError(c, err)
+
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.IndentedJSON(http.StatusOK, utils.MapSessionResponse(session, token))
+	}
+}
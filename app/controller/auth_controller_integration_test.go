@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/oidc"
+	"todo-app/app/openapi"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRedirectURL = "http://localhost:8080/auth/callback"
+
+// setupLoginRouter serves the login routes against fake and a few routes to
+// try the sessions on.
+func setupLoginRouter(fake *utils.FakeOIDCProvider) *gin.Engine {
+	validator, err := openapi.NewValidator(true)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      fake.Issuer(),
+		ClientID:    fake.ClientID,
+		RedirectURL: testRedirectURL,
+		Scopes:      []string{"openid", "email", "profile"},
+	}, nil)
+
+	sessionService := service.NewSessionService(db, provider, "test-session-secret", time.Hour)
+	todoService := service.NewTodoService(db)
+
+	login := gin.New()
+	login.Use(middlewares.AuthMiddleware(service.NewAPIKeyService(db), sessionService))
+	login.Use(middlewares.WorkspaceMiddleware(service.NewWorkspaceService(db), testWorkspaceDomain))
+	login.Use(middlewares.ValidationMiddleware(validator))
+
+	login.GET("/auth/login", Login(sessionService))
+	login.GET("/auth/callback", LoginCallback(sessionService))
+	login.GET("/todos", middlewares.RequireScope(constant.ScopeTodosRead, true), GetTodos(todoService))
+	login.GET("/webhooks", middlewares.RequireScope(constant.ScopeWebhooksRead, true), GetWebhooks(service.NewWebhookService(db)))
+
+	return login
+}
+
+func serveWith(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+// startLogin runs /auth/login and the fake provider's login page, returning
+// the callback URL the provider redirected to and the login flow cookie.
+func startLogin(t *testing.T, login *gin.Engine, headers map[string]string) (*url.URL, *http.Cookie) {
+	req, _ := http.NewRequest("GET", "/auth/login", nil)
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := serveWith(login, req)
+
+	if !assert.Equal(t, http.StatusFound, w.Code) {
+		t.FailNow()
+	}
+
+	cookies := w.Result().Cookies()
+
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to reach the fake provider: %v", err)
+	}
+
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse the callback URL: %v", err)
+	}
+
+	return callback, cookies[0]
+}
+
+func finishLogin(login *gin.Engine, callback *url.URL, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", callback.RequestURI(), nil)
+
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	return serveWith(login, req)
+}
+
+func logIn(t *testing.T, login *gin.Engine, headers map[string]string) types.SessionResponse {
+	var session types.SessionResponse
+
+	callback, cookie := startLogin(t, login, headers)
+	w := finishLogin(login, callback, cookie)
+	json.Unmarshal(w.Body.Bytes(), &session)
+
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	return session
+}
+
+func withSession(token string, method string, path string, headers map[string]string) *http.Request {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	return req
+}
+
+func TestOIDCLogin(t *testing.T) {
+	fake := utils.NewFakeOIDCProvider("todo-app")
+	defer fake.Close()
+
+	login := setupLoginRouter(fake)
+
+	t.Run("It should log in and provision the user", func(t *testing.T) {
+		session := logIn(t, login, nil)
+
+		assert.NotEmpty(t, session.Token)
+		assert.NotEmpty(t, session.UserID)
+		assert.Equal(t, fake.User.Email, session.Email)
+		assert.Equal(t, fake.User.Name, session.Name)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), session.ExpiresAt, time.Minute)
+
+		w := serveWith(login, withSession(session.Token, "GET", "/todos", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serveWith(login, withSession(session.Token, "GET", "/webhooks", nil))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("It should find the same user again by the provider account", func(t *testing.T) {
+		first := logIn(t, login, nil)
+
+		fake.User.Email = "renamed@example.com"
+		defer func() { fake.User.Email = "sso@example.com" }()
+
+		second := logIn(t, login, nil)
+
+		assert.Equal(t, first.UserID, second.UserID)
+	})
+
+	t.Run("It should reject a callback that does not match the login attempt", func(t *testing.T) {
+		callback, cookie := startLogin(t, login, nil)
+
+		assert.Equal(t, http.StatusUnauthorized, finishLogin(login, callback, nil).Code)
+
+		tampered := *callback
+		query := tampered.Query()
+		query.Set("state", "forged")
+		tampered.RawQuery = query.Encode()
+
+		assert.Equal(t, http.StatusUnauthorized, finishLogin(login, &tampered, cookie).Code)
+
+		forged := *cookie
+		forged.Value = "e30." + cookie.Value[len(cookie.Value)-10:]
+
+		assert.Equal(t, http.StatusUnauthorized, finishLogin(login, callback, &forged).Code)
+	})
+
+	t.Run("It should reject codes the provider does not redeem", func(t *testing.T) {
+		callback, cookie := startLogin(t, login, nil)
+
+		query := callback.Query()
+		query.Set("code", "made-up")
+		callback.RawQuery = query.Encode()
+
+		assert.Equal(t, http.StatusBadGateway, finishLogin(login, callback, cookie).Code)
+	})
+
+	t.Run("It should reject forged or tampered session tokens", func(t *testing.T) {
+		session := logIn(t, login, nil)
+
+		for _, token := range []string{
+			session.Token[:len(session.Token)-4] + "AAAA",
+			"eyJhbGciOiJub25lIn0.eyJzdWIiOiJ4In0.",
+		} {
+			w := serveWith(login, withSession(token, "GET", "/todos", nil))
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("It should keep sessions in the workspace they were issued for", func(t *testing.T) {
+		_, _, err := service.NewWorkspaceService(db).CreateWorkspace(types.WorkspaceInput{Slug: "sso", Name: "SSO"})
+		if err != nil {
+			t.Fatalf("Failed to create workspace: %v", err)
+		}
+
+		home := logIn(t, login, nil)
+		away := logIn(t, login, map[string]string{constant.WorkspaceHeader: "sso"})
+
+		assert.NotEqual(t, home.UserID, away.UserID)
+
+		var todos []types.TodoResponse
+
+		w := serveWith(login, withSession(away.Token, "GET", "/todos", nil))
+		json.Unmarshal(w.Body.Bytes(), &todos)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, todos)
+
+		w = serveWith(login, withSession(away.Token, "GET", "/todos", map[string]string{constant.WorkspaceHeader: "default"}))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
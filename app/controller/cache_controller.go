@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+)
+
+// requireDefaultWorkspaceForCache lets only requests served in the default
+// workspace see the todo cache, which holds the todos of every tenant.
+func requireDefaultWorkspaceForCache(c *gin.Context) bool {
+	if middlewares.WorkspaceIDFromContext(c) != constant.DefaultWorkspaceID {
+		respondError(c, http.StatusForbidden, constant.ErrMsgTodoCacheAdminOnly)
+
+		return false
+	}
+
+	return true
+}
+
+// GetTodoCacheStats reports the hits and misses of the todo cache. It is only
+// routed when todoService reads through one.
+func GetTodoCacheStats(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireDefaultWorkspaceForCache(c) {
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, todoService.Cache.Stats())
+	}
+}
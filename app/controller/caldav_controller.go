@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/caldav"
+	"todo-app/app/constant"
+	"todo-app/app/service"
+)
+
+// CalDAV serves the CalDAV tree mounted at constant.CalDAVPrefix. The WebDAV
+// protocol is handled entirely by the caldav package, acting for the user
+// behind the request's API key.
+func CalDAV(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caldav.NewHandler(todoServiceFor(c, todoService), constant.CalDAVPrefix).ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func davRequest(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func syncTokenFrom(body string) string {
+	match := regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>`).FindStringSubmatch(body)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+func TestCalDAV(t *testing.T) {
+	id := uuid.New().String()
+	resource := "/dav/todos/" + id + ".ics"
+	vtodo := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:" + id + "\r\nSUMMARY:Water plants\r\nDUE:20300101T090000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	calendarHeaders := map[string]string{"Content-Type": "text/calendar"}
+
+	t.Run("It should advertise calendar access", func(t *testing.T) {
+		w := davRequest("OPTIONS", "/dav/", "", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("DAV"), "calendar-access")
+		assert.Contains(t, w.Header().Get("Allow"), "REPORT")
+	})
+
+	t.Run("It should list the collection and its todos", func(t *testing.T) {
+		body := `<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getetag/><d:sync-token/><d:unknown/></d:prop></d:propfind>`
+		w := davRequest("PROPFIND", "/dav/todos/", body, map[string]string{"Depth": "1"})
+
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "<c:calendar/>")
+		assert.Contains(t, w.Body.String(), "/dav/todos/"+testData[0].ExternalID+".ics")
+		assert.Contains(t, w.Body.String(), "HTTP/1.1 404 Not Found")
+		assert.Equal(t, len(testData)+1, strings.Count(w.Body.String(), "<d:response>"))
+	})
+
+	tokenResponse := davRequest("PROPFIND", "/dav/todos/", `<d:propfind xmlns:d="DAV:"><d:prop><d:sync-token/></d:prop></d:propfind>`, map[string]string{"Depth": "0"})
+	token := regexp.MustCompile(`urn:todo-app:sync:\d+`).FindString(tokenResponse.Body.String())
+
+	t.Run("It should create a todo from a VTODO", func(t *testing.T) {
+		headers := map[string]string{"Content-Type": "text/calendar", "If-None-Match": "*"}
+		w := davRequest("PUT", resource, vtodo, headers)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, w.Header().Get("ETag"), davRequest("GET", resource, "", nil).Header().Get("ETag"))
+
+		w = davRequest("PUT", resource, vtodo, headers)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	var etag string
+
+	t.Run("It should serve the todo as iCalendar", func(t *testing.T) {
+		w := davRequest("GET", resource, "", nil)
+
+		etag = w.Header().Get("ETag")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, etag)
+		assert.Contains(t, w.Body.String(), "SUMMARY:Water plants\r\n")
+		assert.Contains(t, w.Body.String(), "DUE:20300101T090000Z\r\n")
+	})
+
+	t.Run("It should report the change since the sync token", func(t *testing.T) {
+		body := `<d:sync-collection xmlns:d="DAV:"><d:sync-token>` + token + `</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`
+		w := davRequest("REPORT", "/dav/todos/", body, nil)
+
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), resource)
+		assert.Contains(t, w.Body.String(), etag)
+		assert.Equal(t, 1, strings.Count(w.Body.String(), "<d:response>"))
+
+		token = syncTokenFrom(w.Body.String())
+	})
+
+	t.Run("It should return requested todos with calendar data", func(t *testing.T) {
+		body := `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><c:calendar-data/></d:prop><d:href>` + resource + `</d:href></c:calendar-multiget>`
+		w := davRequest("REPORT", "/dav/todos/", body, nil)
+
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "SUMMARY:Water plants")
+	})
+
+	t.Run("It should refuse a replace with a stale ETag", func(t *testing.T) {
+		w := davRequest("PUT", resource, vtodo, map[string]string{"Content-Type": "text/calendar", "If-Match": `"stale"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = davRequest("PUT", resource, strings.Replace(vtodo, "Water plants", "Water all plants", 1), map[string]string{"Content-Type": "text/calendar", "If-Match": etag})
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, w.Header().Get("ETag"), davRequest("GET", resource, "", nil).Header().Get("ETag"))
+	})
+
+	t.Run("It should refuse to create a todo under a name that is not its ID", func(t *testing.T) {
+		w := davRequest("PUT", "/dav/todos/water-plants.ics", vtodo, calendarHeaders)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "valid-calendar-object-resource")
+
+		w = davRequest("PUT", "/dav/todos/"+uuid.New().String()+".ics", vtodo, calendarHeaders)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "valid-calendar-object-resource")
+	})
+
+	t.Run("It should reject bodies without exactly one VTODO", func(t *testing.T) {
+		w := davRequest("PUT", resource, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n", calendarHeaders)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("It should report deleted todos", func(t *testing.T) {
+		w := davRequest("DELETE", resource, "", nil)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		body := `<d:sync-collection xmlns:d="DAV:"><d:sync-token>` + token + `</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`
+		w = davRequest("REPORT", "/dav/todos/", body, nil)
+
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "<d:href>"+resource+"</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+	})
+
+	t.Run("It should reject an unknown sync token", func(t *testing.T) {
+		body := `<d:sync-collection xmlns:d="DAV:"><d:sync-token>urn:todo-app:sync:999999999</d:sync-token><d:prop/></d:sync-collection>`
+		w := davRequest("REPORT", "/dav/todos/", body, nil)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "valid-sync-token")
+	})
+
+	t.Run("It should return 404 for names that are not todo IDs", func(t *testing.T) {
+		w := davRequest("GET", "/dav/todos/not-a-uuid.ics", "", nil)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/ical"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+// calendarFeedURL builds the absolute subscription URL calendar apps need,
+// honouring a TLS terminating proxy in front of the server.
+func calendarFeedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	feedURL := url.URL{
+		Scheme:   scheme,
+		Host:     c.Request.Host,
+		Path:     "/todos.ics",
+		RawQuery: url.Values{constant.CalendarFeedTokenQueryParam: {token}}.Encode(),
+	}
+
+	return feedURL.String()
+}
+
+// feedServiceFor returns feedService limited to the request's workspace.
+func feedServiceFor(c *gin.Context, feedService *service.CalendarFeedService) *service.CalendarFeedService {
+	return feedService.InWorkspace(middlewares.WorkspaceIDFromContext(c))
+}
+
+func GetCalendarFeeds(feedService *service.CalendarFeedService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feeds, err := feedServiceFor(c, feedService).GetAllFeeds(middlewares.ActorFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedFeeds := make([]types.CalendarFeedResponse, len(feeds))
+
+		for i, feed := range feeds {
+			mappedFeeds[i] = *utils.MapCalendarFeedResponse(&feed)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedFeeds)
+	}
+}
+
+func CreateCalendarFeed(feedService *service.CalendarFeedService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.CalendarFeedInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		newFeed, token, err := feedServiceFor(c, feedService).CreateFeed(input, middlewares.APIKeyFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, types.CalendarFeedCreatedResponse{
+			CalendarFeedResponse: *utils.MapCalendarFeedResponse(newFeed),
+			URL:                  calendarFeedURL(c, token),
+		})
+	}
+}
+
+func DeleteCalendarFeed(feedService *service.CalendarFeedService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		err := feedServiceFor(c, feedService).DeleteFeed(id, middlewares.ActorFromContext(c))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// TodoCalendar serves every todo as a VTODO in an iCalendar feed. Calendar
+// apps cannot send headers, so the feed token comes from the query string.
+func TodoCalendar(todoService *service.TodoService, feedService *service.CalendarFeedService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feed, err := feedService.AuthenticateFeed(c.Query(constant.CalendarFeedTokenQueryParam))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonUnauthorized:
+					respondError(c, http.StatusUnauthorized, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.Header("Content-Type", "text/calendar; charset=utf-8")
+		c.Header("Content-Disposition", `inline; filename="todos.ics"`)
+		c.Status(http.StatusOK)
+
+		encoder := ical.NewEncoder(c.Writer, feed.Name)
+
+		err = encoder.Begin()
+
+		if err == nil {
+			exported := 0
+
+			err = todoService.InWorkspace(feed.WorkspaceID).As(feed.User).WithContext(c.Request.Context()).ExportTodos(types.TodoFilter{}, func(todo types.Todo) error {
+				if err := encoder.Encode(&todo); err != nil {
+					return err
+				}
+
+				exported++
+
+				if exported%constant.ExportBatchSize == 0 {
+					return encoder.Flush()
+				}
+
+				return nil
+			})
+		}
+
+		if err == nil {
+			err = encoder.End()
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.CalendarFeedLogEventErrorKey,
+				"external_id": feed.ExternalID,
+				"error":       err.Error(),
+			}).Error("Calendar feed aborted after streaming started")
+
+			c.Abort()
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestCalendarFeed(t *testing.T, name string) types.CalendarFeedCreatedResponse {
+	jsonValue, _ := json.Marshal(types.CalendarFeedInput{Name: name})
+
+	req, _ := http.NewRequest("POST", "/calendar/feeds", bytes.NewBuffer(jsonValue))
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var feed types.CalendarFeedCreatedResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return feed
+}
+
+func TestTodoCalendar(t *testing.T) {
+	feed := createTestCalendarFeed(t, "My todos")
+
+	feedURL, err := url.Parse(feed.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse feed URL: %v", err)
+	}
+
+	t.Run("It should serve todos as VTODO components", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", feedURL.RequestURI(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "X-WR-CALNAME:My todos\r\n")
+		assert.Contains(t, w.Body.String(), "UID:"+testData[0].ExternalID+"\r\n")
+		assert.Equal(t, len(testData), strings.Count(w.Body.String(), "BEGIN:VTODO"))
+	})
+
+	t.Run("It should record when the feed was used", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/calendar/feeds", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var feeds []types.CalendarFeedResponse
+		json.Unmarshal(w.Body.Bytes(), &feeds)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, feed.ID, feeds[0].ID)
+		assert.NotNil(t, feeds[0].LastUsedAt)
+	})
+
+	t.Run("It should return 401 for an unknown token", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos.ics?token=nope", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("It should stop serving a revoked feed", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/calendar/feeds/"+feed.ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		req, _ = http.NewRequest("GET", feedURL.RequestURI(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("It should stop serving a feed once the key that made it is revoked", func(t *testing.T) {
+		key := createTestAPIKey(t, types.APIKeyInput{Name: "Calendar", Scopes: []string{constant.ScopeTodosRead, constant.ScopeTodosWrite}})
+
+		w := requestAs(key.Key, "POST", "/calendar/feeds", types.CalendarFeedInput{Name: "Key feed"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var keyFeed types.CalendarFeedCreatedResponse
+		json.Unmarshal(w.Body.Bytes(), &keyFeed)
+
+		keyFeedURL, _ := url.Parse(keyFeed.URL)
+
+		w = requestAs("", "GET", keyFeedURL.RequestURI(), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = requestAs("", "DELETE", "/api-keys/"+key.ID, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = requestAs("", "GET", keyFeedURL.RequestURI(), nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("It should import VTODO components from an ics upload", func(t *testing.T) {
+		ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+			"BEGIN:VTODO\r\nUID:x@example.com\r\nSUMMARY:From calendar\r\nDUE:20240501T120000Z\r\nSTATUS:IN-PROCESS\r\nEND:VTODO\r\n" +
+			"END:VCALENDAR\r\n"
+
+		status, report := importTestFile(t, "?dry_run=true", "tasks.ics", ics, nil)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1, report.Total)
+		assert.Equal(t, "would_create", report.Rows[0].Status)
+		assert.Equal(t, "From calendar", report.Rows[0].Title)
+	})
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+// todoExporter writes one export format. begin runs before the first row and
+// end after the last, so formats with framing (a CSV header, a JSON array)
+// can emit it without buffering the rows.
+type todoExporter struct {
+	contentType string
+	extension   string
+	begin       func(c *gin.Context) error
+	row         func(c *gin.Context, todo *types.TodoResponse) error
+	end         func(c *gin.Context) error
+}
+
+// csvCell keeps spreadsheets from running a cell as a formula: values
+// starting with a character that opens one get a leading quote, which
+// spreadsheets take as marking the cell as text.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func newTodoExporter(format string) (*todoExporter, bool) {
+	switch format {
+	case "csv":
+		var writer *csv.Writer
+
+		return &todoExporter{
+			contentType: "text/csv; charset=utf-8",
+			extension:   "csv",
+			begin: func(c *gin.Context) error {
+				writer = csv.NewWriter(c.Writer)
+
+				return writer.Write([]string{"id", "title", "created_at", "due_at", "status", "recurrence"})
+			},
+			row: func(c *gin.Context, todo *types.TodoResponse) error {
+				var dueAt string
+
+				if todo.DueAt != nil {
+					dueAt = todo.DueAt.Format(time.RFC3339Nano)
+				}
+
+				return writer.Write([]string{todo.ID, csvCell(todo.Title), todo.CreatedAt.Format(time.RFC3339Nano), dueAt, todo.Status, csvCell(todo.Recurrence)})
+			},
+			end: func(c *gin.Context) error {
+				writer.Flush()
+
+				return writer.Error()
+			},
+		}, true
+	case "json":
+		first := true
+
+		return &todoExporter{
+			contentType: "application/json; charset=utf-8",
+			extension:   "json",
+			begin: func(c *gin.Context) error {
+				_, err := c.Writer.WriteString("[")
+
+				return err
+			},
+			row: func(c *gin.Context, todo *types.TodoResponse) error {
+				if !first {
+					if _, err := c.Writer.WriteString(","); err != nil {
+						return err
+					}
+				}
+
+				first = false
+
+				data, err := json.Marshal(todo)
+				if err != nil {
+					return err
+				}
+
+				_, err = c.Writer.Write(data)
+
+				return err
+			},
+			end: func(c *gin.Context) error {
+				_, err := c.Writer.WriteString("]\n")
+
+				return err
+			},
+		}, true
+	case "ndjson":
+		var encoder *json.Encoder
+
+		return &todoExporter{
+			contentType: "application/x-ndjson",
+			extension:   "ndjson",
+			begin: func(c *gin.Context) error {
+				encoder = json.NewEncoder(c.Writer)
+
+				return nil
+			},
+			row: func(c *gin.Context, todo *types.TodoResponse) error {
+				return encoder.Encode(todo)
+			},
+			end: func(c *gin.Context) error {
+				return nil
+			},
+		}, true
+	}
+
+	return nil, false
+}
+
+// ExportTodos streams every todo matching the list filters as CSV, a JSON
+// array or newline delimited JSON. Once the first row is written the status
+// can no longer change, so a failure part way through is logged and the
+// response is cut short.
+func ExportTodos(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exporter, ok := newTodoExporter(c.DefaultQuery("format", "csv"))
+		if !ok {
+			respondError(c, http.StatusBadRequest, "format must be one of csv, json, ndjson")
+
+			return
+		}
+
+		filter, err := bindTodoFilter(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		started := false
+
+		start := func() error {
+			started = true
+
+			c.Header("Content-Type", exporter.contentType)
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="todos-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), exporter.extension))
+			c.Status(http.StatusOK)
+
+			return exporter.begin(c)
+		}
+
+		exported := 0
+
+		err = todoServiceFor(c, todoService).ExportTodos(filter, func(todo types.Todo) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			if err := exporter.row(c, utils.MapTodoResponse(&todo)); err != nil {
+				return err
+			}
+
+			exported++
+
+			if exported%constant.ExportBatchSize == 0 {
+				c.Writer.Flush()
+			}
+
+			return nil
+		})
+
+		if err == nil && !started {
+			err = start()
+		}
+
+		if err == nil {
+			err = exporter.end(c)
+		}
+
+		if err == nil {
+			return
+		}
+
+		if !started {
+			if todoErr, ok := err.(service.TodoError); ok {
+				respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event": constant.ExportTodosLogEventErrorKey,
+			"error": err.Error(),
+		}).Error("Export aborted after streaming started")
+
+		c.Abort()
+	}
+}
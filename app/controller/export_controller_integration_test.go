@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportTodos(t *testing.T) {
+	t.Run("It should export todos as csv by default", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/export", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse csv: %v", err)
+		}
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Regexp(t, `^attachment; filename="todos-\d{8}T\d{6}Z\.csv"$`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, []string{"id", "title", "created_at", "due_at", "status", "recurrence"}, records[0])
+		assert.Equal(t, len(testData), len(records)-1)
+	})
+
+	t.Run("It should keep spreadsheets from running titles as formulas", func(t *testing.T) {
+		var created types.TodoResponse
+		json.Unmarshal(requestAs("", "POST", "/todos", types.TodoInput{Title: "=HYPERLINK(\"https://example.com\")"}).Body.Bytes(), &created)
+
+		defer requestAs("", "DELETE", "/todos/"+created.ID, nil)
+
+		req, _ := http.NewRequest("GET", "/todos/export?title_contains=hyperlink", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse csv: %v", err)
+		}
+
+		assert.Len(t, records, 2)
+		assert.Equal(t, "'=HYPERLINK(\"https://example.com\")", records[1][1])
+
+		req, _ = http.NewRequest("GET", "/todos/export?format=json&title_contains=hyperlink", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Contains(t, w.Body.String(), `"title":"=HYPERLINK`)
+	})
+
+	t.Run("It should apply the list filters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/export?format=json&title_contains=task%201", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var todos []types.TodoResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &todos); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %v", err)
+		}
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"Task 10", "Task 1"}, []string{todos[0].Title, todos[1].Title})
+		assert.Len(t, todos, 2)
+	})
+
+	t.Run("It should export an empty json array when nothing matches", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/export?format=json&created_after=2099-01-01T00:00:00Z", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+
+	t.Run("It should export one object per line as ndjson", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/export?format=ndjson", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasSuffix(w.Header().Get("Content-Disposition"), `.ndjson"`))
+
+		lines := 0
+		scanner := bufio.NewScanner(w.Body)
+
+		for scanner.Scan() {
+			var todo types.TodoResponse
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &todo))
+
+			lines++
+		}
+
+		assert.Equal(t, len(testData), lines)
+	})
+
+	t.Run("It should return 400 for an unknown format", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/export?format=xlsx", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
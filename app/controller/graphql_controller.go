@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/graph"
+	"todo-app/app/service"
+)
+
+func GraphQL(todoService *service.TodoService) gin.HandlerFunc {
+	schema, err := graph.NewSchema(todoService)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.GraphQLSchemaLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to build GraphQL schema")
+	}
+
+	return func(c *gin.Context) {
+		var req graph.Request
+
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+
+			if variables := c.Query("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+					respondError(c, http.StatusBadRequest, err.Error())
+
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		if req.Query == "" {
+			respondError(c, http.StatusBadRequest, "Query is required")
+
+			return
+		}
+
+		// GET only needs the read scope, and is not meant to change anything
+		// anyway.
+		if c.Request.Method == http.MethodGet && graph.IsMutation(req) {
+			c.Header("Allow", http.MethodPost)
+			respondError(c, http.StatusMethodNotAllowed, constant.ErrMsgGraphQLMutationOverGet)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, graph.Execute(c.Request.Context(), schema, todoServiceFor(c, todoService), req))
+	}
+}
+
+func GraphiQL() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(graph.GraphiQLPage))
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// importFormatFromFilename guesses the format of an upload whose request did
+// not name one.
+func importFormatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".json":
+		return "json"
+	case ".ics":
+		return "ics"
+	}
+
+	return ""
+}
+
+func ImportTodos(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, constant.ImportMaxUploadSize)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "A file upload is required")
+
+			return
+		}
+
+		format := c.Query("format")
+		if format == "" {
+			format = importFormatFromFilename(fileHeader.Filename)
+		}
+
+		if format == "" {
+			respondError(c, http.StatusBadRequest, "Could not infer the import format, please set format")
+
+			return
+		}
+
+		var mapping *types.TodoImportMapping
+
+		if raw := c.PostForm("mapping"); raw != "" {
+			if format != "json" {
+				respondError(c, http.StatusBadRequest, "mapping is only supported with the json format")
+
+				return
+			}
+
+			mapping = &types.TodoImportMapping{}
+
+			if err := json.Unmarshal([]byte(raw), mapping); err != nil {
+				respondError(c, http.StatusBadRequest, "mapping must be a JSON object: "+err.Error())
+
+				return
+			}
+		}
+
+		opts := types.TodoImportOptions{DedupeKey: c.DefaultQuery("dedupe_key", constant.ImportDedupeTitle)}
+
+		switch opts.DedupeKey {
+		case constant.ImportDedupeNone, constant.ImportDedupeTitle, constant.ImportDedupeID:
+		default:
+			respondError(c, http.StatusBadRequest, "dedupe_key must be one of none, title, id")
+
+			return
+		}
+
+		if raw := c.Query("dry_run"); raw != "" {
+			if opts.DryRun, err = strconv.ParseBool(raw); err != nil {
+				respondError(c, http.StatusBadRequest, "dry_run must be a boolean")
+
+				return
+			}
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		defer file.Close()
+
+		rows, err := service.ParseTodoImport(format, file, mapping)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		report, err := todoServiceFor(c, todoService).ImportTodos(rows, opts)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		status := http.StatusCreated
+		if opts.DryRun {
+			status = http.StatusOK
+		}
+
+		c.IndentedJSON(status, report)
+	}
+}
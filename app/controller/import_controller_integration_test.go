@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todo-app/app/service"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func importTestFile(t *testing.T, query string, filename string, content string, fields map[string]string) (int, types.TodoImportReport) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/todos/import"+query, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var report types.TodoImportReport
+
+	if w.Code == http.StatusOK || w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %v", err)
+		}
+	}
+
+	return w.Code, report
+}
+
+func TestImportTodos(t *testing.T) {
+	todoService := service.NewTodoService(db)
+
+	t.Run("It should report per row results on a dry run", func(t *testing.T) {
+		csv := "id,title,created_at\n" +
+			",Imported 1,2024-01-01T10:00:00Z\n" +
+			",,\n" +
+			",Task 1,\n" +
+			",Imported 1,\n" +
+			",Imported 2,yesterday\n"
+
+		status, report := importTestFile(t, "?dry_run=true", "todos.csv", csv, nil)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 5, report.Total)
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 2, report.Duplicates)
+		assert.Equal(t, 2, report.Invalid)
+		assert.Equal(t, []string{"would_create", "invalid", "duplicate", "duplicate", "invalid"}, []string{
+			report.Rows[0].Status, report.Rows[1].Status, report.Rows[2].Status, report.Rows[3].Status, report.Rows[4].Status,
+		})
+
+		todos, _, _ := todoService.FindTodos(types.TodoFilter{TitleContains: "Imported"})
+		assert.Empty(t, todos)
+	})
+
+	t.Run("It should import ndjson and skip known ids", func(t *testing.T) {
+		id := uuid.New().String()
+		ndjson := `{"id": "` + id + `", "title": "Imported 3"}` + "\n" +
+			`{"id": "` + testData[0].ExternalID + `", "title": "Renamed"}` + "\n"
+
+		status, report := importTestFile(t, "?dedupe_key=id", "todos.ndjson", ndjson, nil)
+
+		t.Cleanup(func() { todoService.DeleteTodo(id) })
+
+		assert.Equal(t, http.StatusCreated, status)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Duplicates)
+
+		todo, err := todoService.GetTodoByID(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Imported 3", todo.Title)
+	})
+
+	t.Run("It should store ids in their canonical form", func(t *testing.T) {
+		id := uuid.New()
+		ndjson := `{"id": "{` + strings.ToUpper(id.String()) + `}", "title": "Imported 6"}` + "\n" +
+			`{"id": "urn:uuid:` + id.String() + `", "title": "Imported 6 again"}` + "\n"
+
+		status, report := importTestFile(t, "", "todos.ndjson", ndjson, nil)
+
+		t.Cleanup(func() { todoService.DeleteTodo(id.String()) })
+
+		assert.Equal(t, http.StatusCreated, status)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Duplicates)
+		assert.Equal(t, id.String(), report.Rows[0].ID)
+
+		todo, err := todoService.GetTodoByID(id.String())
+		assert.NoError(t, err)
+		assert.Equal(t, id.String(), todo.ExternalID)
+	})
+
+	t.Run("It should import a third-party export through a mapping", func(t *testing.T) {
+		export := `{"data": {"tasks": [{"name": "Imported 4", "meta": {"created": "2024-02-01T08:00:00Z"}}]}}`
+		mapping := `{"items": "data.tasks", "title": "name", "created_at": "meta.created"}`
+
+		status, report := importTestFile(t, "", "export.json", export, map[string]string{"mapping": mapping})
+
+		t.Cleanup(func() { todoService.DeleteTodo(report.Rows[0].ID) })
+
+		assert.Equal(t, http.StatusCreated, status)
+		assert.Equal(t, 1, report.Created)
+
+		todo, err := todoService.GetTodoByID(report.Rows[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "2024-02-01T08:00:00Z", todo.CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"))
+	})
+
+	t.Run("It should read a todoist export", func(t *testing.T) {
+		export := `{"items": [{"content": "Imported 5", "added_at": "2024-03-01T09:30:00.000000Z"}]}`
+
+		status, report := importTestFile(t, "?format=todoist&dry_run=true", "todoist.json", export, nil)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "would_create", report.Rows[0].Status)
+		assert.Equal(t, "Imported 5", report.Rows[0].Title)
+	})
+
+	t.Run("It should return 400 for an unreadable file", func(t *testing.T) {
+		status, _ := importTestFile(t, "", "todos.json", "{not json", nil)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("It should return 400 for a csv without a title column", func(t *testing.T) {
+		status, _ := importTestFile(t, "", "todos.csv", "name\nfoo\n", nil)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+func GetLists(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lists, err := todoServiceFor(c, todoService).GetLists()
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedLists := make([]types.TodoListResponse, len(lists))
+
+		for i, list := range lists {
+			mappedLists[i] = *utils.MapTodoListResponse(&list)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedLists)
+	}
+}
+
+func CreateList(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.TodoListInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		list, err := todoServiceFor(c, todoService).CreateList(input)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, utils.MapTodoListResponse(list))
+	}
+}
+
+func GetListByID(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := todoServiceFor(c, todoService).GetList(c.Param("id"))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, utils.MapTodoListResponse(list))
+	}
+}
+
+// DeleteList deletes a list together with its todos.
+func DeleteList(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := todoServiceFor(c, todoService).DeleteList(c.Param("id"))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetListTodos returns the todos of a list, filtered like GetTodos.
+func GetListTodos(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := bindTodoFilter(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		scoped := todoServiceFor(c, todoService)
+
+		list, err := scoped.GetList(c.Param("id"))
+
+		var todos []types.Todo
+
+		if err == nil {
+			filter.ListID = list.ExternalID
+			todos, _, err = scoped.FindTodos(filter)
+		}
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, mapTodoResponses(todos))
+	}
+}
+
+func CreateListTodo(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.TodoInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		newTodo, err := todoServiceFor(c, todoService).CreateTodoInList(c.Param("id"), input.Title)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, utils.MapTodoResponse(newTodo))
+	}
+}
+
+func GetListMembers(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		members, err := todoServiceFor(c, todoService).GetListMembers(c.Param("id"))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedMembers := make([]types.ListMemberResponse, len(members))
+
+		for i, member := range members {
+			mappedMembers[i] = *utils.MapListMemberResponse(&member)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedMembers)
+	}
+}
+
+func RemoveListMember(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := todoServiceFor(c, todoService).RemoveListMember(c.Param("id"), c.Param("userId"))
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func CreateListInvitation(todoService *service.TodoService) gin.HandlerFunc {
+	return createInvitation(todoService, func(scoped *service.TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+		return scoped.CreateListInvitation(id, input)
+	})
+}
+
+func CreateTodoInvitation(todoService *service.TodoService) gin.HandlerFunc {
+	return createInvitation(todoService, func(scoped *service.TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+		return scoped.CreateTodoInvitation(id, input)
+	})
+}
+
+// createInvitation serves both invitation endpoints, which differ only in
+// what the invitation is for. The token is only shown in this response.
+func createInvitation(todoService *service.TodoService, create func(scoped *service.TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.InvitationInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		invitation, token, err := create(todoServiceFor(c, todoService), c.Param("id"), input)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, types.InvitationCreatedResponse{
+			InvitationResponse: *utils.MapInvitationResponse(invitation),
+			Token:              token,
+		})
+	}
+}
+
+func AcceptInvitation(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.AcceptInvitationInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		invitation, err := todoServiceFor(c, todoService).AcceptInvitation(input.Token)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnauthorized:
+					respondError(c, http.StatusUnauthorized, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, utils.MapInvitationResponse(invitation))
+	}
+}
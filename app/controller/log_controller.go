@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/logging"
+	"todo-app/app/middlewares"
+	"todo-app/app/types"
+)
+
+// requireDefaultWorkspaceForLogs lets only requests served in the default
+// workspace touch the log level, which applies to every tenant.
+func requireDefaultWorkspaceForLogs(c *gin.Context) bool {
+	if middlewares.WorkspaceIDFromContext(c) != constant.DefaultWorkspaceID {
+		respondError(c, http.StatusForbidden, constant.ErrMsgLogLevelAdminOnly)
+
+		return false
+	}
+
+	return true
+}
+
+func GetLogLevel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireDefaultWorkspaceForLogs(c) {
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, types.LogLevel{Level: logrus.GetLevel().String()})
+	}
+}
+
+// SetLogLevel changes the level of the standard logger until the app
+// restarts, to look into a problem without redeploying.
+func SetLogLevel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireDefaultWorkspaceForLogs(c) {
+			return
+		}
+
+		var input types.LogLevel
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		level, err := logrus.ParseLevel(input.Level)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		previous := logrus.GetLevel()
+
+		logrus.SetLevel(level)
+
+		// Logged at warning, so the change shows at any usual level.
+		logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"event":    constant.LogLevelLogEventKey,
+			"previous": previous.String(),
+			"level":    level.String(),
+		}).Warn("Log level changed")
+
+		c.IndentedJSON(http.StatusOK, types.LogLevel{Level: level.String()})
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevel(t *testing.T) {
+	previous := logrus.GetLevel()
+	defer logrus.SetLevel(previous)
+
+	admin := createTestAPIKey(t, types.APIKeyInput{Name: "Log admin", Scopes: []string{constant.ScopeLogsManage}}).Key
+	reader := createTestAPIKey(t, types.APIKeyInput{Name: "Log reader", Scopes: []string{constant.ScopeTodosRead}}).Key
+
+	t.Run("It should require a key holding the scope even when authentication is not required", func(t *testing.T) {
+		w := requestAs("", "PUT", "/admin/log-level", types.LogLevel{Level: "trace"})
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = requestAs(reader, "GET", "/admin/log-level", nil)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, previous, logrus.GetLevel())
+	})
+
+	t.Run("It should change the level at runtime", func(t *testing.T) {
+		w := requestAs(admin, "PUT", "/admin/log-level", types.LogLevel{Level: "debug"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+		var level types.LogLevel
+		json.Unmarshal(requestAs(admin, "GET", "/admin/log-level", nil).Body.Bytes(), &level)
+
+		assert.Equal(t, "debug", level.Level)
+	})
+
+	t.Run("It should reject unknown levels", func(t *testing.T) {
+		w := requestAs(admin, "PUT", "/admin/log-level", types.LogLevel{Level: "chatty"})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+	})
+
+	t.Run("It should only be available in the default workspace", func(t *testing.T) {
+		var workspace types.WorkspaceCreatedResponse
+		json.Unmarshal(requestAs("", "POST", "/workspaces", types.WorkspaceInput{Slug: "loggers", Name: "Loggers"}).Body.Bytes(), &workspace)
+
+		w := requestAs(workspace.Key, "PUT", "/admin/log-level", types.LogLevel{Level: "trace"})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), constant.ErrMsgLogLevelAdminOnly)
+		assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+	})
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/ratelimit"
+	"todo-app/app/service"
+	"todo-app/app/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	rules, err := ratelimit.ParseRules("GET /todos=2/1h")
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	limited := gin.New()
+	limited.Use(middlewares.AuthMiddleware(service.NewAPIKeyService(db), nil))
+	limited.Use(middlewares.RateLimitMiddleware(rules, ratelimit.NewPostgresStore(db)))
+	limited.GET("/todos", GetTodos(service.NewTodoService(db)))
+	limited.GET("/lists", GetLists(service.NewTodoService(db)))
+
+	get := func(path string, ip string, key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+
+		if key != "" {
+			req.Header.Set(constant.APIKeyHeader, key)
+		}
+
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("It should answer 429 once a client used up its limit", func(t *testing.T) {
+		for remaining := 1; remaining >= 0; remaining-- {
+			w := get("/todos", "192.0.2.1", "")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, strconv.Itoa(remaining), w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, "2;w=3600", w.Header().Get("RateLimit-Policy"))
+		}
+
+		w := get("/todos", "192.0.2.1", "")
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+		assert.Equal(t, "3600", w.Header().Get("RateLimit-Reset"))
+		assert.Contains(t, w.Body.String(), constant.ErrMsgRateLimited)
+	})
+
+	t.Run("It should count clients apart", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/todos", "192.0.2.2", "").Code)
+
+		key := createTestAPIKey(t, types.APIKeyInput{Name: "Limited"}).Key
+
+		assert.Equal(t, http.StatusOK, get("/todos", "192.0.2.1", key).Code)
+	})
+
+	t.Run("It should not limit routes without a rule", func(t *testing.T) {
+		w := get("/lists", "192.0.2.1", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	})
+}
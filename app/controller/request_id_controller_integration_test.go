@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/logging"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	hook := test.NewLocal(logrus.StandardLogger())
+	defer hook.Reset()
+
+	identified := gin.New()
+	identified.Use(middlewares.RequestIDMiddleware())
+	identified.Use(middlewares.LoggerMiddleware(nil))
+	identified.GET("/todos/:id", GetTodoByID(service.NewTodoService(db)))
+
+	get := func(requestID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/todos/"+uuid.New().String(), nil)
+
+		if requestID != "" {
+			req.Header.Set(constant.RequestIDHeader, requestID)
+		}
+
+		hook.Reset()
+
+		w := httptest.NewRecorder()
+		identified.ServeHTTP(w, req)
+
+		return w
+	}
+
+	assertLogged := func(t *testing.T, requestID string) {
+		entries := hook.AllEntries()
+
+		// The service's not found error and the middleware's request line.
+		if !assert.Len(t, entries, 2) {
+			return
+		}
+
+		assert.Equal(t, constant.GetTodoLogEventErrorKey, entries[0].Data["event"])
+
+		for _, entry := range entries {
+			assert.Equal(t, requestID, entry.Data[logging.RequestIDField], entry.Message)
+		}
+	}
+
+	t.Run("It should echo the caller's request ID and log it", func(t *testing.T) {
+		w := get("caller-1234")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "caller-1234", w.Header().Get(constant.RequestIDHeader))
+		assertLogged(t, "caller-1234")
+	})
+
+	t.Run("It should generate a request ID when there is none", func(t *testing.T) {
+		w := get("")
+
+		requestID := w.Header().Get(constant.RequestIDHeader)
+		_, err := uuid.Parse(requestID)
+
+		assert.NoError(t, err)
+		assertLogged(t, requestID)
+	})
+
+	t.Run("It should replace request IDs it does not accept", func(t *testing.T) {
+		w := get("bad id\r\nforged: yes")
+
+		requestID := w.Header().Get(constant.RequestIDHeader)
+
+		assert.NotContains(t, requestID, " ")
+		assertLogged(t, requestID)
+	})
+
+	t.Run("It should leave the query string out of the request line", func(t *testing.T) {
+		hook.Reset()
+
+		path := "/todos/" + uuid.New().String()
+		req, _ := http.NewRequest("GET", path+"?token=s3cret", nil)
+		identified.ServeHTTP(httptest.NewRecorder(), req)
+
+		entry := hook.LastEntry()
+
+		assert.Equal(t, path, entry.Data["path"])
+		assert.NotContains(t, entry.Data["path"], "s3cret")
+	})
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestAs sends a request with the personal API key of a user.
+func requestAs(key string, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set(constant.APIKeyHeader, key)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestSharing(t *testing.T) {
+	alice := createTestAPIKey(t, types.APIKeyInput{Name: "Alice", UserEmail: "alice@example.com"}).Key
+	bob := createTestAPIKey(t, types.APIKeyInput{Name: "Bob", UserEmail: "bob@example.com"}).Key
+
+	var list types.TodoListResponse
+	var todo types.TodoResponse
+	var private types.TodoResponse
+
+	w := requestAs(alice, "POST", "/lists", types.TodoListInput{Name: "Groceries"})
+	json.Unmarshal(w.Body.Bytes(), &list)
+
+	w = requestAs(alice, "POST", "/lists/"+list.ID+"/todos", types.TodoInput{Title: "Milk"})
+	json.Unmarshal(w.Body.Bytes(), &todo)
+
+	w = requestAs(alice, "POST", "/todos", types.TodoInput{Title: "Diary"})
+	json.Unmarshal(w.Body.Bytes(), &private)
+
+	t.Run("It should make the creator the owner", func(t *testing.T) {
+		assert.Equal(t, constant.RoleOwner, list.Role)
+		assert.Equal(t, list.ID, todo.ListID)
+
+		var todos []types.TodoResponse
+		json.Unmarshal(requestAs(alice, "GET", "/todos", nil).Body.Bytes(), &todos)
+
+		assert.Len(t, todos, 2)
+	})
+
+	t.Run("It should hide todos from users without a role", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "GET", "/lists/"+list.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "GET", "/todos/"+todo.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "PUT", "/todos/"+private.ID, types.TodoInput{Title: "Mine now"}).Code)
+
+		w := requestAs(bob, "GET", "/todos", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	var invitation types.InvitationCreatedResponse
+
+	t.Run("It should only let owners invite", func(t *testing.T) {
+		w := requestAs(bob, "POST", "/lists/"+list.ID+"/invitations", types.InvitationInput{Email: "bob@example.com", Role: constant.RoleOwner})
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = requestAs(alice, "POST", "/lists/"+list.ID+"/invitations", types.InvitationInput{Email: "Bob@example.com", Role: constant.RoleViewer})
+		json.Unmarshal(w.Body.Bytes(), &invitation)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "bob@example.com", invitation.Email)
+		assert.NotEmpty(t, invitation.Token)
+	})
+
+	t.Run("It should only let the invited user accept", func(t *testing.T) {
+		accept := types.AcceptInvitationInput{Token: invitation.Token}
+
+		assert.Equal(t, http.StatusForbidden, requestAs(alice, "POST", "/invitations/accept", accept).Code)
+		assert.Equal(t, http.StatusOK, requestAs(bob, "POST", "/invitations/accept", accept).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "POST", "/invitations/accept", accept).Code)
+	})
+
+	t.Run("It should let viewers read but not write", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, requestAs(bob, "GET", "/todos/"+todo.ID, nil).Code)
+		assert.Equal(t, http.StatusOK, requestAs(bob, "GET", "/lists/"+list.ID+"/todos", nil).Code)
+		assert.Equal(t, http.StatusForbidden, requestAs(bob, "PUT", "/todos/"+todo.ID, types.TodoInput{Title: "Oat milk"}).Code)
+		assert.Equal(t, http.StatusForbidden, requestAs(bob, "POST", "/lists/"+list.ID+"/todos", types.TodoInput{Title: "Cake"}).Code)
+		assert.Equal(t, http.StatusForbidden, requestAs(bob, "DELETE", "/lists/"+list.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "GET", "/todos/"+private.ID, nil).Code)
+	})
+
+	t.Run("It should list members with their roles", func(t *testing.T) {
+		var members []types.ListMemberResponse
+		json.Unmarshal(requestAs(bob, "GET", "/lists/"+list.ID+"/members", nil).Body.Bytes(), &members)
+
+		assert.Len(t, members, 2)
+		assert.Equal(t, "alice@example.com", members[0].Email)
+		assert.Equal(t, constant.RoleOwner, members[0].Role)
+		assert.Equal(t, constant.RoleViewer, members[1].Role)
+
+		w := requestAs(alice, "DELETE", "/lists/"+list.ID+"/members/"+members[1].UserID, nil)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(bob, "GET", "/todos/"+todo.ID, nil).Code)
+	})
+
+	t.Run("It should report forbidden sync changes", func(t *testing.T) {
+		var response types.SyncPushResponse
+
+		w := requestAs(bob, "POST", "/sync", gin.H{"changes": []gin.H{{"id": private.ID, "fields": gin.H{}}}})
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, constant.SyncStatusForbidden, response.Results[0].Status)
+	})
+
+	t.Run("It should delete a list with its todos", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, requestAs(alice, "DELETE", "/lists/"+list.ID, nil).Code)
+		assert.Equal(t, http.StatusNoContent, requestAs(alice, "DELETE", "/todos/"+private.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(alice, "GET", "/todos/"+todo.ID, nil).Code)
+	})
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+func mapTodoResponses(todos []types.Todo) []types.TodoResponse {
+	mapped := make([]types.TodoResponse, len(todos))
+
+	for i := range todos {
+		mapped[i] = *utils.MapTodoResponse(&todos[i])
+	}
+
+	return mapped
+}
+
+// GetSyncChanges returns what changed since the token in the since query
+// parameter, or everything when it is absent, along with the token to send
+// next time. At most SyncMaxChanges todos are returned; more reports that
+// the client should pull again with the token right away. Clients holding a
+// token the server cannot resume from get 410 and must resync from scratch.
+func GetSyncChanges(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var since int64
+
+		if raw := c.Query(constant.SyncTokenQueryParam); raw != "" {
+			var err error
+
+			if since, err = strconv.ParseInt(raw, 10, 64); err != nil || since < 0 {
+				respondError(c, http.StatusBadRequest, constant.ErrMsgInvalidSyncToken)
+
+				return
+			}
+		}
+
+		changes, err := todoServiceFor(c, todoService).ChangesSince(since, constant.SyncMaxChanges)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonPreconditionFailed:
+					respondError(c, http.StatusGone, todoErr.Message)
+				default:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		deleted := changes.Deleted
+		if deleted == nil {
+			deleted = []string{}
+		}
+
+		c.IndentedJSON(http.StatusOK, types.SyncPullResponse{
+			Token:   strconv.FormatInt(changes.Seq, 10),
+			Created: mapTodoResponses(changes.Created),
+			Updated: mapTodoResponses(changes.Updated),
+			Deleted: deleted,
+			More:    changes.More,
+		})
+	}
+}
+
+// PushSyncChanges applies a batch of client changes. Conflicts and invalid
+// changes are reported per change; the client pulls afterwards to pick up the
+// merged state.
+func PushSyncChanges(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.SyncPushInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		if len(input.Changes) > constant.SyncMaxChanges {
+			respondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("At most %d changes can be sent at once", constant.SyncMaxChanges))
+
+			return
+		}
+
+		results, err := todoServiceFor(c, todoService).ApplySyncChanges(input.Changes)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, types.SyncPushResponse{Results: results})
+	}
+}
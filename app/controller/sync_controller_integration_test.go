@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func pullSync(t *testing.T, since string) types.SyncPullResponse {
+	path := "/sync"
+	if since != "" {
+		path += "?since=" + since
+	}
+
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var pulled types.SyncPullResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &pulled); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return pulled
+}
+
+func pushSync(t *testing.T, changes ...types.SyncChange) []types.SyncChangeResult {
+	jsonValue, _ := json.Marshal(types.SyncPushInput{Changes: changes})
+
+	req, _ := http.NewRequest("POST", "/sync", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var pushed types.SyncPushResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &pushed); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return pushed.Results
+}
+
+func TestSync(t *testing.T) {
+	id := uuid.New().String()
+	edited := time.Now().Add(-time.Hour)
+
+	initial := pullSync(t, "")
+
+	t.Run("It should return every todo on the first pull", func(t *testing.T) {
+		assert.Len(t, initial.Created, len(testData))
+		assert.Empty(t, initial.Updated)
+		assert.Empty(t, initial.Deleted)
+		assert.NotEmpty(t, initial.Token)
+		assert.False(t, initial.More)
+	})
+
+	t.Run("It should create todos pushed by the client", func(t *testing.T) {
+		results := pushSync(t, types.SyncChange{
+			ID: id,
+			Fields: types.SyncTodoFields{
+				Title:  &types.SyncStringField{Value: "Written offline", ModifiedAt: edited},
+				Status: &types.SyncStringField{Value: constant.TodoStatusInProcess, ModifiedAt: edited},
+			},
+		})
+
+		assert.Equal(t, constant.SyncStatusCreated, results[0].Status)
+		assert.Equal(t, "Written offline", results[0].Todo.Title)
+
+		pulled := pullSync(t, initial.Token)
+
+		assert.Len(t, pulled.Created, 1)
+		assert.Equal(t, id, pulled.Created[0].ID)
+	})
+
+	t.Run("It should keep newer server fields and report them", func(t *testing.T) {
+		results := pushSync(t, types.SyncChange{
+			ID: id,
+			Fields: types.SyncTodoFields{
+				Title:  &types.SyncStringField{Value: "Stale title", ModifiedAt: edited.Add(-time.Minute)},
+				Status: &types.SyncStringField{Value: constant.TodoStatusCompleted, ModifiedAt: edited.Add(time.Minute)},
+			},
+		})
+
+		assert.Equal(t, constant.SyncStatusConflict, results[0].Status)
+		assert.Equal(t, "title", results[0].Conflicts[0].Field)
+		assert.Equal(t, "Written offline", results[0].Todo.Title)
+		assert.Equal(t, constant.TodoStatusCompleted, results[0].Todo.Status)
+	})
+
+	t.Run("It should report invalid changes without failing the batch", func(t *testing.T) {
+		results := pushSync(t,
+			types.SyncChange{ID: "not-a-uuid"},
+			types.SyncChange{ID: uuid.New().String(), Fields: types.SyncTodoFields{Status: &types.SyncStringField{Value: constant.TodoStatusCompleted, ModifiedAt: edited}}},
+		)
+
+		assert.Equal(t, constant.SyncStatusInvalid, results[0].Status)
+		assert.Equal(t, []string{"title is required"}, results[1].Errors)
+	})
+
+	t.Run("It should not let an older deletion win over newer edits", func(t *testing.T) {
+		results := pushSync(t, types.SyncChange{ID: id, Deleted: true, DeletedAt: &edited})
+
+		assert.Equal(t, constant.SyncStatusConflict, results[0].Status)
+	})
+
+	t.Run("It should tombstone deleted todos", func(t *testing.T) {
+		now := time.Now()
+		results := pushSync(t, types.SyncChange{ID: id, Deleted: true, DeletedAt: &now})
+
+		assert.Equal(t, constant.SyncStatusDeleted, results[0].Status)
+
+		pulled := pullSync(t, initial.Token)
+
+		assert.Empty(t, pulled.Created)
+		assert.Equal(t, []string{id}, pulled.Deleted)
+	})
+
+	t.Run("It should page a pull of more todos than it returns at once", func(t *testing.T) {
+		start := pullSync(t, initial.Token).Token
+		now := time.Now()
+
+		var created, deleted []types.SyncChange
+
+		for i := 0; i <= constant.SyncMaxChanges; i++ {
+			id := uuid.New().String()
+
+			created = append(created, types.SyncChange{ID: id, Fields: types.SyncTodoFields{
+				Title: &types.SyncStringField{Value: "Paged", ModifiedAt: now},
+			}})
+			deleted = append(deleted, types.SyncChange{ID: id, Deleted: true, DeletedAt: &now})
+		}
+
+		pushSync(t, created[:constant.SyncMaxChanges]...)
+		pushSync(t, created[constant.SyncMaxChanges:]...)
+
+		first := pullSync(t, start)
+
+		assert.Len(t, first.Created, constant.SyncMaxChanges)
+		assert.True(t, first.More)
+
+		second := pullSync(t, first.Token)
+
+		assert.Len(t, second.Created, 1)
+		assert.False(t, second.More)
+
+		pushSync(t, deleted[:constant.SyncMaxChanges]...)
+		pushSync(t, deleted[constant.SyncMaxChanges:]...)
+	})
+
+	t.Run("It should return 410 for a token from the future", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/sync?since=999999999", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGone, w.Code)
+	})
+}
@@ -1,29 +1,66 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 
+	"todo-app/app/middlewares"
 	"todo-app/app/service"
 	"todo-app/app/types"
 	"todo-app/app/utils"
 )
 
-func isValidUUID(id string) bool {
-	_, err := uuid.Parse(id)
+func respondError(c *gin.Context, httpStatus int, errMsg string) {
+	c.AbortWithStatusJSON(httpStatus, gin.H{"message": errMsg})
+}
 
-	return err == nil
+// todoServiceFor returns todoService limited to the request's workspace and
+// acting for the user behind its API key, so tenant isolation and access
+// control apply to everything it does. Its statements run in the request's
+// context.
+func todoServiceFor(c *gin.Context, todoService *service.TodoService) *service.TodoService {
+	return todoService.InWorkspace(middlewares.WorkspaceIDFromContext(c)).As(middlewares.ActorFromContext(c)).WithContext(c.Request.Context())
 }
 
-func respondError(c *gin.Context, httpStatus int, errMsg string) {
-	c.AbortWithStatusJSON(httpStatus, gin.H{"message": errMsg})
+// bindTodoFilter reads the optional title_contains, created_after and
+// created_before query parameters shared by the list and export endpoints.
+func bindTodoFilter(c *gin.Context) (types.TodoFilter, error) {
+	filter := types.TodoFilter{TitleContains: c.Query("title_contains")}
+
+	if raw := c.Query("created_after"); raw != "" {
+		after, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("created_after must be an RFC 3339 date-time")
+		}
+
+		filter.CreatedAfter = &after
+	}
+
+	if raw := c.Query("created_before"); raw != "" {
+		before, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("created_before must be an RFC 3339 date-time")
+		}
+
+		filter.CreatedBefore = &before
+	}
+
+	return filter, nil
 }
 
 func GetTodos(todoService *service.TodoService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		todos, err := todoService.GetAllTodos()
+		filter, err := bindTodoFilter(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		todos, _, err := todoServiceFor(c, todoService).FindTodos(filter)
 
 		if err != nil {
 			if todoErr, ok := err.(service.TodoError); ok {
@@ -60,7 +97,7 @@ func CreateTodo(todoService *service.TodoService) gin.HandlerFunc {
 			return
 		}
 
-		newTodo, err := todoService.CreateTodo(input.Title)
+		newTodo, err := todoServiceFor(c, todoService).CreateTodo(input.Title)
 
 		if err != nil {
 			if todoErr, ok := err.(service.TodoError); ok {
@@ -85,19 +122,15 @@ func GetTodoByID(todoService *service.TodoService) gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		if !isValidUUID(id) {
-			respondError(c, http.StatusBadRequest, "Invalid ID")
-
-			return
-		}
-
-		todo, err := todoService.GetTodoByID(id)
+		todo, err := todoServiceFor(c, todoService).GetTodoByID(id)
 
 		if err != nil {
 			if todoErr, ok := err.(service.TodoError); ok {
 				switch todoErr.Reason {
 				case service.ReasonNotFound:
 					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
 				case service.ReasonUnknown:
 					respondError(c, http.StatusInternalServerError, todoErr.Message)
 				}
@@ -120,25 +153,21 @@ func UpdateTodo(todoService *service.TodoService) gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		if !isValidUUID(id) {
-			respondError(c, http.StatusBadRequest, "Invalid ID")
-
-			return
-		}
-
 		if err := c.ShouldBindJSON(&todoInput); err != nil {
 			respondError(c, http.StatusBadRequest, err.Error())
 
 			return
 		}
 
-		updatedTodo, err := todoService.UpdateTodo(id, todoInput.Title)
+		updatedTodo, err := todoServiceFor(c, todoService).UpdateTodo(id, todoInput.Title)
 
 		if err != nil {
 			if todoErr, ok := err.(service.TodoError); ok {
 				switch todoErr.Reason {
 				case service.ReasonNotFound:
 					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
 				case service.ReasonUnknown:
 					respondError(c, http.StatusInternalServerError, todoErr.Message)
 				}
@@ -160,19 +189,15 @@ func DeleteTodo(todoService *service.TodoService) gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		if !isValidUUID(id) {
-			respondError(c, http.StatusBadRequest, "Invalid ID")
-
-			return
-		}
-
-		err := todoService.DeleteTodo(id)
+		err := todoServiceFor(c, todoService).DeleteTodo(id)
 
 		if err != nil {
 			if todoErr, ok := err.(service.TodoError); ok {
 				switch todoErr.Reason {
 				case service.ReasonNotFound:
 					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonForbidden:
+					respondError(c, http.StatusForbidden, todoErr.Message)
 				case service.ReasonUnknown:
 					respondError(c, http.StatusInternalServerError, todoErr.Message)
 				}
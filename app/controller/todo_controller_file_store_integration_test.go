@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"testing"
+
+	"todo-app/app/filestore"
+	"todo-app/app/middlewares"
+	"todo-app/app/openapi"
+	"todo-app/app/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// setupFileStoreRouter serves the todo routes from a file store in dir seeded
+// with testData, the way the router does with DB_TYPE=file: without the
+// auth and workspace middlewares, which need a database.
+func setupFileStoreRouter(t *testing.T, dir string) *gin.Engine {
+	store, err := filestore.Open(dir, filestore.Options{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { store.Close() })
+
+	todoService := service.NewFileTodoService(store)
+
+	for _, todo := range testData {
+		_, _, err := todoService.PutTodo(todo, nil)
+		require.NoError(t, err)
+	}
+
+	validator, err := openapi.NewValidator(true)
+	require.NoError(t, err)
+
+	fileRouter := gin.New()
+	fileRouter.Use(middlewares.ValidationMiddleware(validator))
+
+	fileRouter.GET("/todos", GetTodos(todoService))
+	fileRouter.GET("/todos/:id", GetTodoByID(todoService))
+	fileRouter.POST("/todos", CreateTodo(todoService))
+	fileRouter.PUT("/todos/:id", UpdateTodo(todoService))
+	fileRouter.DELETE("/todos/:id", DeleteTodo(todoService))
+
+	return fileRouter
+}
+
+// TestTodosOnFileStore runs the todo tests against the file store in place of
+// the database.
+func TestTodosOnFileStore(t *testing.T) {
+	dbRouter := router
+
+	defer func() { router = dbRouter }()
+
+	for name, test := range map[string]func(t *testing.T){
+		"GetTodos":    TestGetTodos,
+		"GetTodoByID": TestGetTodoByID,
+		"CreateTodo":  TestCreateTodo,
+		"UpdateTodo":  TestUpdateTodo,
+		"DeleteTodo":  TestDeleteTodo,
+	} {
+		t.Run(name, func(t *testing.T) {
+			router = setupFileStoreRouter(t, t.TempDir())
+
+			test(t)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	traced := gin.New()
+	traced.Use(middlewares.TracingMiddleware())
+	traced.GET("/todos/:id", GetTodoByID(service.NewTodoService(db)))
+
+	t.Run("It should continue the caller's trace down to the SQL statements", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/"+testData[0].ExternalID, nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		w := httptest.NewRecorder()
+		traced.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var server sdktrace.ReadOnlySpan
+		var statements []sdktrace.ReadOnlySpan
+
+		for _, span := range recorder.Ended() {
+			switch span.SpanKind() {
+			case trace.SpanKindServer:
+				server = span
+			case trace.SpanKindClient:
+				statements = append(statements, span)
+			}
+		}
+
+		if !assert.NotNil(t, server) {
+			return
+		}
+
+		assert.Equal(t, "GET /todos/:id", server.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.Contains(t, server.Attributes(), semconv.HTTPResponseStatusCode(http.StatusOK))
+		assert.Equal(t, "response.write", server.Events()[0].Name)
+
+		assert.NotEmpty(t, statements)
+
+		for _, statement := range statements {
+			assert.Equal(t, server.SpanContext().SpanID(), statement.Parent().SpanID(), statement.Name())
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+// webhookServiceFor returns webhookService limited to the request's workspace.
+func webhookServiceFor(c *gin.Context, webhookService *service.WebhookService) *service.WebhookService {
+	return webhookService.InWorkspace(middlewares.WorkspaceIDFromContext(c))
+}
+
+func GetWebhooks(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks, err := webhookServiceFor(c, webhookService).GetAllWebhooks()
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedWebhooks := make([]types.WebhookResponse, len(webhooks))
+
+		for i, wh := range webhooks {
+			mappedWebhooks[i] = *utils.MapWebhookResponse(&wh)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedWebhooks)
+	}
+}
+
+func CreateWebhook(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.WebhookInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		newWebhook, err := webhookServiceFor(c, webhookService).CreateWebhook(input)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, types.WebhookCreatedResponse{
+			WebhookResponse: *utils.MapWebhookResponse(newWebhook),
+			Secret:          newWebhook.Secret,
+		})
+	}
+}
+
+func GetWebhookByID(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		webhook, err := webhookServiceFor(c, webhookService).GetWebhookByID(id)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, utils.MapWebhookResponse(webhook))
+	}
+}
+
+func UpdateWebhook(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input types.WebhookInput
+
+		id := c.Param("id")
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		updatedWebhook, err := webhookServiceFor(c, webhookService).UpdateWebhook(id, input)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, utils.MapWebhookResponse(updatedWebhook))
+	}
+}
+
+func DeleteWebhook(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		err := webhookServiceFor(c, webhookService).DeleteWebhook(id)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func GetWebhookDeliveries(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		deliveries, err := webhookServiceFor(c, webhookService).GetDeliveries(id)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedDeliveries := make([]types.WebhookDeliveryResponse, len(deliveries))
+
+		for i, d := range deliveries {
+			mappedDeliveries[i] = *utils.MapWebhookDeliveryResponse(&d)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedDeliveries)
+	}
+}
+
+func RedeliverWebhookDelivery(webhookService *service.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		deliveryID := c.Param("deliveryId")
+
+		delivery, err := webhookServiceFor(c, webhookService).Redeliver(id, deliveryID)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonNotFound:
+					respondError(c, http.StatusNotFound, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusAccepted, utils.MapWebhookDeliveryResponse(delivery))
+	}
+}
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestWebhook(t *testing.T, url string, eventTypes []string) types.WebhookCreatedResponse {
+	jsonValue, _ := json.Marshal(types.WebhookInput{URL: url, EventTypes: eventTypes})
+
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBuffer(jsonValue))
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var webhook types.WebhookCreatedResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &webhook); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return webhook
+}
+
+func getTestDeliveries(t *testing.T, webhookID string) []types.WebhookDeliveryResponse {
+	req, _ := http.NewRequest("GET", "/webhooks/"+webhookID+"/deliveries", nil)
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var deliveries []types.WebhookDeliveryResponse
+
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+
+	return deliveries
+}
+
+func TestCreateWebhook(t *testing.T) {
+	t.Run("It should create a webhook and return its secret once", func(t *testing.T) {
+		webhook := createTestWebhook(t, "http://example.com/hook", []string{constant.TodoEventCreated})
+
+		assert.NotEmpty(t, webhook.ID)
+		assert.NotEmpty(t, webhook.Secret)
+		assert.True(t, webhook.Active)
+
+		req, _ := http.NewRequest("GET", "/webhooks/"+webhook.ID, nil)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), webhook.Secret)
+	})
+
+	t.Run("It should return 400 if url is invalid", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(types.WebhookInput{URL: "not a url"})
+
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBuffer(jsonValue))
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("It should return 400 if event type is unknown", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(types.WebhookInput{URL: "http://example.com/hook", EventTypes: []string{"todo.exploded"}})
+
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBuffer(jsonValue))
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestWebhookOutbox(t *testing.T) {
+	createdOnly := createTestWebhook(t, "http://example.com/created", []string{constant.TodoEventCreated})
+	deletedOnly := createTestWebhook(t, "http://example.com/deleted", []string{constant.TodoEventDeleted})
+
+	jsonValue, _ := json.Marshal(types.TodoInput{Title: "Webhook todo"})
+
+	req, _ := http.NewRequest("POST", "/todos", bytes.NewBuffer(jsonValue))
+
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	t.Run("It should enqueue deliveries only for matching subscriptions", func(t *testing.T) {
+		deliveries := getTestDeliveries(t, createdOnly.ID)
+
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, constant.TodoEventCreated, deliveries[0].EventType)
+		assert.Equal(t, constant.WebhookDeliveryStatusPending, deliveries[0].Status)
+
+		assert.Len(t, getTestDeliveries(t, deletedOnly.ID), 0)
+	})
+
+	t.Run("It should reset a delivery on redeliver", func(t *testing.T) {
+		deliveries := getTestDeliveries(t, createdOnly.ID)
+
+		_, err := db.Exec("UPDATE webhook_deliveries SET status = $1, attempts = 8 WHERE external_id = $2", constant.WebhookDeliveryStatusDead, deliveries[0].ID)
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest("POST", "/webhooks/"+createdOnly.ID+"/deliveries/"+deliveries[0].ID+"/redeliver", nil)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		var delivery types.WebhookDeliveryResponse
+
+		if err := json.Unmarshal(w.Body.Bytes(), &delivery); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %v", err)
+		}
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, constant.WebhookDeliveryStatusPending, delivery.Status)
+		assert.Equal(t, 0, delivery.Attempts)
+	})
+
+	t.Run("It should return 404 when redelivering an unknown delivery", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/webhooks/"+createdOnly.ID+"/deliveries/"+uuid.New().String()+"/redeliver", nil)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	for _, webhook := range []types.WebhookCreatedResponse{createdOnly, deletedOnly} {
+		req, _ := http.NewRequest("DELETE", "/webhooks/"+webhook.ID, nil)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	receivedBody := make(chan []byte, 1)
+
+	var attempts atomic.Int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		receivedBody <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	webhook := createTestWebhook(t, receiver.URL, nil)
+
+	jsonValue, _ := json.Marshal(types.TodoInput{Title: "Dispatched todo"})
+
+	req, _ := http.NewRequest("POST", "/todos", bytes.NewBuffer(jsonValue))
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dispatcher := service.NewWebhookDispatcher(db, &types.Config{
+		WebhookPollInterval: 50 * time.Millisecond,
+		WebhookTimeout:      time.Second,
+		WebhookMaxAttempts:  3,
+		WebhookBaseBackoff:  time.Second,
+		WebhookMaxBackoff:   time.Minute,
+	})
+
+	go dispatcher.Run(ctx)
+
+	// A second instance's dispatcher, which must not send what the first
+	// claimed.
+	go service.NewWebhookDispatcher(db, &types.Config{
+		WebhookPollInterval: 50 * time.Millisecond,
+		WebhookTimeout:      time.Second,
+		WebhookMaxAttempts:  3,
+		WebhookBaseBackoff:  time.Second,
+		WebhookMaxBackoff:   time.Minute,
+	}).Run(ctx)
+
+	t.Run("It should deliver a signed payload", func(t *testing.T) {
+		select {
+		case r := <-received:
+			body := <-receivedBody
+			timestamp, err := strconv.ParseInt(r.Header.Get(constant.WebhookTimestampHeader), 10, 64)
+
+			assert.NoError(t, err)
+			assert.Equal(t, constant.TodoEventCreated, r.Header.Get(constant.WebhookEventHeader))
+			assert.Equal(t, "sha256="+service.SignWebhookPayload(webhook.Secret, timestamp, body), r.Header.Get(constant.WebhookSignatureHeader))
+		case <-time.After(5 * time.Second):
+			t.Fatal("Webhook was not delivered")
+		}
+	})
+
+	t.Run("It should mark the delivery as delivered", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			deliveries := getTestDeliveries(t, webhook.ID)
+
+			return len(deliveries) == 1 && deliveries[0].Status == constant.WebhookDeliveryStatusDelivered
+		}, 5*time.Second, 50*time.Millisecond)
+
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+}
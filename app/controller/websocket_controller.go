@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// scopesFromContext returns the scopes of the key the request was
+// authenticated with, or nil.
+func scopesFromContext(c *gin.Context) []string {
+	if key := middlewares.APIKeyFromContext(c); key != nil {
+		return key.Scopes
+	}
+
+	return nil
+}
+
+func isValidUUID(id string) bool {
+	_, err := uuid.Parse(id)
+
+	return err == nil
+}
+
+// wsConnection is one socket. scopes are those of the key it was opened
+// with, none for an unscoped key or none at all: the route only asks for the
+// read scope, so writes check for the write scope themselves.
+type wsConnection struct {
+	conn        *websocket.Conn
+	todoService *service.TodoService
+	scopes      []string
+	send        chan types.WebSocketServerMessage
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+	unsubscribe func()
+}
+
+// enqueue hands a message to the write loop. A full queue means the client is
+// not reading fast enough, so the connection is closed instead of buffering
+// without bound.
+func (ws *wsConnection) enqueue(msg types.WebSocketServerMessage) bool {
+	select {
+	case <-ws.done:
+		return false
+	default:
+	}
+
+	select {
+	case ws.send <- msg:
+		return true
+	default:
+		ws.close(websocket.ClosePolicyViolation, "slow consumer")
+
+		return false
+	}
+}
+
+func (ws *wsConnection) close(code int, reason string) {
+	ws.closeOnce.Do(func() {
+		ws.closeCode = code
+		ws.closeReason = reason
+		close(ws.done)
+	})
+}
+
+func (ws *wsConnection) writeLoop() {
+	ticker := time.NewTicker(constant.WebSocketPingInterval)
+
+	defer func() {
+		ticker.Stop()
+		ws.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-ws.send:
+			ws.conn.SetWriteDeadline(time.Now().Add(constant.WebSocketWriteWait))
+
+			if err := ws.conn.WriteJSON(msg); err != nil {
+				ws.close(websocket.CloseAbnormalClosure, err.Error())
+
+				return
+			}
+		case <-ticker.C:
+			ws.conn.SetWriteDeadline(time.Now().Add(constant.WebSocketWriteWait))
+
+			if err := ws.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				ws.close(websocket.CloseAbnormalClosure, err.Error())
+
+				return
+			}
+		case <-ws.done:
+			ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(ws.closeCode, ws.closeReason), time.Now().Add(constant.WebSocketWriteWait))
+
+			return
+		}
+	}
+}
+
+func (ws *wsConnection) subscribe() {
+	if ws.unsubscribe != nil {
+		return
+	}
+
+	events, unsubscribe := ws.todoService.Events.Subscribe(constant.WebSocketSendQueueSize)
+	cancelled := make(chan struct{})
+
+	ws.unsubscribe = func() {
+		close(cancelled)
+		unsubscribe()
+	}
+
+	go func() {
+		for event := range events {
+			if !ws.todoService.VisibleEvent(event) {
+				continue
+			}
+
+			msg := types.WebSocketServerMessage{Type: "event", Event: event.Type}
+
+			if event.Todo != nil {
+				msg.Payload = utils.MapTodoResponse(event.Todo)
+			} else {
+				msg.Payload = gin.H{"id": event.ID}
+			}
+
+			if !ws.enqueue(msg) {
+				return
+			}
+		}
+
+		// The bus closes the channel of subscribers that fall behind.
+		select {
+		case <-cancelled:
+		default:
+			ws.close(websocket.ClosePolicyViolation, "slow consumer")
+		}
+	}()
+}
+
+func (ws *wsConnection) ack(correlationID string, payload interface{}) {
+	ws.enqueue(types.WebSocketServerMessage{Type: "ack", CorrelationID: correlationID, Payload: payload})
+}
+
+func (ws *wsConnection) fail(correlationID string, status int, message string) {
+	ws.enqueue(types.WebSocketServerMessage{
+		Type:          "error",
+		CorrelationID: correlationID,
+		Error:         &types.WebSocketError{Status: status, Message: message},
+	})
+}
+
+func (ws *wsConnection) failWithServiceError(correlationID string, err error) {
+	if todoErr, ok := err.(service.TodoError); ok {
+		switch todoErr.Reason {
+		case service.ReasonNotFound:
+			ws.fail(correlationID, http.StatusNotFound, todoErr.Message)
+		case service.ReasonUnauthorized:
+			ws.fail(correlationID, http.StatusUnauthorized, todoErr.Message)
+		case service.ReasonPreconditionFailed:
+			ws.fail(correlationID, http.StatusPreconditionFailed, todoErr.Message)
+		case service.ReasonForbidden:
+			ws.fail(correlationID, http.StatusForbidden, todoErr.Message)
+		case service.ReasonConflict:
+			ws.fail(correlationID, http.StatusConflict, todoErr.Message)
+		case service.ReasonBadGateway:
+			ws.fail(correlationID, http.StatusBadGateway, todoErr.Message)
+		default:
+			ws.fail(correlationID, http.StatusInternalServerError, todoErr.Message)
+		}
+
+		return
+	}
+
+	ws.fail(correlationID, http.StatusInternalServerError, err.Error())
+}
+
+// mayWrite reports whether the key of the socket holds the write scope, as
+// RequireScope would for the matching HTTP routes.
+func (ws *wsConnection) mayWrite() bool {
+	return len(ws.scopes) == 0 || slices.Contains(ws.scopes, constant.ScopeTodosWrite)
+}
+
+func (ws *wsConnection) handle(msg types.WebSocketClientMessage) {
+	var payload types.WebSocketTodoPayload
+
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			ws.fail(msg.CorrelationID, http.StatusBadRequest, err.Error())
+
+			return
+		}
+	}
+
+	if (msg.Type == "create" || msg.Type == "update" || msg.Type == "delete") && !ws.mayWrite() {
+		ws.fail(msg.CorrelationID, http.StatusForbidden, constant.APIKeyForbiddenMessage)
+
+		return
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		ws.subscribe()
+		ws.ack(msg.CorrelationID, nil)
+	case "unsubscribe":
+		if ws.unsubscribe != nil {
+			ws.unsubscribe()
+			ws.unsubscribe = nil
+		}
+
+		ws.ack(msg.CorrelationID, nil)
+	case "create":
+		if payload.Title == "" {
+			ws.fail(msg.CorrelationID, http.StatusBadRequest, "Title is required")
+
+			return
+		}
+
+		newTodo, err := ws.todoService.CreateTodo(payload.Title)
+		if err != nil {
+			ws.failWithServiceError(msg.CorrelationID, err)
+
+			return
+		}
+
+		ws.ack(msg.CorrelationID, utils.MapTodoResponse(newTodo))
+	case "update":
+		if !isValidUUID(payload.ID) {
+			ws.fail(msg.CorrelationID, http.StatusBadRequest, "Invalid ID")
+
+			return
+		}
+
+		if payload.Title == "" {
+			ws.fail(msg.CorrelationID, http.StatusBadRequest, "Title is required")
+
+			return
+		}
+
+		updatedTodo, err := ws.todoService.UpdateTodo(payload.ID, payload.Title)
+		if err != nil {
+			ws.failWithServiceError(msg.CorrelationID, err)
+
+			return
+		}
+
+		ws.ack(msg.CorrelationID, utils.MapTodoResponse(updatedTodo))
+	case "delete":
+		if !isValidUUID(payload.ID) {
+			ws.fail(msg.CorrelationID, http.StatusBadRequest, "Invalid ID")
+
+			return
+		}
+
+		if err := ws.todoService.DeleteTodo(payload.ID); err != nil {
+			ws.failWithServiceError(msg.CorrelationID, err)
+
+			return
+		}
+
+		ws.ack(msg.CorrelationID, gin.H{"id": payload.ID})
+	default:
+		ws.fail(msg.CorrelationID, http.StatusBadRequest, "Unknown message type")
+	}
+}
+
+func TodoWebSocket(todoService *service.TodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.WebSocketLogEventErrorKey,
+				"error": err.Error(),
+			}).Error("Failed to upgrade connection")
+
+			return
+		}
+
+		ws := &wsConnection{
+			conn:        conn,
+			todoService: todoServiceFor(c, todoService),
+			scopes:      scopesFromContext(c),
+			send:        make(chan types.WebSocketServerMessage, constant.WebSocketSendQueueSize),
+			done:        make(chan struct{}),
+		}
+
+		defer func() {
+			if ws.unsubscribe != nil {
+				ws.unsubscribe()
+			}
+
+			ws.close(websocket.CloseNormalClosure, "")
+		}()
+
+		go ws.writeLoop()
+
+		conn.SetReadLimit(constant.WebSocketMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(constant.WebSocketPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(constant.WebSocketPongWait))
+		})
+
+		logrus.WithFields(logrus.Fields{
+			"event": constant.WebSocketLogEventKey,
+			"ip":    c.ClientIP(),
+		}).Debug("WebSocket connection opened")
+
+		for {
+			var msg types.WebSocketClientMessage
+
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if err := json.Unmarshal(data, &msg); err != nil {
+				ws.fail("", http.StatusBadRequest, err.Error())
+			} else {
+				ws.handle(msg)
+			}
+
+			select {
+			case <-ws.done:
+				return
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialTestWebSocket(t *testing.T) (*websocket.Conn, func()) {
+	server := httptest.NewServer(router)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("Failed to dial websocket: %v", err)
+	}
+
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func sendTestWebSocketMessage(t *testing.T, conn *websocket.Conn, msgType string, correlationID string, payload interface{}) {
+	raw, _ := json.Marshal(payload)
+
+	if err := conn.WriteJSON(types.WebSocketClientMessage{Type: msgType, CorrelationID: correlationID, Payload: raw}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+}
+
+// readTestWebSocketMessage returns the next message matching the predicate,
+// skipping anything else the server sent in between.
+func readTestWebSocketMessage(t *testing.T, conn *websocket.Conn, match func(map[string]interface{}) bool) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for {
+		var msg map[string]interface{}
+
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+func withCorrelationID(id string) func(map[string]interface{}) bool {
+	return func(msg map[string]interface{}) bool {
+		return msg["correlation_id"] == id
+	}
+}
+
+func TestTodoWebSocket(t *testing.T) {
+	conn, cleanup := dialTestWebSocket(t)
+	defer cleanup()
+
+	var createdID string
+
+	t.Run("It should ack a subscribe", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "subscribe", "sub-1", nil)
+
+		msg := readTestWebSocketMessage(t, conn, withCorrelationID("sub-1"))
+
+		assert.Equal(t, "ack", msg["type"])
+	})
+
+	t.Run("It should create a todo and broadcast the event", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "create", "create-1", types.WebSocketTodoPayload{Title: "Socket todo"})
+
+		ack := readTestWebSocketMessage(t, conn, withCorrelationID("create-1"))
+		payload := ack["payload"].(map[string]interface{})
+		createdID = payload["id"].(string)
+
+		assert.Equal(t, "ack", ack["type"])
+		assert.Equal(t, "Socket todo", payload["title"])
+
+		event := readTestWebSocketMessage(t, conn, func(msg map[string]interface{}) bool {
+			return msg["type"] == "event" && msg["event"] == constant.TodoEventCreated
+		})
+
+		assert.Equal(t, createdID, event["payload"].(map[string]interface{})["id"])
+	})
+
+	t.Run("It should update a todo", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "update", "update-1", types.WebSocketTodoPayload{ID: createdID, Title: "Renamed"})
+
+		ack := readTestWebSocketMessage(t, conn, withCorrelationID("update-1"))
+
+		assert.Equal(t, "ack", ack["type"])
+		assert.Equal(t, "Renamed", ack["payload"].(map[string]interface{})["title"])
+	})
+
+	t.Run("It should return an error for an unknown todo", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "delete", "delete-1", types.WebSocketTodoPayload{ID: uuid.New().String()})
+
+		msg := readTestWebSocketMessage(t, conn, withCorrelationID("delete-1"))
+
+		assert.Equal(t, "error", msg["type"])
+		assert.Equal(t, float64(http.StatusNotFound), msg["error"].(map[string]interface{})["status"])
+	})
+
+	t.Run("It should delete a todo", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "delete", "delete-2", types.WebSocketTodoPayload{ID: createdID})
+
+		msg := readTestWebSocketMessage(t, conn, withCorrelationID("delete-2"))
+
+		assert.Equal(t, "ack", msg["type"])
+	})
+
+	t.Run("It should reject an unknown message type", func(t *testing.T) {
+		sendTestWebSocketMessage(t, conn, "explode", "bad-1", nil)
+
+		msg := readTestWebSocketMessage(t, conn, withCorrelationID("bad-1"))
+
+		assert.Equal(t, "error", msg["type"])
+	})
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/middlewares"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+)
+
+// workspaceSlugPattern keeps slugs usable as a subdomain.
+var workspaceSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// requireDefaultWorkspace lets only requests served in the default workspace
+// manage workspaces, so a tenant cannot see or create others.
+func requireDefaultWorkspace(c *gin.Context) bool {
+	if middlewares.WorkspaceIDFromContext(c) != constant.DefaultWorkspaceID {
+		respondError(c, http.StatusForbidden, constant.ErrMsgWorkspaceAdminOnly)
+
+		return false
+	}
+
+	return true
+}
+
+func GetWorkspaces(workspaceService *service.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireDefaultWorkspace(c) {
+			return
+		}
+
+		workspaces, err := workspaceService.GetAllWorkspaces()
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				if todoErr.Reason == service.ReasonUnknown {
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+
+					return
+				}
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		mappedWorkspaces := make([]types.WorkspaceResponse, len(workspaces))
+
+		for i, workspace := range workspaces {
+			mappedWorkspaces[i] = *utils.MapWorkspaceResponse(&workspace)
+		}
+
+		c.IndentedJSON(http.StatusOK, mappedWorkspaces)
+	}
+}
+
+// CreateWorkspace creates a workspace and returns its first API key, which
+// is only shown in this response.
+func CreateWorkspace(workspaceService *service.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireDefaultWorkspace(c) {
+			return
+		}
+
+		var input types.WorkspaceInput
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		if !workspaceSlugPattern.MatchString(input.Slug) {
+			respondError(c, http.StatusBadRequest, constant.ErrMsgWorkspaceSlugInvalid)
+
+			return
+		}
+
+		workspace, secret, err := workspaceService.CreateWorkspace(input)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok {
+				switch todoErr.Reason {
+				case service.ReasonConflict:
+					respondError(c, http.StatusConflict, todoErr.Message)
+				case service.ReasonUnknown:
+					respondError(c, http.StatusInternalServerError, todoErr.Message)
+				}
+
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, types.WorkspaceCreatedResponse{
+			WorkspaceResponse: *utils.MapWorkspaceResponse(workspace),
+			Key:               secret,
+		})
+	}
+}
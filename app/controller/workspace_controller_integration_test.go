@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWorkspaceDomain = "todo.test"
+
+func TestWorkspaceIsolation(t *testing.T) {
+	var workspace types.WorkspaceCreatedResponse
+
+	w := requestAs("", "POST", "/workspaces", types.WorkspaceInput{Slug: "acme", Name: "Acme"})
+	json.Unmarshal(w.Body.Bytes(), &workspace)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotEmpty(t, workspace.Key)
+
+	acme := workspace.Key
+
+	var secret types.TodoResponse
+
+	w = requestAs(acme, "POST", "/todos", types.TodoInput{Title: "Acme secret"})
+	json.Unmarshal(w.Body.Bytes(), &secret)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	t.Run("It should keep other workspaces out of every listing", func(t *testing.T) {
+		var todos []types.TodoResponse
+		json.Unmarshal(requestAs(acme, "GET", "/todos", nil).Body.Bytes(), &todos)
+
+		assert.Len(t, todos, 1)
+		assert.Equal(t, secret.ID, todos[0].ID)
+
+		for _, path := range []string{"/todos", "/todos/export", "/sync", "/lists", "/api-keys"} {
+			w := requestAs("", "GET", path, nil)
+
+			assert.Equal(t, http.StatusOK, w.Code, path)
+			assert.NotContains(t, w.Body.String(), secret.ID, path)
+			assert.NotContains(t, w.Body.String(), "Acme secret", path)
+		}
+
+		w := requestAs("", "POST", "/graphql", gin.H{"query": "{ todos(first: 50) { items { id title } } }"})
+
+		assert.NotContains(t, w.Body.String(), secret.ID)
+
+		w = davRequest("PROPFIND", "/dav/todos/", "", map[string]string{"Depth": "1"})
+
+		assert.NotContains(t, w.Body.String(), secret.ID)
+
+		w = requestAs(acme, "GET", "/todos", nil)
+
+		assert.NotContains(t, w.Body.String(), testData[0].ExternalID)
+	})
+
+	t.Run("It should not find todos of another workspace by ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, requestAs("", "GET", "/todos/"+secret.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs("", "PUT", "/todos/"+secret.ID, types.TodoInput{Title: "Stolen"}).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs("", "DELETE", "/todos/"+secret.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, davRequest("GET", "/dav/todos/"+secret.ID+".ics", "", nil).Code)
+		assert.Equal(t, http.StatusNotFound, requestAs(acme, "GET", "/todos/"+testData[0].ExternalID, nil).Code)
+
+		w := requestAs(acme, "GET", "/todos/"+secret.ID, nil)
+
+		assert.Contains(t, w.Body.String(), "Acme secret")
+	})
+
+	t.Run("It should resolve the workspace from a subdomain or header for its own credentials", func(t *testing.T) {
+		bySubdomain := func(headers map[string]string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/todos", nil)
+			req.Host = "acme." + testWorkspaceDomain
+
+			for name, value := range headers {
+				req.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			return w
+		}
+
+		byHeader := davRequest("GET", "/todos", "", map[string]string{constant.APIKeyHeader: acme, constant.WorkspaceHeader: "acme"})
+
+		for _, w := range []*httptest.ResponseRecorder{byHeader, bySubdomain(map[string]string{constant.APIKeyHeader: acme})} {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), secret.ID)
+		}
+
+		assert.Equal(t, http.StatusNotFound, davRequest("GET", "/todos", "", map[string]string{constant.APIKeyHeader: acme, constant.WorkspaceHeader: "nowhere"}).Code)
+	})
+
+	t.Run("It should not serve another workspace without credentials valid in it", func(t *testing.T) {
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"workspace":"acme"}`))
+		jwt := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + claims + ".sig"
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.Host = "acme." + testWorkspaceDomain
+		bySubdomain := httptest.NewRecorder()
+		router.ServeHTTP(bySubdomain, req)
+
+		for _, w := range []*httptest.ResponseRecorder{
+			davRequest("GET", "/todos", "", map[string]string{constant.WorkspaceHeader: "acme"}),
+			davRequest("GET", "/todos", "", map[string]string{"Authorization": "Bearer " + jwt}),
+			davRequest("GET", "/todos", "", map[string]string{"Authorization": "Bearer " + jwt, constant.WorkspaceHeader: "acme"}),
+			davRequest("DELETE", "/todos/"+secret.ID, "", map[string]string{constant.WorkspaceHeader: "acme"}),
+			bySubdomain,
+		} {
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.NotContains(t, w.Body.String(), secret.ID)
+		}
+
+		// Nor whether a workspace exists.
+		assert.Equal(t, http.StatusUnauthorized, davRequest("GET", "/todos", "", map[string]string{constant.WorkspaceHeader: "nowhere"}).Code)
+		assert.Equal(t, http.StatusOK, requestAs(acme, "GET", "/todos/"+secret.ID, nil).Code)
+	})
+
+	t.Run("It should not let an API key into another workspace", func(t *testing.T) {
+		w := davRequest("GET", "/todos", "", map[string]string{constant.APIKeyHeader: acme, constant.WorkspaceHeader: "default"})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("It should serve calendar feeds from their own workspace", func(t *testing.T) {
+		var feed types.CalendarFeedCreatedResponse
+		json.Unmarshal(requestAs(acme, "POST", "/calendar/feeds", types.CalendarFeedInput{Name: "Acme"}).Body.Bytes(), &feed)
+
+		feedURL, err := url.Parse(feed.URL)
+		if err != nil {
+			t.Fatalf("Failed to parse feed URL: %v", err)
+		}
+
+		w := requestAs("", "GET", feedURL.RequestURI(), nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Acme secret")
+		assert.NotContains(t, w.Body.String(), "SUMMARY:"+testData[0].Title+"\r\n")
+
+		var feeds []types.CalendarFeedResponse
+		json.Unmarshal(requestAs("", "GET", "/calendar/feeds", nil).Body.Bytes(), &feeds)
+
+		for _, other := range feeds {
+			assert.NotEqual(t, feed.ID, other.ID)
+		}
+	})
+
+	t.Run("It should keep webhooks apart", func(t *testing.T) {
+		var webhook types.WebhookResponse
+		json.Unmarshal(requestAs(acme, "POST", "/webhooks", types.WebhookInput{URL: "https://acme.example.com/hook"}).Body.Bytes(), &webhook)
+
+		assert.NotContains(t, requestAs("", "GET", "/webhooks", nil).Body.String(), webhook.ID)
+		assert.Equal(t, http.StatusNotFound, requestAs("", "GET", "/webhooks/"+webhook.ID, nil).Code)
+		assert.Equal(t, http.StatusNoContent, requestAs(acme, "DELETE", "/webhooks/"+webhook.ID, nil).Code)
+	})
+
+	t.Run("It should only manage workspaces from the default workspace", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, requestAs(acme, "GET", "/workspaces", nil).Code)
+		assert.Equal(t, http.StatusConflict, requestAs("", "POST", "/workspaces", types.WorkspaceInput{Slug: "acme", Name: "Again"}).Code)
+		assert.Equal(t, http.StatusBadRequest, requestAs("", "POST", "/workspaces", types.WorkspaceInput{Slug: "Not a slug", Name: "Bad"}).Code)
+	})
+}
@@ -0,0 +1,541 @@
+// Package filestore is an embedded key-value store kept in a directory on
+// local disk, for deployments without a database server.
+//
+// Every committed transaction is appended to a write-ahead log as one frame,
+// checksummed so a frame cut short by a crash is recognized. The whole store
+// is held in memory and written out as a snapshot from time to time, after
+// which the log starts over. Opening a store loads the latest snapshot and
+// replays the log written since; a damaged tail of the log, left by a crash
+// in the middle of a write, is cut off, losing at most the transaction that
+// was being written.
+//
+// A store belongs to a single process.
+package filestore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	walFile      = "wal"
+	snapshotFile = "snapshot"
+)
+
+// SyncPolicy says when writes to the log are flushed to disk with fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes every commit before it returns. A commit that has
+	// returned survives a crash of the machine.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes every Options.SyncInterval, so a crash of the
+	// machine loses the commits of at most that long.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system. Commits survive a
+	// crash of the process, but not of the machine.
+	SyncNever SyncPolicy = "never"
+)
+
+// Options tune a store. The zero value syncs every commit and only compacts
+// when told to.
+type Options struct {
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// CompactAfter compacts the store once the log holds that many
+	// commits; 0 leaves the log to grow.
+	CompactAfter int
+	// SnapshotInterval compacts the store that often if anything was
+	// written since the last time; 0 turns it off.
+	SnapshotInterval time.Duration
+	// OnError hears about errors of the work done in the background, which
+	// has no caller to return them to.
+	OnError func(err error)
+}
+
+// ErrClosed is returned by a store that has been closed.
+var ErrClosed = errors.New("filestore: closed")
+
+// Stats describe the state of a store.
+type Stats struct {
+	// Seq counts the commits ever made.
+	Seq uint64
+	// Keys is the number of keys in the store.
+	Keys int
+	// LogCommits is the number of commits in the log since the last
+	// snapshot.
+	LogCommits int
+	// Truncated is the number of bytes cut off the end of the log when the
+	// store was opened, which were left by an interrupted write.
+	Truncated int64
+}
+
+// Store is an open store. It is safe for concurrent use: transactions made
+// with View run side by side, while those made with Update run one at a time.
+type Store struct {
+	mu   sync.RWMutex
+	dir  string
+	opts Options
+
+	data map[string][]byte
+	seq  uint64
+
+	wal        *os.File
+	walSize    int64
+	logCommits int
+	truncated  int64
+	dirty      bool
+	closed     bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the store in dir, creating both when they do not exist yet, and
+// recovers its state from the snapshot and the log.
+func Open(dir string, opts Options) (*Store, error) {
+	if opts.Sync == "" {
+		opts.Sync = SyncAlways
+	}
+
+	switch opts.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if opts.SyncInterval <= 0 {
+			return nil, errors.New("filestore: the interval sync policy needs a positive SyncInterval")
+		}
+	default:
+		return nil, fmt.Errorf("filestore: unknown sync policy %q", opts.Sync)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	store := &Store{dir: dir, opts: opts, data: make(map[string][]byte), done: make(chan struct{})}
+
+	if err := store.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := store.replayLog(); err != nil {
+		return nil, err
+	}
+
+	var syncEvery time.Duration
+	if opts.Sync == SyncInterval {
+		syncEvery = opts.SyncInterval
+	}
+
+	if syncEvery > 0 || opts.SnapshotInterval > 0 {
+		store.wg.Add(1)
+
+		go store.run(syncEvery, opts.SnapshotInterval)
+	}
+
+	return store, nil
+}
+
+func (s *Store) loadSnapshot() error {
+	file, err := os.Open(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	// Snapshots are renamed into place once complete, so unlike the log
+	// they are never legitimately damaged.
+	rec, _, err := readFrame(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("filestore: read snapshot: %w", err)
+	}
+
+	s.apply(rec)
+
+	return nil
+}
+
+// replayLog applies the commits in the log the snapshot does not cover yet,
+// and cuts off a damaged tail.
+func (s *Store) replayLog() error {
+	wal, err := os.OpenFile(filepath.Join(s.dir, walFile), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(wal)
+
+	var offset int64
+
+	for {
+		rec, size, err := readFrame(reader)
+
+		if err == io.EOF || err == errTorn {
+			break
+		}
+
+		if err != nil {
+			wal.Close()
+
+			return fmt.Errorf("filestore: read log: %w", err)
+		}
+
+		switch {
+		case rec.Seq <= s.seq:
+			// Written before the snapshot, which was taken before the
+			// log could be emptied.
+		case rec.Seq == s.seq+1:
+			s.apply(rec)
+			s.logCommits++
+		default:
+			wal.Close()
+
+			return fmt.Errorf("filestore: log skips from commit %d to %d", s.seq, rec.Seq)
+		}
+
+		offset += size
+	}
+
+	info, err := wal.Stat()
+	if err != nil {
+		wal.Close()
+
+		return err
+	}
+
+	if info.Size() > offset {
+		s.truncated = info.Size() - offset
+
+		if err := wal.Truncate(offset); err != nil {
+			wal.Close()
+
+			return err
+		}
+
+		if err := wal.Sync(); err != nil {
+			wal.Close()
+
+			return err
+		}
+	}
+
+	if _, err := wal.Seek(offset, io.SeekStart); err != nil {
+		wal.Close()
+
+		return err
+	}
+
+	s.wal = wal
+	s.walSize = offset
+
+	return nil
+}
+
+func (s *Store) apply(rec record) {
+	for _, o := range rec.Ops {
+		if o.Delete {
+			delete(s.data, o.Key)
+		} else {
+			s.data[o.Key] = o.Value
+		}
+	}
+
+	s.seq = rec.Seq
+}
+
+// View runs fn in a read-only transaction.
+func (s *Store) View(fn func(tx *Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	return fn(&Tx{store: s})
+}
+
+// Update runs fn in a transaction that may write, and commits its writes
+// when fn returns nil. An error from fn discards them and is passed back.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	tx := &Tx{store: s, writable: true}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	rec := record{Seq: s.seq + 1, Ops: tx.ops}
+
+	frame, err := encodeFrame(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := s.append(frame); err != nil {
+		return err
+	}
+
+	s.apply(rec)
+	s.logCommits++
+
+	if s.opts.CompactAfter > 0 && s.logCommits >= s.opts.CompactAfter {
+		// The commit is durable already; failing to compact only leaves
+		// the log longer than it needs to be.
+		if err := s.compact(); err != nil {
+			s.report(err)
+		}
+	}
+
+	return nil
+}
+
+// append writes frame to the end of the log. On failure the log is cut back
+// to where it was, so a frame that was never committed is not replayed.
+func (s *Store) append(frame []byte) error {
+	_, err := s.wal.Write(frame)
+
+	if err == nil && s.opts.Sync == SyncAlways {
+		err = s.wal.Sync()
+	}
+
+	if err != nil {
+		s.wal.Truncate(s.walSize)
+		s.wal.Seek(s.walSize, io.SeekStart)
+
+		return fmt.Errorf("filestore: write log: %w", err)
+	}
+
+	s.walSize += int64(len(frame))
+	s.dirty = s.opts.Sync != SyncAlways
+
+	return nil
+}
+
+// Compact writes every key out as a snapshot and empties the log.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	return s.compact()
+}
+
+func (s *Store) compact() error {
+	rec := record{Seq: s.seq, Ops: make([]op, 0, len(s.data))}
+
+	for key, value := range s.data {
+		rec.Ops = append(rec.Ops, op{Key: key, Value: value})
+	}
+
+	sort.Slice(rec.Ops, func(i, j int) bool { return rec.Ops[i].Key < rec.Ops[j].Key })
+
+	frame, err := encodeFrame(rec)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, snapshotFile)
+
+	if err := writeFileSync(path+".tmp", frame); err != nil {
+		return fmt.Errorf("filestore: write snapshot: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("filestore: write snapshot: %w", err)
+	}
+
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("filestore: write snapshot: %w", err)
+	}
+
+	// From here on the snapshot holds everything. A crash before the log
+	// is emptied only leaves commits behind that replaying skips.
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("filestore: empty log: %w", err)
+	}
+
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("filestore: empty log: %w", err)
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("filestore: empty log: %w", err)
+	}
+
+	s.walSize = 0
+	s.logCommits = 0
+	s.dirty = false
+
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
+
+// syncDir flushes the entries of dir, making a rename in it durable.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return file.Sync()
+}
+
+// Sync flushes the log to disk, whatever the sync policy.
+func (s *Store) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	return s.sync()
+}
+
+func (s *Store) sync() error {
+	if !s.dirty {
+		return nil
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("filestore: sync log: %w", err)
+	}
+
+	s.dirty = false
+
+	return nil
+}
+
+// Stats returns the current state of the store.
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Stats{Seq: s.seq, Keys: len(s.data), LogCommits: s.logCommits, Truncated: s.truncated}
+}
+
+// run syncs and compacts the store in the background; a zero interval
+// leaves that part out.
+func (s *Store) run(syncEvery time.Duration, snapshotEvery time.Duration) {
+	defer s.wg.Done()
+
+	var syncTick, snapshotTick <-chan time.Time
+
+	if syncEvery > 0 {
+		ticker := time.NewTicker(syncEvery)
+		defer ticker.Stop()
+
+		syncTick = ticker.C
+	}
+
+	if snapshotEvery > 0 {
+		ticker := time.NewTicker(snapshotEvery)
+		defer ticker.Stop()
+
+		snapshotTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-syncTick:
+			if err := s.Sync(); err != nil && err != ErrClosed {
+				s.report(err)
+			}
+		case <-snapshotTick:
+			s.mu.Lock()
+
+			if !s.closed && s.logCommits > 0 {
+				if err := s.compact(); err != nil {
+					s.report(err)
+				}
+			}
+
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Store) report(err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
+
+// Close stops the background work, flushes the log whatever the sync policy
+// and closes it.
+func (s *Store) Close() error {
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+
+		return ErrClosed
+	}
+
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.sync()
+
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,340 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func put(t *testing.T, store *Store, pairs ...string) {
+	t.Helper()
+
+	err := store.Update(func(tx *Tx) error {
+		for i := 0; i < len(pairs); i += 2 {
+			if err := tx.Put(pairs[i], []byte(pairs[i+1])); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// contents returns every key under prefix with its value.
+func contents(t *testing.T, store *Store, prefix string) map[string]string {
+	t.Helper()
+
+	all := map[string]string{}
+
+	err := store.View(func(tx *Tx) error {
+		return tx.Range(prefix, func(key string, value []byte) error {
+			all[key] = string(value)
+
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	return all
+}
+
+// crash abandons store the way a killed process would, without flushing or
+// closing anything.
+func crash(store *Store) {
+	close(store.done)
+	store.wg.Wait()
+	store.wal.Close()
+}
+
+func TestStoreTransactions(t *testing.T) {
+	store, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	put(t, store, "todos/b", "2", "todos/a", "1", "lists/a", "x")
+
+	t.Run("It should range over a prefix in key order", func(t *testing.T) {
+		var keys []string
+
+		err := store.View(func(tx *Tx) error {
+			return tx.Range("todos/", func(key string, value []byte) error {
+				keys = append(keys, key)
+
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"todos/a", "todos/b"}, keys)
+	})
+
+	t.Run("It should see its own writes", func(t *testing.T) {
+		err := store.Update(func(tx *Tx) error {
+			require.NoError(t, tx.Put("todos/c", []byte("3")))
+			require.NoError(t, tx.Delete("todos/a"))
+
+			assert.Equal(t, []byte("3"), tx.Get("todos/c"))
+			assert.Nil(t, tx.Get("todos/a"))
+
+			seen := map[string]string{}
+
+			tx.Range("todos/", func(key string, value []byte) error {
+				seen[key] = string(value)
+
+				return nil
+			})
+
+			assert.Equal(t, map[string]string{"todos/b": "2", "todos/c": "3"}, seen)
+
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("It should discard the writes of a failed transaction", func(t *testing.T) {
+		failure := errors.New("changed my mind")
+
+		err := store.Update(func(tx *Tx) error {
+			tx.Put("todos/d", []byte("4"))
+
+			return failure
+		})
+		assert.Equal(t, failure, err)
+		assert.Equal(t, map[string]string{"todos/b": "2", "todos/c": "3"}, contents(t, store, "todos/"))
+	})
+
+	t.Run("It should refuse writes in a read-only transaction", func(t *testing.T) {
+		err := store.View(func(tx *Tx) error {
+			return tx.Put("todos/e", []byte("5"))
+		})
+		assert.Equal(t, ErrReadOnly, err)
+	})
+}
+
+func TestStoreRecovery(t *testing.T) {
+	t.Run("It should reopen with every commit", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(dir, Options{})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1", "b", "2")
+		put(t, store, "a", "3")
+		require.NoError(t, store.Update(func(tx *Tx) error { return tx.Delete("b") }))
+		require.NoError(t, store.Close())
+
+		store, err = Open(dir, Options{})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		assert.Equal(t, map[string]string{"a": "3"}, contents(t, store, ""))
+		assert.Equal(t, uint64(3), store.Stats().Seq)
+	})
+
+	t.Run("It should recover the commits of a crashed process", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(dir, Options{Sync: SyncNever})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1")
+		put(t, store, "b", "2")
+		crash(store)
+
+		store, err = Open(dir, Options{})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		assert.Equal(t, map[string]string{"a": "1", "b": "2"}, contents(t, store, ""))
+	})
+
+	// Each damages the second of two frames, the first of which ends at
+	// first.
+	for name, damage := range map[string]func(wal []byte, first int64) []byte{
+		"cut short":             func(wal []byte, first int64) []byte { return wal[:len(wal)-3] },
+		"with a bad checksum":   func(wal []byte, first int64) []byte { wal[len(wal)-2] ^= 0xff; return wal },
+		"with a partial header": func(wal []byte, first int64) []byte { return wal[:first+5] },
+	} {
+		t.Run(fmt.Sprintf("It should drop a last frame %s and keep going", name), func(t *testing.T) {
+			dir := t.TempDir()
+
+			store, err := Open(dir, Options{})
+			require.NoError(t, err)
+
+			put(t, store, "a", "1")
+			first := store.walSize
+			put(t, store, "b", "2")
+			require.NoError(t, store.Close())
+
+			path := filepath.Join(dir, walFile)
+			wal, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, damage(wal, first), 0o600))
+
+			store, err = Open(dir, Options{})
+			require.NoError(t, err)
+
+			stats := store.Stats()
+			assert.Equal(t, uint64(1), stats.Seq)
+			assert.Positive(t, stats.Truncated)
+			assert.Equal(t, map[string]string{"a": "1"}, contents(t, store, ""))
+
+			put(t, store, "c", "3")
+			require.NoError(t, store.Close())
+
+			store, err = Open(dir, Options{})
+			require.NoError(t, err)
+
+			defer store.Close()
+
+			assert.Zero(t, store.Stats().Truncated)
+			assert.Equal(t, map[string]string{"a": "1", "c": "3"}, contents(t, store, ""))
+		})
+	}
+
+	t.Run("It should refuse a damaged snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(dir, Options{})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1")
+		require.NoError(t, store.Compact())
+		require.NoError(t, store.Close())
+
+		path := filepath.Join(dir, snapshotFile)
+		snapshot, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, snapshot[:len(snapshot)-1], 0o600))
+
+		_, err = Open(dir, Options{})
+		assert.Error(t, err)
+	})
+}
+
+func TestStoreCompaction(t *testing.T) {
+	t.Run("It should snapshot and empty the log after enough commits", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(dir, Options{CompactAfter: 3})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1")
+		put(t, store, "b", "2")
+		assert.Equal(t, 2, store.Stats().LogCommits)
+
+		require.NoError(t, store.Update(func(tx *Tx) error { return tx.Delete("a") }))
+		assert.Equal(t, 0, store.Stats().LogCommits)
+
+		info, err := os.Stat(filepath.Join(dir, walFile))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+
+		put(t, store, "c", "3")
+		require.NoError(t, store.Close())
+
+		store, err = Open(dir, Options{})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		assert.Equal(t, map[string]string{"b": "2", "c": "3"}, contents(t, store, ""))
+		assert.Equal(t, uint64(4), store.Stats().Seq)
+	})
+
+	t.Run("It should skip commits the snapshot holds when the log was not emptied", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(dir, Options{})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1")
+		put(t, store, "a", "2")
+
+		wal, err := os.ReadFile(filepath.Join(dir, walFile))
+		require.NoError(t, err)
+
+		require.NoError(t, store.Compact())
+		require.NoError(t, store.Close())
+
+		// As if the process died between writing the snapshot and emptying
+		// the log.
+		require.NoError(t, os.WriteFile(filepath.Join(dir, walFile), wal, 0o600))
+
+		store, err = Open(dir, Options{})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		assert.Equal(t, map[string]string{"a": "2"}, contents(t, store, ""))
+		assert.Equal(t, uint64(2), store.Stats().Seq)
+	})
+
+	t.Run("It should snapshot periodically", func(t *testing.T) {
+		store, err := Open(t.TempDir(), Options{SnapshotInterval: 10 * time.Millisecond})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		put(t, store, "a", "1")
+
+		assert.Eventually(t, func() bool { return store.Stats().LogCommits == 0 }, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestStoreSyncPolicies(t *testing.T) {
+	t.Run("It should refuse unknown policies and missing intervals", func(t *testing.T) {
+		_, err := Open(t.TempDir(), Options{Sync: "sometimes"})
+		assert.Error(t, err)
+
+		_, err = Open(t.TempDir(), Options{Sync: SyncInterval})
+		assert.Error(t, err)
+	})
+
+	t.Run("It should flush every commit with the always policy", func(t *testing.T) {
+		store, err := Open(t.TempDir(), Options{Sync: SyncAlways})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		put(t, store, "a", "1")
+		assert.False(t, store.dirty)
+	})
+
+	t.Run("It should flush in the background with the interval policy", func(t *testing.T) {
+		store, err := Open(t.TempDir(), Options{Sync: SyncInterval, SyncInterval: 10 * time.Millisecond})
+		require.NoError(t, err)
+
+		defer store.Close()
+
+		put(t, store, "a", "1")
+
+		assert.Eventually(t, func() bool {
+			store.mu.RLock()
+			defer store.mu.RUnlock()
+
+			return !store.dirty
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("It should leave flushing to Close with the never policy", func(t *testing.T) {
+		store, err := Open(t.TempDir(), Options{Sync: SyncNever})
+		require.NoError(t, err)
+
+		put(t, store, "a", "1")
+		assert.True(t, store.dirty)
+
+		require.NoError(t, store.Close())
+		assert.False(t, store.dirty)
+		assert.Equal(t, ErrClosed, store.Update(func(tx *Tx) error { return nil }))
+	})
+}
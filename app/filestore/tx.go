@@ -0,0 +1,89 @@
+package filestore
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrReadOnly is returned when a transaction started with View writes.
+var ErrReadOnly = errors.New("filestore: write in a read-only transaction")
+
+// Tx is a transaction, seeing the store as it was when the transaction began
+// along with its own writes. Values it returns belong to the store and must
+// not be modified.
+type Tx struct {
+	store    *Store
+	writable bool
+	ops      []op
+	// writes indexes the last op on each key.
+	writes map[string]int
+}
+
+// Get returns the value of key, or nil when there is none.
+func (tx *Tx) Get(key string) []byte {
+	if i, ok := tx.writes[key]; ok {
+		write := tx.ops[i]
+
+		if write.Delete {
+			return nil
+		}
+
+		return write.Value
+	}
+
+	return tx.store.data[key]
+}
+
+// Put sets key to a copy of value.
+func (tx *Tx) Put(key string, value []byte) error {
+	return tx.write(op{Key: key, Value: append([]byte{}, value...)})
+}
+
+// Delete removes key, if it exists.
+func (tx *Tx) Delete(key string) error {
+	return tx.write(op{Key: key, Delete: true})
+}
+
+func (tx *Tx) write(o op) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	if tx.writes == nil {
+		tx.writes = make(map[string]int)
+	}
+
+	tx.writes[o.Key] = len(tx.ops)
+	tx.ops = append(tx.ops, o)
+
+	return nil
+}
+
+// Range calls fn with every key starting with prefix and its value, in key
+// order, until fn returns an error, which Range passes back.
+func (tx *Tx) Range(prefix string, fn func(key string, value []byte) error) error {
+	var keys []string
+
+	for key := range tx.store.data {
+		if _, written := tx.writes[key]; !written && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key, i := range tx.writes {
+		if !tx.ops[i].Delete && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn(key, tx.Get(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package filestore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// frameHeaderSize is the length and the checksum of the payload that precede
+// it in every frame.
+const frameHeaderSize = 8
+
+// maxFrameSize bounds the length read from a header, so a corrupt one is
+// caught before it is used to allocate.
+const maxFrameSize = 1 << 30
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTorn reports a frame that was cut short or does not match its checksum,
+// which is what a crash in the middle of a write leaves behind.
+var errTorn = errors.New("filestore: torn or corrupt frame")
+
+// op is one write of a record. Delete distinguishes removing a key from
+// storing an empty value.
+type op struct {
+	Key    string `json:"k"`
+	Value  []byte `json:"v"`
+	Delete bool   `json:"d,omitempty"`
+}
+
+// record is the payload of a frame: the writes of one transaction in the
+// WAL, or every key at Seq in a snapshot.
+type record struct {
+	Seq uint64 `json:"seq"`
+	Ops []op   `json:"ops"`
+}
+
+// encodeFrame lays out rec as a frame: the length of the payload and its
+// CRC-32C, both big-endian, then the payload.
+func encodeFrame(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+
+	return frame, nil
+}
+
+// readFrame reads the next frame from r and returns its record and size. It
+// returns io.EOF at a clean end, and errTorn for a partial or damaged frame.
+func readFrame(r *bufio.Reader) (record, int64, error) {
+	var rec record
+	var header [frameHeaderSize]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			return rec, 0, errTorn
+		}
+
+		return rec, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxFrameSize {
+		return rec, 0, errTorn
+	}
+
+	payload := make([]byte, size)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return rec, 0, errTorn
+		}
+
+		return rec, 0, err
+	}
+
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, 0, errTorn
+	}
+
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, 0, errTorn
+	}
+
+	return rec, int64(frameHeaderSize) + int64(size), nil
+}
@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+)
+
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Execute runs one GraphQL request with a fresh TodoLoader, so batching and
+// caching never leak between requests. Resolvers use todoService, which may
+// act for a different user than the one the schema was built with.
+func Execute(ctx context.Context, schema graphql.Schema, todoService *service.TodoService, req Request) *graphql.Result {
+	if err := checkLimits(req.Query, req.Variables, constant.GraphQLMaxDepth, constant.GraphQLMaxComplexity); err != nil {
+		return &graphql.Result{
+			Errors: []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Extensions: gqlError{code: "QUERY_TOO_COMPLEX"}.Extensions(),
+			}},
+		}
+	}
+
+	ctx = context.WithValue(ctx, serviceKey{}, todoService)
+	ctx = context.WithValue(ctx, loaderKey{}, NewTodoLoader(todoService.GetTodosByIDs))
+
+	return graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        ctx,
+	})
+}
+
+// IsMutation reports whether the operation req runs is a mutation. When the
+// operation to run is not named, any mutation in the document counts, so a
+// document the executor would refuse is not let through either way.
+func IsMutation(req Request) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		// Nothing runs; the executor reports the syntax error.
+		return false
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if req.OperationName != "" && (operation.Name == nil || operation.Name.Value != req.OperationName) {
+			continue
+		}
+
+		if operation.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+
+	return false
+}
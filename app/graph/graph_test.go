@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"testing"
+	"todo-app/app/service"
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLimits(t *testing.T) {
+	t.Run("It should accept a shallow query", func(t *testing.T) {
+		err := checkLimits(`{ todos(first: 10) { items { id title } totalCount } }`, nil, 8, 2000)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("It should reject a query deeper than the limit", func(t *testing.T) {
+		err := checkLimits(`{ a { b { c { d } } } }`, nil, 3, 2000)
+
+		assert.ErrorContains(t, err, "depth")
+	})
+
+	t.Run("It should follow fragments when measuring depth", func(t *testing.T) {
+		err := checkLimits(`query { a { ...F } } fragment F on A { b { c { d } } }`, nil, 3, 2000)
+
+		assert.ErrorContains(t, err, "depth")
+	})
+
+	t.Run("It should multiply child cost by the page size", func(t *testing.T) {
+		query := `query($n: Int) { todos(first: $n) { items { id title createdAt } } }`
+
+		assert.NoError(t, checkLimits(query, map[string]interface{}{"n": float64(10)}, 8, 100))
+		assert.ErrorContains(t, checkLimits(query, map[string]interface{}{"n": float64(100)}, 8, 100), "complexity")
+	})
+
+	t.Run("It should ignore introspection fields", func(t *testing.T) {
+		err := checkLimits(`{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`, nil, 3, 2000)
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestTodoLoader(t *testing.T) {
+	var batches [][]string
+
+	loader := NewTodoLoader(func(ids []string) ([]types.Todo, error) {
+		batches = append(batches, ids)
+
+		return []types.Todo{{ExternalID: "a", Title: "A"}, {ExternalID: "b", Title: "B"}}, nil
+	})
+
+	t.Run("It should fetch all pending keys in one batch", func(t *testing.T) {
+		thunkA := loader.Load("a")
+		thunkB := loader.Load("b")
+		thunkC := loader.Load("c")
+
+		todoA, err := thunkA()
+		assert.NoError(t, err)
+		assert.Equal(t, "A", todoA.(*types.Todo).Title)
+
+		todoB, err := thunkB()
+		assert.NoError(t, err)
+		assert.Equal(t, "B", todoB.(*types.Todo).Title)
+
+		_, err = thunkC()
+		assert.Equal(t, service.ReasonNotFound, err.(service.TodoError).Reason)
+
+		assert.Len(t, batches, 1)
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, batches[0])
+	})
+
+	t.Run("It should serve repeated keys from the cache", func(t *testing.T) {
+		_, err := loader.Load("a")()
+
+		assert.NoError(t, err)
+		assert.Len(t, batches, 1)
+	})
+}
+
+func TestIsMutation(t *testing.T) {
+	t.Run("It should tell queries from mutations", func(t *testing.T) {
+		assert.False(t, IsMutation(Request{Query: `{ todos(first: 10) { totalCount } }`}))
+		assert.True(t, IsMutation(Request{Query: `mutation { deleteTodo(id: "x") }`}))
+	})
+
+	t.Run("It should look at the named operation only", func(t *testing.T) {
+		query := `query Read { todos(first: 1) { totalCount } } mutation Write { deleteTodo(id: "x") }`
+
+		assert.False(t, IsMutation(Request{Query: query, OperationName: "Read"}))
+		assert.True(t, IsMutation(Request{Query: query, OperationName: "Write"}))
+		assert.True(t, IsMutation(Request{Query: query}))
+	})
+}
@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// checkLimits rejects queries that nest deeper than maxDepth or whose
+// estimated cost exceeds maxComplexity. Every field costs one point and a
+// paginated field multiplies the cost of its children by its page size, so
+// asking for many large pages is as expensive as it really is. Introspection
+// fields are exempt so GraphiQL keeps working.
+func checkLimits(query string, variables map[string]interface{}, maxDepth int, maxComplexity int) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		// Leave syntax errors to the executor, which reports them properly.
+		return nil
+	}
+
+	fragments := make(map[string]*ast.FragmentDefinition)
+
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		w := limitWalker{fragments: fragments, variables: variables, visiting: make(map[string]bool)}
+		depth, complexity := w.selectionSet(operation.SelectionSet)
+
+		if depth > maxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, maxDepth)
+		}
+
+		if complexity > maxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, maxComplexity)
+		}
+	}
+
+	return nil
+}
+
+type limitWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+func (w limitWalker) selectionSet(set *ast.SelectionSet) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	maxDepth, complexity := 0, 0
+
+	for _, selection := range set.Selections {
+		var depth, cost int
+
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if len(sel.Name.Value) > 1 && sel.Name.Value[:2] == "__" {
+				continue
+			}
+
+			childDepth, childCost := w.selectionSet(sel.SelectionSet)
+			depth = childDepth + 1
+			cost = 1 + childCost*w.multiplier(sel)
+		case *ast.InlineFragment:
+			depth, cost = w.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			fragment, ok := w.fragments[name]
+
+			if !ok || w.visiting[name] {
+				continue
+			}
+
+			w.visiting[name] = true
+			depth, cost = w.selectionSet(fragment.SelectionSet)
+			delete(w.visiting, name)
+		}
+
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+
+		complexity += cost
+	}
+
+	return maxDepth, complexity
+}
+
+func (w limitWalker) multiplier(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := w.variables[value.Name.Value].(float64); ok && n > 0 {
+				return int(n)
+			}
+		}
+	}
+
+	if field.Name.Value == "todos" {
+		return defaultPageSize
+	}
+
+	return 1
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// TodoLoader batches todo lookups made while resolving one request. Load only
+// records the key and returns a thunk; graphql-go runs all thunks of a level
+// after the level's resolvers, so the first thunk to run fetches every key
+// queued so far in a single query.
+type TodoLoader struct {
+	fetch   func(ids []string) ([]types.Todo, error)
+	mu      sync.Mutex
+	pending []string
+	results map[string]*types.Todo
+	errs    map[string]error
+}
+
+func NewTodoLoader(fetch func(ids []string) ([]types.Todo, error)) *TodoLoader {
+	return &TodoLoader{
+		fetch:   fetch,
+		results: make(map[string]*types.Todo),
+		errs:    make(map[string]error),
+	}
+}
+
+func (loader *TodoLoader) Load(id string) func() (interface{}, error) {
+	loader.mu.Lock()
+
+	if _, loaded := loader.results[id]; !loaded {
+		loader.pending = append(loader.pending, id)
+	}
+
+	loader.mu.Unlock()
+
+	return func() (interface{}, error) {
+		loader.mu.Lock()
+		defer loader.mu.Unlock()
+
+		if len(loader.pending) > 0 {
+			loader.flush()
+		}
+
+		if err := loader.errs[id]; err != nil {
+			return nil, err
+		}
+
+		todo := loader.results[id]
+		if todo == nil {
+			return nil, service.TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: service.ReasonNotFound}
+		}
+
+		return todo, nil
+	}
+}
+
+func (loader *TodoLoader) flush() {
+	keys := loader.pending
+	loader.pending = nil
+
+	todos, err := loader.fetch(keys)
+
+	for _, key := range keys {
+		loader.results[key] = nil
+
+		if err != nil {
+			loader.errs[key] = err
+		}
+	}
+
+	for i := range todos {
+		loader.results[todos[i].ExternalID] = &todos[i]
+	}
+}
@@ -0,0 +1,309 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type loaderKey struct{}
+
+type serviceKey struct{}
+
+// serviceFrom returns the TodoService Execute stored for the request, which
+// acts for the caller, falling back to the one the schema was built with.
+func serviceFrom(ctx context.Context, fallback *service.TodoService) *service.TodoService {
+	if todoService, ok := ctx.Value(serviceKey{}).(*service.TodoService); ok {
+		return todoService
+	}
+
+	return fallback
+}
+
+// gqlError carries a machine readable code in the "extensions" member of the
+// GraphQL error, mirroring the status codes of the REST API.
+type gqlError struct {
+	message string
+	code    string
+}
+
+func (e gqlError) Error() string {
+	return e.message
+}
+
+func (e gqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func mapError(err error) error {
+	if todoErr, ok := err.(service.TodoError); ok {
+		switch todoErr.Reason {
+		case service.ReasonNotFound:
+			return gqlError{message: todoErr.Message, code: "NOT_FOUND"}
+		case service.ReasonForbidden:
+			return gqlError{message: todoErr.Message, code: "FORBIDDEN"}
+		case service.ReasonUnknown:
+			return gqlError{message: todoErr.Message, code: "INTERNAL_SERVER_ERROR"}
+		}
+	}
+
+	return gqlError{message: err.Error(), code: "INTERNAL_SERVER_ERROR"}
+}
+
+func badInput(message string) error {
+	return gqlError{message: message, code: "BAD_USER_INPUT"}
+}
+
+func idArg(p graphql.ResolveParams) (string, error) {
+	id, _ := p.Args["id"].(string)
+
+	if _, err := uuid.Parse(id); err != nil {
+		return "", badInput("Invalid ID")
+	}
+
+	return id, nil
+}
+
+func titleArg(p graphql.ResolveParams) (string, error) {
+	title, _ := p.Args["title"].(string)
+
+	if title == "" {
+		return "", badInput("Title is required")
+	}
+
+	return title, nil
+}
+
+var todoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Todo",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*types.Todo).ExternalID, nil
+			},
+		},
+		"title": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*types.Todo).Title, nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*types.Todo).CreatedAt, nil
+			},
+		},
+	},
+})
+
+var todoFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "TodoFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"titleContains": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"createdAfter":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"createdBefore": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+	},
+})
+
+type todoPage struct {
+	items       []*types.Todo
+	totalCount  int
+	hasNextPage bool
+}
+
+var todoPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TodoPage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(todoType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*todoPage).items, nil
+			},
+		},
+		"totalCount": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*todoPage).totalCount, nil
+			},
+		},
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*todoPage).hasNextPage, nil
+			},
+		},
+	},
+})
+
+func NewSchema(todoService *service.TodoService) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"todos": &graphql.Field{
+				Type: graphql.NewNonNull(todoPageType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: todoFilterType},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					first, _ := p.Args["first"].(int)
+					offset, _ := p.Args["offset"].(int)
+
+					if first < 1 || first > maxPageSize {
+						return nil, badInput(fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+					}
+
+					if offset < 0 {
+						return nil, badInput("offset must not be negative")
+					}
+
+					filter := types.TodoFilter{Limit: first, Offset: offset}
+
+					if input, ok := p.Args["filter"].(map[string]interface{}); ok {
+						filter.TitleContains, _ = input["titleContains"].(string)
+
+						if after, ok := input["createdAfter"].(time.Time); ok {
+							filter.CreatedAfter = &after
+						}
+
+						if before, ok := input["createdBefore"].(time.Time); ok {
+							filter.CreatedBefore = &before
+						}
+					}
+
+					todos, total, err := serviceFrom(p.Context, todoService).FindTodos(filter)
+					if err != nil {
+						return nil, mapError(err)
+					}
+
+					page := &todoPage{
+						items:       make([]*types.Todo, len(todos)),
+						totalCount:  total,
+						hasNextPage: offset+len(todos) < total,
+					}
+
+					for i := range todos {
+						page.items[i] = &todos[i]
+					}
+
+					return page, nil
+				},
+			},
+			"todo": &graphql.Field{
+				Type: todoType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := idArg(p)
+					if err != nil {
+						return nil, err
+					}
+
+					thunk := p.Context.Value(loaderKey{}).(*TodoLoader).Load(id)
+
+					return func() (interface{}, error) {
+						todo, err := thunk()
+						if err != nil {
+							// A missing todo is a null result, not an error.
+							if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonNotFound {
+								return nil, nil
+							}
+
+							return nil, mapError(err)
+						}
+
+						return todo, nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createTodo": &graphql.Field{
+				Type: graphql.NewNonNull(todoType),
+				Args: graphql.FieldConfigArgument{
+					"title": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					title, err := titleArg(p)
+					if err != nil {
+						return nil, err
+					}
+
+					todo, err := serviceFrom(p.Context, todoService).CreateTodo(title)
+					if err != nil {
+						return nil, mapError(err)
+					}
+
+					return todo, nil
+				},
+			},
+			"updateTodo": &graphql.Field{
+				Type: graphql.NewNonNull(todoType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"title": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := idArg(p)
+					if err != nil {
+						return nil, err
+					}
+
+					title, err := titleArg(p)
+					if err != nil {
+						return nil, err
+					}
+
+					todo, err := serviceFrom(p.Context, todoService).UpdateTodo(id, title)
+					if err != nil {
+						return nil, mapError(err)
+					}
+
+					return todo, nil
+				},
+			},
+			"deleteTodo": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := idArg(p)
+					if err != nil {
+						return nil, err
+					}
+
+					if err := serviceFrom(p.Context, todoService).DeleteTodo(id); err != nil {
+						return nil, mapError(err)
+					}
+
+					return id, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
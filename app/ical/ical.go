@@ -0,0 +1,346 @@
+// Package ical reads and writes the subset of RFC 5545 needed to exchange
+// todos as VTODO components.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"todo-app/app/types"
+)
+
+const (
+	productID     = "-//todo-app//Todos//EN"
+	maxLineOctets = 75
+	dateTimeUTC   = "20060102T150405Z"
+	dateTimeLocal = "20060102T150405"
+	dateOnly      = "20060102"
+)
+
+// Encoder writes a VCALENDAR one VTODO at a time so feeds can be streamed.
+type Encoder struct {
+	w     *bufio.Writer
+	name  string
+	stamp time.Time
+	err   error
+}
+
+func NewEncoder(w io.Writer, name string) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w), name: name, stamp: time.Now().UTC()}
+}
+
+// Begin writes the calendar header.
+func (enc *Encoder) Begin() error {
+	enc.line("BEGIN:VCALENDAR")
+	enc.line("VERSION:2.0")
+	enc.line("PRODID:" + productID)
+	enc.line("CALSCALE:GREGORIAN")
+
+	if enc.name != "" {
+		enc.line("X-WR-CALNAME:" + EscapeText(enc.name))
+	}
+
+	return enc.err
+}
+
+// Encode writes one todo as a VTODO component.
+func (enc *Encoder) Encode(todo *types.Todo) error {
+	enc.line("BEGIN:VTODO")
+	enc.line("UID:" + todo.ExternalID)
+	enc.line("DTSTAMP:" + enc.stamp.Format(dateTimeUTC))
+	enc.line("CREATED:" + todo.CreatedAt.UTC().Format(dateTimeUTC))
+	enc.line("SUMMARY:" + EscapeText(todo.Title))
+
+	if todo.DueAt != nil {
+		enc.line("DUE:" + todo.DueAt.UTC().Format(dateTimeUTC))
+	}
+
+	if todo.Status != "" {
+		enc.line("STATUS:" + strings.ToUpper(todo.Status))
+	}
+
+	// A recurrence stored before it was validated could break the line and
+	// add properties of its own, so it is only written if it is an RRULE.
+	if todo.Recurrence != "" && ValidateRRule(todo.Recurrence) == nil {
+		enc.line("RRULE:" + todo.Recurrence)
+	}
+
+	enc.line("END:VTODO")
+
+	return enc.err
+}
+
+// End closes the calendar and flushes everything written so far.
+func (enc *Encoder) End() error {
+	enc.line("END:VCALENDAR")
+
+	if enc.err == nil {
+		enc.err = enc.w.Flush()
+	}
+
+	return enc.err
+}
+
+// Flush pushes buffered components to the underlying writer.
+func (enc *Encoder) Flush() error {
+	if enc.err == nil {
+		enc.err = enc.w.Flush()
+	}
+
+	return enc.err
+}
+
+// line writes a content line, folding it into 75 octet chunks without
+// splitting a UTF-8 sequence.
+func (enc *Encoder) line(content string) {
+	if enc.err != nil {
+		return
+	}
+
+	limit := maxLineOctets
+
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+
+		if _, enc.err = enc.w.WriteString(content[:cut] + "\r\n "); enc.err != nil {
+			return
+		}
+
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+
+	_, enc.err = enc.w.WriteString(content + "\r\n")
+}
+
+// EscapeText escapes a TEXT property value.
+func EscapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(value string) string {
+	var out strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			out.WriteByte(value[i])
+
+			continue
+		}
+
+		i++
+
+		switch value[i] {
+		case 'n', 'N':
+			out.WriteByte('\n')
+		default:
+			out.WriteByte(value[i])
+		}
+	}
+
+	return out.String()
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseProperty(line string) (property, bool) {
+	var prop property
+
+	inQuotes := false
+	colon := -1
+
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+
+			break
+		}
+	}
+
+	if colon < 0 {
+		return prop, false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+
+	prop.name = strings.ToUpper(parts[0])
+	prop.params = make(map[string]string)
+	prop.value = line[colon+1:]
+
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return prop, true
+}
+
+// parseDateTime reads DATE-TIME and DATE values. Floating times and times
+// with an unknown TZID are taken as UTC.
+func parseDateTime(prop property) (time.Time, error) {
+	if prop.params["VALUE"] == "DATE" || len(prop.value) == len(dateOnly) {
+		return time.ParseInLocation(dateOnly, prop.value, time.UTC)
+	}
+
+	if strings.HasSuffix(prop.value, "Z") {
+		return time.Parse(dateTimeUTC, prop.value)
+	}
+
+	location := time.UTC
+
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+
+	return time.ParseInLocation(dateTimeLocal, prop.value, location)
+}
+
+// ParseTodos reads every VTODO in an iCalendar stream as an import row. A
+// UID is kept as the row ID only when it is a UUID, since other UIDs cannot
+// become todo IDs. Problems with a single component are attached to its row.
+func ParseTodos(r io.Reader) ([]types.TodoImportRow, error) {
+	var rows []types.TodoImportRow
+	var current *types.TodoImportRow
+	var logical string
+
+	sawCalendar := false
+	// nested counts components such as VALARM open inside the current VTODO,
+	// whose properties must not be mistaken for the todo's own.
+	nested := 0
+
+	handle := func(line string) {
+		prop, ok := parseProperty(line)
+		if !ok {
+			if current != nil {
+				current.Errors = append(current.Errors, fmt.Sprintf("malformed line %q", line))
+			}
+
+			return
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR"):
+			sawCalendar = true
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VTODO"):
+			current = &types.TodoImportRow{Row: len(rows) + 1}
+		case prop.name == "END" && strings.EqualFold(prop.value, "VTODO"):
+			if current != nil {
+				rows = append(rows, *current)
+				current = nil
+				nested = 0
+			}
+		case current == nil:
+		case prop.name == "BEGIN":
+			nested++
+		case prop.name == "END":
+			nested--
+		case nested > 0:
+		case prop.name == "UID":
+			if _, err := uuid.Parse(prop.value); err == nil {
+				current.ID = prop.value
+			}
+		case prop.name == "SUMMARY":
+			current.Title = strings.TrimSpace(UnescapeText(prop.value))
+		case prop.name == "CREATED" || prop.name == "DUE":
+			parsed, err := parseDateTime(prop)
+			if err != nil {
+				current.Errors = append(current.Errors, prop.name+" is not a valid date-time")
+
+				return
+			}
+
+			if prop.name == "CREATED" {
+				current.CreatedAt = &parsed
+			} else {
+				current.DueAt = &parsed
+			}
+		case prop.name == "STATUS":
+			current.Status = strings.ToLower(prop.value)
+		case prop.name == "RRULE":
+			current.Recurrence = prop.value
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			logical += line[1:]
+
+			continue
+		}
+
+		if logical != "" {
+			handle(logical)
+		}
+
+		logical = line
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if logical != "" {
+		handle(logical)
+	}
+
+	if !sawCalendar {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	return rows, nil
+}
+
+// Marshal renders a single todo as a complete calendar object.
+func Marshal(todo *types.Todo) []byte {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf, "")
+
+	enc.Begin()
+	enc.Encode(todo)
+	enc.End()
+
+	return buf.Bytes()
+}
+
+// ETag is a strong entity tag for the calendar object of a todo. It only
+// covers stored fields, so it stays stable across requests even though
+// DTSTAMP does not.
+func ETag(todo *types.Todo) string {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00%s", todo.ExternalID, todo.Title, todo.CreatedAt.UTC().Format(time.RFC3339Nano), todo.Status, todo.Recurrence)
+
+	if todo.DueAt != nil {
+		fmt.Fprintf(hash, "\x00%s", todo.DueAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
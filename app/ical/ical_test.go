@@ -0,0 +1,113 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	due := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("It should write a VTODO per todo", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf, "Team, todos")
+
+		assert.NoError(t, enc.Begin())
+		assert.NoError(t, enc.Encode(&types.Todo{
+			ExternalID: "2233a6b2-ae99-40fc-bdd7-db49834993ab",
+			Title:      "Buy milk; eggs",
+			CreatedAt:  time.Date(2023, 12, 29, 18, 26, 45, 0, time.UTC),
+			DueAt:      &due,
+			Status:     "needs-action",
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+		}))
+		assert.NoError(t, enc.End())
+
+		out := buf.String()
+
+		assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+		assert.Contains(t, out, "X-WR-CALNAME:Team\\, todos\r\n")
+		assert.Contains(t, out, "UID:2233a6b2-ae99-40fc-bdd7-db49834993ab\r\n")
+		assert.Contains(t, out, "SUMMARY:Buy milk\\; eggs\r\n")
+		assert.Contains(t, out, "CREATED:20231229T182645Z\r\n")
+		assert.Contains(t, out, "DUE:20240501T120000Z\r\n")
+		assert.Contains(t, out, "STATUS:NEEDS-ACTION\r\n")
+		assert.Contains(t, out, "RRULE:FREQ=WEEKLY;BYDAY=MO\r\n")
+		assert.True(t, strings.HasSuffix(out, "END:VTODO\r\nEND:VCALENDAR\r\n"))
+	})
+
+	t.Run("It should fold long lines without splitting characters", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf, "")
+
+		enc.Begin()
+		enc.Encode(&types.Todo{Title: strings.Repeat("ž", 100)})
+		enc.End()
+
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+			assert.LessOrEqual(t, len(line), 75)
+			assert.True(t, strings.ToValidUTF8(line, "?") == line)
+		}
+
+		rows, err := ParseTodos(buf)
+
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("ž", 100), rows[0].Title)
+	})
+}
+
+func TestParseTodos(t *testing.T) {
+	t.Run("It should read VTODO components", func(t *testing.T) {
+		input := "BEGIN:VCALENDAR\r\n" +
+			"VERSION:2.0\r\n" +
+			"BEGIN:VEVENT\r\nSUMMARY:Ignored\r\nEND:VEVENT\r\n" +
+			"BEGIN:VTODO\r\n" +
+			"UID:4ffaaf6e-6693-45a4-b1d2-02da81bebc46\r\n" +
+			"SUMMARY:Write\\, then\r\n  review\\nnotes\r\n" +
+			"CREATED:20240101T100000Z\r\n" +
+			"DUE;VALUE=DATE:20240201\r\n" +
+			"STATUS:COMPLETED\r\n" +
+			"RRULE:FREQ=DAILY;COUNT=3\r\n" +
+			"BEGIN:VALARM\r\nACTION:DISPLAY\r\nSUMMARY:Alarm text\r\nEND:VALARM\r\n" +
+			"END:VTODO\r\n" +
+			"BEGIN:VTODO\r\n" +
+			"UID:not-a-uuid@example.com\r\n" +
+			"SUMMARY:Second\r\n" +
+			"DUE;TZID=Europe/Prague:20240301T090000\r\n" +
+			"END:VTODO\r\n" +
+			"BEGIN:VTODO\r\n" +
+			"DUE:tomorrow\r\n" +
+			"END:VTODO\r\n" +
+			"END:VCALENDAR\r\n"
+
+		rows, err := ParseTodos(strings.NewReader(input))
+
+		assert.NoError(t, err)
+		assert.Len(t, rows, 3)
+
+		assert.Equal(t, "4ffaaf6e-6693-45a4-b1d2-02da81bebc46", rows[0].ID)
+		assert.Equal(t, "Write, then review\nnotes", rows[0].Title)
+		assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), *rows[0].CreatedAt)
+		assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), *rows[0].DueAt)
+		assert.Equal(t, "completed", rows[0].Status)
+		assert.Equal(t, "FREQ=DAILY;COUNT=3", rows[0].Recurrence)
+
+		assert.Equal(t, "", rows[1].ID)
+		assert.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), rows[1].DueAt.UTC())
+
+		assert.Equal(t, 3, rows[2].Row)
+		assert.Equal(t, []string{"DUE is not a valid date-time"}, rows[2].Errors)
+	})
+
+	t.Run("It should reject files that are not calendars", func(t *testing.T) {
+		_, err := ParseTodos(strings.NewReader("title\nfoo\n"))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var frequencies = map[string]bool{
+	"SECONDLY": true, "MINUTELY": true, "HOURLY": true, "DAILY": true,
+	"WEEKLY": true, "MONTHLY": true, "YEARLY": true,
+}
+
+var weekdays = map[string]bool{
+	"SU": true, "MO": true, "TU": true, "WE": true, "TH": true, "FR": true, "SA": true,
+}
+
+// numberLists bounds the parts holding a list of numbers. Those allowing a
+// negative number count back from the end of the period.
+var numberLists = map[string]struct {
+	min, max int
+	signed   bool
+}{
+	"BYSECOND":   {0, 60, false},
+	"BYMINUTE":   {0, 59, false},
+	"BYHOUR":     {0, 23, false},
+	"BYMONTHDAY": {1, 31, true},
+	"BYYEARDAY":  {1, 366, true},
+	"BYWEEKNO":   {1, 53, true},
+	"BYMONTH":    {1, 12, false},
+	"BYSETPOS":   {1, 366, true},
+}
+
+// ValidateRRule checks that value is an RRULE property value (RFC 5545
+// section 3.3.10). The value is written into calendars as it is, so
+// anything else, control characters above all, must not get stored.
+func ValidateRRule(value string) error {
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ";") {
+		name, partValue, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+
+		if !ok || partValue == "" {
+			return fmt.Errorf("%q is not a NAME=VALUE part", part)
+		}
+
+		if seen[name] {
+			return fmt.Errorf("%s is given twice", name)
+		}
+
+		seen[name] = true
+
+		if err := validateRulePart(name, strings.ToUpper(partValue)); err != nil {
+			return err
+		}
+	}
+
+	if !seen["FREQ"] {
+		return fmt.Errorf("FREQ is required")
+	}
+
+	if seen["UNTIL"] && seen["COUNT"] {
+		return fmt.Errorf("UNTIL and COUNT cannot both be given")
+	}
+
+	return nil
+}
+
+func validateRulePart(name string, value string) error {
+	switch name {
+	case "FREQ":
+		if !frequencies[value] {
+			return fmt.Errorf("FREQ %q is not a frequency", value)
+		}
+	case "UNTIL":
+		if _, err := time.Parse(dateOnly, value); err == nil {
+			return nil
+		}
+
+		if _, err := time.Parse(dateTimeUTC, value); err == nil {
+			return nil
+		}
+
+		if _, err := time.Parse(dateTimeLocal, value); err != nil {
+			return fmt.Errorf("UNTIL %q is not a date or date-time", value)
+		}
+	case "COUNT", "INTERVAL":
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || value[0] == '+' {
+			return fmt.Errorf("%s %q is not a positive number", name, value)
+		}
+	case "WKST":
+		if !weekdays[value] {
+			return fmt.Errorf("WKST %q is not a weekday", value)
+		}
+	case "BYDAY":
+		for _, day := range strings.Split(value, ",") {
+			if len(day) < 2 || !weekdays[day[len(day)-2:]] {
+				return fmt.Errorf("BYDAY %q is not a weekday", day)
+			}
+
+			if ordinal := day[:len(day)-2]; ordinal != "" && !inRange(ordinal, 1, 53, true) {
+				return fmt.Errorf("BYDAY %q has an invalid ordinal", day)
+			}
+		}
+	default:
+		bounds, ok := numberLists[name]
+		if !ok {
+			return fmt.Errorf("%q is not an RRULE part", name)
+		}
+
+		for _, number := range strings.Split(value, ",") {
+			if !inRange(number, bounds.min, bounds.max, bounds.signed) {
+				return fmt.Errorf("%s %q is out of range", name, number)
+			}
+		}
+	}
+
+	return nil
+}
+
+// inRange reports whether value is a number from min to max, or from -max
+// to -min when signed.
+func inRange(value string, min int, max int, signed bool) bool {
+	digits := value
+
+	if signed && (strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+")) {
+		digits = value[1:]
+	}
+
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return false
+	}
+
+	n, err := strconv.Atoi(digits)
+
+	return err == nil && n >= min && n <= max
+}
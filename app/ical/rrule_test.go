@@ -0,0 +1,55 @@
+package ical
+
+import (
+	"bytes"
+	"testing"
+
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRRule(t *testing.T) {
+	t.Run("It should accept RRULE values", func(t *testing.T) {
+		for _, value := range []string{
+			"FREQ=DAILY",
+			"FREQ=WEEKLY;BYDAY=MO,WE,FR;WKST=MO",
+			"freq=monthly;byday=-1fr;interval=2",
+			"FREQ=YEARLY;BYMONTH=1;BYMONTHDAY=-1;UNTIL=20251231T235959Z",
+			"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=12",
+		} {
+			assert.NoError(t, ValidateRRule(value), value)
+		}
+	})
+
+	t.Run("It should reject anything else", func(t *testing.T) {
+		for _, value := range []string{
+			"",
+			"COUNT=3",
+			"FREQ=FORTNIGHTLY",
+			"FREQ=DAILY;FREQ=WEEKLY",
+			"FREQ=DAILY;COUNT=0",
+			"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+			"FREQ=WEEKLY;BYDAY=XX",
+			"FREQ=MONTHLY;BYMONTHDAY=32",
+			"FREQ=DAILY;BYHOUR=-1",
+			"FREQ=DAILY;FOO=BAR",
+			"FREQ=DAILY\r\nATTENDEE:mailto:eve@example.com",
+			"FREQ=DAILY;COUNT=3\nEND:VTODO",
+		} {
+			assert.Error(t, ValidateRRule(value), value)
+		}
+	})
+}
+
+func TestEncoderSkipsInvalidRecurrence(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf, "")
+
+	enc.Begin()
+	enc.Encode(&types.Todo{Title: "Injected", Recurrence: "FREQ=DAILY\r\nATTENDEE:mailto:eve@example.com"})
+	enc.End()
+
+	assert.NotContains(t, buf.String(), "RRULE")
+	assert.NotContains(t, buf.String(), "ATTENDEE")
+}
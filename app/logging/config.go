@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/types"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatText   = "text"
+	FormatLogfmt = "logfmt"
+
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// Configure sets up logger as config asks for: its level, format, whether it
+// reports the calling function, where it writes to and which fields it
+// redacts. An output other than stdout or stderr is a file that is appended
+// to.
+func Configure(logger *logrus.Logger, config *types.Config) error {
+	level, err := logrus.ParseLevel(config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+
+	formatter, err := newFormatter(config.LogFormat)
+	if err != nil {
+		return err
+	}
+
+	output, err := openOutput(config.LogOutput)
+	if err != nil {
+		return err
+	}
+
+	logger.SetLevel(level)
+	logger.SetFormatter(formatter)
+	logger.SetReportCaller(config.LogReportCaller)
+	logger.SetOutput(output)
+	logger.AddHook(NewRedactHook(strings.Split(config.LogRedactFields, ",")))
+
+	return nil
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case FormatJSON:
+		return &logrus.JSONFormatter{}, nil
+	case FormatText:
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	case FormatLogfmt:
+		// Without colours, logrus' text format is logfmt.
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true, QuoteEmptyFields: true}, nil
+	}
+
+	return nil, fmt.Errorf("LOG_FORMAT: unknown format %q, want json, text or logfmt", format)
+}
+
+func openOutput(output string) (io.Writer, error) {
+	switch output {
+	case "", OutputStderr:
+		return os.Stderr, nil
+	case OutputStdout:
+		return os.Stdout, nil
+	}
+
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_OUTPUT: %w", err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,45 @@
+// Package logging carries a request's log entry and ID through its context,
+// so that everything logged while serving a request can be tied together.
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	entryKey contextKey = iota
+	requestIDKey
+)
+
+// RequestIDField is the log field holding the request ID.
+const RequestIDField = "request_id"
+
+// WithRequest returns ctx carrying requestID and an entry of the standard
+// logger that logs it with every line.
+func WithRequest(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+
+	return context.WithValue(ctx, entryKey, logrus.WithField(RequestIDField, requestID))
+}
+
+// FromContext returns the log entry of the request ctx belongs to, or one of
+// the standard logger outside of requests. The entry carries ctx, so hooks
+// can read the trace it belongs to.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey).(*logrus.Entry); ok {
+		return entry.WithContext(ctx)
+	}
+
+	return logrus.WithContext(ctx)
+}
+
+// RequestIDFromContext returns the ID of the request ctx belongs to, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+
+	return requestID
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	hook := test.NewLocal(logrus.StandardLogger())
+	defer hook.Reset()
+
+	t.Run("It should log the request ID of the request", func(t *testing.T) {
+		ctx := WithRequest(context.Background(), "req-1")
+
+		FromContext(ctx).WithField("event", "test").Info("handled")
+
+		entry := hook.LastEntry()
+		assert.Equal(t, "req-1", entry.Data[RequestIDField])
+		assert.Equal(t, "test", entry.Data["event"])
+		assert.Equal(t, ctx, entry.Context)
+		assert.Equal(t, "req-1", RequestIDFromContext(ctx))
+	})
+
+	t.Run("It should fall back to the standard logger outside of requests", func(t *testing.T) {
+		FromContext(context.Background()).Info("background")
+
+		entry := hook.LastEntry()
+		assert.NotContains(t, entry.Data, RequestIDField)
+		assert.Empty(t, RequestIDFromContext(context.Background()))
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	t.Run("It should log in the configured level, format and output", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		logger := logrus.New()
+
+		err := Configure(logger, &types.Config{LogLevel: "debug", LogFormat: FormatLogfmt, LogOutput: path, LogRedactFields: "token"})
+		assert.NoError(t, err)
+
+		logger.WithField("token", "tda_secret").Debug("visible")
+
+		out, _ := os.ReadFile(path)
+		assert.Contains(t, string(out), `level=debug msg=visible token="[REDACTED]"`)
+	})
+
+	t.Run("It should reject unknown levels and formats", func(t *testing.T) {
+		assert.Error(t, Configure(logrus.New(), &types.Config{LogLevel: "chatty", LogFormat: FormatJSON}))
+		assert.Error(t, Configure(logrus.New(), &types.Config{LogLevel: "info", LogFormat: "xml"}))
+	})
+}
+
+func TestRedactHook(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(NewRedactHook([]string{"token", " Secret "}))
+
+	hook := test.NewLocal(logger)
+
+	logger.WithFields(logrus.Fields{
+		"token":  "tda_abc",
+		"SECRET": "hunter2",
+		"error":  errors.New("no user alice@example.com"),
+		"event":  "login",
+	}).Info("Invited bob.smith@example.org")
+
+	entry := hook.LastEntry()
+	assert.Equal(t, Redacted, entry.Data["token"])
+	assert.Equal(t, Redacted, entry.Data["SECRET"])
+	assert.Equal(t, "no user a***@example.com", entry.Data["error"])
+	assert.Equal(t, "login", entry.Data["event"])
+	assert.Equal(t, "Invited b***@example.org", entry.Message)
+}
+
+func TestSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	sampler := NewSampler(2, 3, time.Second)
+	sampler.now = func() time.Time { return now }
+
+	var allowed []bool
+
+	for i := 0; i < 8; i++ {
+		allowed = append(allowed, sampler.Allow())
+	}
+
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true}, allowed)
+
+	now = now.Add(time.Second)
+
+	assert.True(t, sampler.Allow())
+	assert.Nil(t, NewSampler(0, 10, time.Second))
+	assert.True(t, NewSampler(0, 10, time.Second).Allow())
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces the values of sensitive fields.
+const Redacted = "[REDACTED]"
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// RedactHook keeps secrets and personal data out of logs. It replaces the
+// values of the fields it is given, matched regardless of case, and masks
+// email addresses in the message and the other string fields down to their
+// first letter and domain.
+type RedactHook struct {
+	fields map[string]bool
+}
+
+func NewRedactHook(fields []string) *RedactHook {
+	hook := &RedactHook{fields: map[string]bool{}}
+
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			hook.fields[strings.ToLower(field)] = true
+		}
+	}
+
+	return hook
+}
+
+func (hook *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire changes the entry in place, which is safe as logrus hands hooks a copy
+// of the entry's fields.
+func (hook *RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = maskEmails(entry.Message)
+
+	for key, value := range entry.Data {
+		if hook.fields[strings.ToLower(key)] {
+			entry.Data[key] = Redacted
+
+			continue
+		}
+
+		switch value := value.(type) {
+		case string:
+			entry.Data[key] = maskEmails(value)
+		case error:
+			entry.Data[key] = maskEmails(value.Error())
+		}
+	}
+
+	return nil
+}
+
+func maskEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
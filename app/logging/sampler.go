@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler thins out high-volume logs. In every tick it lets the first initial
+// entries through and after that every thereafter-th one, or none if
+// thereafter is 0. A nil Sampler lets everything through.
+type Sampler struct {
+	initial    int
+	thereafter int
+	tick       time.Duration
+	now        func() time.Time
+
+	mu    sync.Mutex
+	start time.Time
+	count int
+}
+
+// NewSampler returns a Sampler, or nil, which samples nothing out, if initial
+// is not positive.
+func NewSampler(initial int, thereafter int, tick time.Duration) *Sampler {
+	if initial <= 0 {
+		return nil
+	}
+
+	return &Sampler{initial: initial, thereafter: thereafter, tick: tick, now: time.Now}
+}
+
+// Allow reports whether the next entry should be logged.
+func (sampler *Sampler) Allow() bool {
+	if sampler == nil {
+		return true
+	}
+
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+
+	now := sampler.now()
+
+	if now.Sub(sampler.start) >= sampler.tick {
+		sampler.start = now
+		sampler.count = 0
+	}
+
+	sampler.count++
+
+	if sampler.count <= sampler.initial {
+		return true
+	}
+
+	return sampler.thereafter > 0 && (sampler.count-sampler.initial)%sampler.thereafter == 0
+}
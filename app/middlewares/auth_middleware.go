@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// apiKeyFromRequest reads a key from an X-API-Key header, a bearer token, or
+// the password of Basic credentials, which is all most CalDAV clients can
+// send. Bearer JWTs are not API keys and are left alone.
+func apiKeyFromRequest(req *http.Request) string {
+	if key := req.Header.Get(constant.APIKeyHeader); key != "" {
+		return key
+	}
+
+	if _, password, ok := req.BasicAuth(); ok {
+		return password
+	}
+
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && bearerJWT(req) == "" {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+func respondUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer, Basic realm="todo-app"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": message})
+}
+
+// sessionKey stands in for an API key for a login session: a personal key of
+// the session's workspace. Users may work on their todos and manage their own
+// keys, but not the workspace-wide webhooks or workspaces.
+func sessionKey(session *types.Session) *types.APIKey {
+	return &types.APIKey{
+		Name:        constant.SessionKeyName,
+		WorkspaceID: session.WorkspaceID,
+		User:        session.User,
+		Scopes:      []string{constant.ScopeTodosRead, constant.ScopeTodosWrite, constant.ScopeKeysManage},
+	}
+}
+
+// AuthMiddleware authenticates the API key or session token of a request, if
+// it carries one, and stores it in the context for RequireScope. Requests
+// without either pass through; whether they may reach a route is up to
+// RequireScope. sessionService is nil when OIDC login is not configured, and
+// session tokens are then refused as they cannot be verified.
+func AuthMiddleware(apiKeyService *service.APIKeyService, sessionService *service.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := bearerJWT(c.Request); token != "" {
+			if sessionService == nil {
+				respondUnauthorized(c, constant.ErrMsgSessionInvalid)
+
+				return
+			}
+
+			session, err := sessionService.AuthenticateSession(token)
+
+			if err != nil {
+				if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonUnauthorized {
+					respondUnauthorized(c, todoErr.Message)
+
+					return
+				}
+
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": constant.ErrMsgInternalServer})
+
+				return
+			}
+
+			c.Set(constant.APIKeyContextKey, sessionKey(session))
+			c.Next()
+
+			return
+		}
+
+		secret := apiKeyFromRequest(c.Request)
+
+		if secret == "" {
+			c.Next()
+
+			return
+		}
+
+		key, err := apiKeyService.AuthenticateKey(secret)
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonUnauthorized {
+				respondUnauthorized(c, todoErr.Message)
+
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": constant.ErrMsgInternalServer})
+
+			return
+		}
+
+		c.Set(constant.APIKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// APIKeyFromContext returns the key AuthMiddleware authenticated, the stand-in
+// key of a session, or nil.
+func APIKeyFromContext(c *gin.Context) *types.APIKey {
+	if value, ok := c.Get(constant.APIKeyContextKey); ok {
+		if key, ok := value.(*types.APIKey); ok {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// ActorFromContext returns the user a personal key acts for, or nil for
+// service keys and unauthenticated requests.
+func ActorFromContext(c *gin.Context) *types.User {
+	if key := APIKeyFromContext(c); key != nil {
+		return key.User
+	}
+
+	return nil
+}
+
+// RequireScope guards a route. A key without scopes may use every route,
+// otherwise it must hold scope. Requests without a key are only let through
+// when authentication is not required.
+func RequireScope(scope string, authRequired bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKeyFromContext(c)
+
+		if key == nil {
+			if authRequired {
+				respondUnauthorized(c, constant.APIKeyUnauthorizedMessage)
+
+				return
+			}
+
+			c.Next()
+
+			return
+		}
+
+		if len(key.Scopes) > 0 && !hasScope(key.Scopes, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": constant.APIKeyForbiddenMessage})
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
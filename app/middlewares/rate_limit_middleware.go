@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/ratelimit"
+)
+
+// rateLimitClient names whose bucket a request draws from: its API key, the
+// user of its session, or else its IP address.
+func rateLimitClient(c *gin.Context) string {
+	if key := APIKeyFromContext(c); key != nil {
+		if key.ExternalID == "" && key.User != nil {
+			return "user:" + key.User.ExternalID
+		}
+
+		return "key:" + key.ExternalID
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimitMiddleware limits each client per route according to rules and
+// reports the state of its bucket in RateLimit-* headers. It must run after
+// AuthMiddleware to tell clients apart by key. When the store fails, requests
+// are let through rather than turning a store outage into an API outage.
+func RateLimitMiddleware(rules ratelimit.Rules, store ratelimit.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+
+		limit, ok := rules.For(c.Request.Method, route)
+		if !ok {
+			c.Next()
+
+			return
+		}
+
+		result, err := store.Take(c.Request.Context(), rateLimitClient(c)+" "+c.Request.Method+" "+route, limit)
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.RateLimitLogEventErrorKey,
+				"error": err.Error(),
+			}).Error("Failed to take a rate limit token")
+
+			c.Next()
+
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.ResetAfter))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Window)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": constant.ErrMsgRateLimited})
+
+			return
+		}
+
+		c.Next()
+	}
+}
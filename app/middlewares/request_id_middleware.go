@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"todo-app/app/constant"
+	"todo-app/app/logging"
+)
+
+// requestIDPattern bounds the IDs accepted from clients, so they cannot
+// smuggle arbitrary text into logs and response headers.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware takes the request's X-Request-ID, or generates one, and
+// echoes it in the response. The ID and a log entry carrying it are put into
+// the request's context for the handlers and services below; see
+// logging.FromContext. It must run after TracingMiddleware so the entry also
+// carries the trace.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(constant.RequestIDHeader)
+
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Header(constant.RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequest(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"todo-app/app/telemetry"
+)
+
+// tracedWriter marks when the response starts being written. Handlers encode
+// their JSON before writing it, so the time from the last SQL span to this
+// event is mostly encoding.
+type tracedWriter struct {
+	gin.ResponseWriter
+	span    trace.Span
+	written bool
+}
+
+func (w *tracedWriter) markWritten() {
+	if !w.written {
+		w.written = true
+		w.span.AddEvent("response.write")
+	}
+}
+
+func (w *tracedWriter) Write(data []byte) (int, error) {
+	w.markWritten()
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *tracedWriter) WriteString(s string) (int, error) {
+	w.markWritten()
+
+	return w.ResponseWriter.WriteString(s)
+}
+
+// TracingMiddleware records a server span per request, continuing the trace
+// of an incoming W3C traceparent header. The span is named after the route
+// rather than the path, so requests for different todos are grouped together,
+// and its context replaces the request's so everything below becomes a child
+// span.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+
+		if route == "" {
+			name = c.Request.Method
+		}
+
+		ctx, span := telemetry.Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			))
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				span.SetStatus(codes.Error, fmt.Sprint(recovered))
+				span.End()
+
+				panic(recovered)
+			}
+
+			status := c.Writer.Status()
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			for _, err := range c.Errors {
+				span.RecordError(err.Err)
+			}
+
+			span.End()
+		}()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &tracedWriter{ResponseWriter: c.Writer, span: span}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/openapi"
+)
+
+// ValidationMiddleware rejects requests whose path parameters, query
+// parameters or JSON body do not match the OpenAPI spec, listing every
+// offending field.
+func ValidationMiddleware(validator *openapi.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if errs := validator.Validate(c.Request, c.FullPath(), c.Param); len(errs) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"message": "Validation failed",
+				"errors":  errs,
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
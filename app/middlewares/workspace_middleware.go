@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// workspaceSlugFromRequest reads the workspace a request names: the subdomain
+// of baseDomain it was sent to or an X-Workspace header, in that order. It
+// returns "" when there is none. Neither proves anything; the request's
+// credentials must be valid in the workspace named.
+func workspaceSlugFromRequest(req *http.Request, baseDomain string) string {
+	if baseDomain != "" {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain)); ok && !strings.Contains(sub, ".") {
+			return sub
+		}
+	}
+
+	return strings.ToLower(req.Header.Get(constant.WorkspaceHeader))
+}
+
+// bearerJWT returns the bearer token of a request if it is shaped like a JWT.
+func bearerJWT(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	token = strings.TrimSpace(token)
+	if strings.Count(token, ".") != 2 {
+		return ""
+	}
+
+	return token
+}
+
+// signInRoutes are where credentials for a workspace are obtained, so they
+// are served in any workspace without any.
+var signInRoutes = map[string]bool{
+	"/auth/login":    true,
+	"/auth/callback": true,
+}
+
+// WorkspaceMiddleware resolves the workspace a request is served in and
+// stores it in the context. A request that names no workspace is served in
+// the workspace of its API key or session, or the default one. Credentials
+// only work in their own workspace, and only the default workspace and the
+// sign-in routes may be used without any; for a session the workspace is the
+// one of its verified token.
+func WorkspaceMiddleware(workspaceService *service.WorkspaceService, baseDomain string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKeyFromContext(c)
+
+		var workspace *types.Workspace
+		var err error
+
+		switch slug := workspaceSlugFromRequest(c.Request, baseDomain); {
+		case slug != "":
+			workspace, err = workspaceService.GetWorkspaceBySlug(slug)
+		case key != nil:
+			workspace, err = workspaceService.GetWorkspaceByID(key.WorkspaceID)
+		default:
+			workspace, err = workspaceService.GetWorkspaceByID(constant.DefaultWorkspaceID)
+		}
+
+		if err != nil {
+			if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonNotFound {
+				// Without credentials, which workspaces exist is not
+				// revealed either.
+				if key == nil && !signInRoutes[c.FullPath()] {
+					respondUnauthorized(c, constant.ErrMsgWorkspaceUnauthorized)
+
+					return
+				}
+
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": todoErr.Message})
+
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": constant.ErrMsgInternalServer})
+
+			return
+		}
+
+		if key == nil && workspace.ID != constant.DefaultWorkspaceID && !signInRoutes[c.FullPath()] {
+			respondUnauthorized(c, constant.ErrMsgWorkspaceUnauthorized)
+
+			return
+		}
+
+		if key != nil && key.WorkspaceID != workspace.ID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": constant.ErrMsgWorkspaceMismatch})
+
+			return
+		}
+
+		c.Set(constant.WorkspaceContextKey, workspace)
+		c.Next()
+	}
+}
+
+// WorkspaceFromContext returns the workspace WorkspaceMiddleware resolved, or
+// nil.
+func WorkspaceFromContext(c *gin.Context) *types.Workspace {
+	if value, ok := c.Get(constant.WorkspaceContextKey); ok {
+		if workspace, ok := value.(*types.Workspace); ok {
+			return workspace
+		}
+	}
+
+	return nil
+}
+
+// WorkspaceIDFromContext returns the ID of the request's workspace. Requests
+// that did not pass WorkspaceMiddleware are served in the default workspace.
+func WorkspaceIDFromContext(c *gin.Context) int {
+	if workspace := WorkspaceFromContext(c); workspace != nil {
+		return workspace.ID
+	}
+
+	return constant.DefaultWorkspaceID
+}
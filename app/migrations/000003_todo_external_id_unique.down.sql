@@ -0,0 +1 @@
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_external_id_key;
@@ -0,0 +1,4 @@
+-- Clients choose the IDs of the todos they import, so only the database can
+-- keep two requests from creating the same one.
+ALTER TABLE todos
+		ADD CONSTRAINT todos_external_id_key UNIQUE (external_id);
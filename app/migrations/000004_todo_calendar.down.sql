@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS calendar_feeds;
+
+ALTER TABLE todos
+		DROP COLUMN IF EXISTS recurrence,
+		DROP COLUMN IF EXISTS status,
+		DROP COLUMN IF EXISTS due_at;
@@ -0,0 +1,13 @@
+ALTER TABLE todos
+		ADD COLUMN IF NOT EXISTS due_at TIMESTAMP WITH TIME ZONE,
+		ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'needs-action',
+		ADD COLUMN IF NOT EXISTS recurrence TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+		id SERIAL PRIMARY KEY,
+		external_id UUID NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		last_used_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS todo_changes (
+		seq BIGSERIAL PRIMARY KEY,
+		external_id UUID NOT NULL,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS todo_changes_external_id_idx ON todo_changes (external_id, seq);
+
+-- Every write to todos is logged here, whatever code path made it. The table
+-- lock serialises writers until they commit, so sequence numbers become
+-- visible in order and a reader never skips a change committed late.
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted) VALUES (OLD.external_id, TRUE);
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id) VALUES (NEW.external_id);
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_record_change ON todos;
+
+CREATE TRIGGER todos_record_change
+		AFTER INSERT OR UPDATE OR DELETE ON todos
+		FOR EACH ROW EXECUTE FUNCTION record_todo_change();
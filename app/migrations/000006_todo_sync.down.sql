@@ -0,0 +1,27 @@
+DROP TRIGGER IF EXISTS todos_touch_fields ON todos;
+DROP FUNCTION IF EXISTS touch_todo_fields();
+
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted) VALUES (OLD.external_id, TRUE);
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id) VALUES (NEW.external_id);
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE todos
+		DROP COLUMN IF EXISTS title_updated_at,
+		DROP COLUMN IF EXISTS due_at_updated_at,
+		DROP COLUMN IF EXISTS status_updated_at,
+		DROP COLUMN IF EXISTS recurrence_updated_at;
+
+ALTER TABLE todo_changes
+		DROP COLUMN IF EXISTS created;
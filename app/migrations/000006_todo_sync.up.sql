@@ -0,0 +1,61 @@
+ALTER TABLE todo_changes
+		ADD COLUMN IF NOT EXISTS created BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Per-field modification times drive last-writer-wins merging in POST /sync.
+ALTER TABLE todos
+		ADD COLUMN IF NOT EXISTS title_updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS due_at_updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS recurrence_updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted) VALUES (OLD.external_id, TRUE);
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created) VALUES (NEW.external_id, TG_OP = 'INSERT');
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Stamps a field whenever its value changes, unless the writer set the stamp
+-- itself as sync does with the client's modification time.
+CREATE OR REPLACE FUNCTION touch_todo_fields() RETURNS TRIGGER AS $$
+BEGIN
+		IF NEW.title IS DISTINCT FROM OLD.title AND NEW.title_updated_at IS NOT DISTINCT FROM OLD.title_updated_at THEN
+				NEW.title_updated_at := CURRENT_TIMESTAMP;
+		END IF;
+
+		IF NEW.due_at IS DISTINCT FROM OLD.due_at AND NEW.due_at_updated_at IS NOT DISTINCT FROM OLD.due_at_updated_at THEN
+				NEW.due_at_updated_at := CURRENT_TIMESTAMP;
+		END IF;
+
+		IF NEW.status IS DISTINCT FROM OLD.status AND NEW.status_updated_at IS NOT DISTINCT FROM OLD.status_updated_at THEN
+				NEW.status_updated_at := CURRENT_TIMESTAMP;
+		END IF;
+
+		IF NEW.recurrence IS DISTINCT FROM OLD.recurrence AND NEW.recurrence_updated_at IS NOT DISTINCT FROM OLD.recurrence_updated_at THEN
+				NEW.recurrence_updated_at := CURRENT_TIMESTAMP;
+		END IF;
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_touch_fields ON todos;
+
+CREATE TRIGGER todos_touch_fields
+		BEFORE UPDATE ON todos
+		FOR EACH ROW EXECUTE FUNCTION touch_todo_fields();
+
+-- Todos written before the change log was added have no entry in it, and
+-- would be missing from a sync that starts from scratch.
+INSERT INTO todo_changes (external_id, created)
+		SELECT external_id, TRUE FROM todos
+		WHERE NOT EXISTS (SELECT 1 FROM todo_changes WHERE todo_changes.external_id = todos.external_id);
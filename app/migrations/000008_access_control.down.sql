@@ -0,0 +1,16 @@
+DROP VIEW IF EXISTS todo_roles;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS acl_entries;
+
+ALTER TABLE todos
+		DROP COLUMN IF EXISTS list_id;
+
+DROP TABLE IF EXISTS todo_lists;
+
+ALTER TABLE calendar_feeds
+		DROP COLUMN IF EXISTS user_id;
+
+ALTER TABLE api_keys
+		DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS users;
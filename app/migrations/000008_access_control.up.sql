@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		external_id UUID NOT NULL UNIQUE,
+		email TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE api_keys
+		ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE calendar_feeds
+		ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS todo_lists (
+		id SERIAL PRIMARY KEY,
+		external_id UUID NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE todos
+		ADD COLUMN IF NOT EXISTS list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE;
+
+-- An entry grants a user a role on either a list, covering every todo in it,
+-- or a single todo.
+CREATE TABLE IF NOT EXISTS acl_entries (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE,
+		todo_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		CHECK ((list_id IS NULL) <> (todo_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS acl_entries_list_user_idx ON acl_entries (list_id, user_id) WHERE list_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS acl_entries_todo_user_idx ON acl_entries (todo_id, user_id) WHERE todo_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS acl_entries_user_idx ON acl_entries (user_id);
+
+CREATE TABLE IF NOT EXISTS invitations (
+		id SERIAL PRIMARY KEY,
+		external_id UUID NOT NULL UNIQUE,
+		list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE,
+		todo_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+		email TEXT NOT NULL,
+		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+		token_hash TEXT NOT NULL UNIQUE,
+		invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		accepted_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		CHECK ((list_id IS NULL) <> (todo_id IS NULL))
+);
+
+-- Every role a user holds on a todo, directly or through its list. rank
+-- orders the roles so the strongest one can be picked with MAX.
+CREATE OR REPLACE VIEW todo_roles AS
+		SELECT a.todo_id, a.user_id, a.role,
+				CASE a.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END AS rank
+		FROM acl_entries a
+		WHERE a.todo_id IS NOT NULL
+		UNION ALL
+		SELECT t.id, a.user_id, a.role,
+				CASE a.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END AS rank
+		FROM todos t
+		JOIN acl_entries a ON a.list_id = t.list_id;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS acl_entries_record_revoke ON acl_entries;
+DROP TRIGGER IF EXISTS acl_entries_record_grant ON acl_entries;
+DROP FUNCTION IF EXISTS record_acl_change();
+DROP TRIGGER IF EXISTS todos_record_viewers_change ON todos;
+DROP FUNCTION IF EXISTS record_todo_viewers_change();
+DELETE FROM todo_changes WHERE user_id IS NOT NULL;
+ALTER TABLE todo_changes DROP COLUMN IF EXISTS user_id;
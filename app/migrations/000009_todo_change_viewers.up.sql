@@ -0,0 +1,57 @@
+-- A sync reader acting for a user only hears about todos that user can see.
+-- Deleting a todo or its share hides who could see it, so those are logged
+-- once more for each user who loses it, and a new share for the user who
+-- gains it. Entries with a user_id are meant for that user only.
+ALTER TABLE todo_changes
+		ADD COLUMN IF NOT EXISTS user_id INTEGER;
+
+-- Runs before the delete, while the roles on the todo can still be read.
+CREATE OR REPLACE FUNCTION record_todo_viewers_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		INSERT INTO todo_changes (external_id, deleted, user_id)
+				SELECT DISTINCT OLD.external_id, TRUE, user_id FROM todo_roles WHERE todo_id = OLD.id;
+
+		RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_record_viewers_change ON todos;
+
+CREATE TRIGGER todos_record_viewers_change
+		BEFORE DELETE ON todos
+		FOR EACH ROW EXECUTE FUNCTION record_todo_viewers_change();
+
+-- A role on a list covers every todo in it.
+CREATE OR REPLACE FUNCTION record_acl_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted, user_id)
+						SELECT external_id, TRUE, OLD.user_id FROM todos
+						WHERE todos.id = OLD.todo_id OR todos.list_id = OLD.list_id;
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created, user_id)
+				SELECT external_id, TRUE, NEW.user_id FROM todos
+				WHERE todos.id = NEW.todo_id OR todos.list_id = NEW.list_id;
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS acl_entries_record_grant ON acl_entries;
+
+CREATE TRIGGER acl_entries_record_grant
+		AFTER INSERT ON acl_entries
+		FOR EACH ROW EXECUTE FUNCTION record_acl_change();
+
+DROP TRIGGER IF EXISTS acl_entries_record_revoke ON acl_entries;
+
+CREATE TRIGGER acl_entries_record_revoke
+		BEFORE DELETE ON acl_entries
+		FOR EACH ROW EXECUTE FUNCTION record_acl_change();
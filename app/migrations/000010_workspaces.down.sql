@@ -0,0 +1,101 @@
+DROP POLICY IF EXISTS workspace_isolation ON invitations;
+DROP POLICY IF EXISTS workspace_isolation ON acl_entries;
+DROP POLICY IF EXISTS workspace_isolation ON todo_lists;
+DROP POLICY IF EXISTS workspace_isolation ON users;
+DROP POLICY IF EXISTS workspace_isolation ON api_keys;
+DROP POLICY IF EXISTS workspace_isolation ON todo_changes;
+DROP POLICY IF EXISTS workspace_isolation ON calendar_feeds;
+DROP POLICY IF EXISTS workspace_isolation ON webhook_deliveries;
+DROP POLICY IF EXISTS workspace_isolation ON webhooks;
+DROP POLICY IF EXISTS workspace_isolation ON todos;
+
+ALTER TABLE invitations DISABLE ROW LEVEL SECURITY;
+ALTER TABLE acl_entries DISABLE ROW LEVEL SECURITY;
+ALTER TABLE todo_lists DISABLE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+ALTER TABLE api_keys DISABLE ROW LEVEL SECURITY;
+ALTER TABLE todo_changes DISABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_feeds DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks DISABLE ROW LEVEL SECURITY;
+ALTER TABLE todos DISABLE ROW LEVEL SECURITY;
+
+ALTER VIEW todo_roles RESET (security_invoker);
+
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM todo_tenant;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM todo_tenant;
+REVOKE USAGE ON SCHEMA public FROM todo_tenant;
+
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted) VALUES (OLD.external_id, TRUE);
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created) VALUES (NEW.external_id, TG_OP = 'INSERT');
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_todo_viewers_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		INSERT INTO todo_changes (external_id, deleted, user_id)
+				SELECT DISTINCT OLD.external_id, TRUE, user_id FROM todo_roles WHERE todo_id = OLD.id;
+
+		RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_acl_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted, user_id)
+						SELECT external_id, TRUE, OLD.user_id FROM todos
+						WHERE todos.id = OLD.todo_id OR todos.list_id = OLD.list_id;
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created, user_id)
+				SELECT external_id, TRUE, NEW.user_id FROM todos
+				WHERE todos.id = NEW.todo_id OR todos.list_id = NEW.list_id;
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE todos
+		DROP CONSTRAINT IF EXISTS todos_workspace_external_id_key;
+
+ALTER TABLE todos
+		ADD CONSTRAINT todos_external_id_key UNIQUE (external_id);
+
+DROP INDEX IF EXISTS users_workspace_email_idx;
+
+ALTER TABLE users
+		ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS todo_changes_workspace_idx;
+DROP INDEX IF EXISTS todos_workspace_idx;
+
+ALTER TABLE invitations DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE acl_entries DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE todo_lists DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE users DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE todo_changes DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE calendar_feeds DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE todos DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,175 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+		id SERIAL PRIMARY KEY,
+		external_id UUID NOT NULL UNIQUE,
+		slug TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Everything created before workspaces existed belongs to the default one.
+INSERT INTO workspaces (id, external_id, slug, name)
+		VALUES (1, 'a8f6d3a0-1c2b-4d5e-8f70-000000000001', 'default', 'Default')
+		ON CONFLICT (id) DO NOTHING;
+
+SELECT setval('workspaces_id_seq', GREATEST((SELECT MAX(id) FROM workspaces), 1));
+
+-- Rows are stamped with the workspace of the transaction that inserts them,
+-- so no INSERT has to name it. Statements run outside of a workspace, such as
+-- migrations and seeds, write to the default one.
+ALTER TABLE todos
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE webhooks
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE webhook_deliveries
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE calendar_feeds
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE todo_changes
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE api_keys
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE todo_lists
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE acl_entries
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+ALTER TABLE invitations
+		ADD COLUMN IF NOT EXISTS workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS todos_workspace_idx ON todos (workspace_id);
+CREATE INDEX IF NOT EXISTS todo_changes_workspace_idx ON todo_changes (workspace_id, seq);
+
+-- The same person may sign up to several workspaces.
+ALTER TABLE users
+		DROP CONSTRAINT IF EXISTS users_email_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_workspace_email_idx ON users (workspace_id, email);
+
+-- Todo IDs are chosen by clients, and only have to be unique within a
+-- workspace.
+ALTER TABLE todos
+		DROP CONSTRAINT IF EXISTS todos_external_id_key;
+
+ALTER TABLE todos
+		ADD CONSTRAINT todos_workspace_external_id_key UNIQUE (workspace_id, external_id);
+
+-- Changes are logged in the workspace of the todo, whoever makes them.
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted, workspace_id) VALUES (OLD.external_id, TRUE, OLD.workspace_id);
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created, workspace_id) VALUES (NEW.external_id, TG_OP = 'INSERT', NEW.workspace_id);
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_todo_viewers_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		INSERT INTO todo_changes (external_id, deleted, workspace_id, user_id)
+				SELECT DISTINCT OLD.external_id, TRUE, OLD.workspace_id, user_id FROM todo_roles WHERE todo_id = OLD.id;
+
+		RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_acl_change() RETURNS TRIGGER AS $$
+BEGIN
+		LOCK TABLE todo_changes IN SHARE ROW EXCLUSIVE MODE;
+
+		IF TG_OP = 'DELETE' THEN
+				INSERT INTO todo_changes (external_id, deleted, workspace_id, user_id)
+						SELECT external_id, TRUE, workspace_id, OLD.user_id FROM todos
+						WHERE todos.id = OLD.todo_id OR todos.list_id = OLD.list_id;
+
+				RETURN OLD;
+		END IF;
+
+		INSERT INTO todo_changes (external_id, created, workspace_id, user_id)
+				SELECT external_id, TRUE, workspace_id, NEW.user_id FROM todos
+				WHERE todos.id = NEW.todo_id OR todos.list_id = NEW.list_id;
+
+		RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Requests run as todo_tenant with app.workspace_id set for their
+-- transaction, and the policies below limit them to that workspace. The role
+-- the application connects as owns the tables and is not subject to them,
+-- which background work such as webhook delivery and credential lookups
+-- relies on.
+DO $$
+BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'todo_tenant') THEN
+				CREATE ROLE todo_tenant NOLOGIN;
+		END IF;
+END
+$$;
+
+GRANT todo_tenant TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO todo_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON todos, webhooks, webhook_deliveries, calendar_feeds, todo_changes, api_keys, users, todo_lists, acl_entries, invitations, todo_roles TO todo_tenant;
+GRANT USAGE ON ALL SEQUENCES IN SCHEMA public TO todo_tenant;
+
+-- Views check the tables they read with the privileges of their owner unless
+-- told otherwise, which would skip the policies.
+ALTER VIEW todo_roles SET (security_invoker = true);
+
+ALTER TABLE todos ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_feeds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE todo_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE todo_lists ENABLE ROW LEVEL SECURITY;
+ALTER TABLE acl_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invitations ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY workspace_isolation ON todos
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON webhooks
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON webhook_deliveries
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON calendar_feeds
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON todo_changes
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON api_keys
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON users
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON todo_lists
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON acl_entries
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
+
+CREATE POLICY workspace_isolation ON invitations
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
@@ -0,0 +1,21 @@
+-- Links users to the accounts they log in with at an OpenID provider, so a
+-- changed email address at the provider still finds the same user.
+CREATE TABLE IF NOT EXISTS user_identities (
+		id SERIAL PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT COALESCE(NULLIF(current_setting('app.workspace_id', true), '')::integer, 1) REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		last_login_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_identities_subject_idx ON user_identities (workspace_id, issuer, subject);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_identities TO todo_tenant;
+GRANT USAGE ON SEQUENCE user_identities_id_seq TO todo_tenant;
+
+ALTER TABLE user_identities ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY workspace_isolation ON user_identities
+		USING (workspace_id = NULLIF(current_setting('app.workspace_id', true), '')::integer);
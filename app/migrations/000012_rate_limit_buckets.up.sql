@@ -0,0 +1,11 @@
+-- Token buckets of the Postgres rate limit store. Rows are keyed by client
+-- and route and are dropped once full_at has passed, when the bucket is full
+-- again and no different from a missing one.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		full_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
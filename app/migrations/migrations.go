@@ -0,0 +1,15 @@
+// Package migrations holds the SQL migrations of the database schema,
+// embedded so the binary does not need them on disk.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLite holds the SQLite migrations, in its sqlite directory.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
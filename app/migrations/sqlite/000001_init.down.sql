@@ -0,0 +1,13 @@
+DROP VIEW IF EXISTS todo_roles;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS acl_entries;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS todo_changes;
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS todo_lists;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS workspaces;
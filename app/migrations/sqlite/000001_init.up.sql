@@ -0,0 +1,253 @@
+-- The SQLite schema matches the one the Postgres migrations build up, less
+-- what SQLite has no use for or no support for: roles, row-level security and
+-- the rate limit buckets of the Postgres store. It holds a single workspace.
+--
+-- UUIDs are text compared without regard to case, as Postgres compares them.
+-- Arrays are text in the Postgres array format. Times are UTC text, with
+-- microseconds so they sort correctly, as the driver writes them too.
+
+CREATE TABLE IF NOT EXISTS workspaces (
+		id INTEGER PRIMARY KEY,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		slug TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+INSERT INTO workspaces (id, external_id, slug, name)
+		VALUES (1, 'a8f6d3a0-1c2b-4d5e-8f70-000000000001', 'default', 'Default')
+		ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_workspace_email_idx ON users (workspace_id, email);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		last_login_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_identities_subject_idx ON user_identities (workspace_id, issuer, subject);
+
+CREATE TABLE IF NOT EXISTS todo_lists (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL,
+		title TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		due_at TIMESTAMP,
+		status TEXT NOT NULL DEFAULT 'needs-action',
+		recurrence TEXT NOT NULL DEFAULT '',
+		list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE,
+		title_updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		due_at_updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		status_updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		recurrence_updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+-- Clients choose the IDs of the todos they import, sync or put over CalDAV,
+-- so only the database can keep two requests from creating the same one.
+CREATE UNIQUE INDEX IF NOT EXISTS todos_workspace_external_id_idx ON todos (workspace_id, external_id);
+CREATE INDEX IF NOT EXISTS todos_workspace_idx ON todos (workspace_id);
+
+CREATE TABLE IF NOT EXISTS todo_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL,
+		created BOOLEAN NOT NULL DEFAULT FALSE,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		changed_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		-- Set on entries meant for that user only; see the viewer triggers below.
+		user_id INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS todo_changes_external_id_idx ON todo_changes (external_id, seq);
+CREATE INDEX IF NOT EXISTS todo_changes_workspace_idx ON todo_changes (workspace_id, seq);
+
+-- Every write to todos is logged in todo_changes, whatever code path made
+-- it. SQLite lets one writer in at a time, so sequence numbers become visible
+-- in order without the table lock Postgres needs.
+CREATE TRIGGER IF NOT EXISTS todos_record_insert
+		AFTER INSERT ON todos
+BEGIN
+		INSERT INTO todo_changes (external_id, created, workspace_id) VALUES (NEW.external_id, TRUE, NEW.workspace_id);
+END;
+
+-- Stamps a field whenever its value changes, unless the writer set the stamp
+-- itself as sync does with the client's modification time. SQLite triggers
+-- cannot change NEW, so this updates the row again; triggers do not fire
+-- each other unless recursive_triggers is on, which it is not.
+CREATE TRIGGER IF NOT EXISTS todos_record_update
+		AFTER UPDATE ON todos
+BEGIN
+		UPDATE todos SET
+				title_updated_at = CASE WHEN NEW.title IS NOT OLD.title AND NEW.title_updated_at IS OLD.title_updated_at
+						THEN strftime('%Y-%m-%d %H:%M:%f', 'now') || '000' ELSE title_updated_at END,
+				due_at_updated_at = CASE WHEN NEW.due_at IS NOT OLD.due_at AND NEW.due_at_updated_at IS OLD.due_at_updated_at
+						THEN strftime('%Y-%m-%d %H:%M:%f', 'now') || '000' ELSE due_at_updated_at END,
+				status_updated_at = CASE WHEN NEW.status IS NOT OLD.status AND NEW.status_updated_at IS OLD.status_updated_at
+						THEN strftime('%Y-%m-%d %H:%M:%f', 'now') || '000' ELSE status_updated_at END,
+				recurrence_updated_at = CASE WHEN NEW.recurrence IS NOT OLD.recurrence AND NEW.recurrence_updated_at IS OLD.recurrence_updated_at
+						THEN strftime('%Y-%m-%d %H:%M:%f', 'now') || '000' ELSE recurrence_updated_at END
+		WHERE id = NEW.id;
+
+		INSERT INTO todo_changes (external_id, created, workspace_id) VALUES (NEW.external_id, FALSE, NEW.workspace_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_record_delete
+		AFTER DELETE ON todos
+BEGIN
+		INSERT INTO todo_changes (external_id, deleted, workspace_id) VALUES (OLD.external_id, TRUE, OLD.workspace_id);
+END;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		event_types TEXT NOT NULL DEFAULT '{}',
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		last_status_code INTEGER,
+		last_error TEXT,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		-- The API key the feed was created with; the feed stops working once
+		-- the key is revoked. Feeds created by a session are left unbound.
+		api_key_id INTEGER REFERENCES api_keys(id) ON DELETE CASCADE,
+		last_used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL DEFAULT '{}',
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000')
+);
+
+-- An entry grants a user a role on either a list, covering every todo in it,
+-- or a single todo.
+CREATE TABLE IF NOT EXISTS acl_entries (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE,
+		todo_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		CHECK ((list_id IS NULL) <> (todo_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS acl_entries_list_user_idx ON acl_entries (list_id, user_id) WHERE list_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS acl_entries_todo_user_idx ON acl_entries (todo_id, user_id) WHERE todo_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS acl_entries_user_idx ON acl_entries (user_id);
+
+CREATE TABLE IF NOT EXISTS invitations (
+		id INTEGER PRIMARY KEY,
+		workspace_id INTEGER NOT NULL DEFAULT 1 REFERENCES workspaces(id) ON DELETE CASCADE,
+		external_id TEXT COLLATE NOCASE NOT NULL UNIQUE,
+		list_id INTEGER REFERENCES todo_lists(id) ON DELETE CASCADE,
+		todo_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+		email TEXT NOT NULL,
+		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+		token_hash TEXT NOT NULL UNIQUE,
+		invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		accepted_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000'),
+		CHECK ((list_id IS NULL) <> (todo_id IS NULL))
+);
+
+-- Every role a user holds on a todo, directly or through its list. rank
+-- orders the roles so the strongest one can be picked with MAX.
+CREATE VIEW IF NOT EXISTS todo_roles AS
+		SELECT a.todo_id, a.user_id, a.role,
+				CASE a.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END AS rank
+		FROM acl_entries a
+		WHERE a.todo_id IS NOT NULL
+		UNION ALL
+		SELECT t.id, a.user_id, a.role,
+				CASE a.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END AS rank
+		FROM todos t
+		JOIN acl_entries a ON a.list_id = t.list_id;
+
+-- A sync reader acting for a user only hears about todos that user can see.
+-- Deleting a todo or its share hides who could see it, so those are logged
+-- once more for each user who loses it, and a new share for the user who
+-- gains it.
+
+-- Runs before the delete, while the roles on the todo can still be read.
+CREATE TRIGGER IF NOT EXISTS todos_record_viewers_change
+		BEFORE DELETE ON todos
+BEGIN
+		INSERT INTO todo_changes (external_id, deleted, workspace_id, user_id)
+				SELECT DISTINCT OLD.external_id, TRUE, OLD.workspace_id, user_id FROM todo_roles WHERE todo_id = OLD.id;
+END;
+
+-- A role on a list covers every todo in it.
+CREATE TRIGGER IF NOT EXISTS acl_entries_record_grant
+		AFTER INSERT ON acl_entries
+BEGIN
+		INSERT INTO todo_changes (external_id, created, workspace_id, user_id)
+				SELECT external_id, TRUE, workspace_id, NEW.user_id FROM todos
+				WHERE todos.id = NEW.todo_id OR todos.list_id = NEW.list_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS acl_entries_record_revoke
+		BEFORE DELETE ON acl_entries
+BEGIN
+		INSERT INTO todo_changes (external_id, deleted, workspace_id, user_id)
+				SELECT external_id, TRUE, workspace_id, OLD.user_id FROM todos
+				WHERE todos.id = OLD.todo_id OR todos.list_id = OLD.list_id;
+END;
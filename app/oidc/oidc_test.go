@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"todo-app/app/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	fake := utils.NewFakeOIDCProvider("todo-app")
+	defer fake.Close()
+
+	provider := NewProvider(Config{
+		Issuer:      fake.Issuer(),
+		ClientID:    "todo-app",
+		RedirectURL: "http://localhost:8080/auth/callback",
+		Scopes:      []string{"openid", "email"},
+	}, nil)
+
+	ctx := context.Background()
+
+	t.Run("It should read the discovery document", func(t *testing.T) {
+		discovery, err := provider.Discover(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, fake.Issuer()+"/token", discovery.TokenEndpoint)
+	})
+
+	t.Run("It should reject a discovery document for another issuer", func(t *testing.T) {
+		_, err := NewProvider(Config{Issuer: fake.Issuer() + "/other"}, nil).Discover(ctx)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should run the code flow with PKCE", func(t *testing.T) {
+		verifier, _ := RandomString()
+
+		authURL, err := provider.AuthCodeURL(ctx, "the-state", "the-nonce", verifier)
+		assert.NoError(t, err)
+
+		parsed, _ := url.Parse(authURL)
+		assert.Equal(t, PKCEChallenge(verifier), parsed.Query().Get("code_challenge"))
+		assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+		resp, err := client.Get(authURL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		callback, _ := url.Parse(resp.Header.Get("Location"))
+		assert.Equal(t, "the-state", callback.Query().Get("state"))
+
+		code := callback.Query().Get("code")
+
+		_, err = provider.Exchange(ctx, code, "not-the-verifier")
+		assert.Error(t, err)
+
+		resp, _ = client.Get(authURL)
+		resp.Body.Close()
+		callback, _ = url.Parse(resp.Header.Get("Location"))
+
+		idToken, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier)
+		assert.NoError(t, err)
+
+		claims, err := provider.VerifyIDToken(ctx, idToken, "the-nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, fake.User.Subject, claims.Subject)
+		assert.Equal(t, fake.User.Email, claims.Email)
+	})
+
+	t.Run("It should reject ID tokens that do not check out", func(t *testing.T) {
+		tamper := map[string]func(claims map[string]interface{}){
+			"wrong audience": func(claims map[string]interface{}) { claims["aud"] = []string{"someone-else"} },
+			"wrong issuer":   func(claims map[string]interface{}) { claims["iss"] = "https://evil.example.com" },
+			"expired":        func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+			"wrong nonce":    func(claims map[string]interface{}) { claims["nonce"] = "replayed" },
+		}
+
+		for name, change := range tamper {
+			claims := fake.IDTokenClaims(fake.User, "the-nonce")
+			change(claims)
+
+			_, err := provider.VerifyIDToken(ctx, fake.SignIDToken(claims), "the-nonce")
+			assert.ErrorIs(t, err, ErrInvalidToken, name)
+		}
+
+		token := fake.SignIDToken(fake.IDTokenClaims(fake.User, "the-nonce"))
+
+		_, err := provider.VerifyIDToken(ctx, token[:len(token)-4]+"AAAA", "the-nonce")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		_, err = provider.VerifyIDToken(ctx, "eyJhbGciOiJub25lIn0.e30.", "")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("It should accept an audience list that includes the client", func(t *testing.T) {
+		claims := fake.IDTokenClaims(fake.User, "n")
+		claims["aud"] = []string{"other", "todo-app"}
+
+		_, err := provider.VerifyIDToken(ctx, fake.SignIDToken(claims), "n")
+
+		assert.NoError(t, err)
+	})
+}
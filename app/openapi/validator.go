@@ -0,0 +1,418 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const specURL = "file:///openapi.json"
+
+// FieldError describes one rejected value. In is "path", "query" or "body";
+// Field is the parameter name or, for bodies, the JSON path of the value.
+type FieldError struct {
+	In      string `json:"in"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Type     string
+	Schema   *jsonschema.Schema
+}
+
+type operation struct {
+	Parameters   []parameter
+	Body         *jsonschema.Schema
+	BodyRequired bool
+}
+
+// Validator checks requests against the operations in Spec before they reach
+// the controllers.
+type Validator struct {
+	operations map[string]*operation
+}
+
+type rawParameter struct {
+	Ref      string          `json:"$ref"`
+	Name     string          `json:"name"`
+	In       string          `json:"in"`
+	Required bool            `json:"required"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+// parameterRef remembers where a parameter lives in the spec so its schema can
+// be compiled by JSON pointer.
+type parameterRef struct {
+	raw     rawParameter
+	pointer string
+}
+
+type rawOperation struct {
+	Parameters  []rawParameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                       `json:"required"`
+		Content  map[string]json.RawMessage `json:"content"`
+	} `json:"requestBody"`
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// NewValidator compiles the schemas of every operation in Spec. In strict
+// mode object schemas that do not say otherwise reject unknown properties.
+func NewValidator(strict bool) (*Validator, error) {
+	var spec map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(Spec))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, err
+	}
+
+	if strict {
+		if components, ok := spec["components"].(map[string]interface{}); ok {
+			closeObjectSchemas(components["schemas"])
+		}
+	}
+
+	processed, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+
+	if err := compiler.AddResource(specURL, bytes.NewReader(processed)); err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Parameters map[string]rawParameter `json:"parameters"`
+		} `json:"components"`
+	}
+
+	if err := json.Unmarshal(processed, &doc); err != nil {
+		return nil, err
+	}
+
+	validator := &Validator{operations: make(map[string]*operation)}
+
+	for path, item := range doc.Paths {
+		var shared []rawParameter
+
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, method := range httpMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+
+			var rawOp rawOperation
+
+			if err := json.Unmarshal(raw, &rawOp); err != nil {
+				return nil, err
+			}
+
+			op := &operation{}
+			opPointer := "#/paths/" + escapePointer(path) + "/" + method
+
+			var params []parameterRef
+
+			for i, p := range shared {
+				params = append(params, parameterRef{p, fmt.Sprintf("#/paths/%s/parameters/%d", escapePointer(path), i)})
+			}
+
+			for i, p := range rawOp.Parameters {
+				params = append(params, parameterRef{p, fmt.Sprintf("%s/parameters/%d", opPointer, i)})
+			}
+
+			for _, p := range params {
+				raw, pointer := p.raw, p.pointer
+
+				if raw.Ref != "" {
+					pointer = raw.Ref
+					raw = doc.Components.Parameters[strings.TrimPrefix(raw.Ref, "#/components/parameters/")]
+				}
+
+				param := parameter{Name: raw.Name, In: raw.In, Required: raw.Required}
+
+				if len(raw.Schema) > 0 {
+					var typed struct {
+						Type interface{} `json:"type"`
+					}
+
+					json.Unmarshal(raw.Schema, &typed)
+					param.Type, _ = typed.Type.(string)
+
+					schema, err := compiler.Compile(specURL + pointer + "/schema")
+					if err != nil {
+						return nil, fmt.Errorf("compile %s %s parameter %s: %w", method, path, raw.Name, err)
+					}
+
+					param.Schema = schema
+				}
+
+				op.Parameters = append(op.Parameters, param)
+			}
+
+			if rawOp.RequestBody != nil {
+				if _, ok := rawOp.RequestBody.Content["application/json"]; ok {
+					schema, err := compiler.Compile(specURL + opPointer + "/requestBody/content/application~1json/schema")
+					if err != nil {
+						return nil, fmt.Errorf("compile %s %s request body: %w", method, path, err)
+					}
+
+					op.Body = schema
+					op.BodyRequired = rawOp.RequestBody.Required
+				}
+			}
+
+			validator.operations[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+
+	return validator, nil
+}
+
+// Validate checks the parameters and JSON body of a request matched to the
+// gin route ginPath. Routes that are not in the spec are not validated. The
+// body is only read for operations that declare a JSON body, and is restored
+// so handlers can bind it again.
+func (validator *Validator) Validate(req *http.Request, ginPath string, pathParam func(string) string) []FieldError {
+	op, ok := validator.operations[req.Method+" "+PathFromGin(ginPath)]
+	if !ok {
+		return nil
+	}
+
+	var errs []FieldError
+
+	query := req.URL.Query()
+
+	for _, param := range op.Parameters {
+		var raw string
+		var present bool
+
+		switch param.In {
+		case "path":
+			raw = pathParam(param.Name)
+			present = true
+		case "query":
+			present = query.Has(param.Name)
+			raw = query.Get(param.Name)
+		default:
+			continue
+		}
+
+		if !present {
+			if param.Required {
+				errs = append(errs, FieldError{In: param.In, Field: param.Name, Message: "is required"})
+			}
+
+			continue
+		}
+
+		if param.Schema == nil {
+			continue
+		}
+
+		value, err := coerceParameter(raw, param.Type)
+		if err != nil {
+			errs = append(errs, FieldError{In: param.In, Field: param.Name, Message: err.Error()})
+
+			continue
+		}
+
+		if err := param.Schema.Validate(value); err != nil {
+			for _, fieldErr := range fieldErrors(param.In, err) {
+				fieldErr.Field = param.Name
+				errs = append(errs, fieldErr)
+			}
+		}
+	}
+
+	if op.Body == nil {
+		return errs
+	}
+
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err != nil {
+			return append(errs, FieldError{In: "body", Field: "", Message: "failed to read request body"})
+		}
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.BodyRequired {
+			errs = append(errs, FieldError{In: "body", Field: "", Message: "request body is required"})
+		}
+
+		return errs
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return append(errs, FieldError{In: "body", Field: "", Message: "invalid JSON: " + err.Error()})
+	}
+
+	if err := op.Body.Validate(value); err != nil {
+		errs = append(errs, fieldErrors("body", err)...)
+	}
+
+	return errs
+}
+
+func coerceParameter(raw string, schemaType string) (interface{}, error) {
+	switch schemaType {
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+
+		return b, nil
+	default:
+		return raw, nil
+	}
+}
+
+var quotedName = regexp.MustCompile(`'([^']+)'`)
+
+// fieldErrors flattens a schema validation error into one entry per failing
+// leaf, naming the offending field in a JSON-path-like form (items[0].name).
+func fieldErrors(in string, err error) []FieldError {
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []FieldError{{In: in, Message: err.Error()}}
+	}
+
+	var errs []FieldError
+
+	for _, leaf := range validationErr.BasicOutput().Errors {
+		if leaf.Error == "" || strings.HasPrefix(leaf.Error, "doesn't validate with") {
+			continue
+		}
+
+		field := fieldFromPointer(leaf.InstanceLocation)
+
+		switch {
+		case strings.HasSuffix(leaf.KeywordLocation, "/required"):
+			for _, match := range quotedName.FindAllStringSubmatch(leaf.Error, -1) {
+				errs = append(errs, FieldError{In: in, Field: joinField(field, match[1]), Message: "is required"})
+			}
+
+			continue
+		case strings.HasSuffix(leaf.KeywordLocation, "/additionalProperties"):
+			for _, match := range quotedName.FindAllStringSubmatch(leaf.Error, -1) {
+				errs = append(errs, FieldError{In: in, Field: joinField(field, match[1]), Message: "is not allowed"})
+			}
+
+			continue
+		}
+
+		errs = append(errs, FieldError{In: in, Field: field, Message: leaf.Error})
+	}
+
+	return errs
+}
+
+func fieldFromPointer(pointer string) string {
+	var field string
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		if _, err := strconv.Atoi(token); err == nil {
+			field += "[" + token + "]"
+		} else {
+			field = joinField(field, token)
+		}
+	}
+
+	return field
+}
+
+func joinField(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// closeObjectSchemas sets additionalProperties to false on every object
+// schema with declared properties that does not set it explicitly. Schemas
+// combined with allOf are left open, since closing them would reject the
+// properties contributed by the other branches.
+func closeObjectSchemas(node interface{}) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if _, composed := value["allOf"]; composed {
+			return
+		}
+
+		if _, hasProperties := value["properties"]; hasProperties {
+			if _, set := value["additionalProperties"]; !set {
+				value["additionalProperties"] = false
+			}
+		}
+
+		for _, child := range value {
+			closeObjectSchemas(child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			closeObjectSchemas(child)
+		}
+	}
+}
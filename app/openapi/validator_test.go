@@ -0,0 +1,105 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validateTestRequest(t *testing.T, validator *Validator, method string, url string, ginPath string, params map[string]string, body string) []FieldError {
+	var reader *bytes.Reader
+
+	if body != "" {
+		reader = bytes.NewReader([]byte(body))
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+
+	return validator.Validate(req, ginPath, func(name string) string { return params[name] })
+}
+
+func TestValidator(t *testing.T) {
+	validator, err := NewValidator(true)
+	if err != nil {
+		t.Fatalf("Failed to build validator: %v", err)
+	}
+
+	t.Run("It should accept a valid todo", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/todos", "/todos", nil, `{"title": "Buy milk"}`)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("It should reject a non uuid path parameter", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "GET", "/todos/123", "/todos/:id", map[string]string{"id": "123"}, "")
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, FieldError{In: "path", Field: "id", Message: errs[0].Message}, errs[0])
+	})
+
+	t.Run("It should reject a blank title", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/todos", "/todos", nil, `{"title": "   "}`)
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "title", errs[0].Field)
+	})
+
+	t.Run("It should reject a title that is too long", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/todos", "/todos", nil, `{"title": "`+string(bytes.Repeat([]byte("a"), 256))+`"}`)
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "title", errs[0].Field)
+	})
+
+	t.Run("It should report a missing required field by name", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/todos", "/todos", nil, `{}`)
+
+		assert.Equal(t, []FieldError{{In: "body", Field: "title", Message: "is required"}}, errs)
+	})
+
+	t.Run("It should reject unknown fields in strict mode", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/todos", "/todos", nil, `{"title": "ok", "priority": 1}`)
+
+		assert.Equal(t, []FieldError{{In: "body", Field: "priority", Message: "is not allowed"}}, errs)
+	})
+
+	t.Run("It should allow unknown fields outside strict mode", func(t *testing.T) {
+		lenient, err := NewValidator(false)
+		assert.NoError(t, err)
+
+		errs := validateTestRequest(t, lenient, "POST", "/todos", "/todos", nil, `{"title": "ok", "priority": 1}`)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("It should index into arrays when naming fields", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "POST", "/webhooks", "/webhooks", nil, `{"url": "https://example.com", "event_types": ["todo.created", "nope"]}`)
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "event_types[1]", errs[0].Field)
+	})
+
+	t.Run("It should require documented query parameters", func(t *testing.T) {
+		errs := validateTestRequest(t, validator, "GET", "/graphql", "/graphql", nil, "")
+
+		assert.Equal(t, []FieldError{{In: "query", Field: "query", Message: "is required"}}, errs)
+	})
+
+	t.Run("It should keep the body readable for handlers", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/todos", bytes.NewReader([]byte(`{"title": "Buy milk"}`)))
+
+		validator.Validate(req, "/todos", func(string) string { return "" })
+
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(req.Body)
+
+		assert.Equal(t, `{"title": "Buy milk"}`, buf.String())
+	})
+}
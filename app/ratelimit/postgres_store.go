@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so instances
+// behind a load balancer share them. Each take locks its bucket's row, so
+// concurrent requests of a client are counted one after another.
+type PostgresStore struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.sweep(ctx, now)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer tx.Rollback()
+
+	initial := newBucket(limit, now)
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at) VALUES ($1, $2, $3, $3) ON CONFLICT (key) DO NOTHING",
+		key, initial.tokens, initial.updated)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var b bucket
+
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE", key).Scan(&b.tokens, &b.updated)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := b.take(limit, now)
+
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1",
+		key, b.tokens, b.updated, now.Add(result.ResetAfter))
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// sweep deletes refilled buckets, at most once per sweepInterval and
+// instance. Failing to is harmless, so errors are ignored.
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+
+		return
+	}
+
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= $1", now)
+}
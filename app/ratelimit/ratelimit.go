@@ -0,0 +1,151 @@
+// Package ratelimit limits requests with token buckets. A bucket holds up to
+// Limit.Requests tokens and refills evenly over Limit.Window, so clients may
+// burst up to the limit and are then held to its average rate. Buckets live
+// in a Store, in memory for a single instance or in Postgres when several
+// instances share the limits.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRoute names the limit of routes that have none of their own.
+const DefaultRoute = "default"
+
+// sweepInterval is how often stores drop buckets that have refilled, which
+// behave exactly like missing ones.
+const sweepInterval = time.Minute
+
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit reads a limit written as requests/window, e.g. "60/1m".
+func ParseLimit(value string) (Limit, error) {
+	requests, window, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not of the form requests/window", value)
+	}
+
+	var limit Limit
+	var err error
+
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests < 1 {
+		return Limit{}, fmt.Errorf("limit %q must allow at least one request", value)
+	}
+
+	if limit.Window, err = time.ParseDuration(window); err != nil || limit.Window <= 0 {
+		return Limit{}, fmt.Errorf("limit %q has an invalid window", value)
+	}
+
+	return limit, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// Rules are the limits per route, keyed by method and gin route pattern like
+// "POST /todos", with DefaultRoute for all others.
+type Rules map[string]Limit
+
+// ParseRules reads comma separated route=limit pairs, e.g.
+// "default=600/1m, POST /todos=60/1m".
+func ParseRules(value string) (Rules, error) {
+	rules := Rules{}
+
+	for _, rule := range strings.Split(value, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		route, limit, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit rule %q is not of the form route=limit", rule)
+		}
+
+		parsed, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		rules[strings.Join(strings.Fields(route), " ")] = parsed
+	}
+
+	return rules, nil
+}
+
+// For returns the limit of the route with method and pattern, if any.
+func (r Rules) For(method string, pattern string) (Limit, bool) {
+	if limit, ok := r[method+" "+pattern]; ok {
+		return limit, true
+	}
+
+	limit, ok := r[DefaultRoute]
+
+	return limit, ok
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token, when none was left.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets. Take takes a token from the bucket with key,
+// creating a full one first if there is none.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Requests), updated: now}
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(math.Ceil(value * float64(time.Second)))
+}
+
+// take refills the bucket for the time since it was last updated and takes a
+// token if there is one. Denied requests take nothing, so clients that keep
+// retrying still get through once the bucket refills.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Window.Seconds()
+
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+
+	b.updated = now
+
+	result := Result{Limit: limit.Requests}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = seconds((capacity - b.tokens) / rate)
+
+	return result
+}
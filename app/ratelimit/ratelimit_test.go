@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	t.Run("It should read limits per route", func(t *testing.T) {
+		rules, err := ParseRules("default=600/1m, POST  /todos=60/1m,GET /todos/:id=5/1s")
+
+		assert.NoError(t, err)
+		assert.Equal(t, Rules{
+			"default":        {Requests: 600, Window: time.Minute},
+			"POST /todos":    {Requests: 60, Window: time.Minute},
+			"GET /todos/:id": {Requests: 5, Window: time.Second},
+		}, rules)
+
+		limit, ok := rules.For("POST", "/todos")
+		assert.True(t, ok)
+		assert.Equal(t, 60, limit.Requests)
+
+		limit, ok = rules.For("GET", "/lists")
+		assert.True(t, ok)
+		assert.Equal(t, 600, limit.Requests)
+	})
+
+	t.Run("It should not limit routes without a rule or default", func(t *testing.T) {
+		rules, err := ParseRules("")
+
+		assert.NoError(t, err)
+
+		_, ok := rules.For("POST", "/todos")
+		assert.False(t, ok)
+	})
+
+	t.Run("It should reject malformed rules", func(t *testing.T) {
+		for _, value := range []string{"POST /todos", "default=60", "default=0/1m", "default=10/soon", "default=10/-1s"} {
+			_, err := ParseRules(value)
+
+			assert.Error(t, err, value)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 3, Window: 3 * time.Second}
+
+	t.Run("It should allow a burst up to the limit", func(t *testing.T) {
+		for remaining := 2; remaining >= 0; remaining-- {
+			result, err := store.Take(ctx, "client", limit)
+
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, remaining, result.Remaining)
+		}
+	})
+
+	t.Run("It should deny requests once the bucket is empty", func(t *testing.T) {
+		result, _ := store.Take(ctx, "client", limit)
+
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 3*time.Second, result.ResetAfter)
+	})
+
+	t.Run("It should keep clients apart", func(t *testing.T) {
+		result, _ := store.Take(ctx, "other", limit)
+
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("It should refill at the average rate", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+
+		result, _ := store.Take(ctx, "client", limit)
+		assert.True(t, result.Allowed)
+
+		result, _ = store.Take(ctx, "client", limit)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	})
+
+	t.Run("It should forget buckets once they are full again", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		store.Take(ctx, "client", limit)
+
+		assert.Len(t, store.buckets, 1)
+	})
+}
@@ -1,24 +1,209 @@
 package router
 
 import (
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
 	"todo-app/app/controller"
+	"todo-app/app/logging"
 	"todo-app/app/middlewares"
+	"todo-app/app/openapi"
+	"todo-app/app/ratelimit"
 	"todo-app/app/service"
+	"todo-app/app/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
-func Init(todoService *service.TodoService) *gin.Engine {
+// trustedProxies splits the comma separated TRUSTED_PROXIES setting, which is
+// nil when unset so that no proxy is trusted.
+func trustedProxies(value string) []string {
+	var proxies []string
+
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+func Init(config *types.Config, todoService *service.TodoService, webhookService *service.WebhookService, calendarFeedService *service.CalendarFeedService, apiKeyService *service.APIKeyService, workspaceService *service.WorkspaceService, sessionService *service.SessionService, rateLimitStore ratelimit.Store) *gin.Engine {
 	router := gin.New()
 
+	// Client IPs, which rate limits are kept by, only come from
+	// X-Forwarded-For when the request was sent by a trusted proxy. With
+	// none configured, anyone could pick their own limit by setting it.
+	if err := router.SetTrustedProxies(trustedProxies(config.TrustedProxies)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.ConfigLoadLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to parse TRUSTED_PROXIES")
+	}
+
+	// The file store keeps todos and lists alone, in a single workspace
+	// with no API keys, users or sessions, so what needs those is left out.
+	files := config.DBType == constant.DBTypeFile
+
 	router.Use(gin.Recovery())
-	router.Use(middlewares.LoggerMiddleware())
+	router.Use(middlewares.TracingMiddleware())
+	router.Use(middlewares.RequestIDMiddleware())
+	router.Use(middlewares.LoggerMiddleware(logging.NewSampler(config.LogSampleInitial, config.LogSampleThereafter, time.Second)))
+
+	if !files {
+		router.Use(middlewares.AuthMiddleware(apiKeyService, sessionService))
+	}
+
+	rateLimits, err := ratelimit.ParseRules(config.RateLimits)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.ConfigLoadLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to parse RATE_LIMITS")
+	}
+
+	if rateLimitStore == nil {
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	router.Use(middlewares.RateLimitMiddleware(rateLimits, rateLimitStore))
+
+	if !files {
+		router.Use(middlewares.WorkspaceMiddleware(workspaceService, config.WorkspaceDomain))
+	}
+
+	validator, err := openapi.NewValidator(config.ValidationStrict)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.OpenAPILoadLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to load OpenAPI spec")
+	}
+
+	router.Use(middlewares.ValidationMiddleware(validator))
+
+	// scope guards a route with an API key scope. Routes without one are
+	// public: the spec, the docs and the calendar feed, which has its own token.
+	scope := func(name string) gin.HandlerFunc {
+		return middlewares.RequireScope(name, config.AuthRequired)
+	}
+
+	read := scope(constant.ScopeTodosRead)
+	write := scope(constant.ScopeTodosWrite)
+
+	router.GET("/todos", read, controller.GetTodos(todoService))
+	router.GET("/todos/export", read, controller.ExportTodos(todoService))
+	router.POST("/todos/import", write, controller.ImportTodos(todoService))
+	router.POST("/todos", write, controller.CreateTodo(todoService))
+	router.GET("/todos/:id", read, controller.GetTodoByID(todoService))
+	router.PUT("/todos/:id", write, controller.UpdateTodo(todoService))
+	router.DELETE("/todos/:id", write, controller.DeleteTodo(todoService))
+	router.GET("/ws", read, controller.TodoWebSocket(todoService))
+	router.GET("/sync", read, controller.GetSyncChanges(todoService))
+	router.POST("/sync", write, controller.PushSyncChanges(todoService))
+	router.POST("/todos/:id/invitations", write, controller.CreateTodoInvitation(todoService))
+
+	router.GET("/lists", read, controller.GetLists(todoService))
+	router.POST("/lists", write, controller.CreateList(todoService))
+	router.GET("/lists/:id", read, controller.GetListByID(todoService))
+	router.DELETE("/lists/:id", write, controller.DeleteList(todoService))
+	router.GET("/lists/:id/todos", read, controller.GetListTodos(todoService))
+	router.POST("/lists/:id/todos", write, controller.CreateListTodo(todoService))
+	router.GET("/lists/:id/members", read, controller.GetListMembers(todoService))
+	router.DELETE("/lists/:id/members/:userId", write, controller.RemoveListMember(todoService))
+	router.POST("/lists/:id/invitations", write, controller.CreateListInvitation(todoService))
+	router.POST("/invitations/accept", write, controller.AcceptInvitation(todoService))
+
+	calDAV := controller.CalDAV(todoService)
+
+	for _, method := range []string{"OPTIONS", "PROPFIND", "REPORT", "GET", "HEAD"} {
+		router.Handle(method, constant.CalDAVPrefix+"/*path", read, calDAV)
+	}
+
+	for _, method := range []string{"PUT", "DELETE"} {
+		router.Handle(method, constant.CalDAVPrefix+"/*path", write, calDAV)
+	}
+
+	graphQL := controller.GraphQL(todoService)
+
+	// Which GraphQL operations a request runs is only known after parsing, so
+	// POST, which clients use for mutations, needs the write scope. Read-only
+	// keys can query over GET.
+	router.GET("/graphql", read, graphQL)
+	router.POST("/graphql", write, graphQL)
+
+	if config.Env == "development" {
+		router.GET("/graphiql", controller.GraphiQL())
+	}
+
+	router.GET("/openapi.json", controller.OpenAPISpec())
+
+	if config.SwaggerUI {
+		router.GET("/docs", controller.SwaggerUI())
+	}
+
+	// The log level is process-wide, so only the default workspace may change
+	// it, and only with a key holding the scope, even when authentication is
+	// not required elsewhere.
+	manageLogs := middlewares.RequireScope(constant.ScopeLogsManage, true)
+
+	router.GET("/admin/log-level", manageLogs, controller.GetLogLevel())
+	router.PUT("/admin/log-level", manageLogs, controller.SetLogLevel())
+
+	// Only a database is read through the todo cache, so the file store never
+	// has one to report on.
+	if todoService.Cache != nil {
+		router.GET("/admin/todo-cache", scope(constant.ScopeMetricsRead), controller.GetTodoCacheStats(todoService))
+	}
+
+	if files {
+		return router
+	}
+
+	router.GET("/todos.ics", controller.TodoCalendar(todoService, calendarFeedService))
+
+	webhooksRead := scope(constant.ScopeWebhooksRead)
+	webhooksWrite := scope(constant.ScopeWebhooksWrite)
+
+	router.GET("/webhooks", webhooksRead, controller.GetWebhooks(webhookService))
+	router.POST("/webhooks", webhooksWrite, controller.CreateWebhook(webhookService))
+	router.GET("/webhooks/:id", webhooksRead, controller.GetWebhookByID(webhookService))
+	router.PUT("/webhooks/:id", webhooksWrite, controller.UpdateWebhook(webhookService))
+	router.DELETE("/webhooks/:id", webhooksWrite, controller.DeleteWebhook(webhookService))
+	router.GET("/webhooks/:id/deliveries", webhooksRead, controller.GetWebhookDeliveries(webhookService))
+	router.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhooksWrite, controller.RedeliverWebhookDelivery(webhookService))
+
+	// A feed token is a credential of its own, which outlives the request
+	// that made it, so handing one out or taking it back needs the write
+	// scope even though the feed only reads.
+	router.GET("/calendar/feeds", read, controller.GetCalendarFeeds(calendarFeedService))
+	router.POST("/calendar/feeds", write, controller.CreateCalendarFeed(calendarFeedService))
+	router.DELETE("/calendar/feeds/:id", write, controller.DeleteCalendarFeed(calendarFeedService))
+
+	manageKeys := scope(constant.ScopeKeysManage)
+
+	router.GET("/api-keys", manageKeys, controller.GetAPIKeys(apiKeyService))
+	router.POST("/api-keys", manageKeys, controller.CreateAPIKey(apiKeyService))
+	router.DELETE("/api-keys/:id", manageKeys, controller.RevokeAPIKey(apiKeyService))
+
+	// OIDC login is optional; sessionService is nil when no provider is
+	// configured.
+	if sessionService != nil {
+		router.GET("/auth/login", controller.Login(sessionService))
+		router.GET("/auth/callback", controller.LoginCallback(sessionService))
+	}
+
+	// SQLite cannot keep workspaces apart, so it serves the default one
+	// alone and no more may be created.
+	if config.DBType != constant.DBTypeSQLite {
+		manageWorkspaces := scope(constant.ScopeWorkspacesManage)
 
-	router.GET("/todos", controller.GetTodos(todoService))
-	router.POST("/todos", controller.CreateTodo(todoService))
-	router.GET("/todos/:id", controller.GetTodoByID(todoService))
-	router.PUT("/todos/:id", controller.UpdateTodo(todoService))
-	router.DELETE("/todos/:id", controller.DeleteTodo(todoService))
+		router.GET("/workspaces", manageWorkspaces, controller.GetWorkspaces(workspaceService))
+		router.POST("/workspaces", manageWorkspaces, controller.CreateWorkspace(workspaceService))
+	}
 
 	return router
 }
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/openapi"
+	"todo-app/app/service"
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	config := &types.Config{Env: "development", SwaggerUI: true}
+	router := Init(config, service.NewTodoService(nil), service.NewWebhookService(nil), service.NewCalendarFeedService(nil), service.NewAPIKeyService(nil), service.NewWorkspaceService(nil), service.NewSessionService(nil, nil, "secret", time.Hour), nil)
+
+	for _, route := range router.Routes() {
+		// OpenAPI has no way to describe WebDAV methods.
+		if route.Method == "PROPFIND" || route.Method == "REPORT" {
+			continue
+		}
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			documented, err := openapi.HasOperation(route.Method, route.Path)
+
+			assert.NoError(t, err)
+			assert.True(t, documented, "%s %s is registered in router.Init but missing from openapi.json", route.Method, openapi.PathFromGin(route.Path))
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	newRouter := func(trusted string) http.Handler {
+		// The file store router has no workspaces to look up in a database.
+		config := &types.Config{DBType: constant.DBTypeFile, RateLimits: "default=1/1h", TrustedProxies: trusted}
+
+		return Init(config, service.NewTodoService(nil), service.NewWebhookService(nil), service.NewCalendarFeedService(nil), service.NewAPIKeyService(nil), service.NewWorkspaceService(nil), service.NewSessionService(nil, nil, "secret", time.Hour), nil)
+	}
+
+	get := func(router http.Handler, forwardedFor string) int {
+		req, _ := http.NewRequest("GET", "/openapi.json", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	t.Run("It should not let clients pick their IP by default", func(t *testing.T) {
+		router := newRouter("")
+
+		assert.Equal(t, http.StatusOK, get(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusTooManyRequests, get(router, "198.51.100.2"))
+	})
+
+	t.Run("It should take the client IP from a trusted proxy", func(t *testing.T) {
+		router := newRouter("192.0.2.0/24")
+
+		assert.Equal(t, http.StatusOK, get(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, get(router, "198.51.100.2"))
+		assert.Equal(t, http.StatusTooManyRequests, get(router, "198.51.100.2"))
+	})
+}
+
+func TestTodoCacheRoute(t *testing.T) {
+	registered := func(todoService *service.TodoService) bool {
+		config := &types.Config{}
+		router := Init(config, todoService, service.NewWebhookService(nil), service.NewCalendarFeedService(nil), service.NewAPIKeyService(nil), service.NewWorkspaceService(nil), service.NewSessionService(nil, nil, "secret", time.Hour), nil)
+
+		for _, route := range router.Routes() {
+			if route.Path == "/admin/todo-cache" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	t.Run("It should only route the todo cache stats when there is a cache", func(t *testing.T) {
+		assert.False(t, registered(service.NewTodoService(nil)))
+		assert.True(t, registered(service.NewTodoService(nil).WithCache(service.NewTodoCache(service.TodoCacheOptions{Size: 10, TTL: time.Minute}))))
+	})
+}
@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"todo-app/app/constant"
+	"todo-app/app/rpc/todopb"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// methodScopes is the scope a key needs for each method, as RequireScope
+// guards the matching HTTP routes.
+var methodScopes = map[string]string{
+	todopb.TodoService_ListTodos_FullMethodName:  constant.ScopeTodosRead,
+	todopb.TodoService_GetTodo_FullMethodName:    constant.ScopeTodosRead,
+	todopb.TodoService_WatchTodos_FullMethodName: constant.ScopeTodosRead,
+	todopb.TodoService_CreateTodo_FullMethodName: constant.ScopeTodosWrite,
+	todopb.TodoService_UpdateTodo_FullMethodName: constant.ScopeTodosWrite,
+	todopb.TodoService_DeleteTodo_FullMethodName: constant.ScopeTodosWrite,
+}
+
+type apiKeyContextKey struct{}
+
+// apiKeyFromMetadata reads a key from x-api-key or a bearer authorization,
+// the metadata counterparts of the HTTP headers.
+func apiKeyFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(strings.ToLower(constant.APIKeyHeader)); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, token, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return ""
+}
+
+// authenticator checks the API key of each call the way AuthMiddleware and
+// RequireScope do for HTTP, and stores it in the call's context. Calls
+// without a key are only let through when authentication is not required.
+// apiKeyService is nil on the file store, which has no keys.
+type authenticator struct {
+	apiKeyService *service.APIKeyService
+	authRequired  bool
+}
+
+func (auth authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	secret := apiKeyFromMetadata(ctx)
+
+	if secret == "" || auth.apiKeyService == nil {
+		if auth.authRequired {
+			return nil, status.Error(codes.Unauthenticated, constant.APIKeyUnauthorizedMessage)
+		}
+
+		return ctx, nil
+	}
+
+	key, err := auth.apiKeyService.AuthenticateKey(secret)
+	if err != nil {
+		if todoErr, ok := err.(service.TodoError); ok && todoErr.Reason == service.ReasonUnauthorized {
+			return nil, status.Error(codes.Unauthenticated, todoErr.Message)
+		}
+
+		return nil, status.Error(codes.Internal, constant.ErrMsgInternalServer)
+	}
+
+	if len(key.Scopes) > 0 && !slices.Contains(key.Scopes, methodScopes[method]) {
+		return nil, status.Error(codes.PermissionDenied, constant.APIKeyForbiddenMessage)
+	}
+
+	return context.WithValue(ctx, apiKeyContextKey{}, key), nil
+}
+
+func (auth authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := auth.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (auth authenticator) stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := auth.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream hands the stream's handler the context holding its key.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream authenticatedStream) Context() context.Context {
+	return stream.ctx
+}
+
+// apiKeyFromContext returns the key the call was authenticated with, or nil.
+func apiKeyFromContext(ctx context.Context) *types.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*types.APIKey)
+
+	return key
+}
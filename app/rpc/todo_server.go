@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"todo-app/app/constant"
+	"todo-app/app/rpc/todopb"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+type TodoServer struct {
+	todopb.UnimplementedTodoServiceServer
+
+	todoService *service.TodoService
+}
+
+// NewServer serves todoService over gRPC. Calls authenticate with an API key
+// in their metadata, as x-api-key or a bearer authorization, and are served
+// in the key's workspace, for its user; without a key they are refused when
+// authRequired, and otherwise served in the default workspace.
+func NewServer(todoService *service.TodoService, apiKeyService *service.APIKeyService, authRequired bool) *grpc.Server {
+	auth := authenticator{apiKeyService: apiKeyService, authRequired: authRequired}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+
+	todopb.RegisterTodoServiceServer(server, &TodoServer{todoService: todoService})
+
+	return server
+}
+
+// serviceFor returns the todo service scoped to the workspace and user of
+// the call's key, running in the call's context.
+func (server *TodoServer) serviceFor(ctx context.Context) *service.TodoService {
+	workspaceID := constant.DefaultWorkspaceID
+
+	var actor *types.User
+
+	if key := apiKeyFromContext(ctx); key != nil {
+		workspaceID = key.WorkspaceID
+		actor = key.User
+	}
+
+	return server.todoService.InWorkspace(workspaceID).As(actor).WithContext(ctx)
+}
+
+func mapTodo(todo *types.Todo) *todopb.Todo {
+	return &todopb.Todo{
+		Id:        todo.ExternalID,
+		Title:     todo.Title,
+		CreatedAt: timestamppb.New(todo.CreatedAt),
+	}
+}
+
+// statusFromError maps service.TodoErrorReason onto the closest gRPC code.
+func statusFromError(err error) error {
+	if todoErr, ok := err.(service.TodoError); ok {
+		switch todoErr.Reason {
+		case service.ReasonNotFound:
+			return status.Error(codes.NotFound, todoErr.Message)
+		case service.ReasonUnauthorized:
+			return status.Error(codes.Unauthenticated, todoErr.Message)
+		case service.ReasonPreconditionFailed:
+			return status.Error(codes.FailedPrecondition, todoErr.Message)
+		case service.ReasonForbidden:
+			return status.Error(codes.PermissionDenied, todoErr.Message)
+		case service.ReasonConflict:
+			return status.Error(codes.AlreadyExists, todoErr.Message)
+		case service.ReasonBadGateway:
+			return status.Error(codes.Unavailable, todoErr.Message)
+		default:
+			return status.Error(codes.Internal, todoErr.Message)
+		}
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func validateID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return status.Error(codes.InvalidArgument, "Invalid ID")
+	}
+
+	return nil
+}
+
+func validateTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return status.Error(codes.InvalidArgument, "Title is required")
+	}
+
+	return nil
+}
+
+func (server *TodoServer) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	todos, err := server.serviceFor(ctx).GetAllTodos()
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	resp := &todopb.ListTodosResponse{Todos: make([]*todopb.Todo, len(todos))}
+
+	for i := range todos {
+		resp.Todos[i] = mapTodo(&todos[i])
+	}
+
+	return resp, nil
+}
+
+func (server *TodoServer) GetTodo(ctx context.Context, req *todopb.GetTodoRequest) (*todopb.Todo, error) {
+	if err := validateID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	todo, err := server.serviceFor(ctx).GetTodoByID(req.GetId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return mapTodo(todo), nil
+}
+
+func (server *TodoServer) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.Todo, error) {
+	if err := validateTitle(req.GetTitle()); err != nil {
+		return nil, err
+	}
+
+	todo, err := server.serviceFor(ctx).CreateTodo(req.GetTitle())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return mapTodo(todo), nil
+}
+
+func (server *TodoServer) UpdateTodo(ctx context.Context, req *todopb.UpdateTodoRequest) (*todopb.Todo, error) {
+	if err := validateID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := validateTitle(req.GetTitle()); err != nil {
+		return nil, err
+	}
+
+	todo, err := server.serviceFor(ctx).UpdateTodo(req.GetId(), req.GetTitle())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return mapTodo(todo), nil
+}
+
+func (server *TodoServer) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*todopb.DeleteTodoResponse, error) {
+	if err := validateID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := server.serviceFor(ctx).DeleteTodo(req.GetId()); err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return &todopb.DeleteTodoResponse{}, nil
+}
+
+func (server *TodoServer) WatchTodos(req *todopb.WatchTodosRequest, stream todopb.TodoService_WatchTodosServer) error {
+	todoService := server.serviceFor(stream.Context())
+
+	events, unsubscribe := todoService.Events.Subscribe(constant.GRPCWatchBufferSize)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Watcher fell too far behind")
+			}
+
+			if !todoService.VisibleEvent(event) {
+				continue
+			}
+
+			msg := &todopb.TodoEvent{Type: event.Type, Id: event.ID}
+
+			if event.Todo != nil {
+				msg.Todo = mapTodo(event.Todo)
+			}
+
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"todo-app/app/constant"
+	"todo-app/app/rpc/todopb"
+	"todo-app/app/service"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialTestServer(t *testing.T, server *grpc.Server) todopb.TodoServiceClient {
+	listener := bufconn.Listen(1 << 20)
+
+	go server.Serve(listener)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	return todopb.NewTodoServiceClient(conn)
+}
+
+func TestStatusFromError(t *testing.T) {
+	t.Run("It should map not found to NotFound", func(t *testing.T) {
+		err := statusFromError(service.TodoError{Message: "missing", Reason: service.ReasonNotFound})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("It should map forbidden to PermissionDenied", func(t *testing.T) {
+		err := statusFromError(service.TodoError{Message: "nope", Reason: service.ReasonForbidden})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("It should map unknown to Internal", func(t *testing.T) {
+		err := statusFromError(service.TodoError{Message: "boom", Reason: service.ReasonUnknown})
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("It should map every other reason to its own code", func(t *testing.T) {
+		for reason, code := range map[service.TodoErrorReason]codes.Code{
+			service.ReasonUnauthorized:       codes.Unauthenticated,
+			service.ReasonPreconditionFailed: codes.FailedPrecondition,
+			service.ReasonConflict:           codes.AlreadyExists,
+			service.ReasonBadGateway:         codes.Unavailable,
+		} {
+			assert.Equal(t, code, status.Code(statusFromError(service.TodoError{Message: "x", Reason: reason})))
+		}
+	})
+}
+
+func TestValidation(t *testing.T) {
+	client := dialTestServer(t, NewServer(service.NewTodoService(nil), nil, false))
+
+	t.Run("It should reject an invalid ID", func(t *testing.T) {
+		_, err := client.GetTodo(context.Background(), &todopb.GetTodoRequest{Id: "123"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("It should reject an empty title", func(t *testing.T) {
+		_, err := client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Title: " "})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	db, err := utils.CreateSQLiteTestDB(filepath.Join(t.TempDir(), "todo.db"), nil)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	apiKeyService := service.NewAPIKeyService(db)
+	client := dialTestServer(t, NewServer(service.NewTodoService(db), apiKeyService, true))
+
+	createKey := func(input types.APIKeyInput) context.Context {
+		_, secret, err := apiKeyService.CreateKey(input, nil)
+		require.NoError(t, err)
+
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+secret)
+	}
+
+	reader := createKey(types.APIKeyInput{Name: "reader", Scopes: []string{constant.ScopeTodosRead}})
+	alice := createKey(types.APIKeyInput{Name: "alice", UserEmail: "alice@example.com"})
+	bob := createKey(types.APIKeyInput{Name: "bob", UserEmail: "bob@example.com"})
+
+	t.Run("It should refuse calls without a valid key", func(t *testing.T) {
+		_, err := client.ListTodos(context.Background(), &todopb.ListTodosRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong")
+
+		_, err = client.ListTodos(ctx, &todopb.ListTodosRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("It should hold keys to their scopes", func(t *testing.T) {
+		_, err := client.ListTodos(reader, &todopb.ListTodosRequest{})
+		assert.NoError(t, err)
+
+		_, err = client.CreateTodo(reader, &todopb.CreateTodoRequest{Title: "Not allowed"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("It should serve personal keys their own todos only", func(t *testing.T) {
+		todo, err := client.CreateTodo(alice, &todopb.CreateTodoRequest{Title: "Alice's"})
+		require.NoError(t, err)
+
+		_, err = client.GetTodo(alice, &todopb.GetTodoRequest{Id: todo.GetId()})
+		assert.NoError(t, err)
+
+		_, err = client.GetTodo(bob, &todopb.GetTodoRequest{Id: todo.GetId()})
+		assert.Error(t, err)
+
+		resp, err := client.ListTodos(bob, &todopb.ListTodosRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, resp.GetTodos())
+	})
+
+	t.Run("It should authenticate watchers too", func(t *testing.T) {
+		stream, err := client.WatchTodos(context.Background(), &todopb.WatchTodosRequest{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// roleRanks orders the roles; each one can do everything the lower ones can.
+// It matches the rank column of the todo_roles view.
+var roleRanks = map[string]int{
+	constant.RoleViewer: 1,
+	constant.RoleEditor: 2,
+	constant.RoleOwner:  3,
+}
+
+// aclRank computes the rank of an acl_entries row in SQL.
+const aclRank = "CASE role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END"
+
+func roleFromRank(rank int) string {
+	for role, r := range roleRanks {
+		if r == rank {
+			return role
+		}
+	}
+
+	return ""
+}
+
+// As returns a copy of the service acting for actor. Todos are then limited
+// to those actor holds a role on, directly or through their list. A nil actor
+// stands for a service key, or an anonymous request when authentication is
+// off, and is not restricted.
+func (service *TodoService) As(actor *types.User) *TodoService {
+	scoped := *service
+	scoped.Actor = actor
+
+	return &scoped
+}
+
+// InWorkspace returns a copy of the service that only sees the workspace with
+// workspaceID, and tags the events it publishes with it.
+func (service *TodoService) InWorkspace(workspaceID int) *TodoService {
+	scoped := *service
+	scoped.WorkspaceID = workspaceID
+
+	// The file store holds a single workspace.
+	if service.DB != nil {
+		scoped.DB = inWorkspace(service.DB, workspaceID)
+	}
+
+	return &scoped
+}
+
+// authorizeTodo checks that the actor holds at least role on the todo. Todos
+// the actor cannot see at all are reported as not found, so their existence
+// is not revealed; a weaker role than needed is forbidden. A todo that does
+// not exist passes, leaving the caller to report it as usual.
+func (service *TodoService) authorizeTodo(q queryRower, id string, role string, event string) error {
+	if service.Actor == nil {
+		return nil
+	}
+
+	var rank sql.NullInt64
+
+	err := q.QueryRow("SELECT (SELECT MAX(rank) FROM todo_roles WHERE todo_roles.todo_id = todos.id AND todo_roles.user_id = $1) FROM todos WHERE external_id = $2",
+		service.Actor.ID, id).Scan(&rank)
+
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       event,
+			"external_id": id,
+		}).Error(constant.DbQueryFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return service.checkRank(rank, role, fmt.Sprintf("Todo with id %s not found", id), id, event)
+}
+
+// authorizeList resolves a list the actor holds at least role on and returns
+// it with the actor's role filled in. Missing and invisible lists are both
+// not found.
+func (service *TodoService) authorizeList(q queryRower, id string, role string, event string) (*types.TodoList, error) {
+	var list types.TodoList
+	var rank sql.NullInt64
+	var userID interface{}
+
+	if service.Actor != nil {
+		userID = service.Actor.ID
+	}
+
+	err := q.QueryRow("SELECT id, external_id, name, created_at, (SELECT MAX("+aclRank+") FROM acl_entries WHERE acl_entries.list_id = todo_lists.id AND acl_entries.user_id = $1) FROM todo_lists WHERE external_id = $2",
+		userID, id).Scan(&list.ID, &list.ExternalID, &list.Name, &list.CreatedAt, &rank)
+
+	notFound := fmt.Sprintf("List with id %s not found", id)
+
+	if err == sql.ErrNoRows {
+		service.Log.WithFields(logrus.Fields{
+			"event":       event,
+			"external_id": id,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return nil, TodoError{Message: notFound, Reason: ReasonNotFound}
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       event,
+			"external_id": id,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if rank.Valid {
+		list.Role = roleFromRank(int(rank.Int64))
+	}
+
+	if service.Actor == nil {
+		return &list, nil
+	}
+
+	if err := service.checkRank(rank, role, notFound, id, event); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (service *TodoService) checkRank(rank sql.NullInt64, role string, notFound string, id string, event string) error {
+	if !rank.Valid {
+		service.Log.WithFields(logrus.Fields{
+			"event":       event,
+			"external_id": id,
+			"user":        service.Actor.ExternalID,
+		}).Warn("Access to an invisible resource denied")
+
+		return TodoError{Message: notFound, Reason: ReasonNotFound}
+	}
+
+	if int(rank.Int64) < roleRanks[role] {
+		service.Log.WithFields(logrus.Fields{
+			"event":       event,
+			"external_id": id,
+			"user":        service.Actor.ExternalID,
+		}).Warn("Access denied")
+
+		return TodoError{Message: constant.ErrMsgForbidden, Reason: ReasonForbidden}
+	}
+
+	return nil
+}
+
+// visibleTodosCondition returns a condition limiting todos to those the
+// actor can see, using the next placeholder after args, or "" when the actor
+// is not restricted.
+func (service *TodoService) visibleTodosCondition(args []interface{}) (string, []interface{}) {
+	if service.Actor == nil {
+		return "", args
+	}
+
+	args = append(args, service.Actor.ID)
+
+	return fmt.Sprintf("id IN (SELECT todo_id FROM todo_roles WHERE user_id = $%d)", len(args)), args
+}
+
+// VisibleEvent reports whether event is for the workspace of the service and
+// about a todo the actor may see, without going back to the database.
+func (service *TodoService) VisibleEvent(event types.TodoEvent) bool {
+	if event.WorkspaceID != service.WorkspaceID {
+		return false
+	}
+
+	return service.Actor == nil || event.Viewers[service.Actor.ID]
+}
+
+// todoViewers looks up the users holding a role on the todos matching
+// condition, directly or through their list, by lower case todo ID.
+func todoViewers(q rowsQuerier, condition string, args ...interface{}) (map[string]map[int]bool, error) {
+	rows, err := q.Query("SELECT todos.external_id, todo_roles.user_id FROM todos JOIN todo_roles ON todo_roles.todo_id = todos.id WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	viewers := make(map[string]map[int]bool)
+
+	for rows.Next() {
+		var id string
+		var userID int
+
+		if err := rows.Scan(&id, &userID); err != nil {
+			return nil, err
+		}
+
+		id = strings.ToLower(id)
+
+		if viewers[id] == nil {
+			viewers[id] = make(map[int]bool)
+		}
+
+		viewers[id][userID] = true
+	}
+
+	return viewers, rows.Err()
+}
+
+// viewersOf returns the viewers of the todo with id out of those todoViewers
+// found; a todo nobody holds a role on gets an empty, not a nil, map.
+func viewersOf(viewers map[string]map[int]bool, id string) map[int]bool {
+	if found, ok := viewers[strings.ToLower(id)]; ok {
+		return found
+	}
+
+	return map[int]bool{}
+}
+
+// deletedTodoViewers looks up the viewers of the todos matching condition
+// within tx, before they are deleted, for the events telling of it. It returns
+// nil when nobody is subscribed; a failed lookup leaves every todo with no
+// viewers rather than fail the delete.
+func (service *TodoService) deletedTodoViewers(tx *sql.Tx, condition string, args ...interface{}) map[string]map[int]bool {
+	if !service.Events.Subscribed() {
+		return nil
+	}
+
+	viewers, err := todoViewers(txRower{tx}, condition, args...)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.AccessLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return map[string]map[int]bool{}
+	}
+
+	return viewers
+}
+
+// publish publishes events once their write committed, first looking up in a
+// single query the viewers of those that do not carry them yet. Deletions
+// must bring theirs, from deletedTodoViewers.
+func (service *TodoService) publish(events ...types.TodoEvent) {
+	if service.Events.Subscribed() {
+		var ids []string
+
+		for _, event := range events {
+			if event.Viewers == nil && event.Todo != nil {
+				ids = append(ids, event.ID)
+			}
+		}
+
+		if len(ids) > 0 {
+			viewers, err := todoViewers(service.DB, service.DB.dialect().contains("$1", "todos.external_id"), pq.Array(ids))
+			if err != nil {
+				service.Log.WithFields(logrus.Fields{
+					"event": constant.AccessLogEventErrorKey,
+				}).Error(constant.DbQueryFailMsg)
+			}
+
+			for i := range events {
+				if events[i].Viewers == nil && events[i].Todo != nil {
+					events[i].Viewers = viewersOf(viewers, events[i].ID)
+				}
+			}
+		}
+	}
+
+	for _, event := range events {
+		service.Events.Publish(event)
+	}
+}
+
+// grantTodoOwner makes the actor the owner of todos it has just created
+// outside of any list.
+func (service *TodoService) grantTodoOwner(tx *sql.Tx, ids ...string) error {
+	if service.Actor == nil || len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec("INSERT INTO acl_entries (user_id, todo_id, role) SELECT $1, id, $2 FROM todos WHERE "+service.DB.dialect().contains("$3", "external_id"),
+		service.Actor.ID, constant.RoleOwner, pq.Array(ids))
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.AccessLogEventErrorKey,
+		}).Error("Failed to grant todo ownership")
+	}
+
+	return err
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type APIKeyService struct {
+	DB Database
+}
+
+func NewAPIKeyService(db *sql.DB) *APIKeyService {
+	return &APIKeyService{
+		DB: NewDatabase(db),
+	}
+}
+
+// InWorkspace returns a copy of the service limited to the API keys of the
+// workspace with workspaceID.
+func (service *APIKeyService) InWorkspace(workspaceID int) *APIKeyService {
+	scoped := *service
+	scoped.DB = inWorkspace(service.DB, workspaceID)
+
+	return &scoped
+}
+
+var apiKeyColumns = "id, workspace_id, external_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at, " + ownerColumns("api_keys")
+
+func scanAPIKey(row rowScanner, key *types.APIKey) error {
+	var user types.User
+
+	dest := append([]interface{}{&key.ID, &key.WorkspaceID, &key.ExternalID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes), &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt}, ownerScanDest(&user)...)
+
+	err := row.Scan(dest...)
+	key.User = ownerOrNil(&user)
+
+	return err
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// GetAllKeys lists every key, or only the keys of owner when it is set.
+func (service *APIKeyService) GetAllKeys(owner *types.User) ([]types.APIKey, error) {
+	var keys []types.APIKey
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys"
+	var args []interface{}
+
+	if owner != nil {
+		query += " WHERE user_id = $1"
+		args = append(args, owner.ID)
+	}
+
+	rows, err := service.DB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.APIKeyLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key types.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.APIKeyLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// CreateKey stores a new key and returns it with the secret, which is not
+// kept in plain text and so cannot be returned again. When owner is set the
+// key is one of theirs; otherwise input.UserEmail, if given, makes it a
+// personal key of that user, who is created on first use.
+func (service *APIKeyService) CreateKey(input types.APIKeyInput, owner *types.User) (*types.APIKey, string, error) {
+	if owner != nil && input.UserEmail != "" && !strings.EqualFold(input.UserEmail, owner.Email) {
+		return nil, "", TodoError{Message: constant.ErrMsgForbidden, Reason: ReasonForbidden}
+	}
+
+	newKey, secret, err := generateAPIKey(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	newKey.User = owner
+
+	if owner == nil && input.UserEmail != "" {
+		user, err := ensureUser(service.DB, input.UserEmail, "")
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.APIKeyLogEventErrorKey,
+			}).Error("Failed to create user")
+
+			return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		newKey.User = user
+	}
+
+	if err := insertAPIKey(service.DB, newKey); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.APIKeyLogEventErrorKey,
+		}).Error("Failed to create API key")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.APIKeyLogEventKey,
+		"external_id": newKey.ExternalID,
+		"prefix":      newKey.Prefix,
+	}).Info("API key created successfully")
+
+	return newKey, secret, nil
+}
+
+// generateAPIKey makes a key from input, returning it with its secret.
+func generateAPIKey(input types.APIKeyInput) (*types.APIKey, string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.APIKeyLogEventErrorKey,
+		}).Error("Failed to generate API key")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	secret := constant.APIKeyTokenPrefix + hex.EncodeToString(buf)
+
+	scopes := input.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return &types.APIKey{
+		ExternalID: uuid.New().String(),
+		Name:       input.Name,
+		Prefix:     secret[:constant.APIKeyVisiblePrefixLength],
+		KeyHash:    hashAPIKey(secret),
+		Scopes:     scopes,
+		CreatedAt:  time.Now(),
+	}, secret, nil
+}
+
+// insertAPIKey stores key in the workspace q runs in and fills in its ID.
+func insertAPIKey(q queryRower, key *types.APIKey) error {
+	return q.QueryRow("INSERT INTO api_keys (external_id, name, prefix, key_hash, scopes, created_at, user_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, workspace_id",
+		key.ExternalID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.CreatedAt, ownerID(key.User)).Scan(&key.ID, &key.WorkspaceID)
+}
+
+// RevokeKey stops a key from authenticating. The key stays listed with its
+// revocation time; revoking it again is a no-op. When owner is set, keys of
+// anyone else are not found.
+func (service *APIKeyService) RevokeKey(id string, owner *types.User) (*types.APIKey, error) {
+	var key types.APIKey
+
+	err := scanAPIKey(service.DB.QueryRow("UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1) WHERE external_id = $2 AND (CAST($3 AS INTEGER) IS NULL OR user_id = $3) RETURNING "+apiKeyColumns,
+		time.Now(), id, ownerID(owner)), &key)
+
+	if err == sql.ErrNoRows {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.APIKeyLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return nil, TodoError{Message: fmt.Sprintf("API key with id %s not found", id), Reason: ReasonNotFound}
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.APIKeyLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.APIKeyLogEventKey,
+		"external_id": id,
+	}).Info("API key revoked successfully")
+
+	return &key, nil
+}
+
+// AuthenticateKey resolves an unrevoked key, with its user for a personal
+// key, and records its use.
+func (service *APIKeyService) AuthenticateKey(secret string) (*types.APIKey, error) {
+	var key types.APIKey
+
+	if secret == "" {
+		return nil, TodoError{Message: constant.APIKeyUnauthorizedMessage, Reason: ReasonUnauthorized}
+	}
+
+	err := scanAPIKey(service.DB.QueryRow("UPDATE api_keys SET last_used_at = $1 WHERE key_hash = $2 AND revoked_at IS NULL RETURNING "+apiKeyColumns,
+		time.Now(), hashAPIKey(secret)), &key)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, TodoError{Message: constant.APIKeyUnauthorizedMessage, Reason: ReasonUnauthorized}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event": constant.APIKeyLogEventErrorKey,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type CalendarFeedService struct {
+	DB Database
+}
+
+func NewCalendarFeedService(db *sql.DB) *CalendarFeedService {
+	return &CalendarFeedService{
+		DB: NewDatabase(db),
+	}
+}
+
+// InWorkspace returns a copy of the service limited to the calendar feeds of the
+// workspace with workspaceID.
+func (service *CalendarFeedService) InWorkspace(workspaceID int) *CalendarFeedService {
+	scoped := *service
+	scoped.DB = inWorkspace(service.DB, workspaceID)
+
+	return &scoped
+}
+
+var calendarFeedColumns = "id, workspace_id, external_id, name, token_hash, last_used_at, created_at, " + ownerColumns("calendar_feeds")
+
+func scanCalendarFeed(row rowScanner, feed *types.CalendarFeed) error {
+	var user types.User
+
+	dest := append([]interface{}{&feed.ID, &feed.WorkspaceID, &feed.ExternalID, &feed.Name, &feed.TokenHash, &feed.LastUsedAt, &feed.CreatedAt}, ownerScanDest(&user)...)
+
+	err := row.Scan(dest...)
+	feed.User = ownerOrNil(&user)
+
+	return err
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// GetAllFeeds lists every feed, or only the feeds of owner when it is set.
+func (service *CalendarFeedService) GetAllFeeds(owner *types.User) ([]types.CalendarFeed, error) {
+	var feeds []types.CalendarFeed
+
+	query := "SELECT " + calendarFeedColumns + " FROM calendar_feeds"
+	var args []interface{}
+
+	if owner != nil {
+		query += " WHERE user_id = $1"
+		args = append(args, owner.ID)
+	}
+
+	rows, err := service.DB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CalendarFeedLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var feed types.CalendarFeed
+		if err := scanCalendarFeed(rows, &feed); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.CalendarFeedLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
+
+// feedKeyID returns the ID of the stored API key a feed is created with, or
+// nil for none, as for anonymous requests and the stand-in keys of sessions.
+func feedKeyID(creator *types.APIKey) interface{} {
+	if creator == nil || creator.ID == 0 {
+		return nil
+	}
+
+	return creator.ID
+}
+
+// CreateFeed stores a new feed and returns it with its token, which is not
+// kept in plain text and so cannot be returned again. A feed created by a
+// user's key only ever shows the todos that user can see, and a feed created
+// with an API key stops working once the key is revoked.
+func (service *CalendarFeedService) CreateFeed(input types.CalendarFeedInput, creator *types.APIKey) (*types.CalendarFeed, string, error) {
+	var owner *types.User
+
+	if creator != nil {
+		owner = creator.User
+	}
+
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CalendarFeedLogEventErrorKey,
+		}).Error("Failed to generate feed token")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	token := hex.EncodeToString(buf)
+
+	newFeed := types.CalendarFeed{
+		ExternalID: uuid.New().String(),
+		Name:       input.Name,
+		TokenHash:  hashFeedToken(token),
+		CreatedAt:  time.Now(),
+		User:       owner,
+	}
+
+	err := service.DB.QueryRow("INSERT INTO calendar_feeds (external_id, name, token_hash, created_at, user_id, api_key_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		newFeed.ExternalID, newFeed.Name, newFeed.TokenHash, newFeed.CreatedAt, ownerID(owner), feedKeyID(creator)).Scan(&newFeed.ID)
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CalendarFeedLogEventErrorKey,
+		}).Error("Failed to create calendar feed")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.CalendarFeedLogEventKey,
+		"external_id": newFeed.ExternalID,
+	}).Info("Calendar feed created successfully")
+
+	return &newFeed, token, nil
+}
+
+// DeleteFeed deletes a feed. When owner is set, feeds of anyone else are not
+// found.
+func (service *CalendarFeedService) DeleteFeed(id string, owner *types.User) error {
+	result, err := service.DB.Exec("DELETE FROM calendar_feeds WHERE external_id = $1 AND (CAST($2 AS INTEGER) IS NULL OR user_id = $2)", id, ownerID(owner))
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.CalendarFeedLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.CalendarFeedLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbRowsAffectedFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if rowsAffected == 0 {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.CalendarFeedLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return TodoError{Message: fmt.Sprintf("Calendar feed with id %s not found", id), Reason: ReasonNotFound}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.CalendarFeedLogEventKey,
+		"external_id": id,
+	}).Info("Calendar feed deleted successfully")
+
+	return nil
+}
+
+// AuthenticateFeed resolves a feed token and records its use. Tokens of feeds
+// whose API key was revoked are refused like unknown ones.
+func (service *CalendarFeedService) AuthenticateFeed(token string) (*types.CalendarFeed, error) {
+	var feed types.CalendarFeed
+
+	if token == "" {
+		return nil, TodoError{Message: constant.CalendarFeedUnauthorizedMessage, Reason: ReasonUnauthorized}
+	}
+
+	err := scanCalendarFeed(service.DB.QueryRow("UPDATE calendar_feeds SET last_used_at = $1 WHERE token_hash = $2"+
+		" AND (api_key_id IS NULL OR EXISTS (SELECT 1 FROM api_keys WHERE api_keys.id = calendar_feeds.api_key_id AND api_keys.revoked_at IS NULL))"+
+		" RETURNING "+calendarFeedColumns,
+		time.Now(), hashFeedToken(token)), &feed)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, TodoError{Message: constant.CalendarFeedUnauthorizedMessage, Reason: ReasonUnauthorized}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CalendarFeedLogEventErrorKey,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &feed, nil
+}
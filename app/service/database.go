@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"todo-app/app/constant"
+)
+
+// Rows is the part of *sql.Rows services read query results through.
+type Rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Close() error
+	Err() error
+}
+
+// Database is what services run their statements on. NewDatabase adapts a
+// plain *sql.DB, which sees every workspace; inWorkspace narrows one down to a
+// single workspace. Statements and transactions run in the context given to
+// withContext, so they are traced as part of the request they serve. The SQL
+// that differs between Postgres and SQLite comes from its dialect.
+type Database interface {
+	Query(query string, args ...interface{}) (Rows, error)
+	QueryRow(query string, args ...interface{}) rowScanner
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+
+	pool() *sql.DB
+	context() context.Context
+	withContext(ctx context.Context) Database
+	dialect() dialect
+}
+
+// queryRower is satisfied by every Database and, through txRower, by
+// transactions, so policy checks can run inside the transaction of the write
+// they guard.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) rowScanner
+}
+
+// rowsQuerier is the same for queries returning several rows.
+type rowsQuerier interface {
+	Query(query string, args ...interface{}) (Rows, error)
+}
+
+type txRower struct {
+	*sql.Tx
+}
+
+func (tx txRower) QueryRow(query string, args ...interface{}) rowScanner {
+	return tx.Tx.QueryRow(query, args...)
+}
+
+func (tx txRower) Query(query string, args ...interface{}) (Rows, error) {
+	return tx.Tx.Query(query, args...)
+}
+
+type sqlDatabase struct {
+	db     *sql.DB
+	ctx    context.Context
+	speaks dialect
+}
+
+func NewDatabase(db *sql.DB) Database {
+	return sqlDatabase{db: db, ctx: context.Background(), speaks: dialectOf(db)}
+}
+
+func (d sqlDatabase) Query(query string, args ...interface{}) (Rows, error) {
+	return d.db.QueryContext(d.ctx, query, args...)
+}
+
+func (d sqlDatabase) QueryRow(query string, args ...interface{}) rowScanner {
+	return d.db.QueryRowContext(d.ctx, query, args...)
+}
+
+func (d sqlDatabase) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.ExecContext(d.ctx, query, args...)
+}
+
+func (d sqlDatabase) Begin() (*sql.Tx, error) {
+	return d.db.BeginTx(d.ctx, nil)
+}
+
+func (d sqlDatabase) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return d.db.BeginTx(ctx, opts)
+}
+
+func (d sqlDatabase) pool() *sql.DB {
+	return d.db
+}
+
+func (d sqlDatabase) context() context.Context {
+	return d.ctx
+}
+
+func (d sqlDatabase) withContext(ctx context.Context) Database {
+	d.ctx = ctx
+
+	return d
+}
+
+func (d sqlDatabase) dialect() dialect {
+	return d.speaks
+}
+
+// workspaceDatabase runs every statement in a transaction that has switched
+// to the tenant role and set app.workspace_id, so row-level security of
+// Postgres limits it to one workspace. Statements run outside of an explicit transaction get one
+// of their own, committed once their rows have been read.
+type workspaceDatabase struct {
+	db          *sql.DB
+	workspaceID int
+	ctx         context.Context
+}
+
+// inWorkspace returns db limited to the workspace with workspaceID.
+func inWorkspace(db Database, workspaceID int) Database {
+	return db.dialect().inWorkspace(db, workspaceID)
+}
+
+func (d workspaceDatabase) Begin() (*sql.Tx, error) {
+	return d.BeginTx(d.ctx, nil)
+}
+
+func (d workspaceDatabase) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// SET takes no placeholders; the ID is an integer, so formatting it in is
+	// safe.
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL ROLE %s; SET LOCAL app.workspace_id = %d", constant.WorkspaceTenantRole, d.workspaceID))
+	if err != nil {
+		tx.Rollback()
+
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (d workspaceDatabase) Query(query string, args ...interface{}) (Rows, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(d.ctx, query, args...)
+	if err != nil {
+		tx.Rollback()
+
+		return nil, err
+	}
+
+	return &workspaceRows{Rows: rows, tx: tx}, nil
+}
+
+func (d workspaceDatabase) QueryRow(query string, args ...interface{}) rowScanner {
+	tx, err := d.Begin()
+	if err != nil {
+		return workspaceRow{err: err}
+	}
+
+	return workspaceRow{row: tx.QueryRowContext(d.ctx, query, args...), tx: tx}
+}
+
+func (d workspaceDatabase) Exec(query string, args ...interface{}) (sql.Result, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(d.ctx, query, args...)
+	if err != nil {
+		tx.Rollback()
+
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+func (d workspaceDatabase) pool() *sql.DB {
+	return d.db
+}
+
+func (d workspaceDatabase) context() context.Context {
+	return d.ctx
+}
+
+func (d workspaceDatabase) withContext(ctx context.Context) Database {
+	d.ctx = ctx
+
+	return d
+}
+
+// dialect is Postgres, the only database with row-level security.
+func (d workspaceDatabase) dialect() dialect {
+	return postgresDialect{}
+}
+
+// workspaceRows commits the transaction of a query when it is closed.
+type workspaceRows struct {
+	*sql.Rows
+	tx *sql.Tx
+}
+
+func (rows *workspaceRows) Close() error {
+	if rows.tx == nil {
+		return nil
+	}
+
+	rows.Rows.Close()
+
+	tx := rows.tx
+	rows.tx = nil
+
+	if err := rows.Rows.Err(); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// workspaceRow commits the transaction of a single-row query once it has been
+// scanned, which also covers INSERT ... RETURNING.
+type workspaceRow struct {
+	row *sql.Row
+	tx  *sql.Tx
+	err error
+}
+
+func (row workspaceRow) Scan(dest ...interface{}) error {
+	if row.err != nil {
+		return row.err
+	}
+
+	err := row.row.Scan(dest...)
+
+	if err != nil && err != sql.ErrNoRows {
+		row.tx.Rollback()
+
+		return err
+	}
+
+	if commitErr := row.tx.Commit(); commitErr != nil {
+		return commitErr
+	}
+
+	return err
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"database/sql"
+
+	"todo-app/app/sqlite"
+)
+
+// dialect holds the SQL that Postgres and SQLite write differently. Every
+// Database speaks one. The statements both read alike are written once, where
+// they run, with their placeholders numbered in the order they first appear,
+// which SQLite needs; see the sqlite package.
+type dialect interface {
+	// contains is the condition that the array array, passed as pq.Array,
+	// holds element.
+	contains(array string, element string) string
+	// ilike matches value against the LIKE pattern ignoring case.
+	ilike(value string, pattern string) string
+	// forUpdate ends a SELECT whose rows must stay locked until its
+	// transaction ends, skipping those locked already if skipLocked.
+	forUpdate(skipLocked bool) string
+	// noLimit is a LIMIT letting every row through, for an OFFSET without
+	// a limit of its own.
+	noLimit() string
+	// cursor pages through the rows of query, run within tx, batch rows at
+	// a time: each call of fetch returns the next ones, none once they ran
+	// out.
+	cursor(tx *sql.Tx, query string, args []interface{}, batch int) (fetch func() (*sql.Rows, error), err error)
+	// inWorkspace limits db to the workspace with workspaceID.
+	inWorkspace(db Database, workspaceID int) Database
+}
+
+// dialectOf tells the dialect of db from the driver it was opened with.
+func dialectOf(db *sql.DB) dialect {
+	if db != nil && sqlite.Opened(db) {
+		return sqliteDialect{}
+	}
+
+	return postgresDialect{}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) contains(array string, element string) string {
+	return element + " = ANY(" + array + ")"
+}
+
+func (postgresDialect) ilike(value string, pattern string) string {
+	return value + " ILIKE " + pattern
+}
+
+func (postgresDialect) forUpdate(skipLocked bool) string {
+	if skipLocked {
+		return " FOR UPDATE SKIP LOCKED"
+	}
+
+	return " FOR UPDATE"
+}
+
+func (postgresDialect) noLimit() string {
+	return "ALL"
+}
+
+// cursor declares a server-side cursor, so the rows are never all held in
+// memory on either side.
+func (postgresDialect) cursor(tx *sql.Tx, query string, args []interface{}, batch int) (func() (*sql.Rows, error), error) {
+	if _, err := tx.Exec("DECLARE rows_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return nil, err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM rows_cursor", batch)
+
+	return func() (*sql.Rows, error) {
+		return tx.Query(fetch)
+	}, nil
+}
+
+func (postgresDialect) inWorkspace(db Database, workspaceID int) Database {
+	return workspaceDatabase{db: db.pool(), workspaceID: workspaceID, ctx: db.context()}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type sqliteDialect struct{}
+
+// contains calls the array_contains function the sqlite driver registers, as
+// SQLite has no arrays; they are stored in their Postgres text form.
+func (sqliteDialect) contains(array string, element string) string {
+	return "array_contains(" + array + ", " + element + ")"
+}
+
+// ilike is LIKE, which ignores the case of ASCII letters in SQLite already.
+func (sqliteDialect) ilike(value string, pattern string) string {
+	return value + " LIKE " + pattern
+}
+
+// forUpdate is empty: writers take the lock on the whole database as they
+// begin, so there are no rows to lock.
+func (sqliteDialect) forUpdate(skipLocked bool) string {
+	return ""
+}
+
+func (sqliteDialect) noLimit() string {
+	return "-1"
+}
+
+// cursor runs query a page at a time. The transaction reads a snapshot, so
+// the pages do not shift under it.
+func (sqliteDialect) cursor(tx *sql.Tx, query string, args []interface{}, batch int) (func() (*sql.Rows, error), error) {
+	position := 0
+
+	return func() (*sql.Rows, error) {
+		rows, err := tx.Query(fmt.Sprintf("%s LIMIT %d OFFSET %d", query, batch, position), args...)
+		position += batch
+
+		return rows, err
+	}, nil
+}
+
+// inWorkspace returns db as it is: SQLite holds a single workspace.
+func (sqliteDialect) inWorkspace(db Database, workspaceID int) Database {
+	return db
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/oidc"
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionService logs users in through an OpenID provider and issues the
+// session tokens they authenticate with afterwards. Sessions are stateless:
+// the token is a JWT MACed with the session secret, so it lasts until it
+// expires.
+type SessionService struct {
+	DB       Database
+	Provider *oidc.Provider
+	secret   []byte
+	ttl      time.Duration
+}
+
+func NewSessionService(db *sql.DB, provider *oidc.Provider, secret string, ttl time.Duration) *SessionService {
+	return &SessionService{
+		DB:       NewDatabase(db),
+		Provider: provider,
+		secret:   []byte(secret),
+		ttl:      ttl,
+	}
+}
+
+// loginFlow is what has to survive the round trip to the provider. It travels
+// in a signed cookie, so no login state is kept on the server.
+type loginFlow struct {
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	WorkspaceID int    `json:"wid"`
+	Workspace   string `json:"workspace"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+type sessionClaims struct {
+	Issuer      string `json:"iss"`
+	Subject     string `json:"sub"`
+	Workspace   string `json:"workspace"`
+	WorkspaceID int    `json:"wid"`
+	IssuedAt    int64  `json:"iat"`
+	Expiry      int64  `json:"exp"`
+}
+
+var errBadSignature = errors.New("bad signature")
+
+// sign MACs payload under purpose, so a value signed for one use cannot be
+// passed off as another.
+func (service *SessionService) sign(purpose string, payload string) string {
+	mac := hmac.New(sha256.New, service.secret)
+	mac.Write([]byte(purpose + "." + payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (service *SessionService) verify(purpose string, payload string, signature string) error {
+	if !hmac.Equal([]byte(service.sign(purpose, payload)), []byte(signature)) {
+		return errBadSignature
+	}
+
+	return nil
+}
+
+// StartLogin begins a login into workspace. It returns the provider URL to
+// send the user to and the flow value to keep in a cookie until the callback.
+func (service *SessionService) StartLogin(ctx context.Context, workspace *types.Workspace) (string, string, error) {
+	flow := loginFlow{
+		WorkspaceID: workspace.ID,
+		Workspace:   workspace.Slug,
+		ExpiresAt:   time.Now().Add(time.Duration(constant.LoginFlowTTL) * time.Second).Unix(),
+	}
+
+	var err error
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *value, err = oidc.RandomString(); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.SessionLogEventErrorKey,
+				"error": err.Error(),
+			}).Error("Failed to generate login state")
+
+			return "", "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+	}
+
+	authURL, err := service.Provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.SessionLogEventErrorKey,
+			"error": err.Error(),
+		}).Error("Failed to discover the identity provider")
+
+		return "", "", TodoError{Message: constant.ErrMsgLoginProviderFailed, Reason: ReasonBadGateway}
+	}
+
+	payload, _ := json.Marshal(flow)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return authURL, encoded + "." + service.sign("flow", encoded), nil
+}
+
+func (service *SessionService) readFlow(value string) (*loginFlow, error) {
+	var flow loginFlow
+
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok || service.verify("flow", payload, signature) != nil {
+		return nil, errBadSignature
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(decoded, &flow); err != nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() > flow.ExpiresAt {
+		return nil, errors.New("login flow expired")
+	}
+
+	return &flow, nil
+}
+
+// FinishLogin completes the login the flow value belongs to with the state
+// and code the provider redirected back with. It provisions the user on
+// first login and returns the new session and its token.
+func (service *SessionService) FinishLogin(ctx context.Context, flowValue string, state string, code string) (*types.Session, string, error) {
+	flow, err := service.readFlow(flowValue)
+	if err != nil || state == "" || !hmac.Equal([]byte(flow.State), []byte(state)) {
+		return nil, "", TodoError{Message: constant.ErrMsgLoginFlowInvalid, Reason: ReasonUnauthorized}
+	}
+
+	rawIDToken, err := service.Provider.Exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.SessionLogEventErrorKey,
+			"error": err.Error(),
+		}).Error("Failed to exchange the authorization code")
+
+		return nil, "", TodoError{Message: constant.ErrMsgLoginProviderFailed, Reason: ReasonBadGateway}
+	}
+
+	claims, err := service.Provider.VerifyIDToken(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.SessionLogEventErrorKey,
+			"error": err.Error(),
+		}).Error("Failed to verify the ID token")
+
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			return nil, "", TodoError{Message: constant.ErrMsgLoginProviderFailed, Reason: ReasonUnauthorized}
+		}
+
+		return nil, "", TodoError{Message: constant.ErrMsgLoginProviderFailed, Reason: ReasonBadGateway}
+	}
+
+	user, err := service.provisionUser(flow.WorkspaceID, claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &types.Session{
+		User:        user,
+		WorkspaceID: flow.WorkspaceID,
+		ExpiresAt:   time.Now().Add(service.ttl).Truncate(time.Second),
+	}
+
+	token := service.issueToken(session, flow.Workspace)
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.SessionLogEventKey,
+		"external_id": user.ExternalID,
+		"workspace":   flow.Workspace,
+	}).Info("User logged in successfully")
+
+	return session, token, nil
+}
+
+// provisionUser returns the user linked to the provider account in claims.
+// On the first login the account is linked to the user with its email
+// address, who is created if needed. Providers that do not send
+// email_verified at all are trusted to only release verified addresses.
+func (service *SessionService) provisionUser(workspaceID int, claims *oidc.Claims) (*types.User, error) {
+	var user types.User
+	var userID int
+
+	tx, err := inWorkspace(service.DB, workspaceID).Begin()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.SessionLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("UPDATE user_identities SET last_login_at = $1 WHERE issuer = $2 AND subject = $3 RETURNING user_id",
+		time.Now(), claims.Issuer, claims.Subject).Scan(&userID)
+
+	switch {
+	case err == nil:
+		err = scanUser(tx.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", userID), &user)
+	case err == sql.ErrNoRows:
+		if claims.Email == "" {
+			return nil, TodoError{Message: constant.ErrMsgLoginEmailMissing, Reason: ReasonForbidden}
+		}
+
+		if claims.EmailVerified != nil && !*claims.EmailVerified {
+			return nil, TodoError{Message: constant.ErrMsgLoginEmailUnverified, Reason: ReasonForbidden}
+		}
+
+		var created *types.User
+
+		if created, err = ensureUser(txRower{tx}, claims.Email, claims.Name); err == nil {
+			user = *created
+			_, err = tx.Exec("INSERT INTO user_identities (user_id, issuer, subject, last_login_at) VALUES ($1, $2, $3, $4)",
+				user.ID, claims.Issuer, claims.Subject, time.Now())
+		}
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":   constant.SessionLogEventErrorKey,
+			"subject": claims.Subject,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &user, nil
+}
+
+func (service *SessionService) issueToken(session *types.Session, workspace string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	payload, _ := json.Marshal(sessionClaims{
+		Issuer:      constant.SessionTokenIssuer,
+		Subject:     session.User.ExternalID,
+		Workspace:   workspace,
+		WorkspaceID: session.WorkspaceID,
+		IssuedAt:    time.Now().Unix(),
+		Expiry:      session.ExpiresAt.Unix(),
+	})
+
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return signingInput + "." + service.sign("session", signingInput)
+}
+
+// AuthenticateSession checks a session token and loads its user.
+func (service *SessionService) AuthenticateSession(token string) (*types.Session, error) {
+	invalid := TodoError{Message: constant.ErrMsgSessionInvalid, Reason: ReasonUnauthorized}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || service.verify("session", parts[0]+"."+parts[1], parts[2]) != nil {
+		return nil, invalid
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+
+	var claims sessionClaims
+
+	if decodeJWTSegment(parts[0], &header) != nil || header.Algorithm != "HS256" || decodeJWTSegment(parts[1], &claims) != nil {
+		return nil, invalid
+	}
+
+	if claims.Issuer != constant.SessionTokenIssuer || time.Now().Unix() >= claims.Expiry {
+		return nil, invalid
+	}
+
+	var user types.User
+
+	err := scanUser(service.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE external_id = $1 AND workspace_id = $2",
+		claims.Subject, claims.WorkspaceID), &user)
+
+	if err == sql.ErrNoRows {
+		return nil, invalid
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.SessionLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &types.Session{
+		User:        &user,
+		WorkspaceID: claims.WorkspaceID,
+		ExpiresAt:   time.Unix(claims.Expiry, 0),
+	}, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(decoded, v)
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/app/types"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// TodoCacheOptions configure a TodoCache.
+type TodoCacheOptions struct {
+	// Size is the number of todos kept; the least recently read one makes
+	// room for the next.
+	Size int
+	// TTL bounds how long a todo is served from memory, and so how stale
+	// it can get when a write elsewhere is not heard of.
+	TTL time.Duration
+	// Broadcast, when set, is called with the ID of every todo written
+	// through this instance, to pass on to the other instances, which call
+	// Invalidate with it. It runs as the write is published and must not
+	// block, so sending is best left to a goroutine of its own. See
+	// TodoCacheNotifier.
+	Broadcast func(id string)
+}
+
+// TodoCache keeps recently read todos in memory for GetTodoByID, least
+// recently used first out. Loads of the same todo at the same time share one
+// query. Every write published on the service's EventBus drops the todo it
+// touched; see TodoService.WithCache.
+type TodoCache struct {
+	opts  TodoCacheOptions
+	now   func() time.Time
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// gen counts invalidations, so a load that raced one is not stored.
+	gen   uint64
+	stats types.TodoCacheStats
+}
+
+type todoCacheEntry struct {
+	id          string
+	workspaceID int
+	todo        types.Todo
+	expires     time.Time
+}
+
+func NewTodoCache(opts TodoCacheOptions) *TodoCache {
+	return &TodoCache{
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// WithCache returns a copy of the service that reads todos by ID through
+// cache, and hooks cache to the service's EventBus so that every write
+// invalidates what it touched. It is meant to be called once, on the service
+// the others are derived from.
+func (service *TodoService) WithCache(cache *TodoCache) *TodoService {
+	scoped := *service
+	scoped.Cache = cache
+
+	service.Events.Handle(cache.invalidateEvent)
+
+	return &scoped
+}
+
+// get returns the todo with id as seen from workspaceID, calling load on a
+// miss. Errors, not found included, are not cached.
+func (cache *TodoCache) get(workspaceID int, id string, load func(id string) (*types.Todo, error)) (*types.Todo, error) {
+	key := strings.ToLower(id)
+
+	cache.mu.Lock()
+
+	if todo, ok := cache.lookup(workspaceID, key); ok {
+		cache.stats.Hits++
+		cache.mu.Unlock()
+
+		return todo, nil
+	}
+
+	cache.stats.Misses++
+	gen := cache.gen
+	cache.mu.Unlock()
+
+	// Reads after an invalidation start a load of their own rather than
+	// wait on one that may return what was there before.
+	flight := strconv.Itoa(workspaceID) + "/" + key + "/" + strconv.FormatUint(gen, 10)
+
+	value, err, _ := cache.group.Do(flight, func() (interface{}, error) {
+		todo, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		cache.store(workspaceID, key, *todo, gen)
+
+		return todo, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return copyTodo(*value.(*types.Todo)), nil
+}
+
+// lookup returns a copy of the live entry for key, dropping it if it expired.
+// The caller holds mu.
+func (cache *TodoCache) lookup(workspaceID int, key string) (*types.Todo, bool) {
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*todoCacheEntry)
+
+	if !cache.now().Before(entry.expires) {
+		cache.remove(element)
+
+		return nil, false
+	}
+
+	// A todo lives in a single workspace, but the unscoped service and
+	// that workspace's may both read it; they take turns in the entry.
+	if entry.workspaceID != workspaceID {
+		return nil, false
+	}
+
+	cache.lru.MoveToFront(element)
+
+	return copyTodo(entry.todo), true
+}
+
+// store keeps todo unless the cache was invalidated since gen, when the load
+// may have read it from before the write.
+func (cache *TodoCache) store(workspaceID int, key string, todo types.Todo, gen uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.gen != gen || cache.opts.Size <= 0 {
+		return
+	}
+
+	entry := &todoCacheEntry{id: key, workspaceID: workspaceID, todo: todo, expires: cache.now().Add(cache.opts.TTL)}
+
+	if element, ok := cache.entries[key]; ok {
+		element.Value = entry
+		cache.lru.MoveToFront(element)
+
+		return
+	}
+
+	cache.entries[key] = cache.lru.PushFront(entry)
+
+	for cache.lru.Len() > cache.opts.Size {
+		cache.remove(cache.lru.Back())
+		cache.stats.Evictions++
+	}
+}
+
+// remove drops element. The caller holds mu.
+func (cache *TodoCache) remove(element *list.Element) {
+	cache.lru.Remove(element)
+	delete(cache.entries, element.Value.(*todoCacheEntry).id)
+}
+
+// Invalidate drops the todo with id, for when another instance wrote it. It
+// does not broadcast.
+func (cache *TodoCache) Invalidate(id string) {
+	key := strings.ToLower(id)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.gen++
+	cache.stats.Invalidations++
+
+	if element, ok := cache.entries[key]; ok {
+		cache.remove(element)
+	}
+}
+
+// purge drops every todo, for when writes elsewhere may have gone unheard.
+func (cache *TodoCache) purge() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.gen++
+	cache.entries = make(map[string]*list.Element)
+	cache.lru.Init()
+}
+
+// invalidateEvent drops the todo a write on this instance touched, and tells
+// the other instances about it.
+func (cache *TodoCache) invalidateEvent(event types.TodoEvent) {
+	cache.Invalidate(event.ID)
+
+	if cache.opts.Broadcast != nil {
+		cache.opts.Broadcast(event.ID)
+	}
+}
+
+// Stats returns the counters of the cache since it was created.
+func (cache *TodoCache) Stats() types.TodoCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Entries = cache.lru.Len()
+	stats.Size = cache.opts.Size
+
+	return stats
+}
+
+// copyTodo returns a copy of todo that shares no memory with it, so callers
+// cannot change what the cache holds.
+func copyTodo(todo types.Todo) *types.Todo {
+	if todo.DueAt != nil {
+		dueAt := *todo.DueAt
+		todo.DueAt = &dueAt
+	}
+
+	return &todo
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"todo-app/app/constant"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// TodoCacheNotifier keeps the todo caches of several instances of the app in
+// step over Postgres NOTIFY: it tells the others of every todo written here,
+// and drops from the cache here every todo they tell of.
+type TodoCacheNotifier struct {
+	db      *sql.DB
+	channel string
+	// instance tells this instance's notifications apart, as Postgres
+	// delivers them to the sender's listener too.
+	instance string
+}
+
+func NewTodoCacheNotifier(db *sql.DB, channel string) *TodoCacheNotifier {
+	return &TodoCacheNotifier{db: db, channel: channel, instance: uuid.New().String()}
+}
+
+// Broadcast notifies the other instances that the todo with id was written,
+// for TodoCacheOptions.Broadcast. It sends from a goroutine of its own so the
+// write is not held up; a notification that is lost leaves the todo stale on
+// the others for at most the TTL.
+func (notifier *TodoCacheNotifier) Broadcast(id string) {
+	go func() {
+		if _, err := notifier.db.Exec("SELECT pg_notify($1, $2)", notifier.channel, notifier.instance+":"+id); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.TodoCacheLogEventErrorKey,
+				"external_id": id,
+				"error":       err.Error(),
+			}).Error("Failed to notify other instances of a todo write")
+		}
+	}()
+}
+
+// Listen drops from cache the todos the other instances write, as they come
+// in on notifications, until ctx is done or notifications is closed. A nil
+// notification, which pq.Listener sends once it reconnects, empties the cache:
+// whatever was written while it was away went unheard.
+func (notifier *TodoCacheNotifier) Listen(ctx context.Context, cache *TodoCache, notifications <-chan *pq.Notification) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-notifications:
+			if !ok {
+				return
+			}
+
+			if notification == nil {
+				cache.purge()
+
+				continue
+			}
+
+			instance, id, ok := strings.Cut(notification.Extra, ":")
+
+			if ok && instance != notifier.instance {
+				cache.Invalidate(id)
+			}
+		}
+	}
+}
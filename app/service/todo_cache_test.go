@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingLoader loads todos titled after their ID and counts the loads.
+type countingLoader struct {
+	loads atomic.Int32
+}
+
+func (loader *countingLoader) load(id string) (*types.Todo, error) {
+	loader.loads.Add(1)
+
+	return &types.Todo{ExternalID: id, Title: "Todo " + id}, nil
+}
+
+func TestTodoCache(t *testing.T) {
+	t.Run("It should serve a todo from memory once loaded", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+
+		for i := 0; i < 3; i++ {
+			todo, err := cache.get(1, "a", loader.load)
+			require.NoError(t, err)
+			assert.Equal(t, "Todo a", todo.Title)
+		}
+
+		assert.Equal(t, int32(1), loader.loads.Load())
+		assert.Equal(t, types.TodoCacheStats{Hits: 2, Misses: 1, Entries: 1, Size: 10}, cache.Stats())
+	})
+
+	t.Run("It should not let callers change what it holds", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		dueAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		load := func(id string) (*types.Todo, error) {
+			return &types.Todo{ExternalID: id, Title: "Original", DueAt: &dueAt}, nil
+		}
+
+		todo, err := cache.get(1, "a", load)
+		require.NoError(t, err)
+
+		todo.Title = "Changed"
+		*todo.DueAt = time.Time{}
+
+		todo, err = cache.get(1, "a", load)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", todo.Title)
+		assert.Equal(t, dueAt, *todo.DueAt)
+	})
+
+	t.Run("It should load again once the TTL passed", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		cache.get(1, "a", loader.load)
+
+		now = now.Add(time.Minute)
+
+		cache.get(1, "a", loader.load)
+		assert.Equal(t, int32(2), loader.loads.Load())
+	})
+
+	t.Run("It should evict the least recently read todo", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 2, TTL: time.Minute})
+		loader := &countingLoader{}
+
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+		cache.get(1, "a", loader.load)
+		cache.get(1, "c", loader.load)
+
+		cache.get(1, "a", loader.load)
+		assert.Equal(t, int32(3), loader.loads.Load())
+
+		cache.get(1, "b", loader.load)
+		assert.Equal(t, int32(4), loader.loads.Load())
+		assert.Equal(t, uint64(2), cache.Stats().Evictions)
+	})
+
+	t.Run("It should keep workspaces apart", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+
+		cache.get(1, "a", loader.load)
+
+		_, err := cache.get(2, "a", func(id string) (*types.Todo, error) {
+			return nil, TodoError{Message: "Todo with id a not found", Reason: ReasonNotFound}
+		})
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+	})
+
+	t.Run("It should share one load between concurrent reads", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+		release := make(chan struct{})
+
+		load := func(id string) (*types.Todo, error) {
+			<-release
+
+			return loader.load(id)
+		}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				todo, err := cache.get(1, "a", load)
+				assert.NoError(t, err)
+				assert.Equal(t, "Todo a", todo.Title)
+			}()
+		}
+
+		// Let the reads pile up on the first load before it returns; each
+		// joins it right after counting its miss.
+		assert.Eventually(t, func() bool { return cache.Stats().Misses == 10 }, time.Second, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), loader.loads.Load())
+	})
+
+	t.Run("It should not store a load that raced a write", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+
+		_, err := cache.get(1, "a", func(id string) (*types.Todo, error) {
+			cache.Invalidate(id)
+
+			return loader.load(id)
+		})
+		require.NoError(t, err)
+
+		cache.get(1, "a", loader.load)
+		assert.Equal(t, int32(2), loader.loads.Load())
+	})
+
+	t.Run("It should not cache errors", func(t *testing.T) {
+		cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+		loader := &countingLoader{}
+
+		_, err := cache.get(1, "a", func(id string) (*types.Todo, error) {
+			return nil, fmt.Errorf("connection refused")
+		})
+		assert.Error(t, err)
+
+		_, err = cache.get(1, "a", loader.load)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), loader.loads.Load())
+	})
+}
+
+func TestTodoCacheInvalidation(t *testing.T) {
+	cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+	loader := &countingLoader{}
+
+	var broadcast []string
+
+	cache.opts.Broadcast = func(id string) { broadcast = append(broadcast, id) }
+
+	service := (&TodoService{Events: NewEventBus()}).WithCache(cache)
+
+	t.Run("It should drop a todo written through the service", func(t *testing.T) {
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+
+		service.Events.Publish(types.TodoEvent{WorkspaceID: 1, Type: constant.TodoEventUpdated, ID: "A"})
+
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+		assert.Equal(t, int32(3), loader.loads.Load())
+		assert.Equal(t, []string{"A"}, broadcast)
+	})
+
+	t.Run("It should drop a todo another instance wrote without telling the others", func(t *testing.T) {
+		cache.Invalidate("b")
+
+		cache.get(1, "b", loader.load)
+		assert.Equal(t, int32(4), loader.loads.Load())
+		assert.Equal(t, []string{"A"}, broadcast)
+	})
+}
+
+func TestTodoCacheNotifier(t *testing.T) {
+	cache := NewTodoCache(TodoCacheOptions{Size: 10, TTL: time.Minute})
+	loader := &countingLoader{}
+	notifier := NewTodoCacheNotifier(nil, "todo_cache")
+	notifications := make(chan *pq.Notification)
+
+	// settle returns once the listener handled every notification sent
+	// before, as it takes the next one only then.
+	settle := func() {
+		notifications <- &pq.Notification{Channel: "todo_cache", Extra: notifier.instance + ":"}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		notifier.Listen(context.Background(), cache, notifications)
+		close(done)
+	}()
+
+	t.Run("It should drop a todo another instance wrote", func(t *testing.T) {
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+
+		notifications <- &pq.Notification{Channel: "todo_cache", Extra: "other:A"}
+		notifications <- &pq.Notification{Channel: "todo_cache", Extra: notifier.instance + ":b"}
+		settle()
+
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+		assert.Equal(t, int32(3), loader.loads.Load())
+	})
+
+	t.Run("It should drop everything once the listener reconnects", func(t *testing.T) {
+		notifications <- nil
+		settle()
+
+		cache.get(1, "a", loader.load)
+		cache.get(1, "b", loader.load)
+		assert.Equal(t, int32(5), loader.loads.Load())
+	})
+
+	close(notifications)
+	<-done
+}
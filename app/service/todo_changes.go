@@ -0,0 +1,313 @@
+package service
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// LatestChangeSeq returns the position of the newest entry in the change log.
+func (service *TodoService) LatestChangeSeq() (int64, error) {
+	return service.Store.LatestChangeSeq(service)
+}
+
+func (sqlTodoStore) LatestChangeSeq(service *TodoService) (int64, error) {
+	var seq int64
+
+	if err := service.DB.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM todo_changes").Scan(&seq); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return 0, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return seq, nil
+}
+
+// ChangesSince reads the change log written by the todos_record_change
+// trigger. Seq 0 means the client has nothing yet, so every todo is returned
+// as created. Created and updated todos are limited to those the actor can
+// see, and deleted ones to those the actor could see until they were deleted
+// or stopped being shared with them. When limit is positive, the log is read
+// up to the point where limit todos are listed, and More is set if it goes
+// on. Everything is read from one snapshot, so the returned Seq covers
+// exactly the changes listed.
+func (service *TodoService) ChangesSince(seq int64, limit int) (*types.TodoChanges, error) {
+	return service.Store.ChangesSince(service, seq, limit)
+}
+
+func (sqlTodoStore) ChangesSince(service *TodoService, seq int64, limit int) (*types.TodoChanges, error) {
+	tx, err := service.DB.BeginTx(service.DB.context(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	changes := &types.TodoChanges{}
+
+	if err := tx.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM todo_changes").Scan(&changes.Seq); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if seq < 0 || seq > changes.Seq {
+		return nil, TodoError{Message: constant.ErrMsgInvalidSyncToken, Reason: ReasonPreconditionFailed}
+	}
+
+	// Entries with a user_id are for that user. A deletion logged for
+	// everybody says nothing about who could see the todo, so only a reader
+	// acting for nobody takes it.
+	query := "SELECT seq, external_id, created, deleted FROM todo_changes WHERE seq > $1"
+	args := []interface{}{seq}
+
+	if service.Actor != nil {
+		query += " AND ((user_id IS NULL AND NOT deleted) OR user_id = $2)"
+		args = append(args, service.Actor.ID)
+	} else {
+		query += " AND user_id IS NULL"
+	}
+
+	rows, err := tx.Query(query+" ORDER BY seq", args...)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	var ids []string
+
+	created := make(map[string]bool)
+	deleted := make(map[string]bool)
+
+	for rows.Next() {
+		var n int64
+		var id string
+		var inserted, removed bool
+
+		if err := rows.Scan(&n, &id, &inserted, &removed); err != nil {
+			rows.Close()
+
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.TodoChangesLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		if _, seen := created[id]; !seen {
+			if limit > 0 && len(ids) == limit {
+				changes.Seq = n - 1
+				changes.More = true
+
+				break
+			}
+
+			ids = append(ids, id)
+		}
+
+		created[id] = created[id] || inserted
+		deleted[id] = deleted[id] || removed
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if len(ids) == 0 {
+		return changes, nil
+	}
+
+	args = []interface{}{pq.Array(ids)}
+	conditions := []string{service.DB.dialect().contains("$1", "external_id")}
+
+	if visible, visibleArgs := service.visibleTodosCondition(args); visible != "" {
+		args = visibleArgs
+		conditions = append(conditions, visible)
+	}
+
+	rows, err = tx.Query("SELECT "+todoColumns+" FROM todos WHERE "+strings.Join(conditions, " AND ")+" ORDER BY created_at, id", args...)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.TodoChangesLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	listed := make(map[string]bool)
+
+	for rows.Next() {
+		var todo types.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.TodoChangesLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		listed[todo.ExternalID] = true
+
+		if seq == 0 || created[todo.ExternalID] {
+			changes.Created = append(changes.Created, todo)
+		} else {
+			changes.Updated = append(changes.Updated, todo)
+		}
+	}
+
+	// A todo deleted or unshared and then recreated or shared again is
+	// listed above. One the actor can no longer see is gone for them.
+	if seq > 0 {
+		for _, id := range ids {
+			if deleted[id] && !listed[id] {
+				changes.Deleted = append(changes.Deleted, id)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// PutTodo stores every field of todo under its ExternalID, creating it when
+// it does not exist yet and keeping the original creation time otherwise.
+// precondition, when set, sees the current todo (nil if absent) while it is
+// locked and can refuse the write by returning an error. Replacing a todo
+// needs the editor role on it; a created todo is owned by the actor. The
+// boolean reports whether the todo was created.
+func (service *TodoService) PutTodo(todo types.Todo, precondition func(current *types.Todo) error) (*types.Todo, bool, error) {
+	return service.Store.PutTodo(service, todo, precondition)
+}
+
+func (sqlTodoStore) PutTodo(service *TodoService, todo types.Todo, precondition func(current *types.Todo) error) (*types.Todo, bool, error) {
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.PutTodoLogEventErrorKey,
+			"external_id": todo.ExternalID,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, false, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	var current *types.Todo
+	var existing types.Todo
+
+	err = scanTodo(tx.QueryRow("SELECT "+todoColumns+" FROM todos WHERE external_id = $1"+service.DB.dialect().forUpdate(false), todo.ExternalID), &existing)
+
+	switch {
+	case err == nil:
+		if err := service.authorizeTodo(txRower{tx}, todo.ExternalID, constant.RoleEditor, constant.PutTodoLogEventErrorKey); err != nil {
+			return nil, false, err
+		}
+
+		current = &existing
+	case err != sql.ErrNoRows:
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.PutTodoLogEventErrorKey,
+			"external_id": todo.ExternalID,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, false, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if precondition != nil {
+		if err := precondition(current); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if todo.Status == "" {
+		todo.Status = constant.TodoStatusNeedsAction
+	}
+
+	eventType := constant.TodoEventCreated
+
+	if current != nil {
+		eventType = constant.TodoEventUpdated
+		todo.ID = current.ID
+		todo.CreatedAt = current.CreatedAt
+		todo.ListID = current.ListID
+
+		_, err = tx.Exec("UPDATE todos SET title = $1, due_at = $2, status = $3, recurrence = $4 WHERE id = $5",
+			todo.Title, todo.DueAt, todo.Status, todo.Recurrence, todo.ID)
+	} else {
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = time.Now()
+		}
+
+		// The lookup above locks no row when there is none, so another
+		// request may have created the todo since. The creation time is read
+		// back as stored, which may be less precise, so the returned todo
+		// matches what reading it later gives.
+		err = tx.QueryRow("INSERT INTO todos (external_id, title, created_at, due_at, status, recurrence) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING RETURNING id, created_at",
+			todo.ExternalID, todo.Title, todo.CreatedAt, todo.DueAt, todo.Status, todo.Recurrence).Scan(&todo.ID, &todo.CreatedAt)
+
+		if err == sql.ErrNoRows {
+			return nil, false, TodoError{Message: constant.ErrMsgTodoCreatedMeanwhile, Reason: ReasonConflict}
+		}
+
+		if err == nil {
+			err = service.grantTodoOwner(tx, todo.ExternalID)
+		}
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.PutTodoLogEventErrorKey,
+			"external_id": todo.ExternalID,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, false, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := enqueueWebhookEvent(tx, eventType, utils.MapTodoResponse(&todo)); err != nil {
+		return nil, false, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.PutTodoLogEventErrorKey,
+			"external_id": todo.ExternalID,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, false, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: eventType, ID: todo.ExternalID, Todo: &todo})
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.PutTodoLogEventKey,
+		"external_id": todo.ExternalID,
+		"created":     current == nil,
+	}).Info("Todo stored successfully")
+
+	return &todo, current == nil, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"sync"
+
+	"todo-app/app/types"
+)
+
+// EventBus fans committed todo changes out to in-process subscribers such as
+// WebSocket connections. Publishing never blocks: a subscriber whose buffer is
+// full is dropped and its channel closed, so one slow client cannot stall
+// writes for everyone else.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan types.TodoEvent]struct{}
+	handlers    []func(types.TodoEvent)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan types.TodoEvent]struct{}),
+	}
+}
+
+func (bus *EventBus) Subscribe(buffer int) (<-chan types.TodoEvent, func()) {
+	ch := make(chan types.TodoEvent, buffer)
+
+	bus.mu.Lock()
+	bus.subscribers[ch] = struct{}{}
+	bus.mu.Unlock()
+
+	return ch, func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+
+		if _, ok := bus.subscribers[ch]; ok {
+			delete(bus.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribed reports whether anyone is subscribed, so publishers can spare
+// the work only subscribers need.
+func (bus *EventBus) Subscribed() bool {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	return len(bus.subscribers) > 0
+}
+
+// Handle calls fn with every event as it is published, before Publish
+// returns, for what must not lag behind the write, like dropping a cached
+// copy. fn must be quick and must not publish.
+func (bus *EventBus) Handle(fn func(types.TodoEvent)) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.handlers = append(bus.handlers, fn)
+}
+
+func (bus *EventBus) Publish(event types.TodoEvent) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for _, fn := range bus.handlers {
+		fn(event)
+	}
+
+	for ch := range bus.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(bus.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,799 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/filestore"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// The file store keeps todos and lists as JSON under their lowercased
+// external IDs, the change log under its sequence numbers, and the time each
+// deleted todo was last deleted, which sync compares client changes with.
+// Counters hand out the IDs and sequence numbers a database would.
+const (
+	fileTodoPrefix      = "todos/"
+	fileListPrefix      = "lists/"
+	fileChangePrefix    = "changes/"
+	fileTombstonePrefix = "tombstones/"
+	fileCounterPrefix   = "counters/"
+)
+
+// fileChange is an entry of the change log, like a row of todo_changes.
+type fileChange struct {
+	ExternalID string    `json:"external_id"`
+	Created    bool      `json:"created"`
+	Deleted    bool      `json:"deleted"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// fileList is how the file store keeps a list.
+type fileList struct {
+	ID         int       `json:"id"`
+	ExternalID string    `json:"external_id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewFileTodoService returns a service keeping todos in store rather than a
+// database, for deployments without a database server. The store holds a
+// single workspace and no users, so it is run with authentication off:
+// every request acts for nobody in particular and may see every todo. Lists
+// work, but have no members to invite.
+func NewFileTodoService(store *filestore.Store) *TodoService {
+	return &TodoService{
+		Store:  fileTodoStore{files: store},
+		Events: NewEventBus(),
+		Log:    logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// fileTodoStore keeps todos and lists in a file store.
+type fileTodoStore struct {
+	files *filestore.Store
+}
+
+// storeError marks a failure of the file store itself, as opposed to an
+// error a transaction returns on purpose, such as a todo that is not found.
+type storeError struct {
+	err error
+}
+
+func (e storeError) Error() string {
+	return e.err.Error()
+}
+
+// fileTx reads and writes todos in a file store transaction. Failures of the
+// store come back as storeError.
+type fileTx struct {
+	*filestore.Tx
+}
+
+func (tx fileTx) get(key string, value interface{}) (bool, error) {
+	data := tx.Get(key)
+	if data == nil {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, storeError{fmt.Errorf("decode %s: %w", key, err)}
+	}
+
+	return true, nil
+}
+
+func (tx fileTx) put(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return storeError{err}
+	}
+
+	if err := tx.Put(key, data); err != nil {
+		return storeError{err}
+	}
+
+	return nil
+}
+
+func (tx fileTx) counter(name string) (int64, error) {
+	var n int64
+
+	if data := tx.Get(fileCounterPrefix + name); data != nil {
+		var err error
+
+		if n, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+			return 0, storeError{fmt.Errorf("decode counter %s: %w", name, err)}
+		}
+	}
+
+	return n, nil
+}
+
+// next advances a counter and returns its new value.
+func (tx fileTx) next(name string) (int64, error) {
+	n, err := tx.counter(name)
+	if err != nil {
+		return 0, err
+	}
+
+	n++
+
+	if err := tx.Put(fileCounterPrefix+name, []byte(strconv.FormatInt(n, 10))); err != nil {
+		return 0, storeError{err}
+	}
+
+	return n, nil
+}
+
+// todo returns the todo with external ID id, or nil.
+func (tx fileTx) todo(id string) (*syncTodo, error) {
+	var todo syncTodo
+
+	found, err := tx.get(fileTodoPrefix+strings.ToLower(id), &todo)
+	if !found || err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// todos returns every todo matching the filter, oldest first. Limit and
+// Offset are left to the caller.
+func (tx fileTx) todos(filter types.TodoFilter) ([]types.Todo, error) {
+	var todos []types.Todo
+
+	title := strings.ToLower(filter.TitleContains)
+
+	err := tx.Range(fileTodoPrefix, func(key string, data []byte) error {
+		var todo types.Todo
+
+		if err := json.Unmarshal(data, &todo); err != nil {
+			return storeError{fmt.Errorf("decode %s: %w", key, err)}
+		}
+
+		switch {
+		case filter.ListID != "" && !strings.EqualFold(todo.ListID, filter.ListID):
+		case title != "" && !strings.Contains(strings.ToLower(todo.Title), title):
+		case filter.CreatedAfter != nil && !todo.CreatedAt.After(*filter.CreatedAfter):
+		case filter.CreatedBefore != nil && !todo.CreatedAt.Before(*filter.CreatedBefore):
+		default:
+			todos = append(todos, todo)
+		}
+
+		return nil
+	})
+
+	sortTodos(todos)
+
+	return todos, err
+}
+
+// sortTodos orders todos by creation, like the ORDER BY created_at, id of
+// the queries.
+func sortTodos(todos []types.Todo) {
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].CreatedAt.Before(todos[j].CreatedAt)
+		}
+
+		return todos[i].ID < todos[j].ID
+	})
+}
+
+// putTodo stores todo and records the change. A created todo gets its ID
+// here.
+func (tx fileTx) putTodo(todo *syncTodo, created bool, now time.Time) error {
+	if created {
+		id, err := tx.next("todos")
+		if err != nil {
+			return err
+		}
+
+		todo.ID = int(id)
+	}
+
+	todo.ExternalID = strings.ToLower(todo.ExternalID)
+
+	if err := tx.put(fileTodoPrefix+todo.ExternalID, todo); err != nil {
+		return err
+	}
+
+	return tx.recordChange(fileChange{ExternalID: todo.ExternalID, Created: created, ChangedAt: now})
+}
+
+// deleteTodo deletes the todo with external ID id and records the change.
+func (tx fileTx) deleteTodo(id string, now time.Time) error {
+	id = strings.ToLower(id)
+
+	if err := tx.Delete(fileTodoPrefix + id); err != nil {
+		return storeError{err}
+	}
+
+	if err := tx.put(fileTombstonePrefix+id, now); err != nil {
+		return err
+	}
+
+	return tx.recordChange(fileChange{ExternalID: id, Deleted: true, ChangedAt: now})
+}
+
+func fileChangeKey(seq int64) string {
+	return fmt.Sprintf("%s%020d", fileChangePrefix, seq)
+}
+
+func (tx fileTx) recordChange(change fileChange) error {
+	seq, err := tx.next("changes")
+	if err != nil {
+		return err
+	}
+
+	return tx.put(fileChangeKey(seq), change)
+}
+
+func (tx fileTx) list(id string) (*fileList, error) {
+	var list fileList
+
+	found, err := tx.get(fileListPrefix+strings.ToLower(id), &list)
+	if !found || err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// newFileTodo returns todo as stored when it is created, with every field
+// written now.
+func newFileTodo(todo types.Todo, now time.Time) *syncTodo {
+	return &syncTodo{Todo: todo, TitleUpdatedAt: now, DueAtUpdatedAt: now, StatusUpdatedAt: now, RecurrenceUpdatedAt: now}
+}
+
+// touchFileTodo stamps the fields of todo that differ from before, as the
+// todos_touch_fields trigger does in the database.
+func touchFileTodo(todo *syncTodo, before *syncTodo, now time.Time) {
+	if todo.Title != before.Title {
+		todo.TitleUpdatedAt = now
+	}
+
+	if !sameTime(todo.DueAt, before.DueAt) {
+		todo.DueAtUpdatedAt = now
+	}
+
+	if todo.Status != before.Status {
+		todo.StatusUpdatedAt = now
+	}
+
+	if todo.Recurrence != before.Recurrence {
+		todo.RecurrenceUpdatedAt = now
+	}
+}
+
+// view runs fn in a read-only file store transaction; see update.
+func (store fileTodoStore) view(service *TodoService, event string, id string, fn func(tx fileTx) error) error {
+	var returned error
+
+	err := store.files.View(func(tx *filestore.Tx) error {
+		returned = fn(fileTx{tx})
+
+		return returned
+	})
+
+	return store.result(service, err, returned, event, id)
+}
+
+// update runs fn in a file store transaction, committed when fn returns
+// nil. Errors fn returns on purpose are passed back as they are; failures of
+// the store are logged under event and reported as an internal error.
+func (store fileTodoStore) update(service *TodoService, event string, id string, fn func(tx fileTx) error) error {
+	var returned error
+
+	err := store.files.Update(func(tx *filestore.Tx) error {
+		returned = fn(fileTx{tx})
+
+		return returned
+	})
+
+	return store.result(service, err, returned, event, id)
+}
+
+func (store fileTodoStore) result(service *TodoService, err error, returned error, event string, id string) error {
+	var failure storeError
+
+	if err == nil || (returned != nil && !errors.As(returned, &failure)) {
+		return err
+	}
+
+	fields := logrus.Fields{
+		"event": event,
+		"error": err.Error(),
+	}
+
+	if id != "" {
+		fields["external_id"] = id
+	}
+
+	service.Log.WithFields(fields).Error(constant.FileStoreFailMsg)
+
+	return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+}
+
+func (service *TodoService) todoNotFound(event string, id string) error {
+	service.Log.WithFields(logrus.Fields{
+		"event":       event,
+		"external_id": id,
+	}).Error(constant.DbIdNotFoundMsg)
+
+	return TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
+}
+
+func (service *TodoService) listNotFound(event string, id string) error {
+	service.Log.WithFields(logrus.Fields{
+		"event":       event,
+		"external_id": id,
+	}).Error(constant.DbIdNotFoundMsg)
+
+	return TodoError{Message: fmt.Sprintf("List with id %s not found", id), Reason: ReasonNotFound}
+}
+
+func (store fileTodoStore) GetAllTodos(service *TodoService) ([]types.Todo, error) {
+	var todos []types.Todo
+
+	err := store.view(service, constant.GetTodosLogEventErrorKey, "", func(tx fileTx) error {
+		var err error
+		todos, err = tx.todos(types.TodoFilter{})
+
+		return err
+	})
+
+	return todos, err
+}
+
+func (store fileTodoStore) FindTodos(service *TodoService, filter types.TodoFilter) ([]types.Todo, int, error) {
+	var todos []types.Todo
+
+	err := store.view(service, constant.GetTodosLogEventErrorKey, "", func(tx fileTx) error {
+		var err error
+		todos, err = tx.todos(filter)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(todos)
+
+	if filter.Offset >= len(todos) {
+		return nil, total, nil
+	}
+
+	todos = todos[filter.Offset:]
+
+	if filter.Limit > 0 && filter.Limit < len(todos) {
+		todos = todos[:filter.Limit]
+	}
+
+	return todos, total, nil
+}
+
+// ExportTodos reads the todos first and hands them to fn afterwards, so
+// a slow client does not hold up writers.
+func (store fileTodoStore) ExportTodos(service *TodoService, filter types.TodoFilter, fn func(todo types.Todo) error) error {
+	var todos []types.Todo
+
+	err := store.view(service, constant.ExportTodosLogEventErrorKey, "", func(tx fileTx) error {
+		var err error
+		todos, err = tx.todos(filter)
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event": constant.ExportTodosLogEventKey,
+		"count": len(todos),
+	}).Debug("Todos exported successfully")
+
+	return nil
+}
+
+func (store fileTodoStore) GetTodosByIDs(service *TodoService, ids []string) ([]types.Todo, error) {
+	var todos []types.Todo
+
+	err := store.view(service, constant.GetTodoLogEventErrorKey, "", func(tx fileTx) error {
+		for _, id := range ids {
+			todo, err := tx.todo(id)
+			if err != nil {
+				return err
+			}
+
+			if todo != nil {
+				todos = append(todos, todo.Todo)
+			}
+		}
+
+		return nil
+	})
+
+	return todos, err
+}
+
+func (store fileTodoStore) GetTodoByID(service *TodoService, id string) (*types.Todo, error) {
+	var todo *syncTodo
+
+	err := store.view(service, constant.GetTodoLogEventErrorKey, id, func(tx fileTx) error {
+		var err error
+		todo, err = tx.todo(id)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if todo == nil {
+		return nil, service.todoNotFound(constant.GetTodoLogEventErrorKey, id)
+	}
+
+	return &todo.Todo, nil
+}
+
+func (store fileTodoStore) CreateTodoInList(service *TodoService, listID string, title string) (*types.Todo, error) {
+	now := time.Now()
+	todo := newFileTodo(types.Todo{
+		ExternalID: uuid.New().String(),
+		Title:      title,
+		CreatedAt:  now,
+		Status:     constant.TodoStatusNeedsAction,
+	}, now)
+
+	err := store.update(service, constant.CreateTodoLogEventErrorKey, "", func(tx fileTx) error {
+		if listID != "" {
+			list, err := tx.list(listID)
+			if err != nil {
+				return err
+			}
+
+			if list == nil {
+				return service.listNotFound(constant.CreateTodoLogEventErrorKey, listID)
+			}
+
+			todo.ListID = list.ExternalID
+		}
+
+		return tx.putTodo(todo, true, now)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventCreated, ID: todo.ExternalID, Todo: &todo.Todo})
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.CreateTodoLogEventKey,
+		"external_id": todo.ExternalID,
+	}).Info("Todo created successfully")
+
+	return &todo.Todo, nil
+}
+
+func (store fileTodoStore) UpdateTodo(service *TodoService, id string, title string) (*types.Todo, error) {
+	var todo *syncTodo
+
+	err := store.update(service, constant.UpdateTodoLogEventErrorKey, id, func(tx fileTx) error {
+		var err error
+
+		if todo, err = tx.todo(id); err != nil {
+			return err
+		}
+
+		if todo == nil {
+			return service.todoNotFound(constant.UpdateTodoLogEventErrorKey, id)
+		}
+
+		now := time.Now()
+		before := *todo
+		todo.Title = title
+		touchFileTodo(todo, &before, now)
+
+		return tx.putTodo(todo, false, now)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventUpdated, ID: id, Todo: &todo.Todo})
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.UpdateTodoLogEventKey,
+		"external_id": id,
+	}).Info("Todo updated successfully")
+
+	return &todo.Todo, nil
+}
+
+func (store fileTodoStore) DeleteTodoIf(service *TodoService, id string, precondition func(current *types.Todo) error) error {
+	err := store.update(service, constant.DeleteTodoLogEventErrorKey, id, func(tx fileTx) error {
+		todo, err := tx.todo(id)
+		if err != nil {
+			return err
+		}
+
+		if todo == nil {
+			return service.todoNotFound(constant.DeleteTodoLogEventErrorKey, id)
+		}
+
+		if precondition != nil {
+			if err := precondition(&todo.Todo); err != nil {
+				return err
+			}
+		}
+
+		return tx.deleteTodo(id, time.Now())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: id})
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.DeleteTodoLogEventKey,
+		"external_id": id,
+	}).Info("Todo deleted successfully")
+
+	return nil
+}
+
+func (store fileTodoStore) PutTodo(service *TodoService, todo types.Todo, precondition func(current *types.Todo) error) (*types.Todo, bool, error) {
+	var created bool
+
+	err := store.update(service, constant.PutTodoLogEventErrorKey, todo.ExternalID, func(tx fileTx) error {
+		current, err := tx.todo(todo.ExternalID)
+		if err != nil {
+			return err
+		}
+
+		if precondition != nil {
+			var currentTodo *types.Todo
+			if current != nil {
+				currentTodo = &current.Todo
+			}
+
+			if err := precondition(currentTodo); err != nil {
+				return err
+			}
+		}
+
+		if todo.Status == "" {
+			todo.Status = constant.TodoStatusNeedsAction
+		}
+
+		now := time.Now()
+		created = current == nil
+
+		if created {
+			if todo.CreatedAt.IsZero() {
+				todo.CreatedAt = now
+			}
+
+			stored := newFileTodo(todo, now)
+			err = tx.putTodo(stored, true, now)
+			todo = stored.Todo
+
+			return err
+		}
+
+		todo.ID = current.ID
+		todo.CreatedAt = current.CreatedAt
+		todo.ListID = current.ListID
+
+		stored := *current
+		stored.Todo = todo
+		touchFileTodo(&stored, current, now)
+
+		err = tx.putTodo(&stored, false, now)
+		todo = stored.Todo
+
+		return err
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	eventType := constant.TodoEventUpdated
+	if created {
+		eventType = constant.TodoEventCreated
+	}
+
+	service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: eventType, ID: todo.ExternalID, Todo: &todo})
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.PutTodoLogEventKey,
+		"external_id": todo.ExternalID,
+		"created":     created,
+	}).Info("Todo stored successfully")
+
+	return &todo, created, nil
+}
+
+func (store fileTodoStore) GetLists(service *TodoService) ([]types.TodoList, error) {
+	var lists []types.TodoList
+
+	err := store.view(service, constant.ListLogEventErrorKey, "", func(tx fileTx) error {
+		return tx.Range(fileListPrefix, func(key string, data []byte) error {
+			var list fileList
+
+			if err := json.Unmarshal(data, &list); err != nil {
+				return storeError{fmt.Errorf("decode %s: %w", key, err)}
+			}
+
+			lists = append(lists, types.TodoList{ID: list.ID, ExternalID: list.ExternalID, Name: list.Name, CreatedAt: list.CreatedAt})
+
+			return nil
+		})
+	})
+
+	sort.Slice(lists, func(i, j int) bool {
+		if !lists[i].CreatedAt.Equal(lists[j].CreatedAt) {
+			return lists[i].CreatedAt.Before(lists[j].CreatedAt)
+		}
+
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, err
+}
+
+func (store fileTodoStore) GetList(service *TodoService, id string) (*types.TodoList, error) {
+	var list *fileList
+
+	err := store.view(service, constant.ListLogEventErrorKey, id, func(tx fileTx) error {
+		var err error
+		list, err = tx.list(id)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		return nil, service.listNotFound(constant.ListLogEventErrorKey, id)
+	}
+
+	return &types.TodoList{ID: list.ID, ExternalID: list.ExternalID, Name: list.Name, CreatedAt: list.CreatedAt}, nil
+}
+
+func (store fileTodoStore) CreateList(service *TodoService, input types.TodoListInput) (*types.TodoList, error) {
+	list := fileList{
+		ExternalID: uuid.New().String(),
+		Name:       input.Name,
+		CreatedAt:  time.Now(),
+	}
+
+	err := store.update(service, constant.ListLogEventErrorKey, "", func(tx fileTx) error {
+		id, err := tx.next("lists")
+		if err != nil {
+			return err
+		}
+
+		list.ID = int(id)
+
+		return tx.put(fileListPrefix+list.ExternalID, list)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.ListLogEventKey,
+		"external_id": list.ExternalID,
+	}).Info("List created successfully")
+
+	return &types.TodoList{ID: list.ID, ExternalID: list.ExternalID, Name: list.Name, CreatedAt: list.CreatedAt}, nil
+}
+
+func (store fileTodoStore) DeleteList(service *TodoService, id string) error {
+	var deleted []string
+
+	err := store.update(service, constant.ListLogEventErrorKey, id, func(tx fileTx) error {
+		list, err := tx.list(id)
+		if err != nil {
+			return err
+		}
+
+		if list == nil {
+			return service.listNotFound(constant.ListLogEventErrorKey, id)
+		}
+
+		todos, err := tx.todos(types.TodoFilter{ListID: list.ExternalID})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		for _, todo := range todos {
+			if err := tx.deleteTodo(todo.ExternalID, now); err != nil {
+				return err
+			}
+
+			deleted = append(deleted, todo.ExternalID)
+		}
+
+		if err := tx.Delete(fileListPrefix + list.ExternalID); err != nil {
+			return storeError{err}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, todoID := range deleted {
+		service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: todoID})
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.ListLogEventKey,
+		"external_id": id,
+		"todos":       len(deleted),
+	}).Info("List deleted successfully")
+
+	return nil
+}
+
+// GetListMembers finds no members: the file store keeps no users.
+func (store fileTodoStore) GetListMembers(service *TodoService, id string) ([]types.ListMember, error) {
+	_, err := store.GetList(service, id)
+
+	return nil, err
+}
+
+func (store fileTodoStore) RemoveListMember(service *TodoService, id string, userID string) error {
+	if _, err := store.GetList(service, id); err != nil {
+		return err
+	}
+
+	return TodoError{Message: fmt.Sprintf("User with id %s is not a member of the list", userID), Reason: ReasonNotFound}
+}
+
+func (fileTodoStore) CreateListInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	return nil, "", TodoError{Message: constant.ErrMsgFileStoreNoInvitations, Reason: ReasonForbidden}
+}
+
+func (fileTodoStore) CreateTodoInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	return nil, "", TodoError{Message: constant.ErrMsgFileStoreNoInvitations, Reason: ReasonForbidden}
+}
+
+func (fileTodoStore) AcceptInvitation(service *TodoService, token string) (*types.Invitation, error) {
+	return nil, TodoError{Message: constant.ErrMsgFileStoreNoInvitations, Reason: ReasonForbidden}
+}
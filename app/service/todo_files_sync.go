@@ -0,0 +1,389 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func (store fileTodoStore) LatestChangeSeq(service *TodoService) (int64, error) {
+	var seq int64
+
+	err := store.view(service, constant.TodoChangesLogEventErrorKey, "", func(tx fileTx) error {
+		var err error
+		seq, err = tx.counter("changes")
+
+		return err
+	})
+
+	return seq, err
+}
+
+// ChangesSince reads the change log like the database does. The log is
+// numbered without gaps, so the entries after seq are looked up directly.
+func (store fileTodoStore) ChangesSince(service *TodoService, seq int64, limit int) (*types.TodoChanges, error) {
+	changes := &types.TodoChanges{}
+
+	err := store.view(service, constant.TodoChangesLogEventErrorKey, "", func(tx fileTx) error {
+		latest, err := tx.counter("changes")
+		if err != nil {
+			return err
+		}
+
+		changes.Seq = latest
+
+		if seq < 0 || seq > latest {
+			return TodoError{Message: constant.ErrMsgInvalidSyncToken, Reason: ReasonPreconditionFailed}
+		}
+
+		var ids []string
+
+		created := make(map[string]bool)
+		deleted := make(map[string]bool)
+
+		for n := seq + 1; n <= latest; n++ {
+			var change fileChange
+
+			if _, err := tx.get(fileChangeKey(n), &change); err != nil {
+				return err
+			}
+
+			if _, seen := created[change.ExternalID]; !seen {
+				if limit > 0 && len(ids) == limit {
+					changes.Seq = n - 1
+					changes.More = true
+
+					break
+				}
+
+				ids = append(ids, change.ExternalID)
+			}
+
+			created[change.ExternalID] = created[change.ExternalID] || change.Created
+			deleted[change.ExternalID] = change.Deleted
+		}
+
+		var todos []types.Todo
+
+		for _, id := range ids {
+			todo, err := tx.todo(id)
+			if err != nil {
+				return err
+			}
+
+			if todo != nil {
+				todos = append(todos, todo.Todo)
+			} else if deleted[id] && seq > 0 {
+				changes.Deleted = append(changes.Deleted, id)
+			}
+		}
+
+		sortTodos(todos)
+
+		for _, todo := range todos {
+			if seq == 0 || created[todo.ExternalID] {
+				changes.Created = append(changes.Created, todo)
+			} else {
+				changes.Updated = append(changes.Updated, todo)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// ApplySyncChanges merges changes like the database does.
+func (store fileTodoStore) ApplySyncChanges(service *TodoService, changes []types.SyncChange) ([]types.SyncChangeResult, error) {
+	results := make([]types.SyncChangeResult, len(changes))
+	var events []types.TodoEvent
+
+	err := store.update(service, constant.SyncTodosLogEventErrorKey, "", func(tx fileTx) error {
+		now := time.Now()
+
+		for i := range changes {
+			change := &changes[i]
+			result := &results[i]
+
+			errs := validateSyncChange(change)
+			result.ID = change.ID
+
+			if len(errs) > 0 {
+				result.Status = constant.SyncStatusInvalid
+				result.Errors = errs
+
+				continue
+			}
+
+			current, err := tx.todo(change.ID)
+			if err != nil {
+				return err
+			}
+
+			if change.Deleted {
+				if current == nil {
+					result.Status = constant.SyncStatusDeleted
+
+					continue
+				}
+
+				deletedAt := clampSyncTime(*change.DeletedAt, now)
+
+				for _, field := range []types.SyncConflict{
+					{Field: "title", ServerValue: current.Title, ServerModifiedAt: current.TitleUpdatedAt},
+					{Field: "due_at", ServerValue: current.DueAt, ServerModifiedAt: current.DueAtUpdatedAt},
+					{Field: "status", ServerValue: current.Status, ServerModifiedAt: current.StatusUpdatedAt},
+					{Field: "recurrence", ServerValue: current.Recurrence, ServerModifiedAt: current.RecurrenceUpdatedAt},
+				} {
+					if field.ServerModifiedAt.After(deletedAt) {
+						result.Conflicts = append(result.Conflicts, field)
+					}
+				}
+
+				if len(result.Conflicts) > 0 {
+					result.Status = constant.SyncStatusConflict
+					result.Todo = utils.MapTodoResponse(&current.Todo)
+
+					continue
+				}
+
+				if err := tx.deleteTodo(change.ID, now); err != nil {
+					return err
+				}
+
+				result.Status = constant.SyncStatusDeleted
+				events = append(events, types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: change.ID})
+
+				continue
+			}
+
+			created := current == nil
+
+			if created {
+				var deletedAt time.Time
+
+				wasDeleted, err := tx.get(fileTombstonePrefix+change.ID, &deletedAt)
+				if err != nil {
+					return err
+				}
+
+				if wasDeleted && !latestFieldTime(change.Fields).After(deletedAt) {
+					result.Status = constant.SyncStatusConflict
+					result.Conflicts = []types.SyncConflict{{Field: "deleted", ServerValue: true, ServerModifiedAt: deletedAt}}
+
+					continue
+				}
+
+				current = &syncTodo{Todo: types.Todo{ExternalID: change.ID, CreatedAt: now, Status: constant.TodoStatusNeedsAction}}
+			}
+
+			before := *current
+			result.Conflicts = mergeSyncFields(current, change.Fields, now)
+
+			row := types.TodoImportRow{Title: current.Title, Status: current.Status, Recurrence: current.Recurrence}
+			ValidateImportRow(&row)
+
+			if len(row.Errors) > 0 {
+				result.Status = constant.SyncStatusInvalid
+				result.Errors = row.Errors
+				result.Conflicts = nil
+
+				continue
+			}
+
+			current.Status = row.Status
+
+			switch {
+			case created:
+				for _, stamp := range []*time.Time{&current.TitleUpdatedAt, &current.DueAtUpdatedAt, &current.StatusUpdatedAt, &current.RecurrenceUpdatedAt} {
+					if stamp.IsZero() {
+						*stamp = now
+					}
+				}
+			case before.Title == current.Title && sameTime(before.DueAt, current.DueAt) && before.Status == current.Status && before.Recurrence == current.Recurrence:
+				result.Todo = utils.MapTodoResponse(&current.Todo)
+				result.Status = constant.SyncStatusUnchanged
+
+				if len(result.Conflicts) > 0 {
+					result.Status = constant.SyncStatusConflict
+				}
+
+				continue
+			}
+
+			if err := tx.putTodo(current, created, now); err != nil {
+				return err
+			}
+
+			eventType := constant.TodoEventUpdated
+			result.Status = constant.SyncStatusUpdated
+
+			if created {
+				eventType = constant.TodoEventCreated
+				result.Status = constant.SyncStatusCreated
+			}
+
+			if len(result.Conflicts) > 0 {
+				result.Status = constant.SyncStatusConflict
+			}
+
+			todo := current.Todo
+			result.Todo = utils.MapTodoResponse(&todo)
+
+			events = append(events, types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: eventType, ID: todo.ExternalID, Todo: &todo})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		service.Events.Publish(event)
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":   constant.SyncTodosLogEventKey,
+		"changes": len(changes),
+		"applied": len(events),
+	}).Info("Sync changes applied")
+
+	return results, nil
+}
+
+// ImportTodos imports rows like the database does, in one commit.
+func (store fileTodoStore) ImportTodos(service *TodoService, rows []types.TodoImportRow, opts types.TodoImportOptions) (*types.TodoImportReport, error) {
+	report := &types.TodoImportReport{
+		DryRun: opts.DryRun,
+		Total:  len(rows),
+		Rows:   make([]types.TodoImportRowResult, len(rows)),
+	}
+
+	var todos []*syncTodo
+
+	err := store.update(service, constant.ImportTodosLogEventErrorKey, "", func(tx fileTx) error {
+		existing, err := tx.todos(types.TodoFilter{})
+		if err != nil {
+			return err
+		}
+
+		taken := make(map[string]bool)
+		takenIDs := make(map[string]bool)
+
+		for _, todo := range existing {
+			if opts.DedupeKey == constant.ImportDedupeTitle {
+				taken[todo.Title] = true
+			}
+
+			if opts.DedupeKey == constant.ImportDedupeID {
+				taken[todo.ExternalID] = true
+			}
+
+			takenIDs[todo.ExternalID] = true
+		}
+
+		now := time.Now()
+
+		for i := range rows {
+			row := &rows[i]
+			result := &report.Rows[i]
+
+			ValidateImportRow(row)
+
+			*result = types.TodoImportRowResult{Row: row.Row, ID: row.ID, Title: row.Title}
+
+			if len(row.Errors) > 0 {
+				result.Status = constant.ImportStatusInvalid
+				result.Errors = row.Errors
+				report.Invalid++
+
+				continue
+			}
+
+			value := importDedupeValue(row, opts.DedupeKey)
+			id := strings.ToLower(row.ID)
+
+			if (value != "" && taken[value]) || (id != "" && takenIDs[id]) {
+				result.Status = constant.ImportStatusDuplicate
+				report.Duplicates++
+
+				continue
+			}
+
+			if value != "" {
+				taken[value] = true
+			}
+
+			externalID := row.ID
+			if externalID == "" {
+				externalID = uuid.New().String()
+			}
+
+			takenIDs[strings.ToLower(externalID)] = true
+
+			createdAt := now
+			if row.CreatedAt != nil {
+				createdAt = *row.CreatedAt
+			}
+
+			todo := newFileTodo(types.Todo{
+				ExternalID: externalID,
+				Title:      row.Title,
+				CreatedAt:  createdAt,
+				DueAt:      row.DueAt,
+				Status:     row.Status,
+				Recurrence: row.Recurrence,
+			}, now)
+
+			result.ID = todo.ExternalID
+			result.Status = constant.ImportStatusWouldCreate
+
+			if !opts.DryRun {
+				if err := tx.putTodo(todo, true, now); err != nil {
+					return err
+				}
+
+				result.Status = constant.ImportStatusCreated
+				todos = append(todos, todo)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, todo := range todos {
+		service.Events.Publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventCreated, ID: todo.ExternalID, Todo: &todo.Todo})
+	}
+
+	report.Created = len(todos)
+
+	service.Log.WithFields(logrus.Fields{
+		"event":      constant.ImportTodosLogEventKey,
+		"created":    report.Created,
+		"duplicates": report.Duplicates,
+		"invalid":    report.Invalid,
+	}).Info("Todos imported successfully")
+
+	return report, nil
+}
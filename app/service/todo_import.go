@@ -0,0 +1,597 @@
+package service
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todo-app/app/constant"
+	"todo-app/app/ical"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// TodoImportPresets maps the names accepted as import formats onto mappings
+// for well known third-party JSON exports.
+var TodoImportPresets = map[string]types.TodoImportMapping{
+	"todoist": {Items: "items", Title: "content", CreatedAt: "added_at"},
+	"trello":  {Items: "cards", Title: "name"},
+}
+
+// defaultImportMapping reads a JSON array of todos as produced by the export
+// endpoint.
+var defaultImportMapping = types.TodoImportMapping{
+	ID:         "id",
+	Title:      "title",
+	CreatedAt:  "created_at",
+	DueAt:      "due_at",
+	Status:     "status",
+	Recurrence: "recurrence",
+}
+
+var todoStatuses = map[string]bool{
+	constant.TodoStatusNeedsAction: true,
+	constant.TodoStatusInProcess:   true,
+	constant.TodoStatusCompleted:   true,
+	constant.TodoStatusCancelled:   true,
+}
+
+// ParseTodoImport reads an import file into rows. format is csv, ndjson, json,
+// ics or one of TodoImportPresets; mapping is only used by json and falls back to
+// the export layout when nil. Problems with a single record are attached to
+// its row, while an unreadable file is returned as an error.
+func ParseTodoImport(format string, r io.Reader, mapping *types.TodoImportMapping) ([]types.TodoImportRow, error) {
+	switch format {
+	case "csv":
+		return parseCSVImport(r)
+	case "ndjson":
+		return parseNDJSONImport(r)
+	case "ics":
+		return ical.ParseTodos(r)
+	case "json":
+		if mapping == nil {
+			mapping = &defaultImportMapping
+		}
+
+		return parseJSONImport(r, *mapping)
+	}
+
+	if preset, ok := TodoImportPresets[format]; ok {
+		return parseJSONImport(r, preset)
+	}
+
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+func parseCSVImport(r io.Reader) ([]types.TodoImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("CSV header must contain a title column")
+	}
+
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	var rows []types.TodoImportRow
+
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		row := types.TodoImportRow{Row: n}
+
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, err
+			}
+
+			row.Errors = append(row.Errors, err.Error())
+			rows = append(rows, row)
+
+			continue
+		}
+
+		row.ID = column(record, "id")
+		row.Title = column(record, "title")
+		row.CreatedAt = parseImportTime(&row, "created_at", column(record, "created_at"))
+		row.DueAt = parseImportTime(&row, "due_at", column(record, "due_at"))
+		row.Status = column(record, "status")
+		row.Recurrence = column(record, "recurrence")
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseNDJSONImport(r io.Reader) ([]types.TodoImportRow, error) {
+	var rows []types.TodoImportRow
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), int(constant.ImportMaxUploadSize))
+
+	for n := 0; scanner.Scan(); {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		n++
+
+		var record interface{}
+
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			rows = append(rows, types.TodoImportRow{Row: n, Errors: []string{"invalid JSON: " + err.Error()}})
+
+			continue
+		}
+
+		rows = append(rows, mapImportRecord(n, record, defaultImportMapping))
+	}
+
+	return rows, scanner.Err()
+}
+
+func parseJSONImport(r io.Reader, mapping types.TodoImportMapping) ([]types.TodoImportRow, error) {
+	var document interface{}
+
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	items, ok := lookupImportPath(document, mapping.Items).([]interface{})
+	if !ok {
+		if mapping.Items == "" {
+			return nil, fmt.Errorf("JSON document must be an array")
+		}
+
+		return nil, fmt.Errorf("%q must point at an array", mapping.Items)
+	}
+
+	if mapping.Title == "" {
+		return nil, fmt.Errorf("mapping must name the title field")
+	}
+
+	rows := make([]types.TodoImportRow, len(items))
+
+	for i, item := range items {
+		rows[i] = mapImportRecord(i+1, item, mapping)
+	}
+
+	return rows, nil
+}
+
+func mapImportRecord(n int, record interface{}, mapping types.TodoImportMapping) types.TodoImportRow {
+	row := types.TodoImportRow{Row: n}
+
+	if _, ok := record.(map[string]interface{}); !ok {
+		row.Errors = append(row.Errors, "record must be an object")
+
+		return row
+	}
+
+	field := func(path string) string {
+		if path == "" {
+			return ""
+		}
+
+		switch value := lookupImportPath(record, path).(type) {
+		case nil:
+			return ""
+		case string:
+			return strings.TrimSpace(value)
+		default:
+			row.Errors = append(row.Errors, path+" must be a string")
+
+			return ""
+		}
+	}
+
+	row.ID = field(mapping.ID)
+	row.Title = field(mapping.Title)
+	row.CreatedAt = parseImportTime(&row, "created_at", field(mapping.CreatedAt))
+	row.DueAt = parseImportTime(&row, "due_at", field(mapping.DueAt))
+	row.Status = field(mapping.Status)
+	row.Recurrence = field(mapping.Recurrence)
+
+	return row
+}
+
+func lookupImportPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		value = object[key]
+	}
+
+	return value
+}
+
+func parseImportTime(row *types.TodoImportRow, name string, raw string) *time.Time {
+	if raw == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		row.Errors = append(row.Errors, name+" must be an RFC 3339 date-time")
+
+		return nil
+	}
+
+	return &parsed
+}
+
+// ValidateImportRow appends to row.Errors every reason the row cannot become
+// a todo, and normalises its ID and status. IDs are stored in their canonical
+// form, whatever form of UUID the row spelt them in.
+func ValidateImportRow(row *types.TodoImportRow) {
+	if strings.TrimSpace(row.Title) == "" {
+		row.Errors = append(row.Errors, "title is required")
+	} else if utf8.RuneCountInString(row.Title) > constant.TodoTitleMaxLength {
+		row.Errors = append(row.Errors, fmt.Sprintf("title must be at most %d characters", constant.TodoTitleMaxLength))
+	}
+
+	if row.ID != "" {
+		if parsed, err := uuid.Parse(row.ID); err != nil {
+			row.Errors = append(row.Errors, "id must be a UUID")
+		} else {
+			row.ID = parsed.String()
+		}
+	}
+
+	row.Status = strings.ToLower(row.Status)
+
+	if row.Status == "" {
+		row.Status = constant.TodoStatusNeedsAction
+	} else if !todoStatuses[row.Status] {
+		row.Errors = append(row.Errors, "status must be one of needs-action, in-process, completed, cancelled")
+	}
+
+	if row.Recurrence != "" {
+		if err := ical.ValidateRRule(row.Recurrence); err != nil {
+			row.Errors = append(row.Errors, "recurrence must be an RRULE value: "+err.Error())
+		}
+	}
+}
+
+func importDedupeValue(row *types.TodoImportRow, key string) string {
+	switch key {
+	case constant.ImportDedupeTitle:
+		return row.Title
+	case constant.ImportDedupeID:
+		return strings.ToLower(row.ID)
+	}
+
+	return ""
+}
+
+// ImportTodos validates the rows, drops duplicates of each other and of
+// existing todos on opts.DedupeKey, and inserts the rest in batches inside a
+// single transaction. With opts.DryRun nothing is written, but the report is
+// the same one a real import would produce.
+func (service *TodoService) ImportTodos(rows []types.TodoImportRow, opts types.TodoImportOptions) (*types.TodoImportReport, error) {
+	return service.Store.ImportTodos(service, rows, opts)
+}
+
+func (sqlTodoStore) ImportTodos(service *TodoService, rows []types.TodoImportRow, opts types.TodoImportOptions) (*types.TodoImportReport, error) {
+	report := &types.TodoImportReport{
+		DryRun: opts.DryRun,
+		Total:  len(rows),
+		Rows:   make([]types.TodoImportRowResult, len(rows)),
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ImportTodosLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	var keys []string
+	var ids []string
+
+	for i := range rows {
+		ValidateImportRow(&rows[i])
+
+		if len(rows[i].Errors) > 0 {
+			continue
+		}
+
+		if value := importDedupeValue(&rows[i], opts.DedupeKey); value != "" {
+			keys = append(keys, value)
+		}
+
+		if rows[i].ID != "" {
+			ids = append(ids, strings.ToLower(rows[i].ID))
+		}
+	}
+
+	existing, err := service.existingImportKeys(tx, opts.DedupeKey, keys)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ImportTodosLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	// Explicit IDs become external IDs, so they must stay unique whatever
+	// the dedupe key is.
+	existingIDs, err := service.existingImportKeys(tx, constant.ImportDedupeID, ids)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ImportTodosLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	var todos []types.Todo
+	var results []*types.TodoImportRowResult
+
+	now := time.Now()
+
+	for i := range rows {
+		row := &rows[i]
+		result := &report.Rows[i]
+
+		*result = types.TodoImportRowResult{Row: row.Row, ID: row.ID, Title: row.Title}
+
+		if len(row.Errors) > 0 {
+			result.Status = constant.ImportStatusInvalid
+			result.Errors = row.Errors
+			report.Invalid++
+
+			continue
+		}
+
+		value := importDedupeValue(row, opts.DedupeKey)
+		id := strings.ToLower(row.ID)
+
+		if (value != "" && existing[value]) || (id != "" && existingIDs[id]) {
+			result.Status = constant.ImportStatusDuplicate
+			report.Duplicates++
+
+			continue
+		}
+
+		if value != "" {
+			existing[value] = true
+		}
+
+		if id != "" {
+			existingIDs[id] = true
+		}
+
+		todo := types.Todo{
+			ExternalID: row.ID,
+			Title:      row.Title,
+			CreatedAt:  now,
+			DueAt:      row.DueAt,
+			Status:     row.Status,
+			Recurrence: row.Recurrence,
+		}
+
+		if todo.ExternalID == "" {
+			todo.ExternalID = uuid.New().String()
+		}
+
+		if row.CreatedAt != nil {
+			todo.CreatedAt = *row.CreatedAt
+		}
+
+		result.ID = todo.ExternalID
+		result.Status = constant.ImportStatusWouldCreate
+
+		todos = append(todos, todo)
+		results = append(results, result)
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for start := 0; start < len(todos); start += constant.ImportBatchSize {
+		end := start + constant.ImportBatchSize
+		if end > len(todos) {
+			end = len(todos)
+		}
+
+		if err := insertTodoBatch(tx, todos[start:end]); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.ImportTodosLogEventErrorKey,
+			}).Error(constant.DbExecFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+	}
+
+	// Todos another request created with the same ID since they were looked
+	// up are left out, and are duplicates after all.
+	inserted := todos[:0]
+	insertedResults := results[:0]
+
+	for i := range todos {
+		if todos[i].ID == 0 {
+			results[i].Status = constant.ImportStatusDuplicate
+			report.Duplicates++
+
+			continue
+		}
+
+		inserted = append(inserted, todos[i])
+		insertedResults = append(insertedResults, results[i])
+	}
+
+	todos, results = inserted, insertedResults
+
+	createdIDs := make([]string, len(todos))
+
+	for i := range todos {
+		createdIDs[i] = todos[i].ExternalID
+	}
+
+	if err := service.grantTodoOwner(tx, createdIDs...); err != nil {
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	for i := range todos {
+		if err := enqueueWebhookEvent(tx, constant.TodoEventCreated, utils.MapTodoResponse(&todos[i])); err != nil {
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ImportTodosLogEventErrorKey,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	events := make([]types.TodoEvent, len(todos))
+
+	for i := range todos {
+		results[i].Status = constant.ImportStatusCreated
+		events[i] = types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventCreated, ID: todos[i].ExternalID, Todo: &todos[i]}
+	}
+
+	service.publish(events...)
+
+	report.Created = len(todos)
+
+	service.Log.WithFields(logrus.Fields{
+		"event":      constant.ImportTodosLogEventKey,
+		"created":    report.Created,
+		"duplicates": report.Duplicates,
+		"invalid":    report.Invalid,
+	}).Info("Todos imported successfully")
+
+	return report, nil
+}
+
+// existingImportKeys returns which values are already taken. Titles are only
+// compared against todos the actor can see; IDs must be unique across all
+// todos.
+func (service *TodoService) existingImportKeys(tx *sql.Tx, key string, values []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+
+	var query string
+	args := []interface{}{pq.Array(values)}
+
+	switch key {
+	case constant.ImportDedupeTitle:
+		query = "SELECT DISTINCT title FROM todos WHERE " + service.DB.dialect().contains("$1", "title")
+
+		if visible, visibleArgs := service.visibleTodosCondition(args); visible != "" {
+			query += " AND " + visible
+			args = visibleArgs
+		}
+	case constant.ImportDedupeID:
+		query = "SELECT DISTINCT external_id FROM todos WHERE " + service.DB.dialect().contains("$1", "external_id")
+	default:
+		return existing, nil
+	}
+
+	if len(values) == 0 {
+		return existing, nil
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+
+		existing[value] = true
+	}
+
+	return existing, rows.Err()
+}
+
+// insertTodoBatch inserts todos with one multi-row statement and fills in
+// their database IDs. Todos whose ID is taken are skipped and keep an ID of 0.
+func insertTodoBatch(tx *sql.Tx, todos []types.Todo) error {
+	placeholders := make([]string, len(todos))
+	args := make([]interface{}, 0, len(todos)*6)
+	index := make(map[string]int, len(todos))
+
+	for i, todo := range todos {
+		placeholders[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", len(args)+1, len(args)+2, len(args)+3, len(args)+4, len(args)+5, len(args)+6)
+		args = append(args, todo.ExternalID, todo.Title, todo.CreatedAt, todo.DueAt, todo.Status, todo.Recurrence)
+		index[todo.ExternalID] = i
+	}
+
+	rows, err := tx.Query("INSERT INTO todos (external_id, title, created_at, due_at, status, recurrence) VALUES "+strings.Join(placeholders, ", ")+" ON CONFLICT DO NOTHING RETURNING id, external_id", args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var externalID string
+
+		if err := rows.Scan(&id, &externalID); err != nil {
+			return err
+		}
+
+		todos[index[externalID]].ID = id
+	}
+
+	return rows.Err()
+}
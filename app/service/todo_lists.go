@@ -0,0 +1,511 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// GetLists returns the lists the actor holds a role on, or every list when
+// the actor is not restricted.
+func (service *TodoService) GetLists() ([]types.TodoList, error) {
+	return service.Store.GetLists(service)
+}
+
+func (sqlTodoStore) GetLists(service *TodoService) ([]types.TodoList, error) {
+	var lists []types.TodoList
+
+	var userID interface{}
+	query := "SELECT id, external_id, name, created_at, (SELECT MAX(" + aclRank + ") FROM acl_entries WHERE acl_entries.list_id = todo_lists.id AND acl_entries.user_id = $1) AS rank FROM todo_lists"
+
+	if service.Actor != nil {
+		userID = service.Actor.ID
+		query = "SELECT * FROM (" + query + ") lists WHERE rank IS NOT NULL"
+	}
+
+	rows, err := service.DB.Query(query+" ORDER BY created_at, id", userID)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ListLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var list types.TodoList
+		var rank sql.NullInt64
+
+		if err := rows.Scan(&list.ID, &list.ExternalID, &list.Name, &list.CreatedAt, &rank); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.ListLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		if rank.Valid {
+			list.Role = roleFromRank(int(rank.Int64))
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+func (service *TodoService) GetList(id string) (*types.TodoList, error) {
+	return service.Store.GetList(service, id)
+}
+
+func (sqlTodoStore) GetList(service *TodoService, id string) (*types.TodoList, error) {
+	return service.authorizeList(service.DB, id, constant.RoleViewer, constant.ListLogEventErrorKey)
+}
+
+// CreateList creates a list owned by the actor.
+func (service *TodoService) CreateList(input types.TodoListInput) (*types.TodoList, error) {
+	return service.Store.CreateList(service, input)
+}
+
+func (sqlTodoStore) CreateList(service *TodoService, input types.TodoListInput) (*types.TodoList, error) {
+	list := types.TodoList{
+		ExternalID: uuid.New().String(),
+		Name:       input.Name,
+		CreatedAt:  time.Now(),
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ListLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO todo_lists (external_id, name, created_at) VALUES ($1, $2, $3) RETURNING id",
+		list.ExternalID, list.Name, list.CreatedAt).Scan(&list.ID)
+
+	if err == nil && service.Actor != nil {
+		list.Role = constant.RoleOwner
+		_, err = tx.Exec("INSERT INTO acl_entries (user_id, list_id, role) VALUES ($1, $2, $3)", service.Actor.ID, list.ID, list.Role)
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ListLogEventErrorKey,
+		}).Error("Failed to create list")
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ListLogEventErrorKey,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.ListLogEventKey,
+		"external_id": list.ExternalID,
+	}).Info("List created successfully")
+
+	return &list, nil
+}
+
+// DeleteList deletes a list and every todo in it, which needs the owner
+// role. The todos are deleted one by one so webhooks and subscribers hear
+// about each of them.
+func (service *TodoService) DeleteList(id string) error {
+	return service.Store.DeleteList(service, id)
+}
+
+func (sqlTodoStore) DeleteList(service *TodoService, id string) error {
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	list, err := service.authorizeList(txRower{tx}, id, constant.RoleOwner, constant.ListLogEventErrorKey)
+	if err != nil {
+		return err
+	}
+
+	viewers := service.deletedTodoViewers(tx, "todos.list_id = $1", list.ID)
+
+	rows, err := tx.Query("DELETE FROM todos WHERE list_id = $1 RETURNING external_id", list.ID)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	var deleted []string
+
+	for rows.Next() {
+		var todoID string
+		if err := rows.Scan(&todoID); err != nil {
+			rows.Close()
+
+			service.Log.WithFields(logrus.Fields{
+				"event":       constant.ListLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbScanFailMsg)
+
+			return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		deleted = append(deleted, todoID)
+	}
+
+	rows.Close()
+
+	for _, todoID := range deleted {
+		if err := enqueueWebhookEvent(tx, constant.TodoEventDeleted, map[string]string{"id": todoID}); err != nil {
+			return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM todo_lists WHERE id = $1", list.ID); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	events := make([]types.TodoEvent, 0, len(deleted))
+
+	for _, todoID := range deleted {
+		events = append(events, types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: todoID, Viewers: viewersOf(viewers, todoID)})
+	}
+
+	service.publish(events...)
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.ListLogEventKey,
+		"external_id": id,
+		"todos":       len(deleted),
+	}).Info("List deleted successfully")
+
+	return nil
+}
+
+// GetListMembers returns everyone holding a role on a list the actor can see.
+func (service *TodoService) GetListMembers(id string) ([]types.ListMember, error) {
+	return service.Store.GetListMembers(service, id)
+}
+
+func (sqlTodoStore) GetListMembers(service *TodoService, id string) ([]types.ListMember, error) {
+	var members []types.ListMember
+
+	list, err := service.authorizeList(service.DB, id, constant.RoleViewer, constant.ListLogEventErrorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := service.DB.Query("SELECT users.id, users.external_id, users.email, users.name, users.created_at, acl_entries.role FROM acl_entries JOIN users ON users.id = acl_entries.user_id WHERE acl_entries.list_id = $1 ORDER BY acl_entries.created_at, acl_entries.id", list.ID)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var member types.ListMember
+		if err := rows.Scan(&member.User.ID, &member.User.ExternalID, &member.User.Email, &member.User.Name, &member.User.CreatedAt, &member.Role); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event":       constant.ListLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// RemoveListMember takes away a user's role on a list, which needs the owner
+// role. Members may always remove themselves.
+func (service *TodoService) RemoveListMember(id string, userID string) error {
+	return service.Store.RemoveListMember(service, id, userID)
+}
+
+func (sqlTodoStore) RemoveListMember(service *TodoService, id string, userID string) error {
+	role := constant.RoleOwner
+	if service.Actor != nil && strings.EqualFold(service.Actor.ExternalID, userID) {
+		role = constant.RoleViewer
+	}
+
+	list, err := service.authorizeList(service.DB, id, role, constant.ListLogEventErrorKey)
+	if err != nil {
+		return err
+	}
+
+	result, err := service.DB.Exec("DELETE FROM acl_entries WHERE list_id = $1 AND user_id = (SELECT id FROM users WHERE external_id = $2)", list.ID, userID)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.ListLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbRowsAffectedFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if rowsAffected == 0 {
+		return TodoError{Message: fmt.Sprintf("User with id %s is not a member of the list", userID), Reason: ReasonNotFound}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.ListLogEventKey,
+		"external_id": id,
+		"user":        userID,
+	}).Info("List member removed successfully")
+
+	return nil
+}
+
+// CreateListInvitation invites someone to a list, which needs the owner role.
+func (service *TodoService) CreateListInvitation(id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	return service.Store.CreateListInvitation(service, id, input)
+}
+
+func (sqlTodoStore) CreateListInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	list, err := service.authorizeList(service.DB, id, constant.RoleOwner, constant.InvitationLogEventErrorKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return service.createInvitation(types.Invitation{ListID: list.ExternalID}, list.ID, nil, input)
+}
+
+// CreateTodoInvitation invites someone to a single todo, which needs the
+// owner role on it.
+func (service *TodoService) CreateTodoInvitation(id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	return service.Store.CreateTodoInvitation(service, id, input)
+}
+
+func (sqlTodoStore) CreateTodoInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error) {
+	var todoID int
+
+	if err := service.authorizeTodo(service.DB, id, constant.RoleOwner, constant.InvitationLogEventErrorKey); err != nil {
+		return nil, "", err
+	}
+
+	err := service.DB.QueryRow("SELECT id FROM todos WHERE external_id = $1", id).Scan(&todoID)
+
+	if err == sql.ErrNoRows {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.InvitationLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return nil, "", TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.InvitationLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return service.createInvitation(types.Invitation{TodoID: id}, nil, todoID, input)
+}
+
+// createInvitation stores an invitation and returns it with its token, which
+// is not kept in plain text and so cannot be returned again.
+func (service *TodoService) createInvitation(invitation types.Invitation, listID interface{}, todoID interface{}, input types.InvitationInput) (*types.Invitation, string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.InvitationLogEventErrorKey,
+		}).Error("Failed to generate invitation token")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	token := constant.InvitationTokenPrefix + hex.EncodeToString(buf)
+
+	invitation.ExternalID = uuid.New().String()
+	invitation.Email = strings.ToLower(input.Email)
+	invitation.Role = input.Role
+	invitation.CreatedAt = time.Now()
+
+	var invitedBy interface{}
+	if service.Actor != nil {
+		invitedBy = service.Actor.ID
+	}
+
+	err := service.DB.QueryRow("INSERT INTO invitations (external_id, list_id, todo_id, email, role, token_hash, invited_by, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		invitation.ExternalID, listID, todoID, invitation.Email, invitation.Role, hashAPIKey(token), invitedBy, invitation.CreatedAt).Scan(&invitation.ID)
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.InvitationLogEventErrorKey,
+		}).Error("Failed to create invitation")
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.InvitationLogEventKey,
+		"external_id": invitation.ExternalID,
+		"role":        invitation.Role,
+	}).Info("Invitation created successfully")
+
+	return &invitation, token, nil
+}
+
+// AcceptInvitation grants the actor the role an invitation offers. Only the
+// user it was sent to can accept it, and only once; accepting replaces any
+// role the user held on the same list or todo before.
+func (service *TodoService) AcceptInvitation(token string) (*types.Invitation, error) {
+	if service.Actor == nil {
+		return nil, TodoError{Message: constant.ErrMsgInvitationNeedsUser, Reason: ReasonUnauthorized}
+	}
+
+	return service.Store.AcceptInvitation(service, token)
+}
+
+func (sqlTodoStore) AcceptInvitation(service *TodoService, token string) (*types.Invitation, error) {
+	var invitation types.Invitation
+	var listID, todoID sql.NullInt64
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.InvitationLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("SELECT id, external_id, list_id, todo_id, COALESCE((SELECT CAST(external_id AS TEXT) FROM todo_lists WHERE todo_lists.id = invitations.list_id), ''), COALESCE((SELECT CAST(external_id AS TEXT) FROM todos WHERE todos.id = invitations.todo_id), ''), email, role, created_at FROM invitations WHERE token_hash = $1 AND accepted_at IS NULL"+service.DB.dialect().forUpdate(false),
+		hashAPIKey(token)).Scan(&invitation.ID, &invitation.ExternalID, &listID, &todoID, &invitation.ListID, &invitation.TodoID, &invitation.Email, &invitation.Role, &invitation.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.InvitationLogEventErrorKey,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return nil, TodoError{Message: "Invitation not found or already accepted", Reason: ReasonNotFound}
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.InvitationLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if !strings.EqualFold(invitation.Email, service.Actor.Email) {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.InvitationLogEventErrorKey,
+			"external_id": invitation.ExternalID,
+			"user":        service.Actor.ExternalID,
+		}).Warn("Invitation accepted by another user")
+
+		return nil, TodoError{Message: constant.ErrMsgInvitationWrongAddress, Reason: ReasonForbidden}
+	}
+
+	if listID.Valid {
+		_, err = tx.Exec("INSERT INTO acl_entries (user_id, list_id, role) VALUES ($1, $2, $3) ON CONFLICT (list_id, user_id) WHERE list_id IS NOT NULL DO UPDATE SET role = EXCLUDED.role",
+			service.Actor.ID, listID.Int64, invitation.Role)
+	} else {
+		_, err = tx.Exec("INSERT INTO acl_entries (user_id, todo_id, role) VALUES ($1, $2, $3) ON CONFLICT (todo_id, user_id) WHERE todo_id IS NOT NULL DO UPDATE SET role = EXCLUDED.role",
+			service.Actor.ID, todoID.Int64, invitation.Role)
+	}
+
+	if err == nil {
+		acceptedAt := time.Now()
+		invitation.AcceptedAt = &acceptedAt
+
+		_, err = tx.Exec("UPDATE invitations SET accepted_at = $1 WHERE id = $2", acceptedAt, invitation.ID)
+	}
+
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.InvitationLogEventErrorKey,
+			"external_id": invitation.ExternalID,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.InvitationLogEventErrorKey,
+			"external_id": invitation.ExternalID,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event":       constant.InvitationLogEventKey,
+		"external_id": invitation.ExternalID,
+		"user":        service.Actor.ExternalID,
+	}).Info("Invitation accepted successfully")
+
+	return &invitation, nil
+}
@@ -1,19 +1,38 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"todo-app/app/constant"
+	"todo-app/app/logging"
 	"todo-app/app/types"
+	"todo-app/app/utils"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+// TodoService reads and writes todos on behalf of Actor. A nil Actor is not
+// restricted by access control; see As. A WorkspaceID of 0 means the service
+// is not limited to a workspace; see InWorkspace. Log is the entry it logs
+// through, which WithContext swaps for the one of the request it serves.
+//
+// Todos and lists live in Store: in DB, or in a file store when there is no
+// database; see NewFileTodoService. Cache, when set, serves GetTodoByID; see
+// WithCache.
 type TodoService struct {
-	DB *sql.DB
+	DB          Database
+	Store       TodoStore
+	Cache       *TodoCache
+	Events      *EventBus
+	Actor       *types.User
+	WorkspaceID int
+	Log         *logrus.Entry
 }
 
 type TodoError struct {
@@ -26,24 +45,66 @@ type TodoErrorReason int
 const (
 	ReasonNotFound TodoErrorReason = iota
 	ReasonUnknown
+	ReasonUnauthorized
+	ReasonPreconditionFailed
+	ReasonForbidden
+	ReasonConflict
+	ReasonBadGateway
 )
 
 func (e TodoError) Error() string {
 	return e.Message
 }
 
+// todoColumns selects a todo from the todos table, resolving its list to the
+// list's external ID.
+const todoColumns = "id, external_id, title, created_at, due_at, status, recurrence, " +
+	"COALESCE((SELECT CAST(todo_lists.external_id AS TEXT) FROM todo_lists WHERE todo_lists.id = todos.list_id), '')"
+
+func scanTodo(row rowScanner, todo *types.Todo) error {
+	return row.Scan(&todo.ID, &todo.ExternalID, &todo.Title, &todo.CreatedAt, &todo.DueAt, &todo.Status, &todo.Recurrence, &todo.ListID)
+}
+
 func NewTodoService(db *sql.DB) *TodoService {
 	return &TodoService{
-		DB: db,
+		DB:     NewDatabase(db),
+		Store:  sqlTodoStore{},
+		Events: NewEventBus(),
+		Log:    logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext returns a copy of the service that runs its statements in ctx,
+// so they are canceled and traced with the request it serves, and logs
+// through the request's entry.
+func (service *TodoService) WithContext(ctx context.Context) *TodoService {
+	scoped := *service
+	scoped.Log = logging.FromContext(ctx)
+
+	if service.DB != nil {
+		scoped.DB = service.DB.withContext(ctx)
 	}
+
+	return &scoped
 }
 
 func (service *TodoService) GetAllTodos() ([]types.Todo, error) {
+	return service.Store.GetAllTodos(service)
+}
+
+func (sqlTodoStore) GetAllTodos(service *TodoService) ([]types.Todo, error) {
 	var todos []types.Todo
 
-	rows, err := service.DB.Query("SELECT * FROM todos")
+	query := "SELECT " + todoColumns + " FROM todos"
+
+	visible, args := service.visibleTodosCondition(nil)
+	if visible != "" {
+		query += " WHERE " + visible
+	}
+
+	rows, err := service.DB.Query(query, args...)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event": constant.GetTodosLogEventErrorKey,
 		}).Error(constant.DbQueryFailMsg)
 
@@ -54,8 +115,8 @@ func (service *TodoService) GetAllTodos() ([]types.Todo, error) {
 
 	for rows.Next() {
 		var todo types.Todo
-		if err := rows.Scan(&todo.ID, &todo.ExternalID, &todo.Title, &todo.CreatedAt); err != nil {
-			logrus.WithFields(logrus.Fields{
+		if err := scanTodo(rows, &todo); err != nil {
+			service.Log.WithFields(logrus.Fields{
 				"event": constant.GetTodosLogEventErrorKey,
 			}).Error(constant.DbScanFailMsg)
 
@@ -65,21 +126,275 @@ func (service *TodoService) GetAllTodos() ([]types.Todo, error) {
 		todos = append(todos, todo)
 	}
 
-	logrus.WithFields(logrus.Fields{
+	service.Log.WithFields(logrus.Fields{
 		"event": constant.GetTodosLogEventKey,
 	}).Debug("Todos fetched successfully")
 
 	return todos, nil
 }
 
+func (service *TodoService) todoFilterClause(filter types.TodoFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.ListID != "" {
+		args = append(args, filter.ListID)
+		conditions = append(conditions, fmt.Sprintf("list_id = (SELECT id FROM todo_lists WHERE external_id = $%d)", len(args)))
+	}
+
+	if filter.TitleContains != "" {
+		args = append(args, "%"+filter.TitleContains+"%")
+		conditions = append(conditions, service.DB.dialect().ilike("title", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at > $%d", len(args)))
+	}
+
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if visible, visibleArgs := service.visibleTodosCondition(args); visible != "" {
+		args = visibleArgs
+		conditions = append(conditions, visible)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// FindTodos returns one page of todos matching the filter together with the
+// total number of matches, ordered oldest first.
+func (service *TodoService) FindTodos(filter types.TodoFilter) ([]types.Todo, int, error) {
+	return service.Store.FindTodos(service, filter)
+}
+
+func (sqlTodoStore) FindTodos(service *TodoService, filter types.TodoFilter) ([]types.Todo, int, error) {
+	var todos []types.Todo
+	var total int
+
+	where, args := service.todoFilterClause(filter)
+
+	if err := service.DB.QueryRow("SELECT COUNT(*) FROM todos"+where, args...).Scan(&total); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.GetTodosLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, 0, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	query := "SELECT " + todoColumns + " FROM todos" + where + " ORDER BY created_at, id"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	if filter.Offset > 0 {
+		if filter.Limit == 0 {
+			query += " LIMIT " + service.DB.dialect().noLimit()
+		}
+
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := service.DB.Query(query, args...)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.GetTodosLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, 0, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo types.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.GetTodosLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, 0, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		todos = append(todos, todo)
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event": constant.GetTodosLogEventKey,
+	}).Debug("Todos fetched successfully")
+
+	return todos, total, nil
+}
+
+// ExportTodos walks every todo matching the filter, oldest first, through a
+// cursor so the result set is never held in memory at once. fn is called
+// once per row; an error returned by fn stops the export and is passed back
+// unchanged. Limit and Offset are ignored.
+func (service *TodoService) ExportTodos(filter types.TodoFilter, fn func(todo types.Todo) error) error {
+	return service.Store.ExportTodos(service, filter, fn)
+}
+
+func (sqlTodoStore) ExportTodos(service *TodoService, filter types.TodoFilter, fn func(todo types.Todo) error) error {
+	where, args := service.todoFilterClause(filter)
+
+	tx, err := service.DB.BeginTx(service.DB.context(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ExportTodosLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	fetch, err := service.DB.dialect().cursor(tx, "SELECT "+todoColumns+" FROM todos"+where+" ORDER BY created_at, id", args, constant.ExportBatchSize)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.ExportTodosLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	exported := 0
+
+	for {
+		rows, err := fetch()
+		if err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.ExportTodosLogEventErrorKey,
+			}).Error(constant.DbQueryFailMsg)
+
+			return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		fetched := 0
+
+		for rows.Next() {
+			var todo types.Todo
+			if err := scanTodo(rows, &todo); err != nil {
+				rows.Close()
+
+				service.Log.WithFields(logrus.Fields{
+					"event": constant.ExportTodosLogEventErrorKey,
+				}).Error(constant.DbScanFailMsg)
+
+				return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+			}
+
+			fetched++
+
+			if err := fn(todo); err != nil {
+				rows.Close()
+
+				return err
+			}
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.ExportTodosLogEventErrorKey,
+			}).Error(constant.DbQueryFailMsg)
+
+			return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		exported += fetched
+
+		if fetched < constant.ExportBatchSize {
+			break
+		}
+	}
+
+	service.Log.WithFields(logrus.Fields{
+		"event": constant.ExportTodosLogEventKey,
+		"count": exported,
+	}).Debug("Todos exported successfully")
+
+	return nil
+}
+
+// GetTodosByIDs fetches several todos in one query. IDs that do not exist are
+// simply absent from the result.
+func (service *TodoService) GetTodosByIDs(ids []string) ([]types.Todo, error) {
+	return service.Store.GetTodosByIDs(service, ids)
+}
+
+func (sqlTodoStore) GetTodosByIDs(service *TodoService, ids []string) ([]types.Todo, error) {
+	var todos []types.Todo
+
+	query := "SELECT " + todoColumns + " FROM todos WHERE " + service.DB.dialect().contains("$1", "external_id")
+
+	visible, args := service.visibleTodosCondition([]interface{}{pq.Array(ids)})
+	if visible != "" {
+		query += " AND " + visible
+	}
+
+	rows, err := service.DB.Query(query, args...)
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.GetTodoLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo types.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event": constant.GetTodoLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, nil
+}
+
 func (service *TodoService) GetTodoByID(id string) (*types.Todo, error) {
+	return service.Store.GetTodoByID(service, id)
+}
+
+func (sqlTodoStore) GetTodoByID(service *TodoService, id string) (*types.Todo, error) {
+	if err := service.authorizeTodo(service.DB, id, constant.RoleViewer, constant.GetTodoLogEventErrorKey); err != nil {
+		return nil, err
+	}
+
+	if service.Cache != nil {
+		return service.Cache.get(service.WorkspaceID, id, service.getTodoByID)
+	}
+
+	return service.getTodoByID(id)
+}
+
+// getTodoByID reads the todo with id, which the caller may see.
+func (service *TodoService) getTodoByID(id string) (*types.Todo, error) {
 	var todo types.Todo
 
-	err := service.DB.QueryRow("SELECT * from todos where external_id = $1", id).Scan(&todo.ID, &todo.ExternalID, &todo.Title, &todo.CreatedAt)
+	err := scanTodo(service.DB.QueryRow("SELECT "+todoColumns+" FROM todos WHERE external_id = $1", id), &todo)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			logrus.WithFields(logrus.Fields{
+			service.Log.WithFields(logrus.Fields{
 				"event":       constant.GetTodoLogEventErrorKey,
 				"external_id": id,
 			}).Error(constant.DbIdNotFoundMsg)
@@ -87,7 +402,7 @@ func (service *TodoService) GetTodoByID(id string) (*types.Todo, error) {
 			return nil, TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
 		}
 
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.GetTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbQueryFailMsg)
@@ -95,7 +410,7 @@ func (service *TodoService) GetTodoByID(id string) (*types.Todo, error) {
 		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
 	}
 
-	logrus.WithFields(logrus.Fields{
+	service.Log.WithFields(logrus.Fields{
 		"event":       constant.GetTodoLogEventKey,
 		"external_id": id,
 	}).Debug("Todo fetched successfully")
@@ -104,23 +419,78 @@ func (service *TodoService) GetTodoByID(id string) (*types.Todo, error) {
 }
 
 func (service *TodoService) CreateTodo(title string) (*types.Todo, error) {
+	return service.CreateTodoInList("", title)
+}
+
+// CreateTodoInList creates a todo in the list with external ID listID, which
+// needs the editor role on it, or outside of any list when listID is empty.
+// Todos outside of lists are owned by their creator.
+func (service *TodoService) CreateTodoInList(listID string, title string) (*types.Todo, error) {
+	return service.Store.CreateTodoInList(service, listID, title)
+}
+
+func (sqlTodoStore) CreateTodoInList(service *TodoService, listID string, title string) (*types.Todo, error) {
 	newTodo := types.Todo{
 		ExternalID: uuid.New().String(),
 		Title:      title,
 		CreatedAt:  time.Now(),
+		Status:     constant.TodoStatusNeedsAction,
+		ListID:     listID,
 	}
 
-	err := service.DB.QueryRow("INSERT INTO todos (external_id, title, created_at) VALUES ($1, $2, $3) RETURNING id", newTodo.ExternalID, newTodo.Title, newTodo.CreatedAt).Scan(&newTodo.ID)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.CreateTodoLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	var listRowID interface{}
+
+	if listID != "" {
+		list, err := service.authorizeList(txRower{tx}, listID, constant.RoleEditor, constant.CreateTodoLogEventErrorKey)
+		if err != nil {
+			return nil, err
+		}
+
+		listRowID = list.ID
+	}
+
+	err = tx.QueryRow("INSERT INTO todos (external_id, title, created_at, list_id) VALUES ($1, $2, $3, $4) RETURNING id", newTodo.ExternalID, newTodo.Title, newTodo.CreatedAt, listRowID).Scan(&newTodo.ID)
 
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event": constant.CreateTodoLogEventErrorKey,
 		}).Error("Failed to create todo")
 
 		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
 	}
 
-	logrus.WithFields(logrus.Fields{
+	if listID == "" {
+		if err := service.grantTodoOwner(tx, newTodo.ExternalID); err != nil {
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+	}
+
+	if err := enqueueWebhookEvent(tx, constant.TodoEventCreated, utils.MapTodoResponse(&newTodo)); err != nil {
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.CreateTodoLogEventErrorKey,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventCreated, ID: newTodo.ExternalID, Todo: &newTodo})
+
+	service.Log.WithFields(logrus.Fields{
 		"event":       constant.CreateTodoLogEventKey,
 		"external_id": newTodo.ExternalID,
 	}).Info("Todo created successfully")
@@ -129,12 +499,32 @@ func (service *TodoService) CreateTodo(title string) (*types.Todo, error) {
 }
 
 func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, error) {
+	return service.Store.UpdateTodo(service, id, title)
+}
+
+func (sqlTodoStore) UpdateTodo(service *TodoService, id string, title string) (*types.Todo, error) {
 	var updatedTodo types.Todo
 
-	result, err := service.DB.Exec("UPDATE todos SET title = $1 WHERE external_id = $2", title, id)
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.UpdateTodoLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	if err := service.authorizeTodo(txRower{tx}, id, constant.RoleEditor, constant.UpdateTodoLogEventErrorKey); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("UPDATE todos SET title = $1 WHERE external_id = $2", title, id)
 
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.UpdateTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbExecFailMsg)
@@ -144,7 +534,7 @@ func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, er
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.UpdateTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbRowsAffectedFailMsg)
@@ -153,7 +543,7 @@ func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, er
 	}
 
 	if rowsAffected == 0 {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.UpdateTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbIdNotFoundMsg)
@@ -161,9 +551,9 @@ func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, er
 		return nil, TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
 	}
 
-	err = service.DB.QueryRow("SELECT * from todos where external_id = $1", id).Scan(&updatedTodo.ID, &updatedTodo.ExternalID, &updatedTodo.Title, &updatedTodo.CreatedAt)
+	err = scanTodo(tx.QueryRow("SELECT "+todoColumns+" FROM todos WHERE external_id = $1", id), &updatedTodo)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.UpdateTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbQueryFailMsg)
@@ -171,7 +561,22 @@ func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, er
 		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
 	}
 
-	logrus.WithFields(logrus.Fields{
+	if err := enqueueWebhookEvent(tx, constant.TodoEventUpdated, utils.MapTodoResponse(&updatedTodo)); err != nil {
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.UpdateTodoLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventUpdated, ID: id, Todo: &updatedTodo})
+
+	service.Log.WithFields(logrus.Fields{
 		"event":       constant.UpdateTodoLogEventKey,
 		"external_id": id,
 	}).Info("Todo updated successfully")
@@ -180,10 +585,66 @@ func (service *TodoService) UpdateTodo(id string, title string) (*types.Todo, er
 }
 
 func (service *TodoService) DeleteTodo(id string) error {
-	result, err := service.DB.Exec("DELETE FROM todos WHERE external_id = $1", id)
+	return service.DeleteTodoIf(id, nil)
+}
+
+// DeleteTodoIf deletes a todo once precondition, when set, has accepted its
+// current state. The todo stays locked between the check and the delete.
+func (service *TodoService) DeleteTodoIf(id string, precondition func(current *types.Todo) error) error {
+	return service.Store.DeleteTodoIf(service, id, precondition)
+}
+
+func (sqlTodoStore) DeleteTodoIf(service *TodoService, id string, precondition func(current *types.Todo) error) error {
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.DeleteTodoLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	if err := service.authorizeTodo(txRower{tx}, id, constant.RoleEditor, constant.DeleteTodoLogEventErrorKey); err != nil {
+		return err
+	}
+
+	if precondition != nil {
+		var current types.Todo
+
+		err := scanTodo(tx.QueryRow("SELECT "+todoColumns+" FROM todos WHERE external_id = $1"+service.DB.dialect().forUpdate(false), id), &current)
+
+		if err == sql.ErrNoRows {
+			service.Log.WithFields(logrus.Fields{
+				"event":       constant.DeleteTodoLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbIdNotFoundMsg)
+
+			return TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
+		}
+
+		if err != nil {
+			service.Log.WithFields(logrus.Fields{
+				"event":       constant.DeleteTodoLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbQueryFailMsg)
+
+			return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		if err := precondition(&current); err != nil {
+			return err
+		}
+	}
+
+	viewers := service.deletedTodoViewers(tx, "todos.external_id = $1", id)
+
+	result, err := tx.Exec("DELETE FROM todos WHERE external_id = $1", id)
 
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.DeleteTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbExecFailMsg)
@@ -193,7 +654,7 @@ func (service *TodoService) DeleteTodo(id string) error {
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.DeleteTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbRowsAffectedFailMsg)
@@ -202,7 +663,7 @@ func (service *TodoService) DeleteTodo(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		logrus.WithFields(logrus.Fields{
+		service.Log.WithFields(logrus.Fields{
 			"event":       constant.DeleteTodoLogEventErrorKey,
 			"external_id": id,
 		}).Error(constant.DbIdNotFoundMsg)
@@ -210,7 +671,22 @@ func (service *TodoService) DeleteTodo(id string) error {
 		return TodoError{Message: fmt.Sprintf("Todo with id %s not found", id), Reason: ReasonNotFound}
 	}
 
-	logrus.WithFields(logrus.Fields{
+	if err := enqueueWebhookEvent(tx, constant.TodoEventDeleted, map[string]string{"id": id}); err != nil {
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.DeleteTodoLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.publish(types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: id, Viewers: viewersOf(viewers, id)})
+
+	service.Log.WithFields(logrus.Fields{
 		"event":       constant.DeleteTodoLogEventKey,
 		"external_id": id,
 	}).Info("Todo deleted successfully")
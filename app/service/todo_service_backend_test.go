@@ -0,0 +1,570 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/filestore"
+	"todo-app/app/ical"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var backendTestData = []types.Todo{
+	{ExternalID: "2233a6b2-ae99-40fc-bdd7-db49834993ab", Title: "Buy milk", CreatedAt: time.Date(2023, 12, 29, 18, 26, 45, 0, time.UTC)},
+	{ExternalID: "1c15f5f7-3207-4d4a-b50f-f6f8bacfb0e9", Title: "Walk the dog", CreatedAt: time.Date(2023, 12, 29, 18, 25, 18, 0, time.UTC)},
+	{ExternalID: "4ffaaf6e-6693-45a4-b1d2-02da81bebc46", Title: "Buy bread", CreatedAt: time.Date(2023, 12, 29, 18, 32, 19, 0, time.UTC)},
+}
+
+// TestTodoServiceBackends runs the same behavioral tests against every
+// backend the service supports, so they are held to the same behavior.
+// Postgres needs Docker, and is skipped without it.
+func TestTodoServiceBackends(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := utils.CreateSQLiteTestDB(filepath.Join(t.TempDir(), "todo.db"), backendTestData)
+		require.NoError(t, err)
+
+		defer db.Close()
+
+		testTodoService(t, NewTodoService(db).InWorkspace(constant.DefaultWorkspaceID), dbSyncStamps(db))
+	})
+
+	t.Run("sqlite with a cache", func(t *testing.T) {
+		db, err := utils.CreateSQLiteTestDB(filepath.Join(t.TempDir(), "todo.db"), backendTestData)
+		require.NoError(t, err)
+
+		defer db.Close()
+
+		cache := NewTodoCache(TodoCacheOptions{Size: 100, TTL: time.Minute})
+
+		testTodoService(t, NewTodoService(db).WithCache(cache).InWorkspace(constant.DefaultWorkspaceID), dbSyncStamps(db))
+		assert.Positive(t, cache.Stats().Invalidations)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		testDB, err := createPostgresTestDB()
+		if err != nil {
+			t.Skipf("Postgres is not available: %v", err)
+		}
+
+		defer testDB.CleanUp()
+
+		testTodoService(t, NewTodoService(testDB.DbInstance).InWorkspace(constant.DefaultWorkspaceID), dbSyncStamps(testDB.DbInstance))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := filestore.Open(dir, filestore.Options{})
+		require.NoError(t, err)
+
+		service := NewFileTodoService(store).InWorkspace(constant.DefaultWorkspaceID)
+		seedFileTodos(t, service, backendTestData)
+
+		testTodoService(t, service, fileSyncStamps(store))
+
+		before, err := service.ChangesSince(0, 0)
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		t.Run("It should hold every todo and change when reopened", func(t *testing.T) {
+			store, err := filestore.Open(dir, filestore.Options{})
+			require.NoError(t, err)
+
+			defer store.Close()
+
+			after, err := NewFileTodoService(store).ChangesSince(0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, before, after)
+		})
+	})
+}
+
+// TestFileTodoServiceLists covers what the file store offers of lists, which
+// have no members there.
+func TestFileTodoServiceLists(t *testing.T) {
+	store, err := filestore.Open(t.TempDir(), filestore.Options{})
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	service := NewFileTodoService(store)
+	seedFileTodos(t, service, backendTestData)
+
+	list, err := service.CreateList(types.TodoListInput{Name: "Groceries"})
+	require.NoError(t, err)
+
+	t.Run("It should create todos in a list and filter by it", func(t *testing.T) {
+		_, err := service.CreateTodoInList(list.ExternalID, "Buy eggs")
+		require.NoError(t, err)
+
+		todos, total, err := service.FindTodos(types.TodoFilter{ListID: list.ExternalID})
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, []string{"Buy eggs"}, todoTitles(todos))
+		assert.Equal(t, list.ExternalID, todos[0].ListID)
+
+		_, err = service.CreateTodoInList(uuid.NewString(), "Buy flour")
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+	})
+
+	t.Run("It should have no members to invite", func(t *testing.T) {
+		members, err := service.GetListMembers(list.ExternalID)
+		require.NoError(t, err)
+		assert.Empty(t, members)
+
+		_, _, err = service.CreateListInvitation(list.ExternalID, types.InvitationInput{Email: "ada@example.com", Role: constant.RoleViewer})
+		assert.Equal(t, ReasonForbidden, err.(TodoError).Reason)
+	})
+
+	t.Run("It should delete a list with its todos", func(t *testing.T) {
+		seq, err := service.LatestChangeSeq()
+		require.NoError(t, err)
+
+		require.NoError(t, service.DeleteList(list.ExternalID))
+
+		_, err = service.GetList(list.ExternalID)
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+
+		lists, err := service.GetLists()
+		require.NoError(t, err)
+		assert.Empty(t, lists)
+
+		changes, err := service.ChangesSince(seq, 0)
+		require.NoError(t, err)
+		assert.Len(t, changes.Deleted, 1)
+
+		todos, err := service.GetAllTodos()
+		require.NoError(t, err)
+		assert.Len(t, todos, len(backendTestData))
+	})
+}
+
+// TestTodoEventViewers checks that events only reach the subscribers whose
+// actor may see the todo, deletions included.
+func TestTodoEventViewers(t *testing.T) {
+	db, err := utils.CreateSQLiteTestDB(filepath.Join(t.TempDir(), "todo.db"), nil)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	service := NewTodoService(db).InWorkspace(constant.DefaultWorkspaceID)
+
+	alice, err := ensureUser(service.DB, "alice@example.com", "Alice")
+	require.NoError(t, err)
+
+	bob, err := ensureUser(service.DB, "bob@example.com", "Bob")
+	require.NoError(t, err)
+
+	events, unsubscribe := service.Events.Subscribe(10)
+	defer unsubscribe()
+
+	created, err := service.As(alice).CreateTodo("Private")
+	require.NoError(t, err)
+	require.NoError(t, service.As(alice).DeleteTodo(created.ExternalID))
+
+	for _, eventType := range []string{constant.TodoEventCreated, constant.TodoEventDeleted} {
+		event := <-events
+
+		assert.Equal(t, eventType, event.Type)
+		assert.True(t, service.VisibleEvent(event))
+		assert.True(t, service.As(alice).VisibleEvent(event))
+		assert.False(t, service.As(bob).VisibleEvent(event))
+		assert.False(t, service.InWorkspace(constant.DefaultWorkspaceID+1).VisibleEvent(event))
+	}
+}
+
+// TestChangesSinceViewers checks that a user syncing only hears of the todos
+// they can see, and of those they lose, whether deleted or unshared.
+func TestChangesSinceViewers(t *testing.T) {
+	db, err := utils.CreateSQLiteTestDB(filepath.Join(t.TempDir(), "todo.db"), nil)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	service := NewTodoService(db).InWorkspace(constant.DefaultWorkspaceID)
+
+	alice, err := ensureUser(service.DB, "alice@example.com", "Alice")
+	require.NoError(t, err)
+
+	bob, err := ensureUser(service.DB, "bob@example.com", "Bob")
+	require.NoError(t, err)
+
+	list, err := service.As(alice).CreateList(types.TodoListInput{Name: "Groceries"})
+	require.NoError(t, err)
+
+	shared, err := service.As(alice).CreateTodoInList(list.ExternalID, "Buy eggs")
+	require.NoError(t, err)
+
+	private, err := service.As(alice).CreateTodo("Private")
+	require.NoError(t, err)
+
+	_, token, err := service.As(alice).CreateListInvitation(list.ExternalID, types.InvitationInput{Email: bob.Email, Role: constant.RoleViewer})
+	require.NoError(t, err)
+
+	_, err = service.As(bob).AcceptInvitation(token)
+	require.NoError(t, err)
+
+	changes, err := service.As(bob).ChangesSince(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Buy eggs"}, todoTitles(changes.Created))
+
+	seq := changes.Seq
+
+	require.NoError(t, service.As(alice).DeleteTodo(private.ExternalID))
+	require.NoError(t, service.As(alice).RemoveListMember(list.ExternalID, bob.ExternalID))
+
+	t.Run("It should report a todo no longer shared as deleted, and not others' deletions", func(t *testing.T) {
+		changes, err := service.As(bob).ChangesSince(seq, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{shared.ExternalID}, changes.Deleted)
+		assert.Empty(t, changes.Created)
+		assert.Empty(t, changes.Updated)
+	})
+
+	t.Run("It should report deletions to those who could see the todo", func(t *testing.T) {
+		changes, err := service.As(alice).ChangesSince(seq, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{private.ExternalID}, changes.Deleted)
+
+		changes, err = service.ChangesSince(seq, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{private.ExternalID}, changes.Deleted)
+	})
+
+	t.Run("It should report a todo shared again as created", func(t *testing.T) {
+		_, token, err := service.As(alice).CreateListInvitation(list.ExternalID, types.InvitationInput{Email: bob.Email, Role: constant.RoleEditor})
+		require.NoError(t, err)
+
+		_, err = service.As(bob).AcceptInvitation(token)
+		require.NoError(t, err)
+
+		changes, err := service.As(bob).ChangesSince(seq, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Buy eggs"}, todoTitles(changes.Created))
+		assert.Empty(t, changes.Deleted)
+	})
+}
+
+// seedFileTodos stores todos in the file store behind service, keeping
+// their IDs and creation times.
+func seedFileTodos(t *testing.T, service *TodoService, todos []types.Todo) {
+	for _, todo := range todos {
+		_, created, err := service.PutTodo(todo, nil)
+		require.NoError(t, err)
+		require.True(t, created)
+	}
+}
+
+// createPostgresTestDB turns the panic testcontainers raises without Docker
+// into an error.
+func createPostgresTestDB() (testDB *utils.TestDB, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	return utils.CreateTestDB(backendTestData)
+}
+
+// syncStamps returns the title and status modification times of a todo.
+type syncStamps func(t *testing.T, id string) [2]time.Time
+
+func testTodoService(t *testing.T, service *TodoService, stamps syncStamps) {
+	startSeq, err := service.LatestChangeSeq()
+	require.NoError(t, err)
+
+	var created *types.Todo
+
+	t.Run("It should create a todo with a UUID and return it by ID", func(t *testing.T) {
+		created, err = service.CreateTodo("Water the plants")
+		require.NoError(t, err)
+
+		_, err := uuid.Parse(created.ExternalID)
+		assert.NoError(t, err)
+		assert.NotZero(t, created.ID)
+
+		todo, err := service.GetTodoByID(created.ExternalID)
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, todo.ID)
+		assert.Equal(t, "Water the plants", todo.Title)
+		assert.Equal(t, constant.TodoStatusNeedsAction, todo.Status)
+		assert.WithinDuration(t, created.CreatedAt, todo.CreatedAt, time.Millisecond)
+	})
+
+	t.Run("It should find IDs without regard to case", func(t *testing.T) {
+		todo, err := service.GetTodoByID("2233A6B2-AE99-40FC-BDD7-DB49834993AB")
+		require.NoError(t, err)
+		assert.Equal(t, "Buy milk", todo.Title)
+	})
+
+	t.Run("It should report missing todos as not found", func(t *testing.T) {
+		_, err := service.GetTodoByID(uuid.NewString())
+
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+	})
+
+	t.Run("It should filter, order and page todos", func(t *testing.T) {
+		todos, total, err := service.FindTodos(types.TodoFilter{TitleContains: "buy"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, []string{"Buy milk", "Buy bread"}, todoTitles(todos))
+
+		after := time.Date(2023, 12, 29, 18, 26, 0, 0, time.UTC)
+		todos, total, err = service.FindTodos(types.TodoFilter{CreatedAfter: &after, Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"Buy bread"}, todoTitles(todos))
+
+		todos, _, err = service.FindTodos(types.TodoFilter{Offset: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Water the plants"}, todoTitles(todos))
+	})
+
+	t.Run("It should fetch several todos by ID", func(t *testing.T) {
+		todos, err := service.GetTodosByIDs([]string{backendTestData[1].ExternalID, created.ExternalID, uuid.NewString()})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Walk the dog", "Water the plants"}, todoTitles(todos))
+	})
+
+	t.Run("It should update a todo and stamp the changed field", func(t *testing.T) {
+		before := stamps(t, created.ExternalID)
+
+		// SQLite stamps to the millisecond.
+		time.Sleep(10 * time.Millisecond)
+
+		updated, err := service.UpdateTodo(created.ExternalID, "Water the cactus")
+		require.NoError(t, err)
+		assert.Equal(t, "Water the cactus", updated.Title)
+		assert.Equal(t, created.ID, updated.ID)
+
+		after := stamps(t, created.ExternalID)
+		assert.True(t, after[0].After(before[0]), "the title should be stamped")
+		assert.True(t, after[1].Equal(before[1]), "the status should not be stamped")
+	})
+
+	t.Run("It should export every todo in order", func(t *testing.T) {
+		var titles []string
+
+		err := service.ExportTodos(types.TodoFilter{}, func(todo types.Todo) error {
+			titles = append(titles, todo.Title)
+
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Walk the dog", "Buy milk", "Buy bread", "Water the cactus"}, titles)
+	})
+
+	t.Run("It should import new rows and drop duplicates by ID", func(t *testing.T) {
+		importedID := uuid.NewString()
+
+		report, err := service.ImportTodos([]types.TodoImportRow{
+			{Row: 1, ID: backendTestData[0].ExternalID, Title: "Buy milk again"},
+			{Row: 2, ID: importedID, Title: "Call grandma"},
+		}, types.TodoImportOptions{DedupeKey: constant.ImportDedupeID})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Duplicates)
+
+		todo, err := service.GetTodoByID(importedID)
+		require.NoError(t, err)
+		assert.Equal(t, "Call grandma", todo.Title)
+	})
+
+	t.Run("It should reject a recurrence that is not an RRULE", func(t *testing.T) {
+		injected := "FREQ=DAILY\r\nATTENDEE:mailto:eve@example.com"
+		id := uuid.NewString()
+
+		report, err := service.ImportTodos([]types.TodoImportRow{
+			{Row: 1, ID: id, Title: "Injected", Recurrence: injected},
+		}, types.TodoImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 1, report.Invalid)
+
+		results, err := service.ApplySyncChanges([]types.SyncChange{
+			{ID: id, Fields: types.SyncTodoFields{
+				Title:      &types.SyncStringField{Value: "Injected", ModifiedAt: time.Now()},
+				Recurrence: &types.SyncStringField{Value: injected, ModifiedAt: time.Now()},
+			}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, constant.SyncStatusInvalid, results[0].Status)
+
+		_, err = service.GetTodoByID(id)
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+	})
+
+	t.Run("It should delete a todo", func(t *testing.T) {
+		require.NoError(t, service.DeleteTodo(backendTestData[1].ExternalID))
+
+		_, err := service.GetTodoByID(backendTestData[1].ExternalID)
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+
+		err = service.DeleteTodo(backendTestData[1].ExternalID)
+		assert.Equal(t, ReasonNotFound, err.(TodoError).Reason)
+	})
+
+	t.Run("It should log every change since a position", func(t *testing.T) {
+		changes, err := service.ChangesSince(startSeq, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{backendTestData[1].ExternalID}, changes.Deleted)
+		assert.ElementsMatch(t, []string{"Water the cactus", "Call grandma"}, todoTitles(changes.Created))
+		assert.Empty(t, changes.Updated)
+
+		latest, err := service.LatestChangeSeq()
+		require.NoError(t, err)
+		assert.Equal(t, latest, changes.Seq)
+	})
+
+	t.Run("It should page the changes and resume where a page ended", func(t *testing.T) {
+		all, err := service.ChangesSince(0, 0)
+		require.NoError(t, err)
+
+		var created []string
+
+		seq := int64(0)
+
+		for more := true; more; {
+			changes, err := service.ChangesSince(seq, 1)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(changes.Created)+len(changes.Updated)+len(changes.Deleted), 1)
+
+			created = append(created, todoTitles(changes.Created)...)
+			seq, more = changes.Seq, changes.More
+		}
+
+		assert.Equal(t, all.Seq, seq)
+		assert.ElementsMatch(t, todoTitles(all.Created), created)
+	})
+
+	t.Run("It should merge sync changes by last writer wins", func(t *testing.T) {
+		results, err := service.ApplySyncChanges([]types.SyncChange{
+			{ID: backendTestData[0].ExternalID, Fields: types.SyncTodoFields{
+				Title: &types.SyncStringField{Value: "Buy oat milk", ModifiedAt: time.Now().Add(time.Minute)},
+			}},
+			{ID: backendTestData[2].ExternalID, Fields: types.SyncTodoFields{
+				Title: &types.SyncStringField{Value: "Buy stale bread", ModifiedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+			}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, constant.SyncStatusUpdated, results[0].Status)
+		assert.Equal(t, constant.SyncStatusConflict, results[1].Status)
+
+		todo, err := service.GetTodoByID(backendTestData[0].ExternalID)
+		require.NoError(t, err)
+		assert.Equal(t, "Buy oat milk", todo.Title)
+
+		todo, err = service.GetTodoByID(backendTestData[2].ExternalID)
+		require.NoError(t, err)
+		assert.Equal(t, "Buy bread", todo.Title)
+	})
+
+	t.Run("It should return a put todo as it is stored", func(t *testing.T) {
+		put, created, err := service.PutTodo(types.Todo{ExternalID: uuid.NewString(), Title: "Stored as is"}, nil)
+		require.NoError(t, err)
+		require.True(t, created)
+
+		todo, err := service.GetTodoByID(put.ExternalID)
+		require.NoError(t, err)
+		assert.Equal(t, ical.ETag(todo), ical.ETag(put))
+
+		require.NoError(t, service.DeleteTodo(put.ExternalID))
+	})
+
+	t.Run("It should create a todo once when requests race to create its ID", func(t *testing.T) {
+		id := uuid.NewString()
+		errs := make(chan error, 30)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(3)
+
+			go func() {
+				defer wg.Done()
+
+				_, _, err := service.PutTodo(types.Todo{ExternalID: id, Title: "Raced"}, nil)
+				errs <- err
+			}()
+
+			go func() {
+				defer wg.Done()
+
+				_, err := service.ApplySyncChanges([]types.SyncChange{{ID: id, Fields: types.SyncTodoFields{
+					Title: &types.SyncStringField{Value: "Raced", ModifiedAt: time.Now()},
+				}}})
+				errs <- err
+			}()
+
+			go func() {
+				defer wg.Done()
+
+				_, err := service.ImportTodos([]types.TodoImportRow{{Row: 1, ID: id, Title: "Raced"}}, types.TodoImportOptions{})
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				assert.Equal(t, ReasonConflict, err.(TodoError).Reason, err.Error())
+			}
+		}
+
+		todos, err := service.GetTodosByIDs([]string{id})
+		require.NoError(t, err)
+		assert.Len(t, todos, 1)
+	})
+}
+
+func todoTitles(todos []types.Todo) []string {
+	titles := []string{}
+
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+
+	return titles
+}
+
+func dbSyncStamps(db *sql.DB) syncStamps {
+	return func(t *testing.T, id string) [2]time.Time {
+		var stamps [2]time.Time
+
+		err := db.QueryRow("SELECT title_updated_at, status_updated_at FROM todos WHERE external_id = $1", id).Scan(&stamps[0], &stamps[1])
+		require.NoError(t, err)
+
+		return stamps
+	}
+}
+
+func fileSyncStamps(store *filestore.Store) syncStamps {
+	return func(t *testing.T, id string) [2]time.Time {
+		var todo *syncTodo
+
+		err := store.View(func(tx *filestore.Tx) error {
+			var err error
+			todo, err = fileTx{tx}.todo(id)
+
+			return err
+		})
+		require.NoError(t, err)
+		require.NotNil(t, todo)
+
+		return [2]time.Time{todo.TitleUpdatedAt, todo.StatusUpdatedAt}
+	}
+}
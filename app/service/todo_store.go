@@ -0,0 +1,43 @@
+package service
+
+import "todo-app/app/types"
+
+// TodoStore keeps the todos and lists of a TodoService. Each method is handed
+// the service it works for, which carries the actor, workspace, log and event
+// bus to honor; access control is left to the store, as only it can look up
+// who holds which role.
+//
+// sqlTodoStore keeps them in the database of the service, Postgres or SQLite,
+// writing its statements through the service's Database so the two differ only
+// in its dialect. fileTodoStore keeps them in a file store, which has no users
+// and so no members or invitations.
+type TodoStore interface {
+	GetAllTodos(service *TodoService) ([]types.Todo, error)
+	FindTodos(service *TodoService, filter types.TodoFilter) ([]types.Todo, int, error)
+	ExportTodos(service *TodoService, filter types.TodoFilter, fn func(todo types.Todo) error) error
+	GetTodosByIDs(service *TodoService, ids []string) ([]types.Todo, error)
+	GetTodoByID(service *TodoService, id string) (*types.Todo, error)
+	CreateTodoInList(service *TodoService, listID string, title string) (*types.Todo, error)
+	UpdateTodo(service *TodoService, id string, title string) (*types.Todo, error)
+	DeleteTodoIf(service *TodoService, id string, precondition func(current *types.Todo) error) error
+	PutTodo(service *TodoService, todo types.Todo, precondition func(current *types.Todo) error) (*types.Todo, bool, error)
+	ImportTodos(service *TodoService, rows []types.TodoImportRow, opts types.TodoImportOptions) (*types.TodoImportReport, error)
+
+	LatestChangeSeq(service *TodoService) (int64, error)
+	ChangesSince(service *TodoService, seq int64, limit int) (*types.TodoChanges, error)
+	ApplySyncChanges(service *TodoService, changes []types.SyncChange) ([]types.SyncChangeResult, error)
+
+	GetLists(service *TodoService) ([]types.TodoList, error)
+	GetList(service *TodoService, id string) (*types.TodoList, error)
+	CreateList(service *TodoService, input types.TodoListInput) (*types.TodoList, error)
+	DeleteList(service *TodoService, id string) error
+	GetListMembers(service *TodoService, id string) ([]types.ListMember, error)
+	RemoveListMember(service *TodoService, id string, userID string) error
+
+	CreateListInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error)
+	CreateTodoInvitation(service *TodoService, id string, input types.InvitationInput) (*types.Invitation, string, error)
+	AcceptInvitation(service *TodoService, token string) (*types.Invitation, error)
+}
+
+// sqlTodoStore keeps todos and lists in the database of the service.
+type sqlTodoStore struct{}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+	"todo-app/app/utils"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// syncTodo is a todo together with the time each mergeable field was last
+// written, as kept by the todos_touch_fields trigger. It is also how the file
+// store keeps todos.
+type syncTodo struct {
+	types.Todo
+	TitleUpdatedAt      time.Time `json:"title_updated_at"`
+	DueAtUpdatedAt      time.Time `json:"due_at_updated_at"`
+	StatusUpdatedAt     time.Time `json:"status_updated_at"`
+	RecurrenceUpdatedAt time.Time `json:"recurrence_updated_at"`
+}
+
+func lockSyncTodo(tx *sql.Tx, speaks dialect, id string) (*syncTodo, error) {
+	var todo syncTodo
+
+	err := tx.QueryRow("SELECT "+todoColumns+", title_updated_at, due_at_updated_at, status_updated_at, recurrence_updated_at FROM todos WHERE external_id = $1"+speaks.forUpdate(false), id).
+		Scan(&todo.ID, &todo.ExternalID, &todo.Title, &todo.CreatedAt, &todo.DueAt, &todo.Status, &todo.Recurrence, &todo.ListID,
+			&todo.TitleUpdatedAt, &todo.DueAtUpdatedAt, &todo.StatusUpdatedAt, &todo.RecurrenceUpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// clampSyncTime caps a client timestamp at the server clock, so a device
+// whose clock runs ahead cannot win every later conflict.
+func clampSyncTime(t time.Time, now time.Time) time.Time {
+	if t.After(now) {
+		return now
+	}
+
+	return t
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return a.Equal(*b)
+}
+
+// validateSyncChange checks what can be checked before touching the
+// database and normalises the ID.
+func validateSyncChange(change *types.SyncChange) []string {
+	var errs []string
+
+	if id, err := uuid.Parse(change.ID); err != nil {
+		errs = append(errs, "id must be a UUID")
+	} else {
+		change.ID = id.String()
+	}
+
+	if change.Deleted {
+		if change.DeletedAt == nil {
+			errs = append(errs, "deleted_at is required for a deletion")
+		}
+
+		return errs
+	}
+
+	fields := change.Fields
+
+	for _, field := range []struct {
+		name       string
+		modifiedAt *time.Time
+	}{
+		{"title", stringFieldTime(fields.Title)},
+		{"due_at", timeFieldTime(fields.DueAt)},
+		{"status", stringFieldTime(fields.Status)},
+		{"recurrence", stringFieldTime(fields.Recurrence)},
+	} {
+		if field.modifiedAt != nil && field.modifiedAt.IsZero() {
+			errs = append(errs, fmt.Sprintf("fields.%s.modified_at is required", field.name))
+		}
+	}
+
+	return errs
+}
+
+func stringFieldTime(field *types.SyncStringField) *time.Time {
+	if field == nil {
+		return nil
+	}
+
+	return &field.ModifiedAt
+}
+
+func timeFieldTime(field *types.SyncTimeField) *time.Time {
+	if field == nil {
+		return nil
+	}
+
+	return &field.ModifiedAt
+}
+
+// latestFieldTime is the newest modification time among the changed fields.
+func latestFieldTime(fields types.SyncTodoFields) time.Time {
+	var latest time.Time
+
+	for _, modifiedAt := range []*time.Time{
+		stringFieldTime(fields.Title),
+		timeFieldTime(fields.DueAt),
+		stringFieldTime(fields.Status),
+		stringFieldTime(fields.Recurrence),
+	} {
+		if modifiedAt != nil && modifiedAt.After(latest) {
+			latest = *modifiedAt
+		}
+	}
+
+	return latest
+}
+
+// mergeSyncFields applies every client field modified after the server's copy
+// to todo and returns the fields where the server copy won with a different
+// value.
+func mergeSyncFields(todo *syncTodo, fields types.SyncTodoFields, now time.Time) []types.SyncConflict {
+	var conflicts []types.SyncConflict
+
+	mergeString := func(name string, field *types.SyncStringField, value *string, stamp *time.Time) {
+		if field == nil || field.Value == *value {
+			return
+		}
+
+		if modifiedAt := clampSyncTime(field.ModifiedAt, now); modifiedAt.After(*stamp) {
+			*value = field.Value
+			*stamp = modifiedAt
+
+			return
+		}
+
+		conflicts = append(conflicts, types.SyncConflict{Field: name, ServerValue: *value, ServerModifiedAt: *stamp})
+	}
+
+	mergeString("title", fields.Title, &todo.Title, &todo.TitleUpdatedAt)
+
+	if fields.DueAt != nil && !sameTime(fields.DueAt.Value, todo.DueAt) {
+		if modifiedAt := clampSyncTime(fields.DueAt.ModifiedAt, now); modifiedAt.After(todo.DueAtUpdatedAt) {
+			todo.DueAt = fields.DueAt.Value
+			todo.DueAtUpdatedAt = modifiedAt
+		} else {
+			conflicts = append(conflicts, types.SyncConflict{Field: "due_at", ServerValue: todo.DueAt, ServerModifiedAt: todo.DueAtUpdatedAt})
+		}
+	}
+
+	mergeString("status", fields.Status, &todo.Status, &todo.StatusUpdatedAt)
+	mergeString("recurrence", fields.Recurrence, &todo.Recurrence, &todo.RecurrenceUpdatedAt)
+
+	return conflicts
+}
+
+// ApplySyncChanges merges a batch of offline client changes in one
+// transaction. Each field is resolved on its own by last-writer-wins: the
+// client value is taken only when the client changed it after the server's
+// copy was last written. A deletion loses against any field changed after it,
+// and an update loses against a deletion made after all of its fields.
+// Problems with a single change, including todos the actor may not edit, are
+// reported in its result and do not stop the batch.
+func (service *TodoService) ApplySyncChanges(changes []types.SyncChange) ([]types.SyncChangeResult, error) {
+	return service.Store.ApplySyncChanges(service, changes)
+}
+
+func (sqlTodoStore) ApplySyncChanges(service *TodoService, changes []types.SyncChange) ([]types.SyncChangeResult, error) {
+	results := make([]types.SyncChangeResult, len(changes))
+	var events []types.TodoEvent
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.SyncTodosLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	fail := func(id string, msg string) error {
+		service.Log.WithFields(logrus.Fields{
+			"event":       constant.SyncTodosLogEventErrorKey,
+			"external_id": id,
+		}).Error(msg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	// retried is the change last retried because another request created
+	// its todo after it was looked up.
+	retried := -1
+
+	for i := 0; i < len(changes); i++ {
+		change := &changes[i]
+		result := &results[i]
+
+		errs := validateSyncChange(change)
+		*result = types.SyncChangeResult{ID: change.ID}
+
+		if len(errs) > 0 {
+			result.Status = constant.SyncStatusInvalid
+			result.Errors = errs
+
+			continue
+		}
+
+		current, err := lockSyncTodo(tx, service.DB.dialect(), change.ID)
+		if err != nil {
+			return nil, fail(change.ID, constant.DbQueryFailMsg)
+		}
+
+		if current != nil {
+			if err := service.authorizeTodo(txRower{tx}, change.ID, constant.RoleEditor, constant.SyncTodosLogEventErrorKey); err != nil {
+				if todoErr, ok := err.(TodoError); ok && todoErr.Reason != ReasonUnknown {
+					result.Status = constant.SyncStatusForbidden
+					result.Errors = []string{constant.ErrMsgForbidden}
+
+					continue
+				}
+
+				return nil, err
+			}
+		}
+
+		if change.Deleted {
+			if current == nil {
+				result.Status = constant.SyncStatusDeleted
+
+				continue
+			}
+
+			deletedAt := clampSyncTime(*change.DeletedAt, now)
+
+			for _, field := range []types.SyncConflict{
+				{Field: "title", ServerValue: current.Title, ServerModifiedAt: current.TitleUpdatedAt},
+				{Field: "due_at", ServerValue: current.DueAt, ServerModifiedAt: current.DueAtUpdatedAt},
+				{Field: "status", ServerValue: current.Status, ServerModifiedAt: current.StatusUpdatedAt},
+				{Field: "recurrence", ServerValue: current.Recurrence, ServerModifiedAt: current.RecurrenceUpdatedAt},
+			} {
+				if field.ServerModifiedAt.After(deletedAt) {
+					result.Conflicts = append(result.Conflicts, field)
+				}
+			}
+
+			if len(result.Conflicts) > 0 {
+				result.Status = constant.SyncStatusConflict
+				result.Todo = utils.MapTodoResponse(&current.Todo)
+
+				continue
+			}
+
+			viewers := service.deletedTodoViewers(tx, "todos.id = $1", current.ID)
+
+			if _, err := tx.Exec("DELETE FROM todos WHERE id = $1", current.ID); err != nil {
+				return nil, fail(change.ID, constant.DbExecFailMsg)
+			}
+
+			if err := enqueueWebhookEvent(tx, constant.TodoEventDeleted, map[string]string{"id": change.ID}); err != nil {
+				return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+			}
+
+			result.Status = constant.SyncStatusDeleted
+			events = append(events, types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: constant.TodoEventDeleted, ID: change.ID, Viewers: viewersOf(viewers, change.ID)})
+
+			continue
+		}
+
+		created := current == nil
+
+		if created {
+			var deletedAt time.Time
+
+			err := tx.QueryRow("SELECT changed_at FROM todo_changes WHERE external_id = $1 AND deleted ORDER BY seq DESC LIMIT 1", change.ID).Scan(&deletedAt)
+
+			switch {
+			case err == nil && !latestFieldTime(change.Fields).After(deletedAt):
+				result.Status = constant.SyncStatusConflict
+				result.Conflicts = []types.SyncConflict{{Field: "deleted", ServerValue: true, ServerModifiedAt: deletedAt}}
+
+				continue
+			case err != nil && err != sql.ErrNoRows:
+				return nil, fail(change.ID, constant.DbQueryFailMsg)
+			}
+
+			// Fields the client did not send start out as old as possible, so
+			// any later client value replaces them.
+			current = &syncTodo{Todo: types.Todo{ExternalID: change.ID, CreatedAt: now, Status: constant.TodoStatusNeedsAction}}
+		}
+
+		before := *current
+		result.Conflicts = mergeSyncFields(current, change.Fields, now)
+
+		row := types.TodoImportRow{Title: current.Title, Status: current.Status, Recurrence: current.Recurrence}
+		ValidateImportRow(&row)
+
+		if len(row.Errors) > 0 {
+			result.Status = constant.SyncStatusInvalid
+			result.Errors = row.Errors
+			result.Conflicts = nil
+
+			continue
+		}
+
+		current.Status = row.Status
+
+		switch {
+		case created:
+			for _, stamp := range []*time.Time{&current.TitleUpdatedAt, &current.DueAtUpdatedAt, &current.StatusUpdatedAt, &current.RecurrenceUpdatedAt} {
+				if stamp.IsZero() {
+					*stamp = now
+				}
+			}
+
+			err = tx.QueryRow("INSERT INTO todos (external_id, title, created_at, due_at, status, recurrence, title_updated_at, due_at_updated_at, status_updated_at, recurrence_updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT DO NOTHING RETURNING id",
+				current.ExternalID, current.Title, current.CreatedAt, current.DueAt, current.Status, current.Recurrence,
+				current.TitleUpdatedAt, current.DueAtUpdatedAt, current.StatusUpdatedAt, current.RecurrenceUpdatedAt).Scan(&current.ID)
+
+			// Merge into the todo the other request created, which the
+			// lookup finds now that it has committed.
+			if err == sql.ErrNoRows && retried != i {
+				retried = i
+				i--
+
+				continue
+			}
+
+			if err == nil {
+				err = service.grantTodoOwner(tx, current.ExternalID)
+			}
+		case before.Title != current.Title || !sameTime(before.DueAt, current.DueAt) || before.Status != current.Status || before.Recurrence != current.Recurrence:
+			_, err = tx.Exec("UPDATE todos SET title = $1, due_at = $2, status = $3, recurrence = $4, title_updated_at = $5, due_at_updated_at = $6, status_updated_at = $7, recurrence_updated_at = $8 WHERE id = $9",
+				current.Title, current.DueAt, current.Status, current.Recurrence,
+				current.TitleUpdatedAt, current.DueAtUpdatedAt, current.StatusUpdatedAt, current.RecurrenceUpdatedAt, current.ID)
+		default:
+			result.Todo = utils.MapTodoResponse(&current.Todo)
+			result.Status = constant.SyncStatusUnchanged
+
+			if len(result.Conflicts) > 0 {
+				result.Status = constant.SyncStatusConflict
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return nil, fail(change.ID, constant.DbExecFailMsg)
+		}
+
+		eventType := constant.TodoEventUpdated
+		result.Status = constant.SyncStatusUpdated
+
+		if created {
+			eventType = constant.TodoEventCreated
+			result.Status = constant.SyncStatusCreated
+		}
+
+		if len(result.Conflicts) > 0 {
+			result.Status = constant.SyncStatusConflict
+		}
+
+		todo := current.Todo
+		result.Todo = utils.MapTodoResponse(&todo)
+
+		if err := enqueueWebhookEvent(tx, eventType, result.Todo); err != nil {
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		events = append(events, types.TodoEvent{WorkspaceID: service.WorkspaceID, Type: eventType, ID: todo.ExternalID, Todo: &todo})
+	}
+
+	if err := tx.Commit(); err != nil {
+		service.Log.WithFields(logrus.Fields{
+			"event": constant.SyncTodosLogEventErrorKey,
+		}).Error(constant.DbTxCommitFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	service.publish(events...)
+
+	service.Log.WithFields(logrus.Fields{
+		"event":   constant.SyncTodosLogEventKey,
+		"changes": len(changes),
+		"applied": len(events),
+	}).Info("Sync changes applied")
+
+	return results, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+)
+
+const userColumns = "id, external_id, email, name, created_at"
+
+func scanUser(row rowScanner, user *types.User) error {
+	return row.Scan(&user.ID, &user.ExternalID, &user.Email, &user.Name, &user.CreatedAt)
+}
+
+// ownerColumns selects the user that owns a row of table through its user_id
+// column, as zero values when there is none. Scan them with ownerScanDest.
+func ownerColumns(table string) string {
+	return fmt.Sprintf("COALESCE(%[1]s.user_id, 0), "+
+		"COALESCE((SELECT CAST(external_id AS TEXT) FROM users WHERE users.id = %[1]s.user_id), ''), "+
+		"COALESCE((SELECT email FROM users WHERE users.id = %[1]s.user_id), ''), "+
+		"COALESCE((SELECT name FROM users WHERE users.id = %[1]s.user_id), '')", table)
+}
+
+func ownerScanDest(user *types.User) []interface{} {
+	return []interface{}{&user.ID, &user.ExternalID, &user.Email, &user.Name}
+}
+
+// ownerOrNil turns the zero user scanned for a row without owner into nil.
+func ownerOrNil(user *types.User) *types.User {
+	if user.ID == 0 {
+		return nil
+	}
+
+	return user
+}
+
+func ownerID(user *types.User) interface{} {
+	if user == nil {
+		return nil
+	}
+
+	return user.ID
+}
+
+// ensureUser returns the user with email, creating it first if needed. A
+// non-empty name replaces the stored one.
+func ensureUser(q queryRower, email string, name string) (*types.User, error) {
+	var user types.User
+
+	err := scanUser(q.QueryRow("INSERT INTO users (external_id, email, name) VALUES ($1, $2, $3) ON CONFLICT (workspace_id, email) DO UPDATE SET name = CASE WHEN EXCLUDED.name = '' THEN users.name ELSE EXCLUDED.name END RETURNING "+userColumns,
+		uuid.New().String(), strings.ToLower(email), name), &user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
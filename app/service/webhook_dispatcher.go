@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+type WebhookDispatcher struct {
+	DB           *sql.DB
+	Client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+func NewWebhookDispatcher(db *sql.DB, config *types.Config) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		DB:           db,
+		Client:       &http.Client{Timeout: config.WebhookTimeout},
+		PollInterval: config.WebhookPollInterval,
+		BatchSize:    20,
+		MaxAttempts:  config.WebhookMaxAttempts,
+		BaseBackoff:  config.WebhookBaseBackoff,
+		MaxBackoff:   config.WebhookMaxBackoff,
+	}
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with their secret and compare it to the signature
+// header, rejecting timestamps that are too old to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff returns the delay before the given retry attempt: exponential
+// growth from base, capped at max, with the upper half randomized to avoid
+// retry storms against a recovering receiver.
+func WebhookBackoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (dispatcher *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatcher.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		dispatcher.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dueDelivery is a delivery claimed for an attempt, with where to send it.
+type dueDelivery struct {
+	id         int
+	externalID string
+	eventType  string
+	payload    []byte
+	attempts   int
+	url        string
+	secret     string
+}
+
+// dispatchDue attempts a batch of due deliveries. They are claimed first, so
+// several app instances can run dispatchers against the same outbox, and are
+// then sent with no transaction or row lock held while receivers answer.
+func (dispatcher *WebhookDispatcher) dispatchDue(ctx context.Context) {
+	due, err := dispatcher.claimDue(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, d := range due {
+		statusCode, deliverErr := dispatcher.deliver(ctx, d.url, d.secret, d.externalID, d.eventType, d.payload)
+		attempts := d.attempts + 1
+
+		if err := dispatcher.recordAttempt(ctx, d, attempts, statusCode, deliverErr); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.DispatchWebhookLogEventErrorKey,
+				"external_id": d.externalID,
+			}).Error(constant.DbExecFailMsg)
+
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.DispatchWebhookLogEventKey,
+			"external_id": d.externalID,
+			"attempt":     attempts,
+			"success":     deliverErr == nil,
+		}).Debug("Webhook delivery attempted")
+	}
+}
+
+// claimDue leases a batch of due deliveries to this dispatcher by moving
+// their next attempt past the time it takes to attempt them all, and returns
+// them. Other dispatchers skip them until then, and pick them up again only if
+// this one stopped before recording its attempts.
+func (dispatcher *WebhookDispatcher) claimDue(ctx context.Context) ([]dueDelivery, error) {
+	leaseUntil := time.Now().Add(time.Duration(dispatcher.BatchSize+1) * dispatcher.Client.Timeout)
+
+	rows, err := dispatcher.DB.QueryContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id IN ("+
+		"SELECT id FROM webhook_deliveries WHERE status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP "+
+		"ORDER BY next_attempt_at LIMIT $3"+dialectOf(dispatcher.DB).forUpdate(true)+") "+
+		"RETURNING id, external_id, event_type, payload, attempts, "+
+		"(SELECT url FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id), "+
+		"(SELECT secret FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id)",
+		leaseUntil, constant.WebhookDeliveryStatusPending, dispatcher.BatchSize)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.DispatchWebhookLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var due []dueDelivery
+
+	for rows.Next() {
+		var d dueDelivery
+		if err := rows.Scan(&d.id, &d.externalID, &d.eventType, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.DispatchWebhookLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, err
+		}
+
+		due = append(due, d)
+	}
+
+	// The claim is committed along with the statement, so rows cut short
+	// here wait out their lease before they are attempted.
+	if err := rows.Err(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.DispatchWebhookLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, err
+	}
+
+	return due, nil
+}
+
+// recordAttempt stores the outcome of an attempt at d: delivered, dead once
+// it used up its attempts, or due again after a backoff.
+func (dispatcher *WebhookDispatcher) recordAttempt(ctx context.Context, d dueDelivery, attempts int, statusCode int, deliverErr error) error {
+	var statusCodeArg interface{}
+	if statusCode != 0 {
+		statusCodeArg = statusCode
+	}
+
+	var err error
+
+	if deliverErr == nil {
+		_, err = dispatcher.DB.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $1, attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP WHERE id = $4",
+			constant.WebhookDeliveryStatusDelivered, attempts, statusCodeArg, d.id)
+	} else if attempts >= dispatcher.MaxAttempts {
+		_, err = dispatcher.DB.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $1, attempts = $2, last_status_code = $3, last_error = $4 WHERE id = $5",
+			constant.WebhookDeliveryStatusDead, attempts, statusCodeArg, deliverErr.Error(), d.id)
+	} else {
+		nextAttemptAt := time.Now().Add(WebhookBackoff(attempts, dispatcher.BaseBackoff, dispatcher.MaxBackoff))
+
+		_, err = dispatcher.DB.ExecContext(ctx, "UPDATE webhook_deliveries SET attempts = $1, last_status_code = $2, last_error = $3, next_attempt_at = $4 WHERE id = $5",
+			attempts, statusCodeArg, deliverErr.Error(), nextAttemptAt, d.id)
+	}
+
+	return err
+}
+
+func (dispatcher *WebhookDispatcher) deliver(ctx context.Context, url string, secret string, deliveryID string, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constant.WebhookEventHeader, eventType)
+	req.Header.Set(constant.WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(constant.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(constant.WebhookSignatureHeader, "sha256="+SignWebhookPayload(secret, timestamp, payload))
+
+	resp, err := dispatcher.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
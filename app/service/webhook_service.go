@@ -0,0 +1,387 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type WebhookService struct {
+	DB Database
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	return &WebhookService{
+		DB: NewDatabase(db),
+	}
+}
+
+// InWorkspace returns a copy of the service limited to the webhooks of the
+// workspace with workspaceID.
+func (service *WebhookService) InWorkspace(workspaceID int) *WebhookService {
+	scoped := *service
+	scoped.DB = inWorkspace(service.DB, workspaceID)
+
+	return &scoped
+}
+
+const webhookColumns = "id, external_id, url, secret, event_types, active, created_at"
+
+const webhookDeliveryColumns = "id, external_id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner, webhook *types.Webhook) error {
+	return row.Scan(&webhook.ID, &webhook.ExternalID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.Active, &webhook.CreatedAt)
+}
+
+func scanWebhookDelivery(row rowScanner, delivery *types.WebhookDelivery) error {
+	var payload []byte
+
+	err := row.Scan(&delivery.ID, &delivery.ExternalID, &delivery.WebhookID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.DeliveredAt, &delivery.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func (service *WebhookService) GetAllWebhooks() ([]types.Webhook, error) {
+	var webhooks []types.Webhook
+
+	rows, err := service.DB.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.GetWebhooksLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook types.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.GetWebhooksLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (service *WebhookService) GetWebhookByID(id string) (*types.Webhook, error) {
+	var webhook types.Webhook
+
+	err := scanWebhook(service.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE external_id = $1", id), &webhook)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.GetWebhookLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbIdNotFoundMsg)
+
+			return nil, TodoError{Message: fmt.Sprintf("Webhook with id %s not found", id), Reason: ReasonNotFound}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.GetWebhookLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &webhook, nil
+}
+
+func (service *WebhookService) CreateWebhook(input types.WebhookInput) (*types.Webhook, error) {
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CreateWebhookLogEventErrorKey,
+		}).Error("Failed to generate webhook secret")
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	newWebhook := types.Webhook{
+		ExternalID: uuid.New().String(),
+		URL:        input.URL,
+		Secret:     secret,
+		EventTypes: input.EventTypes,
+		Active:     input.Active == nil || *input.Active,
+		CreatedAt:  time.Now(),
+	}
+
+	if newWebhook.EventTypes == nil {
+		newWebhook.EventTypes = []string{}
+	}
+
+	err = service.DB.QueryRow("INSERT INTO webhooks (external_id, url, secret, event_types, active, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		newWebhook.ExternalID, newWebhook.URL, newWebhook.Secret, pq.Array(newWebhook.EventTypes), newWebhook.Active, newWebhook.CreatedAt).Scan(&newWebhook.ID)
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.CreateWebhookLogEventErrorKey,
+		}).Error("Failed to create webhook")
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.CreateWebhookLogEventKey,
+		"external_id": newWebhook.ExternalID,
+	}).Info("Webhook created successfully")
+
+	return &newWebhook, nil
+}
+
+func (service *WebhookService) UpdateWebhook(id string, input types.WebhookInput) (*types.Webhook, error) {
+	var updatedWebhook types.Webhook
+
+	eventTypes := input.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	err := scanWebhook(service.DB.QueryRow("UPDATE webhooks SET url = $1, event_types = $2, active = COALESCE($3, active) WHERE external_id = $4 RETURNING "+webhookColumns,
+		input.URL, pq.Array(eventTypes), input.Active, id), &updatedWebhook)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.UpdateWebhookLogEventErrorKey,
+				"external_id": id,
+			}).Error(constant.DbIdNotFoundMsg)
+
+			return nil, TodoError{Message: fmt.Sprintf("Webhook with id %s not found", id), Reason: ReasonNotFound}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.UpdateWebhookLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.UpdateWebhookLogEventKey,
+		"external_id": id,
+	}).Info("Webhook updated successfully")
+
+	return &updatedWebhook, nil
+}
+
+func (service *WebhookService) DeleteWebhook(id string) error {
+	result, err := service.DB.Exec("DELETE FROM webhooks WHERE external_id = $1", id)
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.DeleteWebhookLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbExecFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.DeleteWebhookLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbRowsAffectedFailMsg)
+
+		return TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	if rowsAffected == 0 {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.DeleteWebhookLogEventErrorKey,
+			"external_id": id,
+		}).Error(constant.DbIdNotFoundMsg)
+
+		return TodoError{Message: fmt.Sprintf("Webhook with id %s not found", id), Reason: ReasonNotFound}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.DeleteWebhookLogEventKey,
+		"external_id": id,
+	}).Info("Webhook deleted successfully")
+
+	return nil
+}
+
+func (service *WebhookService) GetDeliveries(webhookID string) ([]types.WebhookDelivery, error) {
+	if _, err := service.GetWebhookByID(webhookID); err != nil {
+		return nil, err
+	}
+
+	var deliveries []types.WebhookDelivery
+
+	rows, err := service.DB.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = (SELECT id FROM webhooks WHERE external_id = $1) ORDER BY id DESC", webhookID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.GetDeliveriesLogEventErrorKey,
+			"external_id": webhookID,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery types.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.GetDeliveriesLogEventErrorKey,
+				"external_id": webhookID,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver resets a delivery, including dead-lettered ones, so the
+// dispatcher picks it up again on its next poll.
+func (service *WebhookService) Redeliver(webhookID string, deliveryID string) (*types.WebhookDelivery, error) {
+	var delivery types.WebhookDelivery
+
+	err := scanWebhookDelivery(service.DB.QueryRow(
+		"UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL "+
+			"WHERE webhook_id = (SELECT id FROM webhooks WHERE external_id = $2) AND external_id = $3 RETURNING "+webhookDeliveryColumns,
+		constant.WebhookDeliveryStatusPending, webhookID, deliveryID), &delivery)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logrus.WithFields(logrus.Fields{
+				"event":       constant.RedeliverLogEventErrorKey,
+				"external_id": deliveryID,
+			}).Error(constant.DbIdNotFoundMsg)
+
+			return nil, TodoError{Message: fmt.Sprintf("Delivery with id %s not found", deliveryID), Reason: ReasonNotFound}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event":       constant.RedeliverLogEventErrorKey,
+			"external_id": deliveryID,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.RedeliverLogEventKey,
+		"external_id": deliveryID,
+	}).Info("Webhook delivery scheduled for redelivery")
+
+	return &delivery, nil
+}
+
+// enqueueWebhookEvent writes one outbox row per matching active subscription.
+// It runs on the caller's transaction so deliveries are only recorded when the
+// todo mutation itself commits.
+func enqueueWebhookEvent(tx *sql.Tx, eventType string, data interface{}) error {
+	payload, err := json.Marshal(types.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	webhookIDs, err := subscribedWebhooks(tx, eventType)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":      constant.EnqueueWebhookLogEventErrorKey,
+			"event_type": eventType,
+		}).Error(constant.DbQueryFailMsg)
+
+		return err
+	}
+
+	for _, webhookID := range webhookIDs {
+		_, err = tx.Exec("INSERT INTO webhook_deliveries (external_id, webhook_id, event_type, payload) VALUES ($1, $2, $3, $4)",
+			uuid.New().String(), webhookID, eventType, string(payload))
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":      constant.EnqueueWebhookLogEventErrorKey,
+				"event_type": eventType,
+			}).Error(constant.DbExecFailMsg)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subscribedWebhooks returns the IDs of the active webhooks subscribed to
+// eventType, either by name or by listing no event types at all.
+func subscribedWebhooks(tx *sql.Tx, eventType string) ([]int, error) {
+	rows, err := tx.Query("SELECT id, event_types FROM webhooks WHERE active")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []int
+
+	for rows.Next() {
+		var id int
+		var eventTypes []string
+
+		if err := rows.Scan(&id, pq.Array(&eventTypes)); err != nil {
+			return nil, err
+		}
+
+		if len(eventTypes) == 0 || slices.Contains(eventTypes, eventType) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// WorkspaceService manages the workspaces themselves. It runs outside of any
+// workspace, so it must never be handed to code serving tenant data.
+type WorkspaceService struct {
+	DB Database
+}
+
+func NewWorkspaceService(db *sql.DB) *WorkspaceService {
+	return &WorkspaceService{
+		DB: NewDatabase(db),
+	}
+}
+
+const workspaceColumns = "id, external_id, slug, name, created_at"
+
+func scanWorkspace(row rowScanner, workspace *types.Workspace) error {
+	return row.Scan(&workspace.ID, &workspace.ExternalID, &workspace.Slug, &workspace.Name, &workspace.CreatedAt)
+}
+
+func (service *WorkspaceService) GetAllWorkspaces() ([]types.Workspace, error) {
+	var workspaces []types.Workspace
+
+	rows, err := service.DB.Query("SELECT " + workspaceColumns + " FROM workspaces ORDER BY id")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.WorkspaceLogEventErrorKey,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var workspace types.Workspace
+		if err := scanWorkspace(rows, &workspace); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.WorkspaceLogEventErrorKey,
+			}).Error(constant.DbScanFailMsg)
+
+			return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+		}
+
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, nil
+}
+
+func (service *WorkspaceService) GetWorkspaceBySlug(slug string) (*types.Workspace, error) {
+	return service.getWorkspace("slug = $1", slug)
+}
+
+func (service *WorkspaceService) GetWorkspaceByID(id int) (*types.Workspace, error) {
+	return service.getWorkspace("id = $1", id)
+}
+
+func (service *WorkspaceService) getWorkspace(condition string, value interface{}) (*types.Workspace, error) {
+	var workspace types.Workspace
+
+	err := scanWorkspace(service.DB.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE "+condition, value), &workspace)
+
+	if err == sql.ErrNoRows {
+		return nil, TodoError{Message: constant.ErrMsgWorkspaceNotFound, Reason: ReasonNotFound}
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.WorkspaceLogEventErrorKey,
+			"value": value,
+		}).Error(constant.DbQueryFailMsg)
+
+		return nil, TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	return &workspace, nil
+}
+
+// CreateWorkspace creates a workspace together with its first API key, a
+// service key with every scope, and returns the key's secret. Without the key
+// nobody could get into the workspace once authentication is required.
+func (service *WorkspaceService) CreateWorkspace(input types.WorkspaceInput) (*types.Workspace, string, error) {
+	workspace := types.Workspace{
+		ExternalID: uuid.New().String(),
+		Slug:       input.Slug,
+		Name:       input.Name,
+		CreatedAt:  time.Now(),
+	}
+
+	key, secret, err := generateAPIKey(types.APIKeyInput{Name: fmt.Sprintf("%s admin", input.Name)})
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := service.DB.Begin()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.WorkspaceLogEventErrorKey,
+		}).Error(constant.DbTxBeginFailMsg)
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO workspaces (external_id, slug, name, created_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		workspace.ExternalID, workspace.Slug, workspace.Name, workspace.CreatedAt).Scan(&workspace.ID)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, "", TodoError{Message: constant.ErrMsgWorkspaceSlugTaken, Reason: ReasonConflict}
+	}
+
+	if err == nil {
+		// The key picks its workspace up from the setting, like every row
+		// written by a request.
+		_, err = tx.Exec(fmt.Sprintf("SET LOCAL app.workspace_id = %d", workspace.ID))
+	}
+
+	if err == nil {
+		err = insertAPIKey(txRower{tx}, key)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.WorkspaceLogEventErrorKey,
+			"slug":  workspace.Slug,
+		}).Error(constant.DbExecFailMsg)
+
+		return nil, "", TodoError{Message: constant.ErrMsgInternalServer, Reason: ReasonUnknown}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event":       constant.WorkspaceLogEventKey,
+		"external_id": workspace.ExternalID,
+		"slug":        workspace.Slug,
+	}).Info("Workspace created successfully")
+
+	return &workspace, secret, nil
+}
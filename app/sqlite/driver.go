@@ -0,0 +1,99 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Available reports whether the driver is built in, which takes cgo.
+const Available = true
+
+// timeFormat is how times are stored: in UTC and with fixed precision, so
+// they compare correctly as text and parse back as go-sqlite3 times.
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+func init() {
+	sql.Register(DriverName, &sqliteDriver{SQLiteDriver: sqlite3.SQLiteDriver{ConnectHook: registerFunctions}})
+}
+
+// sqliteDriver is a go-sqlite3 driver with the functions the services call
+// and the time format the schema expects.
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func isDriver(d driver.Driver) bool {
+	_, ok := d.(*sqliteDriver)
+
+	return ok
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	opened, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{SQLiteConn: opened.(*sqlite3.SQLiteConn)}, nil
+}
+
+type conn struct {
+	*sqlite3.SQLiteConn
+}
+
+// bind converts arguments go-sqlite3 would store differently from what the
+// schema expects.
+func bind(args []driver.NamedValue) []driver.NamedValue {
+	bound := make([]driver.NamedValue, len(args))
+
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			arg.Value = t.UTC().Format(timeFormat)
+		}
+
+		bound[i] = arg
+	}
+
+	return bound
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	prepared, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{SQLiteStmt: prepared.(*sqlite3.SQLiteStmt)}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, query, bind(args))
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, query, bind(args))
+}
+
+// stmt binds arguments like conn does for prepared statements, which
+// golang-migrate and database/sql itself may use.
+type stmt struct {
+	*sqlite3.SQLiteStmt
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.SQLiteStmt.ExecContext(ctx, bind(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.SQLiteStmt.QueryContext(ctx, bind(args))
+}
@@ -0,0 +1,69 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open(DriverName, "file:"+filepath.Join(t.TempDir(), "test.db")+"?_txlock=immediate")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL, tags TEXT NOT NULL DEFAULT '{}', seen_at TIMESTAMP)")
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestDriver(t *testing.T) {
+	db := openTestDB(t)
+
+	seenAt := time.Date(2024, 5, 1, 12, 30, 0, 250000000, time.FixedZone("CEST", 2*60*60))
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		_, err := db.Exec("INSERT INTO items (name, tags, seen_at) VALUES ($1, $2, $3)", name, pq.Array([]string{name, "all"}), seenAt)
+		require.NoError(t, err)
+	}
+
+	t.Run("It should store times in UTC and read them back", func(t *testing.T) {
+		var stored string
+		var read time.Time
+
+		require.NoError(t, db.QueryRow("SELECT seen_at, seen_at FROM items WHERE name = $1", "a").Scan(&stored, &read))
+
+		assert.Equal(t, "2024-05-01T10:30:00.25Z", stored)
+		assert.True(t, read.Equal(seenAt))
+	})
+
+	t.Run("It should query arrays", func(t *testing.T) {
+		var count int
+		var tags []string
+
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items WHERE array_contains(tags, $1)", "all").Scan(&count))
+		assert.Equal(t, 5, count)
+
+		require.NoError(t, db.QueryRow("SELECT tags FROM items WHERE array_contains($1, name)", pq.Array([]string{"c", "z"})).Scan(pq.Array(&tags)))
+		assert.Equal(t, []string{"c", "all"}, tags)
+	})
+
+	t.Run("It should number $N placeholders in the order they appear", func(t *testing.T) {
+		var name string
+
+		require.NoError(t, db.QueryRow("SELECT name FROM items WHERE name = $1 OR (name = $2 AND $1 = 'z')", "b", "c").Scan(&name))
+		assert.Equal(t, "b", name)
+	})
+
+	t.Run("It should be told from other drivers", func(t *testing.T) {
+		assert.True(t, Opened(db))
+	})
+}
@@ -0,0 +1,45 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// registerFunctions adds the functions the SQLite statements of the services
+// call to conn. Arrays are stored as text in the Postgres array format, which
+// pq.Array writes and reads, so the array functions parse that.
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	return conn.RegisterFunc("array_contains", arrayContains, true)
+}
+
+func parseArray(value interface{}) (pq.StringArray, error) {
+	var array pq.StringArray
+
+	// NULL arrives as a nil []byte.
+	if bytes, ok := value.([]byte); ok && bytes == nil {
+		return nil, nil
+	}
+
+	err := array.Scan(value)
+
+	return array, err
+}
+
+// arrayContains reports whether the array holds element, the SQLite
+// counterpart of element = ANY(array).
+func arrayContains(value interface{}, element string) (bool, error) {
+	array, err := parseArray(value)
+	if err != nil {
+		return false, err
+	}
+
+	for _, candidate := range array {
+		if candidate == element {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
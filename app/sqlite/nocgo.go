@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package sqlite
+
+import "database/sql/driver"
+
+// Available reports whether the driver is built in. go-sqlite3 needs cgo,
+// and this build has none.
+const Available = false
+
+func isDriver(d driver.Driver) bool {
+	return false
+}
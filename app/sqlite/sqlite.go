@@ -0,0 +1,41 @@
+// Package sqlite registers the database/sql driver behind DB_TYPE=sqlite. It
+// wraps go-sqlite3, which needs cgo, so builds without cgo leave it out; see
+// Available.
+//
+// The services write the SQLite flavor of the statements where it differs
+// from the Postgres one; see service.Database. Statements both databases read
+// alike number their placeholders in the order they first appear: SQLite
+// takes $1 as a named parameter and numbers those in order of appearance,
+// which then matches the order of the arguments.
+//
+// SQLite has no roles or row-level security, so it holds a single workspace,
+// and the app does not offer to create more.
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+)
+
+// DriverName is the name the driver is registered as, the same as the
+// DB_TYPE that selects it.
+const DriverName = "sqlite"
+
+// Opened reports whether db was opened with this driver, directly or through
+// a wrapper that unwraps to it, like the tracing one.
+func Opened(db *sql.DB) bool {
+	d := db.Driver()
+
+	for {
+		if isDriver(d) {
+			return true
+		}
+
+		wrapper, ok := d.(interface{ Unwrap() driver.Driver })
+		if !ok {
+			return false
+		}
+
+		d = wrapper.Unwrap()
+	}
+}
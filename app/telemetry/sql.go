@@ -0,0 +1,257 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	registerMu sync.Mutex
+	registered = map[string]string{}
+)
+
+// TracedDriver registers a wrapper of the database/sql driver called name that
+// records a span per statement, and returns the name to open it by. Statements
+// are children of the span in their context or, for statements run through a
+// *sql.Tx without one, of the context the transaction was begun in.
+func TracedDriver(name string) (string, error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	if traced, ok := registered[name]; ok {
+		return traced, nil
+	}
+
+	// Opening does not connect; it only looks the driver up.
+	db, err := sql.Open(name, "")
+	if err != nil {
+		return "", err
+	}
+
+	defer db.Close()
+
+	system := semconv.DBSystemKey.String(name)
+
+	switch name {
+	case "postgres":
+		system = semconv.DBSystemPostgreSQL
+	case "sqlite":
+		system = semconv.DBSystemSqlite
+	}
+
+	traced := name + "+otel"
+	sql.Register(traced, tracedDriver{Driver: db.Driver(), system: system})
+	registered[name] = traced
+
+	return traced, nil
+}
+
+// operation is the SQL command a statement starts with, like SELECT.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+
+	return strings.ToUpper(strings.TrimSuffix(fields[0], ";"))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, driver.ErrSkip) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+type tracedDriver struct {
+	driver.Driver
+	system attribute.KeyValue
+}
+
+// Unwrap returns the driver d traces, so the database behind it can be told.
+func (d tracedDriver) Unwrap() driver.Driver {
+	return d.Driver
+}
+
+func (d tracedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, system: d.system}, nil
+}
+
+// tracedConn traces the statements of one connection. A connection serves
+// one transaction at a time, so it can remember the transaction's context.
+type tracedConn struct {
+	driver.Conn
+	system attribute.KeyValue
+	txCtx  context.Context
+}
+
+func (c *tracedConn) startSpan(ctx context.Context, name string, query string) trace.Span {
+	if c.txCtx != nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = c.txCtx
+	}
+
+	attributes := []attribute.KeyValue{c.system, semconv.DBOperationName(name)}
+	if query != "" {
+		attributes = append(attributes, semconv.DBQueryText(query))
+	}
+
+	_, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+
+	return span
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := c.startSpan(ctx, operation(query), query)
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		endSpan(span, err)
+
+		return nil, err
+	}
+
+	// The span lasts until the rows are closed, as reading them is part of
+	// the statement.
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := c.startSpan(ctx, operation(query), query)
+
+	result, err := execer.ExecContext(ctx, query, args)
+	endSpan(span, err)
+
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	span := c.startSpan(ctx, "BEGIN", "")
+
+	var tx driver.Tx
+	var err error
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+
+	endSpan(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.txCtx = ctx
+
+	return &tracedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+type tracedTx struct {
+	driver.Tx
+	conn *tracedConn
+}
+
+func (tx *tracedTx) end(name string, finish func() error) error {
+	span := tx.conn.startSpan(context.Background(), name, "")
+	tx.conn.txCtx = nil
+
+	err := finish()
+	endSpan(span, err)
+
+	return err
+}
+
+func (tx *tracedTx) Commit() error {
+	return tx.end("COMMIT", tx.Tx.Commit)
+}
+
+func (tx *tracedTx) Rollback() error {
+	return tx.end("ROLLBACK", tx.Tx.Rollback)
+}
+
+type tracedRows struct {
+	driver.Rows
+	span trace.Span
+	err  error
+}
+
+func (rows *tracedRows) Next(dest []driver.Value) error {
+	err := rows.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		rows.err = err
+	}
+
+	return err
+}
+
+func (rows *tracedRows) Close() error {
+	err := rows.Rows.Close()
+
+	if rows.err == nil {
+		rows.err = err
+	}
+
+	endSpan(rows.span, rows.err)
+
+	return err
+}
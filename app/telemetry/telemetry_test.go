@@ -0,0 +1,203 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// fakeDriver answers every query with one row and fails statements that
+// mention FAIL.
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeRows struct{ done bool }
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeConn{}, nil }
+func (fakeConn) Commit() error                       { return nil }
+func (fakeConn) Rollback() error                     { return nil }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "FAIL") {
+		return nil, errors.New("query failed")
+	}
+
+	return &fakeRows{}, nil
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "FAIL") {
+		return nil, errors.New("exec failed")
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (rows *fakeRows) Columns() []string { return []string{"n"} }
+func (rows *fakeRows) Close() error      { return nil }
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.done {
+		return io.EOF
+	}
+
+	rows.done = true
+	dest[0] = int64(1)
+
+	return nil
+}
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+
+	for i, span := range spans {
+		names[i] = span.Name()
+	}
+
+	return names
+}
+
+func TestTracedDriver(t *testing.T) {
+	name, err := TracedDriver("fake")
+	assert.NoError(t, err)
+
+	again, _ := TracedDriver("fake")
+	assert.Equal(t, name, again)
+
+	db, err := sql.Open(name, "")
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	t.Run("It should record statements as children of the request span", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		ctx, parent := Tracer().Start(context.Background(), "request")
+
+		var n int
+		assert.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+
+		_, err := db.ExecContext(ctx, "  update todos SET title = $1", "x")
+		assert.NoError(t, err)
+
+		parent.End()
+
+		spans := recorder.Ended()
+		assert.Equal(t, []string{"SELECT", "UPDATE", "request"}, spanNames(spans))
+
+		for _, span := range spans[:2] {
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			assert.Contains(t, span.Attributes(), semconv.DBOperationName(span.Name()))
+		}
+
+		assert.Contains(t, spans[0].Attributes(), semconv.DBQueryText("SELECT 1"))
+	})
+
+	t.Run("It should parent statements of a transaction to its context", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		ctx, parent := Tracer().Start(context.Background(), "request")
+
+		tx, err := db.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec("DELETE FROM todos")
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		parent.End()
+
+		spans := recorder.Ended()
+		assert.Equal(t, []string{"BEGIN", "DELETE", "COMMIT", "request"}, spanNames(spans))
+
+		for _, span := range spans[:3] {
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+	})
+
+	t.Run("It should mark failed statements", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		_, err := db.ExecContext(context.Background(), "INSERT FAIL")
+		assert.Error(t, err)
+
+		_, err = db.QueryContext(context.Background(), "SELECT FAIL")
+		assert.Error(t, err)
+
+		for _, span := range recorder.Ended() {
+			assert.Equal(t, codes.Error, span.Status().Code)
+		}
+	})
+}
+
+func TestLogHook(t *testing.T) {
+	recordSpans(t)
+
+	var out bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(LogHook{})
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+
+	logger.WithContext(ctx).Info("traced")
+	assert.Contains(t, out.String(), `"trace_id":"`+span.SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, out.String(), `"span_id":"`+span.SpanContext().SpanID().String()+`"`)
+
+	out.Reset()
+
+	logger.WithContext(context.Background()).Info("untraced")
+	assert.NotContains(t, out.String(), "trace_id")
+}
+
+func TestSetup(t *testing.T) {
+	t.Run("It should reject unknown exporters", func(t *testing.T) {
+		_, err := Setup(context.Background(), &types.Config{TraceExporter: "carrier-pigeon"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should record nothing without an exporter", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), &types.Config{TraceExporter: ExporterNone})
+
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"time"
+)
+
+// User is a person todos can be shared with. Requests made with a personal
+// API key act as its user.
+type User struct {
+	ID         int
+	ExternalID string
+	Email      string
+	Name       string
+	CreatedAt  time.Time
+}
+
+// TodoList groups todos so they can be shared together. Role is the role of
+// the acting user, empty when there is none.
+type TodoList struct {
+	ID         int
+	ExternalID string
+	Name       string
+	Role       string
+	CreatedAt  time.Time
+}
+
+type TodoListResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TodoListInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ListMember is a user holding a role on a list.
+type ListMember struct {
+	User User
+	Role string
+}
+
+type ListMemberResponse struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+// Invitation offers a role on a list or a single todo to whoever signs in
+// with Email. Only a hash of its token is stored.
+type Invitation struct {
+	ID         int
+	ExternalID string
+	ListID     string
+	TodoID     string
+	Email      string
+	Role       string
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type InvitationResponse struct {
+	ID         string     `json:"id"`
+	ListID     string     `json:"list_id,omitempty"`
+	TodoID     string     `json:"todo_id,omitempty"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// InvitationCreatedResponse carries the invitation token, which cannot be
+// retrieved later.
+type InvitationCreatedResponse struct {
+	InvitationResponse
+	Token string `json:"token"`
+}
+
+type InvitationInput struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner editor viewer"`
+}
+
+type AcceptInvitationInput struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"time"
+)
+
+// APIKey authenticates a machine client. Only a hash of the key is stored;
+// Prefix is its first characters, kept so the key can be recognised in
+// listings. No scopes means the key may do anything. A personal key belongs to
+// User and acts on their behalf; a key without one is a service key. A key
+// only works in the workspace it was created in.
+type APIKey struct {
+	ID          int
+	WorkspaceID int
+	ExternalID  string
+	Name        string
+	Prefix      string
+	KeyHash     string
+	Scopes      []string
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+	User        *User
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	UserID     string     `json:"user_id,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeyCreatedResponse carries the secret key, which cannot be retrieved
+// later.
+type APIKeyCreatedResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type APIKeyInput struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes"`
+	UserEmail string   `json:"user_email" binding:"omitempty,email"`
+}
@@ -0,0 +1,11 @@
+package types
+
+// TodoCacheStats count what the todo cache did since the app started.
+type TodoCacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	Size          int    `json:"size"`
+}
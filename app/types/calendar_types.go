@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+)
+
+// CalendarFeed grants read access to the iCalendar feed. Only a hash of its
+// token is stored, so the feed URL can be shown once, on creation. A feed
+// created with a personal key shows what its User can see, within the
+// workspace it was created in.
+type CalendarFeed struct {
+	ID          int
+	WorkspaceID int
+	ExternalID  string
+	Name        string
+	TokenHash   string
+	LastUsedAt  *time.Time
+	CreatedAt   time.Time
+	User        *User
+}
+
+type CalendarFeedResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CalendarFeedCreatedResponse carries the subscription URL, which embeds the
+// secret token and cannot be retrieved later.
+type CalendarFeedCreatedResponse struct {
+	CalendarFeedResponse
+	URL string `json:"url"`
+}
+
+type CalendarFeedInput struct {
+	Name string `json:"name" binding:"required"`
+}
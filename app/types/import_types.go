@@ -0,0 +1,54 @@
+package types
+
+import (
+	"time"
+)
+
+// TodoImportRow is one record read from an import file. Errors collects
+// problems found while parsing, before the row reaches the service.
+type TodoImportRow struct {
+	Row        int
+	ID         string
+	Title      string
+	CreatedAt  *time.Time
+	DueAt      *time.Time
+	Status     string
+	Recurrence string
+	Errors     []string
+}
+
+// TodoImportMapping describes where todo fields live in a third-party JSON
+// export. Each value is a dot separated path: Items points at the array of
+// records from the document root (empty when the root is the array), the
+// others are relative to each record. Unset paths are not imported.
+type TodoImportMapping struct {
+	Items      string `json:"items"`
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	CreatedAt  string `json:"created_at"`
+	DueAt      string `json:"due_at"`
+	Status     string `json:"status"`
+	Recurrence string `json:"recurrence"`
+}
+
+type TodoImportOptions struct {
+	DryRun    bool
+	DedupeKey string
+}
+
+type TodoImportRowResult struct {
+	Row    int      `json:"row"`
+	Status string   `json:"status"`
+	ID     string   `json:"id,omitempty"`
+	Title  string   `json:"title,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type TodoImportReport struct {
+	DryRun     bool                  `json:"dry_run"`
+	Total      int                   `json:"total"`
+	Created    int                   `json:"created"`
+	Duplicates int                   `json:"duplicates"`
+	Invalid    int                   `json:"invalid"`
+	Rows       []TodoImportRowResult `json:"rows"`
+}
@@ -0,0 +1,7 @@
+package types
+
+// LogLevel is the level the app currently logs at, one of logrus' level
+// names.
+type LogLevel struct {
+	Level string `json:"level" binding:"required"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"time"
+)
+
+// Session is a user logged in through the OpenID provider. Requests carry it
+// as a signed bearer token, which is only valid in the workspace it was
+// issued for.
+type Session struct {
+	User        *User
+	WorkspaceID int
+	ExpiresAt   time.Time
+}
+
+type SessionResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"time"
+)
+
+type SyncStringField struct {
+	Value      string    `json:"value"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type SyncTimeField struct {
+	Value      *time.Time `json:"value"`
+	ModifiedAt time.Time  `json:"modified_at"`
+}
+
+// SyncTodoFields carries the fields a client changed while offline, each
+// with the time the client changed it. Omitted fields are left alone.
+type SyncTodoFields struct {
+	Title      *SyncStringField `json:"title"`
+	DueAt      *SyncTimeField   `json:"due_at"`
+	Status     *SyncStringField `json:"status"`
+	Recurrence *SyncStringField `json:"recurrence"`
+}
+
+// SyncChange is one client side change. A deletion sets Deleted and
+// DeletedAt, anything else creates or updates the todo with Fields.
+type SyncChange struct {
+	ID        string         `json:"id"`
+	Deleted   bool           `json:"deleted"`
+	DeletedAt *time.Time     `json:"deleted_at"`
+	Fields    SyncTodoFields `json:"fields"`
+}
+
+type SyncPushInput struct {
+	Changes []SyncChange `json:"changes" binding:"required"`
+}
+
+// SyncConflict reports a field where the server kept its own value because
+// it was modified later than the client's.
+type SyncConflict struct {
+	Field            string      `json:"field"`
+	ServerValue      interface{} `json:"server_value"`
+	ServerModifiedAt time.Time   `json:"server_modified_at"`
+}
+
+type SyncChangeResult struct {
+	ID        string         `json:"id"`
+	Status    string         `json:"status"`
+	Conflicts []SyncConflict `json:"conflicts,omitempty"`
+	Errors    []string       `json:"errors,omitempty"`
+	Todo      *TodoResponse  `json:"todo,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Results []SyncChangeResult `json:"results"`
+}
+
+type SyncPullResponse struct {
+	Token   string         `json:"token"`
+	Created []TodoResponse `json:"created"`
+	Updated []TodoResponse `json:"updated"`
+	Deleted []string       `json:"deleted"`
+	More    bool           `json:"more"`
+}
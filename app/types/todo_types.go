@@ -5,30 +5,127 @@ import (
 )
 
 type Config struct {
-	Env            string `mapstructure:"ENV"`
-	Port           string `mapstructure:"PORT"`
-	DBType         string `mapstructure:"DB_TYPE"`
-	DBHost         string `mapstructure:"DB_HOST"`
-	DBPort         int    `mapstructure:"DB_PORT"`
-	DBUser         string `mapstructure:"DB_USER"`
-	DBPass         string `mapstructure:"DB_PASS"`
-	DBName         string `mapstructure:"DB_NAME"`
-	MigrationsPath string `mapstructure:"MIGRATIONS_PATH"`
+	Env                       string        `mapstructure:"ENV"`
+	Port                      string        `mapstructure:"PORT"`
+	GRPCPort                  string        `mapstructure:"GRPC_PORT"`
+	DBType                    string        `mapstructure:"DB_TYPE"`
+	DBHost                    string        `mapstructure:"DB_HOST"`
+	DBPort                    int           `mapstructure:"DB_PORT"`
+	DBUser                    string        `mapstructure:"DB_USER"`
+	DBPass                    string        `mapstructure:"DB_PASS" secret:"true"`
+	DBName                    string        `mapstructure:"DB_NAME"`
+	DBURL                     string        `mapstructure:"DB_URL" secret:"true"`
+	DBSSLMode                 string        `mapstructure:"DB_SSL_MODE"`
+	DBSSLRootCert             string        `mapstructure:"DB_SSL_ROOT_CERT"`
+	DBSSLCert                 string        `mapstructure:"DB_SSL_CERT"`
+	DBSSLKey                  string        `mapstructure:"DB_SSL_KEY"`
+	DBStatementTimeout        time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT"`
+	DBApplicationName         string        `mapstructure:"DB_APPLICATION_NAME"`
+	DBMaxOpenConns            int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns            int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime         time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime         time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+	DBConnectAttempts         int           `mapstructure:"DB_CONNECT_ATTEMPTS"`
+	DBConnectBackoff          time.Duration `mapstructure:"DB_CONNECT_BACKOFF"`
+	FileStorePath             string        `mapstructure:"FILE_STORE_PATH"`
+	FileStoreSync             string        `mapstructure:"FILE_STORE_SYNC"`
+	FileStoreSyncInterval     time.Duration `mapstructure:"FILE_STORE_SYNC_INTERVAL"`
+	FileStoreCompactAfter     int           `mapstructure:"FILE_STORE_COMPACT_AFTER"`
+	FileStoreSnapshotInterval time.Duration `mapstructure:"FILE_STORE_SNAPSHOT_INTERVAL"`
+	TodoCacheSize             int           `mapstructure:"TODO_CACHE_SIZE"`
+	TodoCacheTTL              time.Duration `mapstructure:"TODO_CACHE_TTL"`
+	TodoCacheChannel          string        `mapstructure:"TODO_CACHE_CHANNEL"`
+	MigrationsPath            string        `mapstructure:"MIGRATIONS_PATH"`
+	AutoMigrate               bool          `mapstructure:"AUTO_MIGRATE"`
+	SwaggerUI                 bool          `mapstructure:"SWAGGER_UI"`
+	ValidationStrict          bool          `mapstructure:"VALIDATION_STRICT"`
+	AuthRequired              bool          `mapstructure:"AUTH_REQUIRED"`
+	WorkspaceDomain           string        `mapstructure:"WORKSPACE_DOMAIN"`
+	OIDCIssuer                string        `mapstructure:"OIDC_ISSUER"`
+	OIDCClientID              string        `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret          string        `mapstructure:"OIDC_CLIENT_SECRET" secret:"true"`
+	OIDCRedirectURL           string        `mapstructure:"OIDC_REDIRECT_URL"`
+	OIDCScopes                string        `mapstructure:"OIDC_SCOPES"`
+	SessionSecret             string        `mapstructure:"SESSION_SECRET" secret:"true"`
+	SessionTTL                time.Duration `mapstructure:"SESSION_TTL"`
+	RateLimits                string        `mapstructure:"RATE_LIMITS"`
+	RateLimitStore            string        `mapstructure:"RATE_LIMIT_STORE"`
+	TrustedProxies            string        `mapstructure:"TRUSTED_PROXIES"`
+	TraceExporter             string        `mapstructure:"TRACE_EXPORTER"`
+	TraceOTLPEndpoint         string        `mapstructure:"TRACE_OTLP_ENDPOINT"`
+	TraceSampleRatio          float64       `mapstructure:"TRACE_SAMPLE_RATIO"`
+	TraceServiceName          string        `mapstructure:"TRACE_SERVICE_NAME"`
+	LogLevel                  string        `mapstructure:"LOG_LEVEL"`
+	LogFormat                 string        `mapstructure:"LOG_FORMAT"`
+	LogReportCaller           bool          `mapstructure:"LOG_REPORT_CALLER"`
+	LogOutput                 string        `mapstructure:"LOG_OUTPUT"`
+	LogSampleInitial          int           `mapstructure:"LOG_SAMPLE_INITIAL"`
+	LogSampleThereafter       int           `mapstructure:"LOG_SAMPLE_THEREAFTER"`
+	LogRedactFields           string        `mapstructure:"LOG_REDACT_FIELDS"`
+	WebhookPollInterval       time.Duration `mapstructure:"WEBHOOK_POLL_INTERVAL"`
+	WebhookTimeout            time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts        int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookBaseBackoff        time.Duration `mapstructure:"WEBHOOK_BASE_BACKOFF"`
+	WebhookMaxBackoff         time.Duration `mapstructure:"WEBHOOK_MAX_BACKOFF"`
 }
 
 type Todo struct {
-	ID         int       `json:"id"`
-	ExternalID string    `json:"external_id"`
-	Title      string    `json:"title"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         int        `json:"id"`
+	ExternalID string     `json:"external_id"`
+	Title      string     `json:"title"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DueAt      *time.Time `json:"due_at"`
+	Status     string     `json:"status"`
+	Recurrence string     `json:"recurrence"`
+	ListID     string     `json:"list_id"`
 }
 
 type TodoResponse struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	ListID     string     `json:"list_id,omitempty"`
 }
 
 type TodoInput struct {
 	Title string `json:"title" binding:"required"`
 }
+
+// TodoFilter narrows a todo listing. Zero values mean "no constraint";
+// Limit 0 returns every matching row.
+type TodoFilter struct {
+	TitleContains string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	ListID        string
+	Limit         int
+	Offset        int
+}
+
+type TodoEvent struct {
+	WorkspaceID int
+	Type        string
+	ID          string
+	Todo        *Todo
+	// Viewers holds the IDs of the users who may see the todo, looked up as
+	// it is published, or before it is deleted. It is nil when it was not
+	// looked up, on the file store or with nobody subscribed; only
+	// subscribers not acting for a user get the event then.
+	Viewers map[int]bool
+}
+
+// TodoChanges lists what happened to todos after a point in the change log.
+// Created and Updated hold the current state of todos inserted or modified
+// since, Deleted the IDs of those removed, and Seq the position to resume
+// from. A todo created and then updated is only listed as created. More
+// reports that the log goes on past Seq, the changes having been cut short.
+type TodoChanges struct {
+	Created []Todo
+	Updated []Todo
+	Deleted []string
+	Seq     int64
+	More    bool
+}
@@ -0,0 +1,31 @@
+package types
+
+import "encoding/json"
+
+// WebSocketClientMessage is sent by clients over /ws. Type is one of
+// subscribe, unsubscribe, create, update or delete; CorrelationID is echoed
+// back on the matching ack or error.
+type WebSocketClientMessage struct {
+	Type          string          `json:"type"`
+	CorrelationID string          `json:"correlation_id"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+type WebSocketTodoPayload struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// WebSocketServerMessage is sent by the server. Type is ack, error or event.
+type WebSocketServerMessage struct {
+	Type          string          `json:"type"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Event         string          `json:"event,omitempty"`
+	Payload       interface{}     `json:"payload,omitempty"`
+	Error         *WebSocketError `json:"error,omitempty"`
+}
+
+type WebSocketError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
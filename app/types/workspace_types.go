@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+)
+
+// Workspace is a tenant. Every other row belongs to exactly one workspace,
+// and requests only ever see the rows of theirs. Slug names it in subdomains,
+// the X-Workspace header and token claims.
+type Workspace struct {
+	ID         int
+	ExternalID string
+	Slug       string
+	Name       string
+	CreatedAt  time.Time
+}
+
+type WorkspaceResponse struct {
+	ID        string    `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceCreatedResponse carries the first API key of a new workspace,
+// which has every scope and cannot be retrieved later.
+type WorkspaceCreatedResponse struct {
+	WorkspaceResponse
+	Key string `json:"key"`
+}
+
+type WorkspaceInput struct {
+	Slug string `json:"slug" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// FakeOIDCProvider is an in-process OpenID provider for tests. Its authorize
+// endpoint logs in User straight away and redirects back with a code, which
+// the token endpoint only redeems with the matching PKCE verifier.
+type FakeOIDCProvider struct {
+	Server   *httptest.Server
+	Key      *rsa.PrivateKey
+	KeyID    string
+	ClientID string
+	User     FakeOIDCUser
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+type FakeOIDCUser struct {
+	Subject string
+	Email   string
+	Name    string
+}
+
+type fakeAuthorization struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+	user        FakeOIDCUser
+}
+
+func NewFakeOIDCProvider(clientID string) *FakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	provider := &FakeOIDCProvider{
+		Key:      key,
+		KeyID:    "test-key",
+		ClientID: clientID,
+		User:     FakeOIDCUser{Subject: "fake-subject", Email: "sso@example.com", Name: "Sam Sso"},
+		codes:    make(map[string]fakeAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/authorize", provider.authorize)
+	mux.HandleFunc("/token", provider.token)
+	mux.HandleFunc("/jwks", provider.jwks)
+
+	provider.Server = httptest.NewServer(mux)
+
+	return provider
+}
+
+func (p *FakeOIDCProvider) Issuer() string {
+	return p.Server.URL
+}
+
+func (p *FakeOIDCProvider) Close() {
+	p.Server.Close()
+}
+
+// SignIDToken signs claims as an ID token with the provider's key.
+func (p *FakeOIDCProvider) SignIDToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": p.KeyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// IDTokenClaims returns valid claims for user, for tests to tamper with.
+func (p *FakeOIDCProvider) IDTokenClaims(user FakeOIDCUser, nonce string) map[string]interface{} {
+	now := time.Now()
+
+	return map[string]interface{}{
+		"iss":            p.Issuer(),
+		"sub":            user.Subject,
+		"aud":            p.ClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          user.Email,
+		"email_verified": true,
+		"name":           user.Name,
+	}
+}
+
+func (p *FakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/jwks",
+	})
+}
+
+func (p *FakeOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != p.ClientID || query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+
+		return
+	}
+
+	code := make([]byte, 16)
+	rand.Read(code)
+
+	p.mu.Lock()
+	p.codes[base64.RawURLEncoding.EncodeToString(code)] = fakeAuthorization{
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		user:        p.User,
+	}
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", base64.RawURLEncoding.EncodeToString(code))
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *FakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != authorization.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"id_token":     p.SignIDToken(p.IDTokenClaims(authorization.user, authorization.nonce)),
+	})
+}
+
+func (p *FakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.Key.E)).Bytes()),
+		}},
+	})
+}
@@ -4,8 +4,118 @@ import "todo-app/app/types"
 
 func MapTodoResponse(todo *types.Todo) *types.TodoResponse {
 	return &types.TodoResponse{
-		ID:        todo.ExternalID,
-		Title:     todo.Title,
-		CreatedAt: todo.CreatedAt,
+		ID:         todo.ExternalID,
+		Title:      todo.Title,
+		CreatedAt:  todo.CreatedAt,
+		DueAt:      todo.DueAt,
+		Status:     todo.Status,
+		Recurrence: todo.Recurrence,
+		ListID:     todo.ListID,
+	}
+}
+
+func MapWebhookResponse(webhook *types.Webhook) *types.WebhookResponse {
+	return &types.WebhookResponse{
+		ID:         webhook.ExternalID,
+		URL:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		Active:     webhook.Active,
+		CreatedAt:  webhook.CreatedAt,
+	}
+}
+
+func MapWebhookDeliveryResponse(delivery *types.WebhookDelivery) *types.WebhookDeliveryResponse {
+	return &types.WebhookDeliveryResponse{
+		ID:             delivery.ExternalID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+func MapCalendarFeedResponse(feed *types.CalendarFeed) *types.CalendarFeedResponse {
+	return &types.CalendarFeedResponse{
+		ID:         feed.ExternalID,
+		Name:       feed.Name,
+		LastUsedAt: feed.LastUsedAt,
+		CreatedAt:  feed.CreatedAt,
+	}
+}
+
+func MapAPIKeyResponse(key *types.APIKey) *types.APIKeyResponse {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	response := &types.APIKeyResponse{
+		ID:         key.ExternalID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+
+	if key.User != nil {
+		response.UserID = key.User.ExternalID
+	}
+
+	return response
+}
+
+func MapTodoListResponse(list *types.TodoList) *types.TodoListResponse {
+	return &types.TodoListResponse{
+		ID:        list.ExternalID,
+		Name:      list.Name,
+		Role:      list.Role,
+		CreatedAt: list.CreatedAt,
+	}
+}
+
+func MapListMemberResponse(member *types.ListMember) *types.ListMemberResponse {
+	return &types.ListMemberResponse{
+		UserID: member.User.ExternalID,
+		Email:  member.User.Email,
+		Name:   member.User.Name,
+		Role:   member.Role,
+	}
+}
+
+func MapInvitationResponse(invitation *types.Invitation) *types.InvitationResponse {
+	return &types.InvitationResponse{
+		ID:         invitation.ExternalID,
+		ListID:     invitation.ListID,
+		TodoID:     invitation.TodoID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		AcceptedAt: invitation.AcceptedAt,
+		CreatedAt:  invitation.CreatedAt,
+	}
+}
+
+func MapWorkspaceResponse(workspace *types.Workspace) *types.WorkspaceResponse {
+	return &types.WorkspaceResponse{
+		ID:        workspace.ExternalID,
+		Slug:      workspace.Slug,
+		Name:      workspace.Name,
+		CreatedAt: workspace.CreatedAt,
+	}
+}
+
+func MapSessionResponse(session *types.Session, token string) *types.SessionResponse {
+	return &types.SessionResponse{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		UserID:    session.User.ExternalID,
+		Email:     session.User.Email,
+		Name:      session.User.Name,
 	}
 }
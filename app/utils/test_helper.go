@@ -5,20 +5,21 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"path/filepath"
-	"runtime"
 	"time"
 
+	"todo-app/app/migrations"
+	"todo-app/app/sqlite"
+	"todo-app/app/telemetry"
 	"todo-app/app/types"
 
 	"github.com/docker/go-connections/nat"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
-
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
 )
 
 type TestDB struct {
@@ -58,7 +59,7 @@ func CreateTestDB(testData []types.Todo) (*TestDB, error) {
 		return nil, err
 	}
 
-	if err = runMigrations(db, "migrations"); err != nil {
+	if err = runMigrations(db); err != nil {
 		return nil, err
 	}
 
@@ -72,6 +73,34 @@ func CreateTestDB(testData []types.Todo) (*TestDB, error) {
 	}, nil
 }
 
+// CreateSQLiteTestDB creates a migrated and seeded SQLite database in the
+// file at path.
+func CreateSQLiteTestDB(path string, testData []types.Todo) (*sql.DB, error) {
+	driverName, err := telemetry.TracedDriver(sqlite.DriverName)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, "file:"+path+"?_foreign_keys=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = runSQLiteMigrations(db); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	if err := SeedDB(db, testData); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return db, nil
+}
+
 func (t *TestDB) CleanUp() {
 	t.DbInstance.Close()
 	if err := t.Container.Terminate(context.Background()); err != nil {
@@ -83,7 +112,14 @@ func (t *TestDB) CleanUp() {
 func setupDBConnection(host string, port string) (*sql.DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=postgres password=postgres dbname=postgres sslmode=disable", host, port)
 
-	db, err := sql.Open("postgres", connStr)
+	// Tests use the traced driver like the app does; without a tracer
+	// provider its spans are no-ops.
+	driverName, err := telemetry.TracedDriver("postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, connStr)
 
 	// TODO - Fix this
 	time.Sleep(time.Second)
@@ -137,34 +173,40 @@ func createTestContainer(ctx context.Context) (container testcontainers.Containe
 	return container, host, port, nil
 }
 
-func runMigrations(db *sql.DB, migrationsPath string) error {
+func runMigrations(db *sql.DB) error {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
-
-	_, path, _, ok := runtime.Caller(0)
-	if !ok {
-		return fmt.Errorf("failed to get path")
+	if err != nil {
+		return err
 	}
 
-	pathToMigrationFiles := filepath.Join(filepath.Dir(path), "..", "migrations")
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return err
+	}
 
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
 	if err != nil {
-		log.Fatalf("Failed to create new database driver instance: %v", err)
 		return err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		fmt.Sprintf("file:%s", pathToMigrationFiles),
-		"postgres", driver,
-	)
+	return m.Up()
+}
+
+func runSQLiteMigrations(db *sql.DB) error {
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		return err
+	}
 
+	source, err := iofs.New(migrations.SQLite, "sqlite")
 	if err != nil {
-		fmt.Println(err.Error())
 		return err
 	}
 
-	if err := m.Up(); err != nil {
+	m, err := migrate.NewWithInstance("iofs", source, sqlite.DriverName, driver)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return m.Up()
 }
@@ -1,31 +1,275 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
 	"todo-app/app/constant"
+	"todo-app/app/filestore"
 	"todo-app/app/types"
 
-	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-func LoadConfig(path string) (config *types.Config) {
-	viper.AddConfigPath(path)
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
+// ErrHelp is returned by LoadConfig when the flags ask for usage, which has
+// been printed by then.
+var ErrHelp = pflag.ErrHelp
+
+// masked replaces secrets in PrintConfig.
+const masked = "********"
+
+var defaults = map[string]interface{}{
+	"ENV":                          "development",
+	"PORT":                         "8080",
+	"GRPC_PORT":                    "9090",
+	"DB_TYPE":                      constant.DBTypePostgres,
+	"DB_HOST":                      "localhost",
+	"DB_PORT":                      5432,
+	"DB_USER":                      "postgres",
+	"DB_NAME":                      "postgres",
+	"DB_SSL_MODE":                  constant.DBSSLModeDisable,
+	"DB_APPLICATION_NAME":          "todo-app",
+	"DB_MAX_OPEN_CONNS":            25,
+	"DB_MAX_IDLE_CONNS":            10,
+	"DB_CONN_MAX_LIFETIME":         "30m",
+	"DB_CONN_MAX_IDLE_TIME":        "5m",
+	"DB_CONNECT_ATTEMPTS":          10,
+	"DB_CONNECT_BACKOFF":           "1s",
+	"FILE_STORE_PATH":              "data",
+	"FILE_STORE_SYNC":              string(filestore.SyncAlways),
+	"FILE_STORE_SYNC_INTERVAL":     "1s",
+	"FILE_STORE_COMPACT_AFTER":     10000,
+	"FILE_STORE_SNAPSHOT_INTERVAL": "10m",
+	"TODO_CACHE_SIZE":              0,
+	"TODO_CACHE_TTL":               "30s",
+	"MIGRATIONS_PATH":              "app/migrations",
+	"AUTO_MIGRATE":                 true,
+	"WEBHOOK_POLL_INTERVAL":        "2s",
+	"WEBHOOK_TIMEOUT":              "10s",
+	"WEBHOOK_MAX_ATTEMPTS":         8,
+	"WEBHOOK_BASE_BACKOFF":         "5s",
+	"WEBHOOK_MAX_BACKOFF":          "1h",
+	"OIDC_SCOPES":                  "openid email profile",
+	"SESSION_TTL":                  "12h",
+	"RATE_LIMITS":                  "default=600/1m,POST /todos=60/1m",
+	"RATE_LIMIT_STORE":             constant.RateLimitStoreMemory,
+	"TRACE_EXPORTER":               "none",
+	"TRACE_SAMPLE_RATIO":           1.0,
+	"TRACE_SERVICE_NAME":           "todo-app",
+	"LOG_LEVEL":                    "info",
+	"LOG_FORMAT":                   "json",
+	"LOG_REPORT_CALLER":            true,
+	"LOG_OUTPUT":                   "stderr",
+	"LOG_REDACT_FIELDS":            constant.LogRedactFieldsDefault,
+}
+
+// setting is a field of types.Config, named by its mapstructure tag.
+type setting struct {
+	key    string
+	field  reflect.StructField
+	secret bool
+}
+
+func settings() []setting {
+	configType := reflect.TypeOf(types.Config{})
+	all := make([]setting, configType.NumField())
+
+	for i := range all {
+		field := configType.Field(i)
+
+		all[i] = setting{key: field.Tag.Get("mapstructure"), field: field, secret: field.Tag.Get("secret") == "true"}
+	}
+
+	return all
+}
+
+// flagName turns DB_HOST into db-host.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// LoadConfig builds the config in layers, each overriding the ones before:
+// defaults, a config file, environment variables, then the flags in args.
+//
+// The config file is the one named by --config or CONFIG_FILE, in env, YAML
+// or TOML format going by its extension, or else .env in path if there is
+// one. Every setting KEY can also be read from the file named by KEY_FILE,
+// to pass secrets as mounted files.
+//
+// The result is validated, and all problems are returned at once. The
+// returned bool reports whether --print-config was given.
+func LoadConfig(path string, args []string) (*types.Config, bool, error) {
+	v := viper.New()
+	flags := pflag.NewFlagSet("todo-app", pflag.ContinueOnError)
+
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "config file in env, YAML or TOML format (CONFIG_FILE)")
+	printConfig := flags.Bool("print-config", false, "print the resulting config with secrets masked and exit")
+
+	for _, s := range settings() {
+		if value, ok := defaults[s.key]; ok {
+			v.SetDefault(s.key, value)
+		}
+
+		if err := v.BindEnv(s.key); err != nil {
+			return nil, false, err
+		}
+
+		addFlag(flags, s)
+
+		if err := v.BindPFlag(s.key, flags.Lookup(flagName(s.key))); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	var problems []error
+
+	if err := readConfigFile(v, path, *configFile); err != nil {
+		problems = append(problems, err)
+	}
+
+	problems = append(problems, readSecretFiles(v, flags)...)
+
+	var config types.Config
+
+	if err := v.Unmarshal(&config); err != nil {
+		problems = append(problems, err)
+	}
+
+	// Settings that failed to decode are validated as zero values, which
+	// may repeat their problem, but reports all others along with them.
+	if err := Validate(&config); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(problems) > 0 {
+		return nil, false, errors.Join(problems...)
+	}
+
+	return &config, *printConfig, nil
+}
+
+func addFlag(flags *pflag.FlagSet, s setting) {
+	name := flagName(s.key)
+	usage := "overrides " + s.key
+
+	switch s.field.Type {
+	case reflect.TypeOf(time.Duration(0)):
+		flags.Duration(name, 0, usage)
+	case reflect.TypeOf(0):
+		flags.Int(name, 0, usage)
+	case reflect.TypeOf(0.0):
+		flags.Float64(name, 0, usage)
+	case reflect.TypeOf(false):
+		flags.Bool(name, false, usage)
+	default:
+		flags.String(name, "", usage)
+	}
+}
+
+// readConfigFile reads the config file named by file, or else .env in path
+// if it exists. Keys the config does not know are rejected, as they are
+// likely typos.
+func readConfigFile(v *viper.Viper, path string, file string) error {
+	if file == "" {
+		file = filepath.Join(path, ".env")
+
+		if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	// The file only knows the settings it sets, unlike v, which has
+	// defaults, environment variables and flags for all of them.
+	fileOnly := viper.New()
+	fileOnly.SetConfigFile(file)
 
-	if err := viper.ReadInConfig(); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"event": constant.ConfigLoadLogEventErrorKey,
-			"error": err.Error(),
-		}).Fatal("Failed to read config file")
+	if strings.HasPrefix(filepath.Base(file), ".env") {
+		fileOnly.SetConfigType("env")
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"event": constant.ConfigLoadLogEventErrorKey,
-			"error": err.Error(),
-		}).Fatal("Failed to unmarshal config file")
+	if err := fileOnly.ReadInConfig(); err != nil {
+		return fmt.Errorf("config file %s: %w", file, err)
 	}
 
-	return config
+	known := map[string]bool{}
+
+	for _, s := range settings() {
+		known[strings.ToLower(s.key)] = true
+	}
+
+	var unknown []string
+
+	for _, key := range fileOnly.AllKeys() {
+		if !known[key] {
+			unknown = append(unknown, strings.ToUpper(key))
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("config file %s: unknown settings %s", file, strings.Join(unknown, ", "))
+	}
+
+	return v.MergeConfigMap(fileOnly.AllSettings())
+}
+
+// readSecretFiles sets every setting KEY whose KEY_FILE names a file to the
+// file's contents, unless a flag sets it.
+func readSecretFiles(v *viper.Viper, flags *pflag.FlagSet) []error {
+	var problems []error
+
+	for _, s := range settings() {
+		file := os.Getenv(s.key + "_FILE")
+
+		if file == "" || flags.Changed(flagName(s.key)) {
+			continue
+		}
+
+		if _, set := os.LookupEnv(s.key); set {
+			problems = append(problems, fmt.Errorf("%s and %s_FILE are both set", s.key, s.key))
+
+			continue
+		}
+
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s_FILE: %w", s.key, err))
+
+			continue
+		}
+
+		v.Set(s.key, strings.TrimRight(string(contents), "\r\n"))
+	}
+
+	return problems
+}
+
+// PrintConfig writes config to w in env format, with secrets masked.
+func PrintConfig(w io.Writer, config *types.Config) {
+	value := reflect.ValueOf(*config)
+
+	for i, s := range settings() {
+		formatted := fmt.Sprint(value.Field(i).Interface())
+
+		if s.secret && formatted != "" {
+			formatted = masked
+		}
+
+		if strings.ContainsAny(formatted, " \t\"'#$\\") {
+			formatted = strconv.Quote(formatted)
+		}
+
+		fmt.Fprintf(w, "%s=%s\n", s.key, formatted)
+	}
 }
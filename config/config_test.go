@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("It should run on defaults alone", func(t *testing.T) {
+		config, printConfig, err := LoadConfig(t.TempDir(), nil)
+
+		assert.NoError(t, err)
+		assert.False(t, printConfig)
+		assert.Equal(t, "8080", config.Port)
+		assert.Equal(t, "localhost", config.DBHost)
+		assert.Equal(t, 5432, config.DBPort)
+		assert.Equal(t, 12*time.Hour, config.SessionTTL)
+	})
+
+	t.Run("It should layer the file, environment and flags over the defaults", func(t *testing.T) {
+		dir := filepath.Dir(writeFile(t, ".env", "DB_HOST=file\nDB_NAME=file\nDB_USER=file\n"))
+
+		t.Setenv("DB_NAME", "env")
+		t.Setenv("DB_USER", "env")
+
+		config, _, err := LoadConfig(dir, []string{"--db-user", "flag", "--session-ttl", "1h"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "file", config.DBHost)
+		assert.Equal(t, "env", config.DBName)
+		assert.Equal(t, "flag", config.DBUser)
+		assert.Equal(t, time.Hour, config.SessionTTL)
+	})
+
+	t.Run("It should read YAML and TOML files", func(t *testing.T) {
+		yaml := writeFile(t, "config.yaml", "DB_HOST: yaml\nLOG_LEVEL: debug\n")
+		toml := writeFile(t, "config.toml", "DB_HOST = \"toml\"\nWEBHOOK_MAX_ATTEMPTS = 3\n")
+
+		config, _, err := LoadConfig(t.TempDir(), []string{"--config", yaml})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "yaml", config.DBHost)
+		assert.Equal(t, "debug", config.LogLevel)
+
+		t.Setenv("CONFIG_FILE", toml)
+
+		config, _, err = LoadConfig(t.TempDir(), nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "toml", config.DBHost)
+		assert.Equal(t, 3, config.WebhookMaxAttempts)
+	})
+
+	t.Run("It should read secrets from files", func(t *testing.T) {
+		t.Setenv("DB_PASS_FILE", writeFile(t, "db_pass", "s3cret\n"))
+
+		config, _, err := LoadConfig(t.TempDir(), nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", config.DBPass)
+
+		t.Setenv("DB_PASS", "plain")
+
+		_, _, err = LoadConfig(t.TempDir(), nil)
+
+		assert.ErrorContains(t, err, "DB_PASS and DB_PASS_FILE are both set")
+	})
+
+	t.Run("It should report every problem at once", func(t *testing.T) {
+		dir := filepath.Dir(writeFile(t, ".env", "DB_HOST=x\nDB_HSOT=typo\n"))
+
+		t.Setenv("LOG_FORMAT", "xml")
+		t.Setenv("OIDC_ISSUER", "https://login.example.com")
+		t.Setenv("PORT", "http")
+
+		_, _, err := LoadConfig(dir, []string{"--trace-sample-ratio", "2"})
+
+		assert.ErrorContains(t, err, "unknown settings DB_HSOT")
+		assert.ErrorContains(t, err, "LOG_FORMAT")
+		assert.ErrorContains(t, err, "TRACE_SAMPLE_RATIO")
+		assert.ErrorContains(t, err, "OIDC_CLIENT_ID")
+		assert.ErrorContains(t, err, "SESSION_SECRET")
+		assert.ErrorContains(t, err, `PORT: "http" is not a port`)
+	})
+
+	t.Run("It should only trust proxies given as IPs or CIDR ranges", func(t *testing.T) {
+		config, _, err := LoadConfig(t.TempDir(), []string{"--trusted-proxies", "10.0.0.0/8, 192.0.2.1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.0/8, 192.0.2.1", config.TrustedProxies)
+
+		_, _, err = LoadConfig(t.TempDir(), []string{"--trusted-proxies", "10.0.0.0/8,proxy.internal"})
+
+		assert.ErrorContains(t, err, "TRUSTED_PROXIES")
+	})
+}
+
+func TestValidateSQLite(t *testing.T) {
+	t.Run("It should not ask SQLite for a server", func(t *testing.T) {
+		t.Setenv("DB_HOST", "")
+		t.Setenv("DB_USER", "")
+
+		config, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "sqlite", "--db-name", "todo.db"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "sqlite", config.DBType)
+	})
+
+	t.Run("It should keep the Postgres rate limit store to Postgres", func(t *testing.T) {
+		_, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "sqlite", "--rate-limit-store", "postgres"})
+
+		assert.ErrorContains(t, err, "RATE_LIMIT_STORE")
+	})
+
+	t.Run("It should keep the todo cache channel to Postgres", func(t *testing.T) {
+		_, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "sqlite", "--todo-cache-size", "100", "--todo-cache-channel", "todo_cache"})
+
+		assert.ErrorContains(t, err, "TODO_CACHE_CHANNEL")
+
+		_, _, err = LoadConfig(t.TempDir(), []string{"--todo-cache-size", "100", "--todo-cache-channel", "todo_cache"})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestValidateFileStore(t *testing.T) {
+	t.Run("It should not ask the file store for a database", func(t *testing.T) {
+		t.Setenv("DB_HOST", "")
+		t.Setenv("DB_USER", "")
+
+		config, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "file", "--file-store-path", "/var/lib/todo"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/var/lib/todo", config.FileStorePath)
+		assert.Equal(t, "always", config.FileStoreSync)
+	})
+
+	t.Run("It should check the fsync policy and its interval", func(t *testing.T) {
+		_, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "file", "--file-store-sync", "sometimes"})
+		assert.ErrorContains(t, err, "FILE_STORE_SYNC")
+
+		_, _, err = LoadConfig(t.TempDir(), []string{"--db-type", "file", "--file-store-sync", "interval", "--file-store-sync-interval", "0s"})
+		assert.ErrorContains(t, err, "FILE_STORE_SYNC_INTERVAL")
+	})
+
+	t.Run("It should refuse the todo cache, which it has no use for", func(t *testing.T) {
+		_, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "file", "--todo-cache-size", "100"})
+
+		assert.ErrorContains(t, err, "TODO_CACHE_SIZE")
+	})
+
+	t.Run("It should refuse authentication, which needs a database", func(t *testing.T) {
+		_, _, err := LoadConfig(t.TempDir(), []string{"--db-type", "file", "--auth-required"})
+
+		assert.ErrorContains(t, err, "AUTH_REQUIRED")
+	})
+}
+
+func TestPrintConfig(t *testing.T) {
+	config, printConfig, err := LoadConfig(t.TempDir(), []string{"--print-config", "--db-pass", "hunter2"})
+
+	assert.NoError(t, err)
+	assert.True(t, printConfig)
+
+	var out bytes.Buffer
+	PrintConfig(&out, config)
+
+	assert.Contains(t, out.String(), "DB_PASS="+masked+"\n")
+	assert.Contains(t, out.String(), "SESSION_SECRET=\n")
+	assert.Contains(t, out.String(), "RATE_LIMITS=\"default=600/1m,POST /todos=60/1m\"\n")
+	assert.NotContains(t, out.String(), "hunter2")
+}
@@ -1,24 +1,37 @@
 package config
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 
 	"todo-app/app/constant"
+	"todo-app/app/telemetry"
 	"todo-app/app/types"
-
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
 )
 
+// ConnectToDB opens the database config describes, with its pool settings,
+// and waits for it to be reachable, retrying with backoff as it may still be
+// starting up alongside the app. It does not migrate it; see MigrateUp.
 func ConnectToDB(config *types.Config) *sql.DB {
-	cnt := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", config.DBHost, config.DBPort, config.DBUser, config.DBPass, config.DBName)
+	var db *sql.DB
+
+	dsn, err := dataSourceName(config)
+
+	if err == nil {
+		var driverName string
+
+		driverName, err = telemetry.TracedDriver(config.DBType)
+		if err == nil {
+			db, err = sql.Open(driverName, dsn)
+		}
+	}
 
-	db, err := sql.Open(config.DBType, cnt)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"event": constant.DbInitErrorEventKey,
@@ -26,42 +39,117 @@ func ConnectToDB(config *types.Config) *sql.DB {
 		}).Fatal("Failed to initialize database")
 	}
 
-	if err = db.Ping(); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"event": constant.DbInitErrorEventKey,
-			"error": err.Error(),
-		}).Fatal("Failed to ping database")
-	}
+	db.SetMaxOpenConns(config.DBMaxOpenConns)
+	db.SetMaxIdleConns(config.DBMaxIdleConns)
+	db.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.DBConnMaxIdleTime)
 
-	if err := runMigrations(db, config.MigrationsPath); err != nil {
+	if err = pingWithRetry(context.Background(), db, config.DBConnectAttempts, config.DBConnectBackoff); err != nil {
 		logrus.WithFields(logrus.Fields{
-			"event": "db_migrations_fail",
+			"event": constant.DbInitErrorEventKey,
 			"error": err.Error(),
-		}).Fatal("Failed to run migrations")
+		}).Fatal(constant.DbConnectFailMsg)
 	}
 
 	return db
 }
 
-func runMigrations(db *sql.DB, migrationsPath string) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// dataSourceName builds the connection string for config. For Postgres it is
+// the lib/pq one, from DB_URL if set and from the separate DB_* settings
+// otherwise, with the statement timeout and application name either way.
+func dataSourceName(config *types.Config) (string, error) {
+	if config.DBType == constant.DBTypeSQLite {
+		return sqliteDataSourceName(config), nil
+	}
+
+	var params []string
 
-	if err != nil {
-		return err
+	add := func(key string, value string) {
+		if value != "" {
+			params = append(params, key+"="+dsnValue(value))
+		}
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://"+migrationsPath,
-		"postgres", driver,
-	)
+	if config.DBURL != "" {
+		dsn := config.DBURL
 
-	if err != nil {
-		return err
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			var err error
+
+			if dsn, err = pq.ParseURL(dsn); err != nil {
+				return "", err
+			}
+		}
+
+		params = append(params, dsn)
+	} else {
+		add("host", config.DBHost)
+		add("port", strconv.Itoa(config.DBPort))
+		add("user", config.DBUser)
+		add("password", config.DBPass)
+		add("dbname", config.DBName)
+		add("sslmode", config.DBSSLMode)
+		add("sslrootcert", config.DBSSLRootCert)
+		add("sslcert", config.DBSSLCert)
+		add("sslkey", config.DBSSLKey)
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return err
+	add("application_name", config.DBApplicationName)
+
+	// lib/pq passes parameters it does not know on to the server, which
+	// takes the timeout in milliseconds.
+	if config.DBStatementTimeout > 0 {
+		add("statement_timeout", strconv.FormatInt(config.DBStatementTimeout.Milliseconds(), 10))
 	}
 
-	return nil
+	return strings.Join(params, " "), nil
+}
+
+// sqliteDataSourceName builds the go-sqlite3 connection string for config:
+// DB_URL as it is if set, and otherwise the file DB_NAME names, in WAL mode so
+// readers do not block the writer. Transactions take the write lock when they
+// begin, as upgrading a read lock later fails rather than waits when another
+// connection is writing, and writers wait for each other up to a timeout.
+func sqliteDataSourceName(config *types.Config) string {
+	if config.DBURL != "" {
+		return config.DBURL
+	}
+
+	return "file:" + config.DBName + "?_foreign_keys=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+}
+
+// dsnValue quotes value for a key=value connection string if it needs it.
+func dsnValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// pingWithRetry pings db up to attempts times, doubling the wait between
+// attempts from backoff up to constant.DbConnectMaxBackoff.
+func pingWithRetry(ctx context.Context, db *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil || attempt >= attempts {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"event":   constant.DbConnectLogEventKey,
+			"attempt": attempt,
+			"backoff": backoff.String(),
+			"error":   err.Error(),
+		}).Warn(constant.DbConnectRetryMsg)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, constant.DbConnectMaxBackoff)
+	}
 }
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"todo-app/app/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyDriver refuses the first failures connections.
+type flakyDriver struct {
+	failures *int
+}
+
+type flakyConn struct{ driver.Conn }
+
+func (d flakyDriver) Open(string) (driver.Conn, error) {
+	if *d.failures > 0 {
+		*d.failures--
+
+		return nil, errors.New("connection refused")
+	}
+
+	return flakyConn{}, nil
+}
+
+func (flakyConn) Close() error { return nil }
+
+var flakyFailures int
+
+func init() {
+	sql.Register("flaky", flakyDriver{failures: &flakyFailures})
+}
+
+func TestDataSourceName(t *testing.T) {
+	t.Run("It should build a connection string from the separate settings", func(t *testing.T) {
+		dsn, err := dataSourceName(&types.Config{
+			DBHost:             "db",
+			DBPort:             5432,
+			DBUser:             "todo",
+			DBPass:             "it's secret",
+			DBName:             "todos",
+			DBSSLMode:          "verify-full",
+			DBSSLRootCert:      "/certs/ca.pem",
+			DBApplicationName:  "todo-app",
+			DBStatementTimeout: 5 * time.Second,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, `host=db port=5432 user=todo password='it\'s secret' dbname=todos sslmode=verify-full sslrootcert=/certs/ca.pem application_name=todo-app statement_timeout=5000`, dsn)
+	})
+
+	t.Run("It should take a URL instead", func(t *testing.T) {
+		dsn, err := dataSourceName(&types.Config{DBURL: "postgres://todo:pw@db:5433/todos?sslmode=require", DBHost: "ignored", DBApplicationName: "worker"})
+
+		assert.NoError(t, err)
+		assert.Contains(t, dsn, "host='db'")
+		assert.Contains(t, dsn, "port='5433'")
+		assert.Contains(t, dsn, "sslmode='require'")
+		assert.Contains(t, dsn, "application_name=worker")
+		assert.NotContains(t, dsn, "ignored")
+	})
+
+	t.Run("It should reject malformed URLs", func(t *testing.T) {
+		_, err := dataSourceName(&types.Config{DBURL: "postgres://%zz"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should open the SQLite file DB_NAME names", func(t *testing.T) {
+		dsn, err := dataSourceName(&types.Config{DBType: "sqlite", DBName: "/data/todo.db", DBHost: "ignored"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "file:/data/todo.db?_foreign_keys=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate", dsn)
+	})
+}
+
+func TestPingWithRetry(t *testing.T) {
+	db, _ := sql.Open("flaky", "")
+	defer db.Close()
+
+	t.Run("It should retry until the database is reachable", func(t *testing.T) {
+		flakyFailures = 2
+
+		assert.NoError(t, pingWithRetry(context.Background(), db, 3, time.Millisecond))
+	})
+
+	t.Run("It should give up after the last attempt", func(t *testing.T) {
+		db.SetMaxIdleConns(0)
+		flakyFailures = 3
+
+		assert.ErrorContains(t, pingWithRetry(context.Background(), db, 2, time.Millisecond), "connection refused")
+		assert.Equal(t, 1, flakyFailures)
+	})
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/filestore"
+	"todo-app/app/types"
+)
+
+// OpenFileStore opens the file store config describes for DB_TYPE=file,
+// recovering whatever the last run left in it.
+func OpenFileStore(config *types.Config) *filestore.Store {
+	store, err := filestore.Open(config.FileStorePath, filestore.Options{
+		Sync:             filestore.SyncPolicy(config.FileStoreSync),
+		SyncInterval:     config.FileStoreSyncInterval,
+		CompactAfter:     config.FileStoreCompactAfter,
+		SnapshotInterval: config.FileStoreSnapshotInterval,
+		OnError: func(err error) {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.FileStoreLogEventErrorKey,
+				"error": err.Error(),
+			}).Error(constant.FileStoreFailMsg)
+		},
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.DbInitErrorEventKey,
+			"path":  config.FileStorePath,
+			"error": err.Error(),
+		}).Fatal("Failed to open file store")
+	}
+
+	stats := store.Stats()
+	fields := logrus.Fields{
+		"event":       constant.FileStoreLogEventKey,
+		"path":        config.FileStorePath,
+		"seq":         stats.Seq,
+		"keys":        stats.Keys,
+		"log_commits": stats.LogCommits,
+	}
+
+	if stats.Truncated > 0 {
+		fields["truncated_bytes"] = stats.Truncated
+		logrus.WithFields(fields).Warn("File store opened, dropping a write cut short by a crash")
+	} else {
+		logrus.WithFields(fields).Info("File store opened")
+	}
+
+	return store
+}
@@ -1,11 +1,21 @@
 package config
 
 import (
+	"todo-app/app/constant"
+	"todo-app/app/logging"
+	"todo-app/app/telemetry"
+	"todo-app/app/types"
+
 	"github.com/sirupsen/logrus"
 )
 
-func InitLogger() {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
-	logrus.SetLevel(logrus.InfoLevel)
-	logrus.SetReportCaller(true)
+func InitLogger(config *types.Config) {
+	if err := logging.Configure(logrus.StandardLogger(), config); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.ConfigLoadLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to configure logging")
+	}
+
+	logrus.AddHook(telemetry.LogHook{})
 }
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/migrations"
+)
+
+var (
+	migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+)
+
+// Migrate runs fn with a migrator for db, a database of type dbType, and the
+// embedded migrations for that type. On Postgres it holds an advisory lock for
+// the whole run, so replicas that start at once or an operator running the
+// migrate command wait for each other instead of racing to apply the same
+// migrations. SQLite allows a single writer anyway.
+func Migrate(ctx context.Context, db *sql.DB, dbType string, fn func(m *migrate.Migrate) error) error {
+	if dbType == constant.DBTypeSQLite {
+		driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+		if err != nil {
+			return err
+		}
+
+		return runMigrator(driver, migrations.SQLite, "sqlite", dbType, fn)
+	}
+
+	// Session advisory locks belong to a connection, so the lock and the
+	// migrator share one.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err := lockMigrations(ctx, conn); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", constant.MigrationLockID)
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		return err
+	}
+
+	return runMigrator(driver, migrations.FS, ".", dbType, fn)
+}
+
+// runMigrator runs fn with a migrator for driver and the migrations in dir of
+// files.
+func runMigrator(driver database.Driver, files fs.FS, dir string, dbType string, fn func(m *migrate.Migrate) error) error {
+	source, err := iofs.New(files, dir)
+	if err != nil {
+		return err
+	}
+
+	// The migrator is not closed, as that would close the connection, which
+	// belongs to the caller; on Postgres the lock is released on it first.
+	m, err := migrate.NewWithInstance("iofs", source, dbType, driver)
+	if err != nil {
+		return err
+	}
+
+	return fn(m)
+}
+
+func lockMigrations(ctx context.Context, conn *sql.Conn) error {
+	var locked bool
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", constant.MigrationLockID).Scan(&locked); err != nil {
+		return err
+	}
+
+	if locked {
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event": constant.MigrateLogEventKey,
+	}).Info("Waiting for another migrator to finish")
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", constant.MigrationLockID)
+
+	return err
+}
+
+// MigrateUp applies all pending migrations to db, a database of type dbType.
+func MigrateUp(ctx context.Context, db *sql.DB, dbType string) error {
+	return Migrate(ctx, db, dbType, func(m *migrate.Migrate) error {
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// CreateMigration writes an empty pair of up and down migrations called name
+// to dir, numbered after the last one there, and returns their paths.
+func CreateMigration(dir string, name string) ([]string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("migration name %q must be lowercase letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	last := 0
+
+	for _, entry := range entries {
+		if match := migrationFilePattern.FindStringSubmatch(entry.Name()); match != nil {
+			if number, _ := strconv.Atoi(match[1]); number > last {
+				last = number
+			}
+		}
+	}
+
+	var paths []string
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", last+1, name, direction))
+
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+
+		file.Close()
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"todo-app/app/constant"
+	"todo-app/app/migrations"
+	"todo-app/app/sqlite"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "000009_user_identities.up.sql"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "000010_rate_limit_buckets.up.sql"), nil, 0o644)
+
+	t.Run("It should number the migration after the last one", func(t *testing.T) {
+		paths, err := CreateMigration(dir, "add_tags")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "000011_add_tags.up.sql"),
+			filepath.Join(dir, "000011_add_tags.down.sql"),
+		}, paths)
+	})
+
+	t.Run("It should reject names that do not make good file names", func(t *testing.T) {
+		_, err := CreateMigration(dir, "Add tags!")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	files, err := migrations.FS.ReadDir(".")
+
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		assert.Regexp(t, migrationFilePattern, file.Name())
+	}
+
+	assert.GreaterOrEqual(t, len(files), 20)
+
+	files, err = migrations.SQLite.ReadDir("sqlite")
+
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		assert.Regexp(t, migrationFilePattern, file.Name())
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db, err := sql.Open(sqlite.DriverName, "file:"+filepath.Join(t.TempDir(), "todo.db"))
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	t.Run("It should apply the SQLite migrations", func(t *testing.T) {
+		assert.NoError(t, MigrateUp(context.Background(), db, constant.DBTypeSQLite))
+
+		var slug string
+
+		assert.NoError(t, db.QueryRow("SELECT slug FROM workspaces WHERE id = $1", constant.DefaultWorkspaceID).Scan(&slug))
+		assert.Equal(t, "default", slug)
+	})
+
+	t.Run("It should roll them back", func(t *testing.T) {
+		err := Migrate(context.Background(), db, constant.DBTypeSQLite, func(m *migrate.Migrate) error {
+			if err := m.Down(); err != nil {
+				return err
+			}
+
+			_, _, err := m.Version()
+
+			return err
+		})
+
+		assert.True(t, errors.Is(err, migrate.ErrNilVersion))
+
+		var tables int
+
+		assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables))
+		assert.Zero(t, tables)
+	})
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/service"
+	"todo-app/app/types"
+)
+
+// NewTodoCache builds the todo cache config describes, or returns nil when
+// TODO_CACHE_SIZE turns it off. With TODO_CACHE_CHANNEL set, instances sharing
+// db tell each other of their writes on that Postgres NOTIFY channel, and the
+// cache listens there until ctx is done.
+func NewTodoCache(ctx context.Context, config *types.Config, db *sql.DB) *service.TodoCache {
+	if config.TodoCacheSize == 0 {
+		return nil
+	}
+
+	opts := service.TodoCacheOptions{Size: config.TodoCacheSize, TTL: config.TodoCacheTTL}
+
+	if config.TodoCacheChannel == "" {
+		return service.NewTodoCache(opts)
+	}
+
+	dsn, err := dataSourceName(config)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.DbInitErrorEventKey,
+			"error": err.Error(),
+		}).Fatal("Failed to initialize database")
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event":   constant.TodoCacheLogEventErrorKey,
+				"channel": config.TodoCacheChannel,
+				"error":   err.Error(),
+			}).Warn("Todo cache listener lost its connection")
+		}
+	})
+
+	if err := listener.Listen(config.TodoCacheChannel); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":   constant.TodoCacheLogEventErrorKey,
+			"channel": config.TodoCacheChannel,
+			"error":   err.Error(),
+		}).Fatal("Failed to listen for todo cache invalidations")
+	}
+
+	notifier := service.NewTodoCacheNotifier(db, config.TodoCacheChannel)
+	opts.Broadcast = notifier.Broadcast
+	cache := service.NewTodoCache(opts)
+
+	go func() {
+		defer listener.Close()
+
+		notifier.Listen(ctx, cache, listener.Notify)
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"event":   constant.TodoCacheLogEventKey,
+		"channel": config.TodoCacheChannel,
+	}).Info("Todo cache listening for writes of other instances")
+
+	return cache
+}
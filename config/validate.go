@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"todo-app/app/constant"
+	"todo-app/app/filestore"
+	"todo-app/app/logging"
+	"todo-app/app/ratelimit"
+	"todo-app/app/sqlite"
+	"todo-app/app/telemetry"
+	"todo-app/app/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Validate checks config as a whole and returns every problem it finds, each
+// naming the setting at fault, rather than stopping at the first.
+func Validate(config *types.Config) error {
+	var problems []error
+
+	check := func(ok bool, key string, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	oneOf := func(value string, key string, allowed ...string) {
+		for _, candidate := range allowed {
+			if value == candidate {
+				return
+			}
+		}
+
+		check(false, key, "%q is not one of %q", value, allowed)
+	}
+
+	oneOf(config.DBType, "DB_TYPE", constant.DBTypePostgres, constant.DBTypeSQLite, constant.DBTypeFile)
+
+	if config.DBType == constant.DBTypeFile {
+		check(config.FileStorePath != "", "FILE_STORE_PATH", "must name the store directory")
+		oneOf(config.FileStoreSync, "FILE_STORE_SYNC", string(filestore.SyncAlways), string(filestore.SyncInterval), string(filestore.SyncNever))
+		check(config.FileStoreSync != string(filestore.SyncInterval) || config.FileStoreSyncInterval > 0, "FILE_STORE_SYNC_INTERVAL", "must be positive to sync on an interval")
+		check(config.FileStoreCompactAfter >= 0, "FILE_STORE_COMPACT_AFTER", "must not be negative")
+		check(config.FileStoreSnapshotInterval >= 0, "FILE_STORE_SNAPSHOT_INTERVAL", "must not be negative")
+		// The file store keeps no users or API keys to authenticate.
+		check(!config.AuthRequired, "AUTH_REQUIRED", "must be false with DB_TYPE=file")
+		check(config.OIDCIssuer == "", "OIDC_ISSUER", "must not be set with DB_TYPE=file")
+	} else if config.DBType == constant.DBTypeSQLite {
+		// go-sqlite3 is only built with cgo.
+		check(sqlite.Available, "DB_TYPE", "sqlite needs a build with cgo")
+		check(config.DBURL != "" || config.DBName != "", "DB_NAME", "must name the database file")
+	} else if config.DBURL != "" {
+		_, err := dataSourceName(config)
+		check(err == nil, "DB_URL", "%v", err)
+	} else {
+		check(config.DBHost != "", "DB_HOST", "must be set")
+		check(config.DBPort > 0 && config.DBPort <= 65535, "DB_PORT", "%d is not a port", config.DBPort)
+		check(config.DBUser != "", "DB_USER", "must be set")
+		check(config.DBName != "", "DB_NAME", "must be set")
+		oneOf(config.DBSSLMode, "DB_SSL_MODE", constant.DBSSLModeDisable, constant.DBSSLModeRequire, constant.DBSSLModeVerifyCA, constant.DBSSLModeVerifyFull)
+		check((config.DBSSLCert == "") == (config.DBSSLKey == ""), "DB_SSL_CERT", "must be set together with DB_SSL_KEY")
+	}
+
+	check(config.DBStatementTimeout >= 0, "DB_STATEMENT_TIMEOUT", "must not be negative")
+	check(config.DBMaxOpenConns >= 0, "DB_MAX_OPEN_CONNS", "must not be negative")
+	check(config.DBMaxIdleConns >= 0, "DB_MAX_IDLE_CONNS", "must not be negative")
+	check(config.DBConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME", "must not be negative")
+	check(config.DBConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME", "must not be negative")
+	check(config.DBConnectAttempts > 0, "DB_CONNECT_ATTEMPTS", "must be positive")
+	check(config.DBConnectBackoff > 0, "DB_CONNECT_BACKOFF", "must be positive")
+
+	check(config.TodoCacheSize >= 0, "TODO_CACHE_SIZE", "must not be negative")
+	check(config.TodoCacheSize == 0 || config.TodoCacheTTL > 0, "TODO_CACHE_TTL", "must be positive to cache todos")
+	check(config.TodoCacheSize == 0 || config.DBType != constant.DBTypeFile, "TODO_CACHE_SIZE", "must be 0 with DB_TYPE=file, which keeps todos in memory already")
+	check(config.TodoCacheChannel == "" || config.TodoCacheSize > 0, "TODO_CACHE_CHANNEL", "needs TODO_CACHE_SIZE to turn the cache on")
+	check(config.TodoCacheChannel == "" || config.DBType == constant.DBTypePostgres, "TODO_CACHE_CHANNEL", "needs DB_TYPE=postgres")
+
+	port, err := strconv.Atoi(config.Port)
+	check(err == nil && port > 0 && port <= 65535, "PORT", "%q is not a port", config.Port)
+
+	grpcPort, err := strconv.Atoi(config.GRPCPort)
+	check(err == nil && grpcPort > 0 && grpcPort <= 65535, "GRPC_PORT", "%q is not a port", config.GRPCPort)
+
+	if config.OIDCIssuer != "" {
+		issuer, err := url.Parse(config.OIDCIssuer)
+		check(err == nil && issuer.Scheme != "" && issuer.Host != "", "OIDC_ISSUER", "%q is not a URL", config.OIDCIssuer)
+		check(config.OIDCClientID != "", "OIDC_CLIENT_ID", "must be set to enable OIDC login")
+		check(config.OIDCRedirectURL != "", "OIDC_REDIRECT_URL", "must be set to enable OIDC login")
+		check(config.SessionSecret != "", "SESSION_SECRET", "must be set to enable OIDC login")
+	}
+
+	check(config.SessionTTL > 0, "SESSION_TTL", "must be positive")
+
+	_, err = ratelimit.ParseRules(config.RateLimits)
+	check(err == nil, "RATE_LIMITS", "%v", err)
+	oneOf(config.RateLimitStore, "RATE_LIMIT_STORE", constant.RateLimitStoreMemory, constant.RateLimitStorePostgres)
+	check(config.RateLimitStore != constant.RateLimitStorePostgres || config.DBType == constant.DBTypePostgres, "RATE_LIMIT_STORE", "postgres needs DB_TYPE=postgres")
+
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			_, _, err := net.ParseCIDR(proxy)
+			check(err == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES", "%q is not an IP address or CIDR range", proxy)
+		}
+	}
+
+	oneOf(config.TraceExporter, "TRACE_EXPORTER", telemetry.ExporterNone, telemetry.ExporterStdout, telemetry.ExporterOTLP)
+	check(config.TraceSampleRatio >= 0 && config.TraceSampleRatio <= 1, "TRACE_SAMPLE_RATIO", "%g is not between 0 and 1", config.TraceSampleRatio)
+
+	_, err = logrus.ParseLevel(config.LogLevel)
+	check(err == nil, "LOG_LEVEL", "%v", err)
+	oneOf(config.LogFormat, "LOG_FORMAT", logging.FormatJSON, logging.FormatText, logging.FormatLogfmt)
+	check(config.LogSampleInitial >= 0, "LOG_SAMPLE_INITIAL", "must not be negative")
+	check(config.LogSampleThereafter >= 0, "LOG_SAMPLE_THEREAFTER", "must not be negative")
+
+	check(config.WebhookPollInterval > 0, "WEBHOOK_POLL_INTERVAL", "must be positive")
+	check(config.WebhookTimeout > 0, "WEBHOOK_TIMEOUT", "must be positive")
+	check(config.WebhookMaxAttempts > 0, "WEBHOOK_MAX_ATTEMPTS", "must be positive")
+	check(config.WebhookBaseBackoff > 0, "WEBHOOK_BASE_BACKOFF", "must be positive")
+	check(config.WebhookMaxBackoff >= config.WebhookBaseBackoff, "WEBHOOK_MAX_BACKOFF", "must not be below WEBHOOK_BASE_BACKOFF")
+
+	return errors.Join(problems...)
+}
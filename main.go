@@ -1,30 +1,195 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"todo-app/app/constant"
+	"todo-app/app/oidc"
+	"todo-app/app/ratelimit"
 	"todo-app/app/router"
+	"todo-app/app/rpc"
 	"todo-app/app/service"
+	_ "todo-app/app/sqlite"
+	"todo-app/app/telemetry"
+	"todo-app/app/types"
 	"todo-app/config"
 
 	"github.com/sirupsen/logrus"
 
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 )
 
+const usage = `Usage:
+  todo-app [serve] [flags]        run the API (the default)
+  todo-app migrate up [N]         apply all or the next N migrations
+  todo-app migrate down [N]       roll back the last N migrations, 1 by default
+  todo-app migrate goto V         migrate up or down to version V
+  todo-app migrate version        print the current version
+  todo-app migrate force V        set the version without migrating, after a failed migration
+  todo-app migrate create NAME    add an empty migration to MIGRATIONS_PATH, or its sqlite
+                                  directory with DB_TYPE=sqlite
+
+The migrate commands do not apply with DB_TYPE=file, which keeps no schema.
+
+Run todo-app --help for the flags, which every command accepts.
+`
+
 func main() {
-	config.InitLogger()
+	command, args := splitCommand(os.Args[1:])
+
+	env, printConfig, err := config.LoadConfig(".", args)
+	if errors.Is(err, config.ErrHelp) {
+		fmt.Fprint(os.Stderr, "\n"+usage)
+
+		return
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.ConfigLoadLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Invalid configuration")
+	}
+
+	if printConfig {
+		config.PrintConfig(os.Stdout, env)
+
+		return
+	}
+
+	config.InitLogger(env)
+
+	switch {
+	case len(command) == 0 || len(command) == 1 && command[0] == "serve":
+		serve(env)
+	case command[0] == "migrate":
+		if err := runMigrate(env, command[1:]); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.MigrateLogEventErrorKey,
+				"error": err.Error(),
+			}).Fatal("Failed to migrate")
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// splitCommand splits args into the command words that lead them and the
+// flags that follow.
+func splitCommand(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+
+	return args, nil
+}
 
-	env := config.LoadConfig(".")
+func serve(env *types.Config) {
+	shutdownTracing, err := telemetry.Setup(context.Background(), env)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.TracingLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to set up tracing")
+	}
+
+	defer shutdownTracing(context.Background())
+
+	var (
+		todoService         *service.TodoService
+		webhookService      *service.WebhookService
+		calendarFeedService *service.CalendarFeedService
+		apiKeyService       *service.APIKeyService
+		workspaceService    *service.WorkspaceService
+		sessionService      *service.SessionService
+
+		rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	)
+
+	if env.DBType == constant.DBTypeFile {
+		// Only todos and lists are kept without a database; the router
+		// leaves out the routes of the other services.
+		store := config.OpenFileStore(env)
+
+		defer store.Close()
+
+		todoService = service.NewFileTodoService(store)
+	} else {
+		db := config.ConnectToDB(env)
+
+		defer db.Close()
+
+		if env.AutoMigrate {
+			if err := config.MigrateUp(context.Background(), db, env.DBType); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"event": constant.MigrateLogEventErrorKey,
+					"error": err.Error(),
+				}).Fatal("Failed to run migrations")
+			}
+		}
 
-	db := config.ConnectToDB(env)
+		todoService = service.NewTodoService(db)
+
+		if cache := config.NewTodoCache(context.Background(), env, db); cache != nil {
+			todoService = todoService.WithCache(cache)
+		}
+
+		webhookService = service.NewWebhookService(db)
+		calendarFeedService = service.NewCalendarFeedService(db)
+		apiKeyService = service.NewAPIKeyService(db)
+		workspaceService = service.NewWorkspaceService(db)
+
+		if env.OIDCIssuer != "" {
+			provider := oidc.NewProvider(oidc.Config{
+				Issuer:       env.OIDCIssuer,
+				ClientID:     env.OIDCClientID,
+				ClientSecret: env.OIDCClientSecret,
+				RedirectURL:  env.OIDCRedirectURL,
+				Scopes:       strings.Fields(env.OIDCScopes),
+			}, nil)
+
+			sessionService = service.NewSessionService(db, provider, env.SessionSecret, env.SessionTTL)
+		}
+
+		go service.NewWebhookDispatcher(db, env).Run(context.Background())
+
+		if env.RateLimitStore == constant.RateLimitStorePostgres {
+			rateLimitStore = ratelimit.NewPostgresStore(db)
+		}
+	}
+
+	grpcListener, err := net.Listen("tcp", ":"+env.GRPCPort)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event": constant.GRPCServerLogEventErrorKey,
+			"error": err.Error(),
+		}).Fatal("Failed to listen on gRPC port")
+	}
 
-	defer db.Close()
+	// gRPC authenticates API keys like the HTTP API does and serves each call
+	// in the workspace of its key.
+	grpcServer := rpc.NewServer(todoService, apiKeyService, env.AuthRequired)
 
-	todoService := service.NewTodoService(db)
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": constant.GRPCServerLogEventErrorKey,
+				"error": err.Error(),
+			}).Fatal("Failed to run gRPC server")
+		}
+	}()
 
-	router := router.Init(todoService)
+	router := router.Init(env, todoService, webhookService, calendarFeedService, apiKeyService, workspaceService, sessionService, rateLimitStore)
 
-	if err := router.Run("localhost:8080"); err != nil {
+	if err := router.Run(":" + env.Port); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"event": "server_run_fail",
 			"error": err.Error(),
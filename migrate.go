@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/sirupsen/logrus"
+
+	"todo-app/app/constant"
+	"todo-app/app/types"
+	"todo-app/config"
+)
+
+// runMigrate runs the migrate subcommand in args; see usage.
+func runMigrate(env *types.Config, args []string) error {
+	if env.DBType == constant.DBTypeFile {
+		return errors.New("the file store has no schema to migrate")
+	}
+
+	if len(args) == 0 {
+		return errors.New("missing migrate subcommand, see --help")
+	}
+
+	subcommand, args := args[0], args[1:]
+
+	if subcommand == "create" {
+		if len(args) != 1 {
+			return errors.New("usage: migrate create NAME")
+		}
+
+		dir := env.MigrationsPath
+
+		if env.DBType == constant.DBTypeSQLite {
+			dir = filepath.Join(dir, "sqlite")
+		}
+
+		paths, err := config.CreateMigration(dir, args[0])
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+
+		return nil
+	}
+
+	// number parses the optional numeric argument of the subcommand.
+	number := func(fallback int) (int, error) {
+		switch len(args) {
+		case 0:
+			if fallback < 0 {
+				return 0, fmt.Errorf("usage: migrate %s V", subcommand)
+			}
+
+			return fallback, nil
+		case 1:
+			return strconv.Atoi(args[0])
+		}
+
+		return 0, fmt.Errorf("too many arguments to migrate %s", subcommand)
+	}
+
+	var run func(m *migrate.Migrate) error
+
+	switch subcommand {
+	case "up":
+		n, err := number(0)
+		if err != nil {
+			return err
+		}
+
+		run = func(m *migrate.Migrate) error {
+			if n == 0 {
+				return m.Up()
+			}
+
+			return m.Steps(n)
+		}
+	case "down":
+		n, err := number(1)
+		if err != nil {
+			return err
+		}
+
+		run = func(m *migrate.Migrate) error { return m.Steps(-n) }
+	case "goto":
+		version, err := number(-1)
+		if err != nil {
+			return err
+		}
+
+		run = func(m *migrate.Migrate) error { return m.Migrate(uint(version)) }
+	case "force":
+		version, err := number(-1)
+		if err != nil {
+			return err
+		}
+
+		run = func(m *migrate.Migrate) error { return m.Force(version) }
+	case "version":
+		if _, err := number(0); err != nil {
+			return err
+		}
+
+		run = func(m *migrate.Migrate) error { return nil }
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q, see --help", subcommand)
+	}
+
+	db := config.ConnectToDB(env)
+
+	defer db.Close()
+
+	return config.Migrate(context.Background(), db, env.DBType, func(m *migrate.Migrate) error {
+		if err := run(m); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+
+		version, dirty, err := m.Version()
+
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("no migrations applied")
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if subcommand != "version" {
+			logrus.WithFields(logrus.Fields{
+				"event":   constant.MigrateLogEventKey,
+				"command": subcommand,
+				"version": version,
+				"dirty":   dirty,
+			}).Info("Migrated")
+		}
+
+		if dirty {
+			fmt.Printf("version %d (dirty)\n", version)
+		} else {
+			fmt.Printf("version %d\n", version)
+		}
+
+		return nil
+	})
+}